@@ -35,6 +35,16 @@ describe('HttpClient', () => {
     )
   })
 
+  test('pins API version in path and header when apiVersion is set', async () => {
+    const client = new HttpClient({ url: 'http://localhost:3000', apiKey: 'test-key', apiVersion: '1' })
+
+    await client.request('/system/stats')
+
+    const [url, options] = (globalThis.fetch as ReturnType<typeof mock>).mock.calls[0] as [string, RequestInit]
+    expect(url).toBe('http://localhost:3000/api/v1/system/stats')
+    expect((options.headers as Record<string, string>)['X-API-Version']).toBe('1')
+  })
+
   test('sends auth via Bearer token when token is set', async () => {
     const client = new HttpClient({ url: 'http://localhost:3000', token: 'jwt-token' })
 
@@ -3,12 +3,15 @@ import {
   AuthResource,
   CronResource,
   DownloadsResource,
+  EventsResource,
   FilesResource,
   JobsResource,
   PackagesResource,
   SearchResource,
   SystemResource,
   TerminalResource,
+  TrashResource,
+  WebhooksResource,
 } from './resources'
 import type { GloskiClientConfig, HealthResponse } from './types'
 
@@ -66,6 +69,15 @@ export class GloskiClient {
   /** Download manager */
   readonly downloads: DownloadsResource
 
+  /** Real-time server events */
+  readonly events: EventsResource
+
+  /** Outbound webhooks (optional, may not be available) */
+  readonly webhooks: WebhooksResource
+
+  /** Trash of deleted files (optional, may not be available) */
+  readonly trash: TrashResource
+
   /**
    * Create a new Gloski client
    * @param config - Client configuration
@@ -94,6 +106,9 @@ export class GloskiClient {
     this.packages = new PackagesResource(this.http)
     this.cron = new CronResource(this.http)
     this.downloads = new DownloadsResource(this.http)
+    this.events = new EventsResource(this.http)
+    this.webhooks = new WebhooksResource(this.http)
+    this.trash = new TrashResource(this.http)
   }
 
   /**
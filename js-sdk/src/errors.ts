@@ -4,14 +4,20 @@
 export class GloskiError extends Error {
   /** HTTP status code (0 for network errors) */
   readonly status: number
-  /** Error code from server (if provided) */
+  /** Machine-readable error code from server (e.g. "path_not_allowed") */
   readonly code?: string
+  /** Structured error details from server (if provided) */
+  readonly details?: unknown
+  /** Request ID from server, useful for correlating with server logs */
+  readonly requestId?: string
 
-  constructor(status: number, message: string, code?: string) {
+  constructor(status: number, message: string, code?: string, details?: unknown, requestId?: string) {
     super(message)
     this.name = 'GloskiError'
     this.status = status
     this.code = code
+    this.details = details
+    this.requestId = requestId
 
     // Maintains proper stack trace in V8 environments
     const ErrorWithCapture = Error as typeof Error & {
@@ -51,6 +57,20 @@ export class GloskiError extends Error {
   }
 }
 
+/**
+ * Builds a GloskiError from a server error body ({ code, message, details, request_id })
+ */
+export function errorFromBody(status: number, data: Record<string, unknown>, fallback: string): GloskiError {
+  const message = (data.message as string) || (data.error as string) || fallback
+  return new GloskiError(
+    status,
+    message,
+    data.code as string | undefined,
+    data.details,
+    data.request_id as string | undefined
+  )
+}
+
 /**
  * Wraps a promise into a Result, catching errors
  */
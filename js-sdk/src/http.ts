@@ -1,8 +1,10 @@
-import { GloskiError, getErrorMessage } from './errors'
-import type { GloskiClientConfig, HealthResponse } from './types'
+import { GloskiError, errorFromBody, getErrorMessage } from './errors'
+import type { GloskiClientConfig, HealthResponse, ListOptions } from './types'
 
 const DEFAULT_TIMEOUT = 30000
 const DEFAULT_API_PREFIX = '/api'
+const API_VERSION_HEADER = 'X-API-Version'
+const IDEMPOTENCY_KEY_HEADER = 'Idempotency-Key'
 
 export interface RequestOptions {
   method?: 'GET' | 'POST' | 'PUT' | 'DELETE'
@@ -10,6 +12,8 @@ export interface RequestOptions {
   headers?: Record<string, string>
   timeout?: number
   signal?: AbortSignal
+  /** Makes a POST safe to retry: the server replays the first response for repeats */
+  idempotencyKey?: string
 }
 
 export interface ClientCallbacks {
@@ -18,6 +22,23 @@ export interface ClientCallbacks {
   onOnline?: () => void
 }
 
+/**
+ * Builds a query string from list options plus endpoint-specific parameters.
+ * Returns "" when there is nothing to encode, otherwise a string starting with "?".
+ */
+export function listQuery(
+  options: ListOptions = {},
+  extra: Record<string, string | number | undefined> = {}
+): string {
+  const params = new URLSearchParams()
+  for (const [key, value] of Object.entries({ ...extra, ...options })) {
+    if (value === undefined || value === '') continue
+    params.set(key, Array.isArray(value) ? value.join(',') : String(value))
+  }
+  const query = params.toString()
+  return query ? `?${query}` : ''
+}
+
 /**
  * HTTP client for making API requests
  */
@@ -32,6 +53,9 @@ export class HttpClient {
     this.config = config
     this.callbacks = callbacks
     this.apiPrefix = config.apiPrefix ?? DEFAULT_API_PREFIX
+    if (config.apiVersion) {
+      this.apiPrefix = `${this.apiPrefix.replace(/\/+$/, '')}/v${config.apiVersion}`
+    }
   }
 
   /**
@@ -52,6 +76,13 @@ export class HttpClient {
     return `${this.apiPrefix}${path}`
   }
 
+  /**
+   * Headers pinning the API version, if one is configured
+   */
+  private versionHeaders(): Record<string, string> {
+    return this.config.apiVersion ? { [API_VERSION_HEADER]: this.config.apiVersion } : {}
+  }
+
   /**
    * Get the full URL for an endpoint
    */
@@ -69,8 +100,8 @@ export class HttpClient {
   /**
    * POST request
    */
-  async post<T>(endpoint: string, body: unknown): Promise<T> {
-    return this.request<T>(endpoint, { method: 'POST', body })
+  async post<T>(endpoint: string, body: unknown, idempotencyKey?: string): Promise<T> {
+    return this.request<T>(endpoint, { method: 'POST', body, idempotencyKey })
   }
 
   /**
@@ -95,9 +126,14 @@ export class HttpClient {
     const url = `${this.config.url}${fullEndpoint}`
     const headers: Record<string, string> = {
       'Content-Type': 'application/json',
+      ...this.versionHeaders(),
       ...options.headers,
     }
 
+    if (options.idempotencyKey) {
+      headers[IDEMPOTENCY_KEY_HEADER] = options.idempotencyKey
+    }
+
     // Add authentication header
     if (this.config.apiKey) {
       headers['X-API-Key'] = this.config.apiKey
@@ -119,6 +155,7 @@ export class HttpClient {
     const url = `${this.config.url}${fullEndpoint}`
     const headers: Record<string, string> = {
       'Content-Type': 'application/json',
+      ...this.versionHeaders(),
       ...options.headers,
     }
 
@@ -138,7 +175,7 @@ export class HttpClient {
   async upload<T>(endpoint: string, formData: FormData): Promise<T> {
     const fullEndpoint = this.buildEndpoint(endpoint)
     const url = `${this.config.url}${fullEndpoint}`
-    const headers: Record<string, string> = {}
+    const headers: Record<string, string> = { ...this.versionHeaders() }
 
     // Add authentication header
     if (this.config.apiKey) {
@@ -168,7 +205,7 @@ export class HttpClient {
 
       if (!response.ok) {
         const data = await response.json().catch(() => ({ error: 'Unknown error' }))
-        throw new GloskiError(response.status, data.error || `HTTP ${response.status}`)
+        throw errorFromBody(response.status, data, `HTTP ${response.status}`)
       }
 
       const json = await response.json()
@@ -187,7 +224,7 @@ export class HttpClient {
   /**
    * Build an authenticated URL for downloads, WebSockets, etc.
    */
-  buildAuthUrl(endpoint: string, params: Record<string, string> = {}): string {
+  buildAuthUrl(endpoint: string, params: Record<string, string | string[]> = {}): string {
     const fullEndpoint = this.buildEndpoint(endpoint)
     const url = new URL(this.config.url)
 
@@ -202,9 +239,13 @@ export class HttpClient {
       url.searchParams.set('token', this.config.token)
     }
 
-    // Add additional params
+    // Add additional params; arrays become repeated params
     for (const [key, value] of Object.entries(params)) {
-      url.searchParams.set(key, value)
+      if (Array.isArray(value)) {
+        for (const v of value) url.searchParams.append(key, v)
+      } else {
+        url.searchParams.set(key, value)
+      }
     }
 
     return url.toString()
@@ -213,7 +254,7 @@ export class HttpClient {
   /**
    * Build a WebSocket URL with authentication
    */
-  buildWebSocketUrl(endpoint: string, params: Record<string, string> = {}): string {
+  buildWebSocketUrl(endpoint: string, params: Record<string, string | string[]> = {}): string {
     const httpUrl = this.buildAuthUrl(endpoint, params)
     return httpUrl.replace(/^http/, 'ws')
   }
@@ -286,9 +327,10 @@ export class HttpClient {
 
         if (!response.ok) {
           let errorMessage = `HTTP ${response.status}`
+          let data: Record<string, unknown> = {}
           try {
-            const data = await response.json()
-            errorMessage = data.error || data.message || errorMessage
+            data = await response.json()
+            errorMessage = (data.message as string) || (data.error as string) || errorMessage
           } catch {
             if (response.statusText) {
               errorMessage = `${response.status} ${response.statusText}`
@@ -306,7 +348,7 @@ export class HttpClient {
             }
           }
 
-          throw new GloskiError(response.status, errorMessage)
+          throw errorFromBody(response.status, { ...data, message: errorMessage }, errorMessage)
         }
 
         const json = await response.json()
@@ -4,6 +4,9 @@ export { GloskiClient } from './client'
 // Error classes and utilities
 export { GloskiError, safe } from './errors'
 
+// Query helpers
+export { listQuery } from './http'
+
 // Event emitter (for extending)
 export { EventEmitter } from './events'
 
@@ -11,9 +14,15 @@ export { EventEmitter } from './events'
 export {
   AuthResource,
   CronResource,
+  DirectoryWatch,
   DownloadsResource,
+  EventsResource,
+  EventStream,
+  FileFollow,
   FilesResource,
+  HistorySubResource,
   JobsResource,
+  OperationsSubResource,
   PackagesResource,
   PinnedSubResource,
   SearchResource,
@@ -21,6 +30,9 @@ export {
   SystemResource,
   TerminalConnection,
   TerminalResource,
+  TrashResource,
+  UsageSubResource,
+  WebhooksResource,
   type ProgressCallback,
 } from './resources'
 
@@ -64,6 +76,8 @@ export type {
   JobsResponse,
   // Job types
   JobStatus,
+  ListMeta,
+  ListOptions,
   ListResponse,
   LoadAvg,
   MemoryStats,
@@ -76,13 +90,30 @@ export type {
   PinnedFolder,
   PinnedFoldersResponse,
   ProcessInfo,
+  ProcessesResponse,
+  FileEncoding,
+  ReadRangeOptions,
+  ReadRangeResponse,
   ReadResponse,
+  SearchHighlight,
+  SearchMode,
   SearchOptions,
   SearchResponse,
   SearchResult,
+  SearchStreamEvent,
+  SearchSummary,
+  // Search index types
+  SearchIndexRootStatus,
+  SearchIndexState,
+  SearchIndexStatus,
   ServerHealthReport,
   ShareLink,
   StatsConnectionEvents,
+  // Event stream types
+  EventStreamEvents,
+  EventStreamOptions,
+  EventTopic,
+  ServerEvent,
   // Stats WebSocket types
   StatsConnectionOptions,
   StatsConnectionState,
@@ -98,4 +129,88 @@ export type {
   TerminalState,
   UpgradeInfo,
   UploadResponse,
+  VersionConflictDetails,
+  WriteOptions,
+  WriteResponse,
+  // File operation types
+  ArchiveFormat,
+  ConflictPolicy,
+  FileOperation,
+  FileOperationRequest,
+  FileOperationsResponse,
+  FileOperationStatus,
+  FileOperationType,
+  // Directory watch types
+  DirectoryWatchEvents,
+  DirectoryWatchMessage,
+  WatchEvent,
+  WatchEventOp,
+  // File follow types
+  FileFollowEvents,
+  FollowEventType,
+  FollowMessage,
+  FollowOptions,
+  // File attribute types
+  ACL,
+  ChmodOptions,
+  ChownOptions,
+  Xattr,
+  XattrEncoding,
+  XattrsResponse,
+  // File checksum types
+  ChecksumAlgorithm,
+  ChecksumEntry,
+  ChecksumOptions,
+  ChecksumResponse,
+  ChecksumResult,
+  VerifyChecksumsEntry,
+  VerifyChecksumsRequest,
+  VerifyChecksumsResult,
+  // Disk usage types
+  UsageEntry,
+  UsageScanOptions,
+  UsageSort,
+  UsageTopOptions,
+  UsageTree,
+  UsageTreeOptions,
+  // Media types
+  ContainerInfo,
+  ExifInfo,
+  GPSInfo,
+  ImageInfo,
+  MediaInfo,
+  MediaKind,
+  MediaTrack,
+  ThumbnailOptions,
+  // File diff types
+  FileDiffOptions,
+  FileDiffResult,
+  PatchFileResult,
+  PatchHunkResult,
+  PatchOptions,
+  PatchResult,
+  TreeDiffEntry,
+  TreeDiffSide,
+  TreeDiffSummary,
+  // Batch types
+  BatchItemResult,
+  BatchOptions,
+  BatchResponse,
+  FilesBatchItem,
+  FilesBatchOp,
+  FilesBatchOptions,
+  // Trash types
+  TrashItem,
+  TrashListResponse,
+  // File history types
+  FileVersion,
+  FileVersionContent,
+  FileVersionsResponse,
+  // Webhook types
+  CreateWebhookRequest,
+  UpdateWebhookRequest,
+  Webhook,
+  WebhookDeliveriesResponse,
+  WebhookDelivery,
+  WebhookDeliveryStatus,
 } from './types'
@@ -1,6 +1,6 @@
 import { safe } from '../errors'
-import type { HttpClient } from '../http'
-import type { CronJobInput, CronJobsResponse, CronScope, Result } from '../types'
+import { listQuery, type HttpClient } from '../http'
+import type { CronJobInput, CronJobsResponse, CronScope, ListOptions, Result } from '../types'
 
 /**
  * Cron job management resource
@@ -15,10 +15,10 @@ export class CronResource {
   /**
    * List cron jobs
    * @param scope - Which jobs to list: "user", "system", or "all" (default: all)
+   * @param options - Pagination, sorting and filters (name glob on command, status = source)
    */
-  async list(scope?: CronScope): Promise<Result<CronJobsResponse>> {
-    const params = scope ? `?scope=${scope}` : ''
-    return safe(this.http.request<CronJobsResponse>(`/cron/jobs${params}`))
+  async list(scope?: CronScope, options?: ListOptions): Promise<Result<CronJobsResponse>> {
+    return safe(this.http.request<CronJobsResponse>(`/cron/jobs${listQuery(options, { scope })}`))
   }
 
   /**
@@ -1,6 +1,16 @@
 import { safe } from '../errors'
-import type { HttpClient } from '../http'
-import type { AddDownloadRequest, CreateShareOptions, Download, DownloadsResponse, Result, ShareLink } from '../types'
+import { listQuery, type HttpClient } from '../http'
+import type {
+  AddDownloadRequest,
+  BatchOptions,
+  BatchResponse,
+  CreateShareOptions,
+  Download,
+  DownloadsResponse,
+  ListOptions,
+  Result,
+  ShareLink,
+} from '../types'
 
 export class DownloadsResource {
   private http: HttpClient
@@ -10,10 +20,18 @@ export class DownloadsResource {
   }
 
   /**
-   * List all downloads
+   * List downloads (newest first by default)
+   * @param options - Pagination, sorting and filters (status, name, min_size...)
    */
-  async list(): Promise<Result<Download[]>> {
-    return safe(this.http.get<DownloadsResponse>('/downloads').then(r => r.downloads))
+  async list(options?: ListOptions): Promise<Result<Download[]>> {
+    return safe(this.http.get<DownloadsResponse>(`/downloads${listQuery(options)}`).then(r => r.downloads))
+  }
+
+  /**
+   * List one page of downloads with pagination metadata
+   */
+  async listPage(options?: ListOptions): Promise<Result<DownloadsResponse>> {
+    return safe(this.http.get<DownloadsResponse>(`/downloads${listQuery(options)}`))
   }
 
   /**
@@ -28,13 +46,14 @@ export class DownloadsResource {
    * @param url - URL to download from
    * @param destination - Destination directory on the server
    * @param filename - Optional filename (auto-detected if not provided)
+   * @param idempotencyKey - Key that makes retrying this call safe (optional)
    */
-  async add(url: string, destination: string, filename?: string): Promise<Result<Download>> {
+  async add(url: string, destination: string, filename?: string, idempotencyKey?: string): Promise<Result<Download>> {
     const request: AddDownloadRequest = { url, destination }
     if (filename) {
       request.filename = filename
     }
-    return safe(this.http.post<Download>('/downloads', request))
+    return safe(this.http.post<Download>('/downloads', request, idempotencyKey))
   }
 
   /**
@@ -75,11 +94,29 @@ export class DownloadsResource {
     return safe(this.http.delete<{ status: string }>(`/downloads/${id}${params}`).then(() => {}))
   }
 
+  /**
+   * Cancel, retry or delete many downloads in one request
+   * @param op - cancel, retry or delete
+   * @param ids - Download IDs
+   * @param options - Concurrency, and deleteFile to also remove the files on delete
+   */
+  async batch(
+    op: 'cancel' | 'retry' | 'delete',
+    ids: string[],
+    options?: BatchOptions & { deleteFile?: boolean }
+  ): Promise<Result<BatchResponse>> {
+    const params = options?.deleteFile ? '?delete_file=true' : ''
+    return safe(
+      this.http.post<BatchResponse>(`/downloads/batch${params}`, { op, ids, concurrency: options?.concurrency })
+    )
+  }
+
   /**
    * Get the download URL for a completed download (authenticated)
+   * @param options - inline: let the browser display the file instead of saving it
    */
-  getDownloadUrl(id: string): string {
-    return this.http.getFullUrl(`/downloads/${id}/file`)
+  getDownloadUrl(id: string, options?: { inline?: boolean }): string {
+    return this.http.buildAuthUrl(`/downloads/${id}/file`, options?.inline ? { disposition: 'inline' } : {})
   }
 
   /**
@@ -0,0 +1,221 @@
+import { EventEmitter } from '../events'
+import type { HttpClient } from '../http'
+import type { EventStreamEvents, EventStreamOptions, ServerEvent, StatsConnectionState } from '../types'
+
+const DEFAULT_MAX_RECONNECT_ATTEMPTS = 10
+const DEFAULT_RECONNECT_DELAY = 1000
+const DEFAULT_MAX_RECONNECT_DELAY = 30000
+
+/**
+ * Event stream WebSocket connection with auto-reconnect.
+ * Reconnects resume from the last received event, so no events are missed
+ * while the server still has them in its history.
+ */
+export class EventStream extends EventEmitter<EventStreamEvents> {
+  private ws: WebSocket | null = null
+  private _state: StatsConnectionState = 'connecting'
+  private _lastEventId?: number
+  private topics: string[]
+  private reconnectAttempts = 0
+  private reconnectTimer?: ReturnType<typeof setTimeout>
+  private manualClose = false
+  private wasReconnect = false
+
+  private readonly options: {
+    autoReconnect: boolean
+    maxReconnectAttempts: number
+    reconnectDelay: number
+    maxReconnectDelay: number
+  }
+
+  constructor(
+    private readonly buildUrl: (params: Record<string, string>) => string,
+    options: EventStreamOptions = {}
+  ) {
+    super()
+    this.topics = options.topics ?? []
+    this._lastEventId = options.lastEventId
+
+    this.options = {
+      autoReconnect: options.autoReconnect ?? true,
+      maxReconnectAttempts: options.maxReconnectAttempts ?? DEFAULT_MAX_RECONNECT_ATTEMPTS,
+      reconnectDelay: options.reconnectDelay ?? DEFAULT_RECONNECT_DELAY,
+      maxReconnectDelay: options.maxReconnectDelay ?? DEFAULT_MAX_RECONNECT_DELAY,
+    }
+
+    this.setupWebSocket()
+  }
+
+  /**
+   * Current connection state
+   */
+  get state(): StatsConnectionState {
+    return this._state
+  }
+
+  /**
+   * ID of the last event received
+   */
+  get lastEventId(): number | undefined {
+    return this._lastEventId
+  }
+
+  /**
+   * Add topic patterns to the subscription
+   */
+  subscribe(...topics: string[]): void {
+    this.topics = [...new Set([...this.topics, ...topics])]
+    this.send({ action: 'subscribe', topics })
+  }
+
+  /**
+   * Remove topic patterns from the subscription
+   */
+  unsubscribe(...topics: string[]): void {
+    this.topics = this.topics.filter((t) => !topics.includes(t))
+    this.send({ action: 'unsubscribe', topics })
+  }
+
+  /**
+   * Close the connection (disables auto-reconnect)
+   */
+  close(): void {
+    this.manualClose = true
+    this.clearReconnectTimer()
+
+    if (this.ws) {
+      this.ws.close()
+      this.ws = null
+    }
+
+    this._state = 'closed'
+  }
+
+  private send(message: unknown): void {
+    if (this.ws && this._state === 'open') {
+      this.ws.send(JSON.stringify(message))
+    }
+  }
+
+  private setupWebSocket(): void {
+    this._state = this.wasReconnect ? 'reconnecting' : 'connecting'
+
+    const params: Record<string, string> = {}
+    if (this.topics.length > 0) {
+      params.topics = this.topics.join(',')
+    }
+    if (this._lastEventId !== undefined) {
+      params.last_event_id = String(this._lastEventId)
+    }
+
+    try {
+      this.ws = new WebSocket(this.buildUrl(params))
+
+      this.ws.onopen = () => {
+        this._state = 'open'
+        this.reconnectAttempts = 0
+
+        if (this.wasReconnect) {
+          this.emit('reconnected')
+          this.wasReconnect = false
+        } else {
+          this.emit('open')
+        }
+      }
+
+      this.ws.onclose = (event) => {
+        this.emit('close', event)
+
+        if (this.options.autoReconnect && !this.manualClose) {
+          this.scheduleReconnect()
+        } else {
+          this._state = 'closed'
+        }
+      }
+
+      this.ws.onerror = (error) => {
+        this.emit('error', error)
+      }
+
+      this.ws.onmessage = (message) => {
+        try {
+          const event = JSON.parse(message.data) as ServerEvent
+          if (event.topic === 'reset') {
+            this.emit('reset')
+            return
+          }
+          this._lastEventId = event.id
+          this.emit('event', event)
+        } catch (error) {
+          this.emit('error', error as Event)
+        }
+      }
+    } catch (error) {
+      this._state = 'closed'
+      this.emit('error', error as Event)
+    }
+  }
+
+  private scheduleReconnect(): void {
+    if (this.reconnectAttempts >= this.options.maxReconnectAttempts) {
+      this._state = 'closed'
+      return
+    }
+
+    this._state = 'reconnecting'
+    this.reconnectAttempts++
+
+    const delay = Math.min(
+      this.options.reconnectDelay * Math.pow(2, this.reconnectAttempts - 1),
+      this.options.maxReconnectDelay
+    )
+
+    this.emit('reconnecting', this.reconnectAttempts)
+
+    this.reconnectTimer = setTimeout(() => {
+      this.wasReconnect = true
+      this.setupWebSocket()
+    }, delay)
+  }
+
+  private clearReconnectTimer(): void {
+    if (this.reconnectTimer) {
+      clearTimeout(this.reconnectTimer)
+      this.reconnectTimer = undefined
+    }
+  }
+}
+
+/**
+ * Real-time server events (jobs, downloads, file changes, cron, terminals)
+ */
+export class EventsResource {
+  constructor(private readonly http: HttpClient) {}
+
+  /**
+   * Open a WebSocket event stream
+   *
+   * @example
+   * ```typescript
+   * const stream = client.events.connect({ topics: ['jobs', 'downloads.state'] })
+   * stream.on('event', (e) => console.log(e.topic, e.data))
+   * ```
+   */
+  connect(options?: EventStreamOptions): EventStream {
+    return new EventStream((params) => this.http.buildWebSocketUrl('/events', params), options)
+  }
+
+  /**
+   * URL for a Server-Sent Events stream, for use with EventSource
+   */
+  getStreamUrl(options: Pick<EventStreamOptions, 'topics' | 'lastEventId'> = {}): string {
+    const params: Record<string, string> = {}
+    if (options.topics?.length) {
+      params.topics = options.topics.join(',')
+    }
+    if (options.lastEventId !== undefined) {
+      params.last_event_id = String(options.lastEventId)
+    }
+    return this.http.buildAuthUrl('/events', params)
+  }
+}
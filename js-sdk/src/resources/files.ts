@@ -1,17 +1,60 @@
 import { GloskiError, safe } from '../errors'
-import type { HttpClient } from '../http'
+import { listQuery, type HttpClient } from '../http'
+import { FileFollow } from './follow'
+import { DirectoryWatch } from './watch'
 import type {
+  ACL,
+  ArchiveFormat,
+  BatchResponse,
+  ChecksumOptions,
+  ChecksumResponse,
+  ChecksumResult,
+  ChmodOptions,
+  ChownOptions,
   ChunkedUploadChunkResponse,
   ChunkedUploadCompleteRequest,
   ChunkedUploadCompleteResponse,
   ChunkedUploadInfo,
   ChunkedUploadInit,
+  ConflictPolicy,
+  FileDiffOptions,
+  FileDiffResult,
+  FileOperation,
+  FileOperationRequest,
+  FileOperationsResponse,
+  FileVersion,
+  FileVersionContent,
+  FileVersionsResponse,
+  FilesBatchItem,
+  FilesBatchOp,
+  FilesBatchOptions,
+  FollowOptions,
+  ListOptions,
   ListResponse,
+  MediaInfo,
+  PatchOptions,
+  PatchResult,
   PinnedFolder,
   PinnedFoldersResponse,
+  ReadRangeOptions,
+  ReadRangeResponse,
   ReadResponse,
   Result,
+  StatsConnectionOptions,
+  ThumbnailOptions,
   UploadResponse,
+  UsageEntry,
+  UsageScanOptions,
+  UsageTopOptions,
+  UsageTree,
+  UsageTreeOptions,
+  VerifyChecksumsRequest,
+  VerifyChecksumsResult,
+  WriteOptions,
+  WriteResponse,
+  Xattr,
+  XattrEncoding,
+  XattrsResponse,
 } from '../types'
 
 /**
@@ -60,6 +103,201 @@ export class PinnedSubResource {
   }
 }
 
+/**
+ * Background copy/move/extract operations sub-resource (accessed via files.operations)
+ */
+export class OperationsSubResource {
+  private http: HttpClient
+
+  constructor(http: HttpClient) {
+    this.http = http
+  }
+
+  /**
+   * List operations (newest first by default)
+   * @param options - Pagination, sorting and filters (status, type)
+   */
+  async list(options?: ListOptions): Promise<Result<FileOperationsResponse>> {
+    return safe(this.http.get<FileOperationsResponse>(`/files/operations${listQuery(options)}`))
+  }
+
+  /**
+   * Get an operation with its current progress
+   */
+  async get(id: string): Promise<Result<FileOperation>> {
+    return safe(this.http.get<FileOperation>(`/files/operations/${id}`))
+  }
+
+  /**
+   * Start a copy or move in the background. Follow progress with get() or
+   * files.operation events.
+   */
+  async start(request: FileOperationRequest): Promise<Result<FileOperation>> {
+    return safe(this.http.post<FileOperation>('/files/operations', request))
+  }
+
+  /**
+   * Copy files and directories into a destination directory
+   */
+  async copy(sources: string[], destination: string, conflict?: ConflictPolicy): Promise<Result<FileOperation>> {
+    return this.start({ type: 'copy', sources, destination, conflict })
+  }
+
+  /**
+   * Move files and directories into a destination directory (works across filesystems)
+   */
+  async move(sources: string[], destination: string, conflict?: ConflictPolicy): Promise<Result<FileOperation>> {
+    return this.start({ type: 'move', sources, destination, conflict })
+  }
+
+  /**
+   * Extract a zip, tar, tar.gz or tar.zst archive into a destination directory.
+   * Entries escaping the destination and archives over the server's size or
+   * file count limits fail the operation.
+   */
+  async extract(archive: string, destination: string, conflict?: ConflictPolicy): Promise<Result<FileOperation>> {
+    return this.start({ type: 'extract', sources: [archive], destination, conflict })
+  }
+
+  /**
+   * Cancel a running operation
+   */
+  async cancel(id: string): Promise<Result<void>> {
+    return safe(this.http.post<{ status: string }>(`/files/operations/${id}/cancel`, {}).then(() => {}))
+  }
+
+  /**
+   * Remove a finished operation from the list
+   */
+  async delete(id: string): Promise<Result<void>> {
+    return safe(this.http.delete<{ status: string }>(`/files/operations/${id}`).then(() => {}))
+  }
+}
+
+/**
+ * File version history sub-resource (accessed via files.history). Files are
+ * snapshotted before writes, uploads and restores overwrite them; the
+ * server's 'history' feature must be enabled.
+ */
+export class HistorySubResource {
+  private http: HttpClient
+
+  constructor(http: HttpClient) {
+    this.http = http
+  }
+
+  /**
+   * List earlier versions of a file (newest first), including a deleted file's
+   */
+  async list(path: string): Promise<Result<FileVersion[]>> {
+    return safe(
+      this.http
+        .get<FileVersionsResponse>(`/files/history?path=${encodeURIComponent(path)}`)
+        .then((r) => r.versions)
+    )
+  }
+
+  /**
+   * Get a version with its content (text versions only; see getDownloadUrl)
+   */
+  async get(id: string): Promise<Result<FileVersionContent>> {
+    return safe(this.http.get<FileVersionContent>(`/files/history/${id}`))
+  }
+
+  /**
+   * Unified diff from a version to the file's current content ('' if unchanged)
+   * @param context - Unchanged lines shown around each change (default: 3)
+   */
+  async diff(id: string, context?: number): Promise<Result<string>> {
+    const query = context !== undefined ? `?context=${context}` : ''
+    return safe(this.http.get<{ diff: string }>(`/files/history/${id}/diff${query}`).then((r) => r.diff))
+  }
+
+  /**
+   * Write a version's content back to its file. The content it replaces is
+   * kept as a new version.
+   */
+  async restore(id: string): Promise<Result<WriteResponse>> {
+    return safe(this.http.post<WriteResponse>(`/files/history/${id}/restore`, {}))
+  }
+
+  /**
+   * Delete a version
+   */
+  async delete(id: string): Promise<Result<void>> {
+    return safe(this.http.delete<{ status: string }>(`/files/history/${id}`).then(() => {}))
+  }
+
+  /**
+   * Get download URL for a version's content (authenticated)
+   * @param options.inline - Ask the browser to display the file instead of saving it
+   */
+  getDownloadUrl(id: string, options?: { inline?: boolean }): string {
+    return this.http.buildAuthUrl(`/files/history/${id}/download`, options?.inline ? { disposition: 'inline' } : {})
+  }
+}
+
+/**
+ * Disk usage sub-resource (accessed via files.usage). Scans run as background
+ * file operations; their size trees are cached on the server for queries.
+ */
+export class UsageSubResource {
+  private http: HttpClient
+
+  constructor(http: HttpClient) {
+    this.http = http
+  }
+
+  /**
+   * Start scanning a directory tree; follow it with files.operations.get.
+   * Scanning a directory inside a cached tree refreshes just that directory.
+   */
+  async scan(path: string, options?: UsageScanOptions): Promise<Result<FileOperation>> {
+    return safe(
+      this.http.post<FileOperation>('/files/usage/scan', { path, one_file_system: options?.oneFileSystem })
+    )
+  }
+
+  /**
+   * Get the cached usage of a scanned directory, or one inside it, with its
+   * largest subdirectories and files
+   */
+  async tree(path: string, options?: UsageTreeOptions): Promise<Result<UsageTree>> {
+    const params = new URLSearchParams({ path })
+    if (options?.depth !== undefined) params.set('depth', String(options.depth))
+    if (options?.limit !== undefined) params.set('limit', String(options.limit))
+    if (options?.sort) params.set('sort', options.sort)
+    return safe(this.http.get<UsageTree>(`/files/usage?${params}`))
+  }
+
+  /**
+   * Largest directories or files anywhere below a scanned directory
+   */
+  async top(path: string, options?: UsageTopOptions): Promise<Result<UsageEntry[]>> {
+    const params = new URLSearchParams({ path })
+    if (options?.type) params.set('type', options.type)
+    if (options?.limit !== undefined) params.set('limit', String(options.limit))
+    if (options?.sort) params.set('sort', options.sort)
+    return safe(this.http.get<{ entries: UsageEntry[] }>(`/files/usage/top?${params}`).then((r) => r.entries))
+  }
+
+  /**
+   * List cached scans, without children
+   */
+  async cached(): Promise<Result<UsageTree[]>> {
+    return safe(this.http.get<{ trees: UsageTree[] }>('/files/usage/cached').then((r) => r.trees))
+  }
+
+  /**
+   * Drop the cached scan rooted at path
+   */
+  async forget(path: string): Promise<Result<void>> {
+    return safe(
+      this.http.delete<{ status: string }>(`/files/usage?path=${encodeURIComponent(path)}`).then(() => {})
+    )
+  }
+}
+
 /**
  * Progress callback for file operations
  */
@@ -74,17 +312,59 @@ export class FilesResource {
   /** Pinned folders sub-resource */
   readonly pinned: PinnedSubResource
 
+  /** Background copy/move operations sub-resource */
+  readonly operations: OperationsSubResource
+
+  /** Earlier versions of overwritten files sub-resource */
+  readonly history: HistorySubResource
+
+  /** Disk usage scans sub-resource */
+  readonly usage: UsageSubResource
+
   constructor(http: HttpClient) {
     this.http = http
     this.pinned = new PinnedSubResource(http)
+    this.operations = new OperationsSubResource(http)
+    this.history = new HistorySubResource(http)
+    this.usage = new UsageSubResource(http)
+  }
+
+  /**
+   * Watch directories for changes made by any process. Events are debounced
+   * and limited to allowed paths; the server caps how many directories one
+   * connection may watch.
+   *
+   * @example
+   * ```typescript
+   * const watch = client.files.watch(['~/projects'])
+   * watch.on('change', (events) => refresh(events))
+   * ```
+   */
+  watch(paths: string[], options?: StatsConnectionOptions): DirectoryWatch {
+    return new DirectoryWatch((params) => this.http.buildWebSocketUrl('/files/watch', params), paths, options)
+  }
+
+  /**
+   * Follow lines appended to a file, like tail -F. Truncation and log
+   * rotation are followed; a client that falls far behind skips ahead.
+   *
+   * @example
+   * ```typescript
+   * const follow = client.files.follow('/var/log/app.log', { lines: 50, filter: 'ERROR' })
+   * follow.on('lines', (lines) => append(lines))
+   * ```
+   */
+  follow(path: string, options?: FollowOptions): FileFollow {
+    return new FileFollow((params) => this.http.buildWebSocketUrl('/files/follow', params), path, options)
   }
 
   /**
    * List directory contents
    * @param path - Directory path (default: "/")
+   * @param options - Pagination, sorting and filters (type, name, min_size, modified_after...)
    */
-  async list(path = '/'): Promise<Result<ListResponse>> {
-    return safe(this.http.request<ListResponse>(`/files?path=${encodeURIComponent(path)}`))
+  async list(path = '/', options?: ListOptions): Promise<Result<ListResponse>> {
+    return safe(this.http.request<ListResponse>(`/files${listQuery(options, { path })}`))
   }
 
   /**
@@ -180,18 +460,35 @@ export class FilesResource {
   }
 
   /**
-   * Write content to a file
+   * Read part of a file of any size, text or binary: a byte range, a range of
+   * lines, or the last lines. Binary content comes as a hex dump unless text
+   * is asked for.
+   * @param path - File path
+   * @param options - Byte range by default; line or tail for lines
+   */
+  async readRange(path: string, options?: ReadRangeOptions): Promise<Result<ReadRangeResponse>> {
+    const params = new URLSearchParams({ path })
+    for (const [key, value] of Object.entries(options ?? {})) {
+      if (value !== undefined) params.set(key, String(value))
+    }
+    return safe(this.http.get<ReadRangeResponse>(`/files/read/range?${params}`))
+  }
+
+  /**
+   * Write content to a file. The file is replaced atomically, keeping its
+   * mode and ownership.
    * @param path - File path
    * @param content - File content
+   * @param options - ifMatch: version from read(); a stale version fails with
+   *   a version_conflict error whose details are VersionConflictDetails
    */
-  async write(path: string, content: string): Promise<Result<void>> {
+  async write(path: string, content: string, options?: WriteOptions): Promise<Result<WriteResponse>> {
     return safe(
-      this.http
-        .request('/files/write', {
-          method: 'POST',
-          body: { path, content },
-        })
-        .then(() => {})
+      this.http.request<WriteResponse>('/files/write', {
+        method: 'POST',
+        body: { path, content },
+        headers: options?.ifMatch ? { 'If-Match': `"${options.ifMatch}"` } : undefined,
+      })
     )
   }
 
@@ -211,13 +508,16 @@ export class FilesResource {
   }
 
   /**
-   * Delete a file or directory
+   * Delete a file or directory. It goes to the trash when the server has
+   * one, unless permanent is set.
    * @param path - Path to delete
+   * @param options - permanent: remove instead of moving to the trash
    */
-  async delete(path: string): Promise<Result<void>> {
+  async delete(path: string, options?: { permanent?: boolean }): Promise<Result<void>> {
+    const permanent = options?.permanent ? '&permanent=true' : ''
     return safe(
       this.http
-        .request(`/files?path=${encodeURIComponent(path)}`, {
+        .request(`/files?path=${encodeURIComponent(path)}${permanent}`, {
           method: 'DELETE',
         })
         .then(() => {})
@@ -240,6 +540,171 @@ export class FilesResource {
     )
   }
 
+  /**
+   * Apply one operation to many paths. Items succeed or fail independently;
+   * check each result rather than the overall call.
+   * @param op - delete, move, copy or chmod
+   * @param items - Paths, with destination (move/copy) or mode (chmod)
+   */
+  async batch(op: FilesBatchOp, items: FilesBatchItem[], options?: FilesBatchOptions): Promise<Result<BatchResponse>> {
+    return safe(this.http.post<BatchResponse>('/files/batch', { op, items, ...options }))
+  }
+
+  /**
+   * Change permission bits
+   * @param path - File or directory path
+   * @param mode - Octal mode such as "644"
+   * @param options - recursive: apply to a whole tree; dirMode: mode for directories when recursive
+   * @returns How many entries changed (recursive only)
+   */
+  async chmod(path: string, mode: string, options?: ChmodOptions): Promise<Result<{ changed?: number }>> {
+    return safe(
+      this.http
+        .post<{ changed?: number } | null>('/files/chmod', {
+          path,
+          mode,
+          dir_mode: options?.dirMode,
+          recursive: options?.recursive,
+        })
+        .then((data) => data ?? {})
+    )
+  }
+
+  /**
+   * Change owner and/or group. Names and numeric ids are both accepted;
+   * leave one out to keep it.
+   */
+  async chown(path: string, owner: ChownOptions): Promise<Result<{ changed?: number }>> {
+    return safe(
+      this.http
+        .post<{ changed?: number } | null>('/files/chown', { path, ...owner })
+        .then((data) => data ?? {})
+    )
+  }
+
+  /**
+   * Create a symbolic link at path pointing to target. Relative targets are
+   * kept relative to the link's directory.
+   */
+  async symlink(target: string, path: string): Promise<Result<void>> {
+    return safe(this.http.post('/files/symlink', { target, path }).then(() => {}))
+  }
+
+  /**
+   * Create a hard link at path to the existing file target
+   */
+  async link(target: string, path: string): Promise<Result<void>> {
+    return safe(this.http.post('/files/link', { target, path }).then(() => {}))
+  }
+
+  /**
+   * List extended attributes. Binary values come back base64 encoded.
+   */
+  async xattrs(path: string): Promise<Result<Xattr[]>> {
+    return safe(
+      this.http
+        .get<XattrsResponse>(`/files/xattrs?path=${encodeURIComponent(path)}`)
+        .then((data) => data.xattrs)
+    )
+  }
+
+  /**
+   * Set an extended attribute, such as "user.comment"
+   * @param encoding - How value is encoded (default utf8)
+   */
+  async setXattr(path: string, name: string, value: string, encoding?: XattrEncoding): Promise<Result<void>> {
+    return safe(this.http.post('/files/xattrs', { path, name, value, encoding }).then(() => {}))
+  }
+
+  /**
+   * Remove an extended attribute
+   */
+  async removeXattr(path: string, name: string): Promise<Result<void>> {
+    return safe(
+      this.http
+        .delete(`/files/xattrs?path=${encodeURIComponent(path)}&name=${encodeURIComponent(name)}`)
+        .then(() => {})
+    )
+  }
+
+  /**
+   * Get the POSIX ACL in getfacl form ("user::rw-", "group:staff:r-x", ...)
+   */
+  async acl(path: string): Promise<Result<ACL>> {
+    return safe(this.http.get<ACL>(`/files/acl?path=${encodeURIComponent(path)}`))
+  }
+
+  /**
+   * Replace the access and/or default ACL. An omitted list is left unchanged;
+   * an empty default list removes the default ACL.
+   */
+  async setAcl(path: string, acl: Partial<ACL>): Promise<Result<void>> {
+    return safe(this.http.post('/files/acl', { path, ...acl }).then(() => {}))
+  }
+
+  /**
+   * Compare two files (unified diff) or two directories (recursive list of
+   * added, removed and changed entries)
+   */
+  async diff(oldPath: string, newPath: string, options?: FileDiffOptions): Promise<Result<FileDiffResult>> {
+    const params = new URLSearchParams({ old: oldPath, new: newPath })
+    if (options?.context !== undefined) params.set('context', String(options.context))
+    if (options?.ignoreWhitespace) params.set('ignore_whitespace', 'true')
+    if (options?.ignoreSpaceChange) params.set('ignore_space_change', 'true')
+    if (options?.compare) params.set('compare', options.compare)
+    return safe(this.http.get<FileDiffResult>(`/files/diff?${params}`))
+  }
+
+  /**
+   * Apply a unified diff of one or more files, whose names are relative to
+   * base. Either every file is changed or none is: a patch that doesn't apply
+   * fails with patch_failed, whose details are the per-hunk PatchResult.
+   */
+  async patch(base: string, patch: string, options?: PatchOptions): Promise<Result<PatchResult>> {
+    return safe(
+      this.http.post<PatchResult>('/files/patch', {
+        base,
+        patch,
+        strip: options?.strip,
+        fuzz: options?.fuzz,
+        dry_run: options?.dryRun,
+      })
+    )
+  }
+
+  /**
+   * Hash a file, or build a manifest of a directory tree. Over 256MB, or
+   * with background, a cancellable operation is returned instead; follow it
+   * with files.operations.get.
+   */
+  async checksum(path: string, options?: ChecksumOptions): Promise<Result<ChecksumResponse<ChecksumResult>>> {
+    return safe(
+      this.http.post<ChecksumResponse<ChecksumResult>>('/files/checksum', {
+        path,
+        algorithm: options?.algorithm,
+        background: options?.background,
+      })
+    )
+  }
+
+  /**
+   * Check files against a SHA256SUMS-style list. Mismatches are reported per
+   * file; like checksum, large verifications run in the background.
+   */
+  async verifyChecksums(
+    request: VerifyChecksumsRequest
+  ): Promise<Result<ChecksumResponse<VerifyChecksumsResult>>> {
+    return safe(this.http.post<ChecksumResponse<VerifyChecksumsResult>>('/files/checksum/verify', request))
+  }
+
+  /**
+   * Read what a file's headers say about it: dimensions and EXIF data (camera,
+   * date, GPS) of images, and container, duration and tracks of audio and video
+   */
+  async media(path: string): Promise<Result<MediaInfo>> {
+    return safe(this.http.get<MediaInfo>(`/files/media?path=${encodeURIComponent(path)}`))
+  }
+
   /**
    * Upload a file
    * @param destPath - Destination directory path
@@ -260,12 +725,42 @@ export class FilesResource {
   }
 
   /**
-   * Get download URL for a file (authenticated)
+   * Get download URL for a file (authenticated). The URL supports range
+   * requests, so it can be used directly as a media source.
    * @param path - File path
+   * @param options - inline: let the browser display the file instead of saving it
    */
-  getDownloadUrl(path: string): string {
+  getDownloadUrl(path: string, options?: { inline?: boolean }): string {
     return this.http.buildAuthUrl('/files/download', {
       path,
+      ...(options?.inline ? { disposition: 'inline' } : {}),
+    })
+  }
+
+  /**
+   * Get URL of a thumbnail of a PNG, JPEG, GIF or WebP image (authenticated).
+   * The server caches thumbnails and answers conditional requests, so the URL
+   * can be used directly as an image source.
+   * @param path - Image path
+   * @param options - size: longer side in pixels (default: 256)
+   */
+  getThumbnailUrl(path: string, options?: ThumbnailOptions): string {
+    return this.http.buildAuthUrl('/files/thumbnail', {
+      path,
+      ...(options?.size ? { size: String(options.size) } : {}),
+    })
+  }
+
+  /**
+   * Get download URL for an archive of files and directories (authenticated).
+   * The archive is streamed as the server builds it.
+   * @param paths - Files or directories to include
+   * @param format - Archive format (default: zip)
+   */
+  getArchiveUrl(paths: string | string[], format: ArchiveFormat = 'zip'): string {
+    return this.http.buildAuthUrl('/files/archive', {
+      path: Array.isArray(paths) ? paths : [paths],
+      format,
     })
   }
 
@@ -0,0 +1,160 @@
+import { EventEmitter } from '../events'
+import type { FileFollowEvents, FollowMessage, FollowOptions, StatsConnectionState } from '../types'
+
+const DEFAULT_MAX_RECONNECT_ATTEMPTS = 10
+const DEFAULT_RECONNECT_DELAY = 1000
+const DEFAULT_MAX_RECONNECT_DELAY = 30000
+
+/**
+ * Lines appended to a file on the server, like tail -F. Truncation and log
+ * rotation are followed. Reconnects start at the end of the file, so lines
+ * written while disconnected are not sent.
+ */
+export class FileFollow extends EventEmitter<FileFollowEvents> {
+  private ws: WebSocket | null = null
+  private _state: StatsConnectionState = 'connecting'
+  private reconnectAttempts = 0
+  private reconnectTimer?: ReturnType<typeof setTimeout>
+  private manualClose = false
+  private wasReconnect = false
+
+  private readonly options: Required<Omit<FollowOptions, 'filter'>> & Pick<FollowOptions, 'filter'>
+
+  constructor(
+    private readonly buildUrl: (params: Record<string, string>) => string,
+    private readonly path: string,
+    options: FollowOptions = {}
+  ) {
+    super()
+
+    this.options = {
+      lines: options.lines ?? 10,
+      filter: options.filter,
+      autoReconnect: options.autoReconnect ?? true,
+      maxReconnectAttempts: options.maxReconnectAttempts ?? DEFAULT_MAX_RECONNECT_ATTEMPTS,
+      reconnectDelay: options.reconnectDelay ?? DEFAULT_RECONNECT_DELAY,
+      maxReconnectDelay: options.maxReconnectDelay ?? DEFAULT_MAX_RECONNECT_DELAY,
+    }
+
+    this.setupWebSocket()
+  }
+
+  /**
+   * Current connection state
+   */
+  get state(): StatsConnectionState {
+    return this._state
+  }
+
+  /**
+   * Close the connection (disables auto-reconnect)
+   */
+  close(): void {
+    this.manualClose = true
+    this.clearReconnectTimer()
+
+    if (this.ws) {
+      this.ws.close()
+      this.ws = null
+    }
+
+    this._state = 'closed'
+  }
+
+  private setupWebSocket(): void {
+    this._state = this.wasReconnect ? 'reconnecting' : 'connecting'
+
+    const params: Record<string, string> = {
+      path: this.path,
+      lines: String(this.wasReconnect ? 0 : this.options.lines),
+    }
+    if (this.options.filter) params.filter = this.options.filter
+
+    try {
+      this.ws = new WebSocket(this.buildUrl(params))
+
+      this.ws.onopen = () => {
+        this._state = 'open'
+        this.reconnectAttempts = 0
+
+        if (this.wasReconnect) {
+          this.emit('reconnected')
+          this.wasReconnect = false
+        } else {
+          this.emit('open')
+        }
+      }
+
+      this.ws.onclose = (event) => {
+        this.emit('close', event)
+
+        if (this.options.autoReconnect && !this.manualClose) {
+          this.scheduleReconnect()
+        } else {
+          this._state = 'closed'
+        }
+      }
+
+      this.ws.onerror = (error) => {
+        this.emit('error', error)
+      }
+
+      this.ws.onmessage = (message) => {
+        try {
+          const msg = JSON.parse(message.data) as FollowMessage
+          switch (msg.type) {
+            case 'lines':
+              this.emit('lines', msg.lines ?? [], msg.offset ?? 0)
+              break
+            case 'truncated':
+              this.emit('truncated')
+              break
+            case 'rotated':
+              this.emit('rotated')
+              break
+            case 'skipped':
+              this.emit('skipped', msg.skipped ?? 0)
+              break
+            case 'error':
+              this.emit('followError', msg.error ?? { code: 'unknown', message: 'unknown error' })
+              break
+          }
+        } catch (error) {
+          this.emit('error', error as Event)
+        }
+      }
+    } catch (error) {
+      this._state = 'closed'
+      this.emit('error', error as Event)
+    }
+  }
+
+  private scheduleReconnect(): void {
+    if (this.reconnectAttempts >= this.options.maxReconnectAttempts) {
+      this._state = 'closed'
+      return
+    }
+
+    this._state = 'reconnecting'
+    this.reconnectAttempts++
+
+    const delay = Math.min(
+      this.options.reconnectDelay * Math.pow(2, this.reconnectAttempts - 1),
+      this.options.maxReconnectDelay
+    )
+
+    this.emit('reconnecting', this.reconnectAttempts)
+
+    this.reconnectTimer = setTimeout(() => {
+      this.wasReconnect = true
+      this.setupWebSocket()
+    }, delay)
+  }
+
+  private clearReconnectTimer(): void {
+    if (this.reconnectTimer) {
+      clearTimeout(this.reconnectTimer)
+      this.reconnectTimer = undefined
+    }
+  }
+}
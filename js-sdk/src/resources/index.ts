@@ -1,10 +1,22 @@
 export { AuthResource } from './auth'
 export { CronResource } from './cron'
 export { DownloadsResource } from './downloads'
-export { FilesResource, PinnedSubResource, type ProgressCallback } from './files'
+export { EventStream, EventsResource } from './events'
+export {
+  FilesResource,
+  HistorySubResource,
+  OperationsSubResource,
+  PinnedSubResource,
+  type ProgressCallback,
+  UsageSubResource,
+} from './files'
+export { FileFollow } from './follow'
 export { JobsResource } from './jobs'
 export { PackagesResource } from './packages'
 export { SearchResource } from './search'
 export { StatsConnection } from './stats-ws'
 export { SystemResource } from './system'
 export { TerminalConnection, TerminalResource } from './terminal'
+export { TrashResource } from './trash'
+export { DirectoryWatch } from './watch'
+export { WebhooksResource } from './webhooks'
@@ -1,6 +1,6 @@
 import { safe } from '../errors'
-import type { HttpClient } from '../http'
-import type { Job, JobLogsResponse, JobsResponse, Result } from '../types'
+import { listQuery, type HttpClient } from '../http'
+import type { BatchOptions, BatchResponse, Job, JobLogsResponse, JobsResponse, ListOptions, Result } from '../types'
 
 /**
  * Job management resource
@@ -16,21 +16,32 @@ export class JobsResource {
   }
 
   /**
-   * List all jobs
+   * List jobs (newest first by default)
+   * @param options - Pagination, sorting and filters (status, name, created_after...)
    */
-  async list(): Promise<Result<Job[]>> {
-    return safe(this.http.request<JobsResponse>('/jobs').then(r => r.jobs))
+  async list(options?: ListOptions): Promise<Result<Job[]>> {
+    return safe(this.http.request<JobsResponse>(`/jobs${listQuery(options)}`).then(r => r.jobs))
+  }
+
+  /**
+   * List one page of jobs with pagination metadata
+   * @param options - Pagination, sorting and filters; pass `meta.next_cursor` as `cursor` for the next page
+   */
+  async listPage(options?: ListOptions): Promise<Result<JobsResponse>> {
+    return safe(this.http.request<JobsResponse>(`/jobs${listQuery(options)}`))
   }
 
   /**
    * Start a new job
    * @param command - Command to execute
    * @param cwd - Working directory (optional)
+   * @param idempotencyKey - Key that makes retrying this call safe (optional)
    */
-  async start(command: string, cwd?: string): Promise<Result<Job>> {
+  async start(command: string, cwd?: string, idempotencyKey?: string): Promise<Result<Job>> {
     return safe(this.http.request<Job>('/jobs', {
       method: 'POST',
       body: { command, cwd },
+      idempotencyKey,
     }))
   }
 
@@ -69,4 +80,13 @@ export class JobsResource {
       method: 'DELETE',
     }).then(() => {}))
   }
+
+  /**
+   * Stop or delete many jobs in one request
+   * @param op - stop or delete
+   * @param ids - Job IDs
+   */
+  async batch(op: 'stop' | 'delete', ids: string[], options?: BatchOptions): Promise<Result<BatchResponse>> {
+    return safe(this.http.post<BatchResponse>('/jobs/batch', { op, ids, ...options }))
+  }
 }
@@ -1,7 +1,8 @@
 import { safe } from '../errors'
-import type { HttpClient } from '../http'
+import { listQuery, type HttpClient } from '../http'
 import type {
   InstalledPackagesResponse,
+  ListOptions,
   Package,
   PackageManagerInfo,
   PackageSearchResponse,
@@ -29,9 +30,10 @@ export class PackagesResource {
   /**
    * List installed packages
    * @param limit - Maximum number of packages to return (default: 100)
+   * @param options - Pagination, sorting and filters (name glob)
    */
-  async listInstalled(limit = 100): Promise<Result<InstalledPackagesResponse>> {
-    return safe(this.http.request<InstalledPackagesResponse>(`/packages/installed?limit=${limit}`))
+  async listInstalled(limit = 100, options?: ListOptions): Promise<Result<InstalledPackagesResponse>> {
+    return safe(this.http.request<InstalledPackagesResponse>(`/packages/installed${listQuery({ limit, ...options })}`))
   }
 
   /**
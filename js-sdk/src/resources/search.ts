@@ -1,6 +1,6 @@
-import { safe } from '../errors'
+import { GloskiError, safe } from '../errors'
 import type { HttpClient } from '../http'
-import type { Result, SearchOptions, SearchResponse } from '../types'
+import type { Result, SearchIndexStatus, SearchOptions, SearchResponse, SearchStreamEvent } from '../types'
 
 /**
  * File search resource
@@ -17,17 +17,47 @@ export class SearchResource {
    * @param options - Search options
    */
   async search(options: SearchOptions): Promise<Result<SearchResponse>> {
-    const params = new URLSearchParams({
-      path: options.path,
-      q: options.query,
-      limit: String(options.limit ?? 100),
-    })
+    return safe(this.http.request<SearchResponse>(`/search?${this.params(options)}`))
+  }
+
+  /**
+   * Search for files, yielding results as the server finds them and then a
+   * done event with the summary. Searches always walk the tree.
+   * @param options - Search options
+   */
+  async *stream(options: SearchOptions): AsyncGenerator<SearchStreamEvent, void, unknown> {
+    const params = this.params(options)
+    params.set('stream', 'ndjson')
+    const url = this.http.buildAuthUrl('/search', Object.fromEntries(params))
+
+    const response = await fetch(url)
+    if (!response.ok) {
+      const data = await response.json().catch(() => ({ error: 'Unknown error' }))
+      throw new GloskiError(response.status, data.error || `HTTP ${response.status}`)
+    }
 
-    if (options.content) {
-      params.set('content', 'true')
+    const reader = response.body?.getReader()
+    if (!reader) {
+      throw new GloskiError(0, 'Streaming not supported')
     }
 
-    return safe(this.http.request<SearchResponse>(`/search?${params}`))
+    const decoder = new TextDecoder()
+    let buffered = ''
+    try {
+      while (true) {
+        const { done, value } = await reader.read()
+        if (done) break
+        buffered += decoder.decode(value, { stream: true })
+        const lines = buffered.split('\n')
+        buffered = lines.pop() ?? ''
+        for (const line of lines) {
+          if (line.trim()) yield JSON.parse(line) as SearchStreamEvent
+        }
+      }
+      if (buffered.trim()) yield JSON.parse(buffered) as SearchStreamEvent
+    } finally {
+      reader.releaseLock()
+    }
   }
 
   /**
@@ -49,4 +79,36 @@ export class SearchResource {
   async byContent(path: string, query: string, limit = 100): Promise<Result<SearchResponse>> {
     return this.search({ path, query, content: true, limit })
   }
+
+  /**
+   * Get the state of the search index
+   */
+  async indexStatus(): Promise<Result<SearchIndexStatus>> {
+    return safe(this.http.get<SearchIndexStatus>('/search/index'))
+  }
+
+  /**
+   * Queue a rescan of the search index
+   * @param root - Indexed root to rescan; all roots if omitted
+   */
+  async rescanIndex(root?: string): Promise<Result<{ status: string }>> {
+    return safe(this.http.post<{ status: string }>('/search/index/rescan', root ? { path: root } : {}))
+  }
+
+  private params(options: SearchOptions): URLSearchParams {
+    const { path, query, limit, index, ...rest } = options
+    const params = new URLSearchParams({
+      path,
+      q: query,
+      limit: String(limit ?? 100),
+    })
+    if (index === false) {
+      params.set('index', 'false')
+    }
+    for (const [key, value] of Object.entries(rest)) {
+      if (value === undefined || value === false || value === '') continue
+      params.set(key, Array.isArray(value) ? value.join(',') : String(value))
+    }
+    return params
+  }
 }
@@ -1,6 +1,8 @@
 import { safe } from '../errors'
-import type { HttpClient, RequestOptions } from '../http'
+import { listQuery, type HttpClient, type RequestOptions } from '../http'
 import type {
+  ListOptions,
+  ProcessesResponse,
   ProcessInfo,
   Result,
   ServerHealthReport,
@@ -52,11 +54,16 @@ export class SystemResource {
   }
 
   /**
-   * Get list of running processes
+   * Get list of running processes (largest RSS first by default)
    * @param limit - Maximum number of processes to return (default: 100)
+   * @param options - Pagination, sorting and filters (name, status)
    */
-  async getProcesses(limit = 100): Promise<Result<ProcessInfo[]>> {
-    return safe(this.http.request<{ processes: ProcessInfo[] }>(`/system/processes?limit=${limit}`).then(r => r.processes))
+  async getProcesses(limit = 100, options?: ListOptions): Promise<Result<ProcessInfo[]>> {
+    return safe(
+      this.http
+        .request<ProcessesResponse>(`/system/processes${listQuery({ limit, ...options })}`)
+        .then(r => r.processes)
+    )
   }
 
   /**
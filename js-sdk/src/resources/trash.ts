@@ -0,0 +1,51 @@
+import { safe } from '../errors'
+import { listQuery, type HttpClient } from '../http'
+import type { ListOptions, Result, TrashItem, TrashListResponse } from '../types'
+
+export class TrashResource {
+  private http: HttpClient
+
+  constructor(http: HttpClient) {
+    this.http = http
+  }
+
+  /**
+   * List trashed items (most recently deleted first by default)
+   * @param options - Pagination, sorting and filters (type, name, min_size, created_after...)
+   */
+  async list(options?: ListOptions): Promise<Result<TrashListResponse>> {
+    return safe(this.http.get<TrashListResponse>(`/trash${listQuery(options)}`))
+  }
+
+  /**
+   * Get a trashed item by ID
+   */
+  async get(id: string): Promise<Result<TrashItem>> {
+    return safe(this.http.get<TrashItem>(`/trash/${id}`))
+  }
+
+  /**
+   * Restore an item to where it was deleted from, or to another path
+   * @param destination - Optional path to restore to instead
+   */
+  async restore(id: string, destination?: string): Promise<Result<TrashItem>> {
+    return safe(this.http.post<TrashItem>(`/trash/${id}/restore`, destination ? { destination } : {}))
+  }
+
+  /**
+   * Permanently delete one item
+   */
+  async purge(id: string): Promise<Result<void>> {
+    return safe(this.http.delete<{ status: string }>(`/trash/${id}`).then(() => {}))
+  }
+
+  /**
+   * Permanently delete everything in the trash
+   * @param olderThanDays - Only purge items deleted longer ago than this
+   * @returns Number of items purged
+   */
+  async empty(olderThanDays?: number): Promise<Result<number>> {
+    const query = olderThanDays !== undefined ? `?older_than_days=${olderThanDays}` : ''
+    return safe(this.http.delete<{ purged: number }>(`/trash${query}`).then(r => r.purged))
+  }
+}
@@ -0,0 +1,170 @@
+import { EventEmitter } from '../events'
+import type { DirectoryWatchEvents, DirectoryWatchMessage, StatsConnectionOptions, StatsConnectionState } from '../types'
+
+const DEFAULT_MAX_RECONNECT_ATTEMPTS = 10
+const DEFAULT_RECONNECT_DELAY = 1000
+const DEFAULT_MAX_RECONNECT_DELAY = 30000
+
+/**
+ * Live changes in a set of directories, made through the API or by any other
+ * process on the server. Reconnects watch the same directories again; changes
+ * made while disconnected are not replayed, so reload listings on 'reconnected'.
+ */
+export class DirectoryWatch extends EventEmitter<DirectoryWatchEvents> {
+  private ws: WebSocket | null = null
+  private _state: StatsConnectionState = 'connecting'
+  private paths: string[]
+  private reconnectAttempts = 0
+  private reconnectTimer?: ReturnType<typeof setTimeout>
+  private manualClose = false
+  private wasReconnect = false
+
+  private readonly options: Required<StatsConnectionOptions>
+
+  constructor(
+    private readonly buildUrl: (params: Record<string, string[]>) => string,
+    paths: string[],
+    options: StatsConnectionOptions = {}
+  ) {
+    super()
+    this.paths = [...new Set(paths)]
+
+    this.options = {
+      autoReconnect: options.autoReconnect ?? true,
+      maxReconnectAttempts: options.maxReconnectAttempts ?? DEFAULT_MAX_RECONNECT_ATTEMPTS,
+      reconnectDelay: options.reconnectDelay ?? DEFAULT_RECONNECT_DELAY,
+      maxReconnectDelay: options.maxReconnectDelay ?? DEFAULT_MAX_RECONNECT_DELAY,
+    }
+
+    this.setupWebSocket()
+  }
+
+  /**
+   * Current connection state
+   */
+  get state(): StatsConnectionState {
+    return this._state
+  }
+
+  /**
+   * Start watching more directories
+   */
+  watch(...paths: string[]): void {
+    this.paths = [...new Set([...this.paths, ...paths])]
+    this.send({ action: 'watch', paths })
+  }
+
+  /**
+   * Stop watching directories
+   */
+  unwatch(...paths: string[]): void {
+    this.paths = this.paths.filter((p) => !paths.includes(p))
+    this.send({ action: 'unwatch', paths })
+  }
+
+  /**
+   * Close the connection (disables auto-reconnect)
+   */
+  close(): void {
+    this.manualClose = true
+    this.clearReconnectTimer()
+
+    if (this.ws) {
+      this.ws.close()
+      this.ws = null
+    }
+
+    this._state = 'closed'
+  }
+
+  private send(message: unknown): void {
+    if (this.ws && this._state === 'open') {
+      this.ws.send(JSON.stringify(message))
+    }
+  }
+
+  private setupWebSocket(): void {
+    this._state = this.wasReconnect ? 'reconnecting' : 'connecting'
+
+    try {
+      this.ws = new WebSocket(this.buildUrl({ path: this.paths }))
+
+      this.ws.onopen = () => {
+        this._state = 'open'
+        this.reconnectAttempts = 0
+
+        if (this.wasReconnect) {
+          this.emit('reconnected')
+          this.wasReconnect = false
+        } else {
+          this.emit('open')
+        }
+      }
+
+      this.ws.onclose = (event) => {
+        this.emit('close', event)
+
+        if (this.options.autoReconnect && !this.manualClose) {
+          this.scheduleReconnect()
+        } else {
+          this._state = 'closed'
+        }
+      }
+
+      this.ws.onerror = (error) => {
+        this.emit('error', error)
+      }
+
+      this.ws.onmessage = (message) => {
+        try {
+          const msg = JSON.parse(message.data) as DirectoryWatchMessage
+          switch (msg.type) {
+            case 'events':
+              this.emit('change', msg.events ?? [])
+              break
+            case 'watch':
+              this.emit('watching', msg.path ?? '')
+              break
+            case 'error':
+              this.emit('watchError', msg.path ?? '', msg.error ?? { code: 'unknown', message: 'unknown error' })
+              break
+          }
+        } catch (error) {
+          this.emit('error', error as Event)
+        }
+      }
+    } catch (error) {
+      this._state = 'closed'
+      this.emit('error', error as Event)
+    }
+  }
+
+  private scheduleReconnect(): void {
+    if (this.reconnectAttempts >= this.options.maxReconnectAttempts) {
+      this._state = 'closed'
+      return
+    }
+
+    this._state = 'reconnecting'
+    this.reconnectAttempts++
+
+    const delay = Math.min(
+      this.options.reconnectDelay * Math.pow(2, this.reconnectAttempts - 1),
+      this.options.maxReconnectDelay
+    )
+
+    this.emit('reconnecting', this.reconnectAttempts)
+
+    this.reconnectTimer = setTimeout(() => {
+      this.wasReconnect = true
+      this.setupWebSocket()
+    }, delay)
+  }
+
+  private clearReconnectTimer(): void {
+    if (this.reconnectTimer) {
+      clearTimeout(this.reconnectTimer)
+      this.reconnectTimer = undefined
+    }
+  }
+}
@@ -0,0 +1,77 @@
+import { safe } from '../errors'
+import { listQuery, type HttpClient } from '../http'
+import type {
+  CreateWebhookRequest,
+  ListOptions,
+  Result,
+  UpdateWebhookRequest,
+  Webhook,
+  WebhookDeliveriesResponse,
+  WebhookDelivery,
+} from '../types'
+
+export class WebhooksResource {
+  private http: HttpClient
+
+  constructor(http: HttpClient) {
+    this.http = http
+  }
+
+  /**
+   * List all webhooks
+   */
+  async list(): Promise<Result<Webhook[]>> {
+    return safe(this.http.get<{ webhooks: Webhook[] }>('/webhooks').then(r => r.webhooks))
+  }
+
+  /**
+   * Get a specific webhook by ID
+   */
+  async get(id: string): Promise<Result<Webhook>> {
+    return safe(this.http.get<Webhook>(`/webhooks/${id}`))
+  }
+
+  /**
+   * Create a webhook. The returned webhook includes the signing secret,
+   * which is not returned again.
+   */
+  async create(request: CreateWebhookRequest): Promise<Result<Webhook>> {
+    return safe(this.http.post<Webhook>('/webhooks', request))
+  }
+
+  /**
+   * Update a webhook (only the given fields change)
+   */
+  async update(id: string, request: UpdateWebhookRequest): Promise<Result<Webhook>> {
+    return safe(this.http.put<Webhook>(`/webhooks/${id}`, request))
+  }
+
+  /**
+   * Delete a webhook and its delivery log
+   */
+  async delete(id: string): Promise<Result<void>> {
+    return safe(this.http.delete<{ status: string }>(`/webhooks/${id}`).then(() => {}))
+  }
+
+  /**
+   * Send a webhooks.ping event to a webhook
+   */
+  async ping(id: string): Promise<Result<WebhookDelivery>> {
+    return safe(this.http.post<WebhookDelivery>(`/webhooks/${id}/ping`, {}))
+  }
+
+  /**
+   * List one page of a webhook's deliveries (newest first by default)
+   * @param options - Pagination, sorting and filters (status, name matches the topic)
+   */
+  async deliveries(id: string, options?: ListOptions): Promise<Result<WebhookDeliveriesResponse>> {
+    return safe(this.http.get<WebhookDeliveriesResponse>(`/webhooks/${id}/deliveries${listQuery(options)}`))
+  }
+
+  /**
+   * Queue a new delivery of a past delivery's payload
+   */
+  async redeliver(id: string, deliveryId: string): Promise<Result<WebhookDelivery>> {
+    return safe(this.http.post<WebhookDelivery>(`/webhooks/${id}/deliveries/${deliveryId}/redeliver`, {}))
+  }
+}
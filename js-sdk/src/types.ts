@@ -21,6 +21,12 @@ export interface GloskiClientConfig {
   timeout?: number
   /** API path prefix (default: "/api") */
   apiPrefix?: string
+  /**
+   * Pin the server API version (e.g. "1"). Requests go to `{apiPrefix}/v{apiVersion}`
+   * and always receive the `{ success, data }` envelope. Omit to use the legacy,
+   * unversioned routes.
+   */
+  apiVersion?: string
   /** Called when server returns 401 Unauthorized */
   onUnauthorized?: () => void
   /** Called when server is unreachable (network error) */
@@ -43,6 +49,90 @@ export interface HealthResponse {
   status: string
 }
 
+// =============================================================================
+// Batch Types
+// =============================================================================
+
+/** Outcome of one item of a batch request */
+export interface BatchItemResult {
+  index: number
+  id?: string
+  path?: string
+  ok: boolean
+  /** HTTP status the item would have had as a single request */
+  status: number
+  error?: {
+    code: string
+    message: string
+    details?: unknown
+  }
+}
+
+/** Per-item outcomes of a batch request, in request order */
+export interface BatchResponse {
+  op: string
+  succeeded: number
+  failed: number
+  results: BatchItemResult[]
+}
+
+export interface BatchOptions {
+  /** Items processed at the same time (default 4, max 16) */
+  concurrency?: number
+}
+
+export type FilesBatchOp = 'delete' | 'move' | 'copy' | 'chmod'
+
+export interface FilesBatchOptions extends BatchOptions {
+  /** delete: remove permanently instead of moving to the trash */
+  permanent?: boolean
+}
+
+export interface FilesBatchItem {
+  path: string
+  /** Target path for move and copy */
+  destination?: string
+  /** Octal permission for chmod, e.g. "644" */
+  mode?: string
+}
+
+// =============================================================================
+// Listing Types
+// =============================================================================
+
+/** Pagination metadata returned by list endpoints */
+export interface ListMeta {
+  total: number
+  count: number
+  /** Page size; 0 when everything was listed */
+  limit: number
+  sort: string
+  order: 'asc' | 'desc'
+  /** Pass as `cursor` to fetch the next page; absent on the last page */
+  next_cursor?: string
+}
+
+/** Pagination, sorting and filtering options accepted by list endpoints */
+export interface ListOptions {
+  limit?: number
+  cursor?: string
+  /** Sort field; prefix with "-" for descending (e.g. "-created_at") */
+  sort?: string
+  order?: 'asc' | 'desc'
+  /** Status filter (comma-separated or array) */
+  status?: string | string[]
+  /** Shell glob matched against the item name */
+  name?: string
+  /** Entry type filter (directory listings) */
+  type?: 'file' | 'directory'
+  created_after?: string
+  created_before?: string
+  modified_after?: string
+  modified_before?: string
+  min_size?: number
+  max_size?: number
+}
+
 // =============================================================================
 // Auth Types
 // =============================================================================
@@ -201,22 +291,101 @@ export interface FileEntry {
   size: number
   modified: string
   permissions: string
+  uid: number
+  gid: number
+  /** User name, when the uid has one */
+  owner?: string
+  /** Group name, when the gid has one */
+  group?: string
+  /** Symlinks only: where the link points, as stored */
+  link_target?: string
+  inode: number
+  /** Hard link count */
+  nlink: number
 }
 
 export interface ListResponse {
   path: string
   entries: FileEntry[]
+  meta?: ListMeta
 }
 
 export interface ReadResponse {
   content: string
   path: string
+  /** Pass to files.write as ifMatch to detect concurrent edits */
+  version: string
+}
+
+export type FileEncoding = 'ascii' | 'utf-8' | 'utf-16le' | 'utf-16be' | 'iso-8859-1' | 'binary'
+
+/** Byte ranges are the default; line or tail select lines instead */
+export interface ReadRangeOptions {
+  /** First byte; negative counts from the end */
+  offset?: number
+  /** Bytes (default: 64KB, max 1MB); with line or tail, a byte limit */
+  length?: number
+  /** First line, from 1 */
+  line?: number
+  /** Lines from line (default: 100) */
+  lines?: number
+  /** Last lines */
+  tail?: number
+  /** Default: auto, which is hex for binary files */
+  format?: 'auto' | 'text' | 'hex'
+}
+
+export interface ReadRangeResponse {
+  path: string
+  /** Of the whole file */
+  size: number
+  mod_time: string
+  /** Detected from the start of the file */
+  encoding: FileEncoding
+  bom?: boolean
+  /** Of the first byte read */
+  offset: number
+  /** Bytes read; the next page starts at offset + length */
+  length: number
+  eof: boolean
+  format: 'text' | 'hex'
+  /** Text, or a hexdump -C style dump */
+  content: string
+  /** Line reads from line: the first line's number */
+  start_line?: number
+  /** Line reads: lines returned */
+  line_count?: number
+  /** Line reads: fewer lines than asked for fit the byte limit */
+  truncated?: boolean
+}
+
+export interface WriteOptions {
+  /** Only save if the file still has this version; otherwise fails with 409 version_conflict */
+  ifMatch?: string
+}
+
+export interface WriteResponse {
+  version: string
+}
+
+/** Details of a version_conflict error from files.write */
+export interface VersionConflictDetails {
+  /** Empty if the file no longer exists */
+  current_version: string
+  /** Unified diff from the current content to the rejected content */
+  diff?: string
 }
 
 export interface UploadResponse {
   filename: string
 }
 
+/** A matched span, in characters */
+export interface SearchHighlight {
+  start: number
+  end: number
+}
+
 export interface SearchResult {
   path: string
   name: string
@@ -224,22 +393,113 @@ export interface SearchResult {
   size: number
   match?: string
   line_num?: number
+  /** Index searches: matching content, cut with … */
+  snippet?: string
+  /** Matched text in the snippet or matching line */
+  highlights?: SearchHighlight[]
+  /** Matched text in the name */
+  name_highlights?: SearchHighlight[]
+  /** Index searches: relevance, higher is better */
+  score?: number
 }
 
+export type SearchMode = 'substring' | 'glob' | 'regex'
+
 export interface SearchOptions {
   /** Starting path for search */
   path: string
-  /** Search query */
+  /**
+   * Search query. Inside the search index it may use "phrases", prefix*,
+   * AND, OR, NOT, parentheses and name: or content: filters.
+   */
   query: string
   /** Search within file contents */
   content?: boolean
   /** Maximum results to return */
   limit?: number
+  /** Index searches: results to skip */
+  offset?: number
+  /** Only files or only directories */
+  type?: 'file' | 'directory'
+  /** Set false to walk the tree even where the search index covers the path */
+  index?: boolean
+  /** How the query matches: substring (default), glob (gitignore-style, names only) or regex */
+  mode?: SearchMode
+  case_sensitive?: boolean
+  /** Only files with these extensions */
+  ext?: string[]
+  /** Only files whose path below path matches one of these globs */
+  include?: string[]
+  /** Skip files and directories matching any of these globs */
+  exclude?: string[]
+  /** Descend into hidden directories */
+  hidden?: boolean
+  /** Don't skip what .gitignore and .ignore files list, nor node_modules and the like */
+  no_ignore?: boolean
+  /** Content matches per file (default 3) */
+  max_matches?: number
+  /** Shell glob matched against names */
+  name?: string
+  min_size?: number
+  max_size?: number
+  modified_after?: string
+  modified_before?: string
 }
 
 export interface SearchResponse {
   results: SearchResult[]
   count: number
+  /** Whether the search index answered the search */
+  indexed: boolean
+  /** Tree searches: stopped at the limit */
+  truncated?: boolean
+  /** Tree searches: stopped at the timeout */
+  timed_out?: boolean
+}
+
+export interface SearchSummary {
+  count: number
+  /** Entries visited */
+  scanned: number
+  truncated?: boolean
+  timed_out?: boolean
+  duration_ms: number
+}
+
+/** An event of a streamed search */
+export type SearchStreamEvent =
+  | { type: 'result'; data: SearchResult }
+  | { type: 'done'; data: SearchSummary }
+  | { type: 'error'; data: { code: string; message: string } }
+
+// =============================================================================
+// Search Index Types
+// =============================================================================
+
+export type SearchIndexState = 'pending' | 'scanning' | 'ready' | 'failed'
+
+export interface SearchIndexRootStatus {
+  root: string
+  state: SearchIndexState
+  entries: number
+  /** Entries visited by the running or last scan */
+  scanned: number
+  scan_started_at?: string
+  /** When the last complete scan finished */
+  last_scan_at?: string
+  last_scan_ms?: number
+  error?: string
+}
+
+export interface SearchIndexStatus {
+  roots: SearchIndexRootStatus[]
+  /** Changes are followed with inotify between scans */
+  watching: boolean
+  watched_dirs: number
+  /** Some directories couldn't be watched; their changes wait for the next scan */
+  watch_limited?: boolean
+  /** Minutes between scans, 0 if only scanned at startup */
+  scan_interval: number
 }
 
 // =============================================================================
@@ -295,6 +555,473 @@ export interface PinnedFoldersResponse {
   home_dir: string
 }
 
+// =============================================================================
+// File Operation Types
+// =============================================================================
+
+export type FileOperationType = 'copy' | 'move' | 'extract' | 'checksum' | 'verify' | 'usage'
+
+export type FileOperationStatus = 'running' | 'completed' | 'failed' | 'cancelled'
+
+/** What to do when a destination already exists */
+export type ConflictPolicy = 'fail' | 'skip' | 'overwrite' | 'rename'
+
+export interface FileOperation {
+  id: string
+  type: FileOperationType
+  sources: string[]
+  /** Directory the sources are placed in (copy, move and extract) */
+  destination?: string
+  conflict?: ConflictPolicy
+  /** Checksum and verify only */
+  algorithm?: ChecksumAlgorithm
+  status: FileOperationStatus
+  error?: string
+  /** Set once a checksum, verify or usage operation completes */
+  result?: ChecksumResult | VerifyChecksumsResult | UsageEntry
+  /** Totals stay 0 while scanning, and for tar-based extracts */
+  total_files: number
+  total_bytes: number
+  files_done: number
+  files_skipped: number
+  bytes_done: number
+  /** Last file processed */
+  current?: string
+  created_at: string
+  finished_at?: string
+}
+
+export interface FileOperationRequest {
+  type: 'copy' | 'move' | 'extract'
+  /** For extract, exactly one archive */
+  sources: string[]
+  destination: string
+  /** Default: fail */
+  conflict?: ConflictPolicy
+}
+
+export interface FileOperationsResponse {
+  operations: FileOperation[]
+  meta?: ListMeta
+}
+
+/** Archive formats for downloads. Extraction detects the format itself. */
+export type ArchiveFormat = 'zip' | 'tar' | 'tar.gz' | 'tar.zst'
+
+// =============================================================================
+// Directory Watch Types
+// =============================================================================
+
+export type WatchEventOp = 'create' | 'modify' | 'delete' | 'rename' | 'overflow'
+
+export interface WatchEvent {
+  op: WatchEventOp
+  /** The watched directory the change happened in */
+  dir: string
+  /** Empty for overflow, which means events were lost and listings should be reloaded */
+  path?: string
+  /** Renames only */
+  old_path?: string
+  is_dir?: boolean
+}
+
+export interface DirectoryWatchMessage {
+  type: 'watch' | 'unwatch' | 'error' | 'events'
+  path?: string
+  error?: { code: string; message: string }
+  events?: WatchEvent[]
+}
+
+export interface DirectoryWatchEvents {
+  open: []
+  /** A debounced batch of changes */
+  change: [events: WatchEvent[]]
+  /** A directory is being watched, with its normalized path */
+  watching: [path: string]
+  /** A directory could not be watched or unwatched */
+  watchError: [path: string, error: { code: string; message: string }]
+  close: [event: CloseEvent]
+  error: [error: Event | Error]
+  reconnecting: [attempt: number]
+  reconnected: []
+}
+
+// =============================================================================
+// File Follow Types
+// =============================================================================
+
+export interface FollowOptions extends StatsConnectionOptions {
+  /** Last lines to send first (default 10, 0 starts at the end). Reconnects start at the end. */
+  lines?: number
+  /** Regular expression lines must match */
+  filter?: string
+}
+
+export type FollowEventType = 'lines' | 'truncated' | 'rotated' | 'skipped'
+
+export interface FollowMessage {
+  type: FollowEventType | 'error'
+  lines?: string[]
+  /** Bytes of the current file read */
+  offset?: number
+  /** Bytes skipped because the client fell behind */
+  skipped?: number
+  error?: { code: string; message: string }
+}
+
+export interface FileFollowEvents {
+  open: []
+  /** Lines appended, or the last lines of the file at the start */
+  lines: [lines: string[], offset: number]
+  /** The file shrank; following continues from its start */
+  truncated: []
+  /** The path names a new file, e.g. after log rotation; following continues from its start */
+  rotated: []
+  /** The client fell behind and this many bytes were skipped */
+  skipped: [bytes: number]
+  /** Following stopped on the server */
+  followError: [error: { code: string; message: string }]
+  close: [event: CloseEvent]
+  error: [error: Event | Error]
+  reconnecting: [attempt: number]
+  reconnected: []
+}
+
+// =============================================================================
+// File Attribute Types
+// =============================================================================
+
+export interface ChmodOptions {
+  recursive?: boolean
+  /** Mode for directories when recursive (default: same as mode) */
+  dirMode?: string
+}
+
+export interface ChownOptions {
+  /** User name or uid */
+  owner?: string
+  /** Group name or gid */
+  group?: string
+  recursive?: boolean
+}
+
+export type XattrEncoding = 'utf8' | 'base64'
+
+export interface Xattr {
+  name: string
+  value: string
+  encoding: XattrEncoding
+}
+
+export interface XattrsResponse {
+  xattrs: Xattr[]
+}
+
+/** POSIX ACL entries in getfacl form, such as "user:alice:r-x" */
+export interface ACL {
+  access: string[]
+  /** Directories only */
+  default?: string[]
+}
+
+// =============================================================================
+// File Diff Types
+// =============================================================================
+
+export interface FileDiffOptions {
+  /** Unchanged lines shown around each change (default: 3) */
+  context?: number
+  /** Ignore all whitespace (diff -w) */
+  ignoreWhitespace?: boolean
+  /** Ignore changes in the amount of whitespace (diff -b) */
+  ignoreSpaceChange?: boolean
+  /** How files in directories are compared (default: size) */
+  compare?: 'size' | 'mtime' | 'hash'
+}
+
+export interface TreeDiffSide {
+  type: 'file' | 'directory' | 'symlink'
+  size: number
+  modified: string
+  /** Symlinks only */
+  target?: string
+}
+
+export interface TreeDiffEntry {
+  /** Relative to both directories */
+  path: string
+  status: 'added' | 'removed' | 'changed'
+  old?: TreeDiffSide
+  new?: TreeDiffSide
+}
+
+export interface TreeDiffSummary {
+  added: number
+  removed: number
+  changed: number
+  unchanged: number
+}
+
+export interface FileDiffResult {
+  type: 'file' | 'directory'
+  old: string
+  new: string
+  identical: boolean
+  /** Files: compared byte for byte only (binary or too large for a line diff) */
+  binary?: boolean
+  /** Files: unified diff */
+  diff?: string
+  /** Directories: entries that differ */
+  entries?: TreeDiffEntry[]
+  summary?: TreeDiffSummary
+  /** Directories: more entries differ than were returned */
+  truncated?: boolean
+}
+
+export interface PatchOptions {
+  /** Leading path components removed from names (default: git's a/ and b/) */
+  strip?: number
+  /** Context lines that may be ignored at each end of a hunk (default: 2) */
+  fuzz?: number
+  /** Only report whether each hunk would apply */
+  dryRun?: boolean
+}
+
+export interface PatchHunkResult {
+  /** 1-based, in patch order */
+  hunk: number
+  applied: boolean
+  /** Line of the original file the hunk matched at */
+  line?: number
+  /** Lines away from where the hunk header placed it */
+  offset?: number
+  /** Context lines ignored at each end */
+  fuzz?: number
+}
+
+export interface PatchFileResult {
+  path: string
+  op: 'modify' | 'create' | 'delete'
+  /** Every hunk applies */
+  ok: boolean
+  error?: string
+  hunks: PatchHunkResult[]
+}
+
+/** Result of files.patch; also the details of a patch_failed error */
+export interface PatchResult {
+  dry_run: boolean
+  /** Every file was changed (false for dry runs) */
+  applied: boolean
+  files: PatchFileResult[]
+}
+
+// =============================================================================
+// File Checksum Types
+// =============================================================================
+
+export type ChecksumAlgorithm = 'md5' | 'sha1' | 'sha256' | 'sha512' | 'blake3'
+
+export interface ChecksumOptions {
+  /** Default: sha256 */
+  algorithm?: ChecksumAlgorithm
+  /** Run as a background operation even when small */
+  background?: boolean
+}
+
+export interface ChecksumEntry {
+  /** Relative to the hashed directory */
+  path: string
+  hash: string
+  size: number
+}
+
+export interface ChecksumResult {
+  path: string
+  algorithm: ChecksumAlgorithm
+  type: 'file' | 'directory'
+  /** Bytes hashed */
+  size: number
+  /** For directories, the hash of the manifest */
+  hash: string
+  /** Directories: each regular file; symlinks and special files are skipped */
+  files?: ChecksumEntry[]
+  /** Directories: the files in sha256sum format */
+  manifest?: string
+}
+
+export interface VerifyChecksumsRequest {
+  /** Content of a SHA256SUMS-style list; requires base */
+  sums?: string
+  /** Or the path of one */
+  sums_path?: string
+  /** Directory names in the list are relative to. Default: that of sums_path */
+  base?: string
+  /** Default: from the list's tags, file name or hash length */
+  algorithm?: ChecksumAlgorithm
+  background?: boolean
+}
+
+export interface VerifyChecksumsEntry {
+  /** As listed */
+  path: string
+  algorithm: ChecksumAlgorithm
+  status: 'ok' | 'failed' | 'missing'
+  expected: string
+  actual?: string
+  error?: string
+}
+
+export interface VerifyChecksumsResult {
+  base: string
+  algorithm: ChecksumAlgorithm
+  /** Every file matched */
+  ok: boolean
+  passed: number
+  failed: number
+  missing: number
+  /** Lines that aren't checksums */
+  malformed: number
+  files: VerifyChecksumsEntry[]
+}
+
+/**
+ * Checksums over 256MB run in the background: the operation is returned
+ * instead of the result, which is set on it once completed
+ */
+export interface ChecksumResponse<T> {
+  result?: T
+  operation?: FileOperation
+}
+
+// =============================================================================
+// Disk Usage Types
+// =============================================================================
+
+export type UsageSort = 'size' | 'allocated'
+
+export interface UsageScanOptions {
+  /** Don't descend into other mounts (du -x) */
+  oneFileSystem?: boolean
+}
+
+export interface UsageEntry {
+  path: string
+  type: 'directory' | 'file'
+  /** Apparent size */
+  size: number
+  /** Bytes of blocks allocated on disk */
+  allocated: number
+  /** Directories: non-directory entries below */
+  files: number
+  /** Directories: subdirectories below */
+  dirs: number
+  /** Entries that couldn't be read */
+  errors?: number
+  children?: UsageEntry[]
+}
+
+/** A cached scan, or a directory within one */
+export interface UsageTree extends UsageEntry {
+  /** Directory the scan started at */
+  root: string
+  one_file_system: boolean
+  scanned_at: string
+  /** Last rescan of a directory inside the tree */
+  refreshed_at?: string
+}
+
+export interface UsageTreeOptions {
+  /** Levels of children (default: 1) */
+  depth?: number
+  /** Children per level (max 100) */
+  limit?: number
+  sort?: UsageSort
+}
+
+export interface UsageTopOptions {
+  /** Default: directory */
+  type?: 'directory' | 'file'
+  /** Default: 20, max 100 */
+  limit?: number
+  sort?: UsageSort
+}
+
+// =============================================================================
+// Media Types
+// =============================================================================
+
+export type MediaKind = 'image' | 'audio' | 'video' | 'other'
+
+export interface ThumbnailOptions {
+  /** Longer side in pixels, 16-1024 (default: 256) */
+  size?: number
+}
+
+export interface ImageInfo {
+  format: 'png' | 'jpeg' | 'gif' | 'webp'
+  width: number
+  height: number
+}
+
+export interface GPSInfo {
+  latitude: number
+  longitude: number
+  /** Meters above sea level */
+  altitude?: number
+}
+
+export interface ExifInfo {
+  make?: string
+  model?: string
+  lens?: string
+  software?: string
+  /** When the photo was taken, in the camera's local time (no zone) */
+  date_time?: string
+  /** EXIF orientation, 1-8 */
+  orientation?: number
+  /** Seconds, like "1/125" */
+  exposure_time?: string
+  f_number?: number
+  iso?: number
+  /** Millimeters */
+  focal_length?: number
+  gps?: GPSInfo
+}
+
+export interface MediaTrack {
+  type: 'audio' | 'video'
+  codec?: string
+  width?: number
+  height?: number
+  sample_rate?: number
+  channels?: number
+  bits_per_sample?: number
+}
+
+export interface ContainerInfo {
+  /** mp4, mov, m4a, wav, flac, mp3, ogg, matroska or webm */
+  format: string
+  /** Seconds */
+  duration?: number
+  /** Bits per second */
+  bitrate?: number
+  tracks?: MediaTrack[]
+  /** title, artist, album and year, when tagged */
+  tags?: Record<string, string>
+}
+
+export interface MediaInfo {
+  path: string
+  kind: MediaKind
+  mime_type: string
+  size: number
+  image?: ImageInfo
+  exif?: ExifInfo
+  /** Audio and video */
+  container?: ContainerInfo
+}
+
 // =============================================================================
 // Job Types
 // =============================================================================
@@ -316,6 +1043,7 @@ export interface Job {
 
 export interface JobsResponse {
   jobs: Job[]
+  meta?: ListMeta
 }
 
 export interface JobLogsResponse {
@@ -342,6 +1070,7 @@ export interface InstalledPackagesResponse {
   manager: string
   packages: Package[]
   count: number
+  meta?: ListMeta
 }
 
 export interface UpgradeInfo {
@@ -373,6 +1102,7 @@ export interface CronJob {
 export interface CronJobsResponse {
   jobs: CronJob[]
   count: number
+  meta?: ListMeta
 }
 
 export interface CronJobInput {
@@ -432,6 +1162,50 @@ export interface StatsConnectionEvents {
   reconnected: []
 }
 
+// =============================================================================
+// Event Stream Types
+// =============================================================================
+
+/** Topics published on /api/events */
+export type EventTopic =
+  | 'jobs.started'
+  | 'jobs.finished'
+  | 'jobs.failed'
+  | 'downloads.progress'
+  | 'downloads.state'
+  | 'downloads.completed'
+  | 'downloads.failed'
+  | 'files.changed'
+  | 'files.operation'
+  | 'cron.changed'
+  | 'terminal.opened'
+  | 'terminal.closed'
+
+export interface ServerEvent<T = unknown> {
+  id: number
+  topic: EventTopic | string
+  time: string
+  data?: T
+}
+
+export interface EventStreamOptions extends StatsConnectionOptions {
+  /** Topic patterns to subscribe to, e.g. ['jobs', 'downloads.state'] (default: all) */
+  topics?: string[]
+  /** Resume after this event ID */
+  lastEventId?: number
+}
+
+export interface EventStreamEvents {
+  open: []
+  event: [event: ServerEvent]
+  /** Events were lost between the last seen ID and the replay */
+  reset: []
+  close: [event: CloseEvent]
+  error: [error: Event | Error]
+  reconnecting: [attempt: number]
+  reconnected: []
+}
+
 // =============================================================================
 // Download Types
 // =============================================================================
@@ -466,6 +1240,7 @@ export interface Download {
 
 export interface DownloadsResponse {
   downloads: Download[]
+  meta?: ListMeta
 }
 
 export interface AddDownloadRequest {
@@ -478,3 +1253,105 @@ export interface CreateShareOptions {
   /** Expiry time in seconds (null = never expires) */
   expires_in?: number
 }
+
+// =============================================================================
+// Webhook Types
+// =============================================================================
+
+export type WebhookDeliveryStatus = 'pending' | 'succeeded' | 'failed'
+
+export interface Webhook {
+  id: string
+  url: string
+  /** Topic patterns, e.g. "jobs.failed" or "downloads.*" */
+  events: string[]
+  active: boolean
+  /** Signing secret, only returned on create */
+  secret?: string
+  created_at: string
+  updated_at: string
+}
+
+export interface CreateWebhookRequest {
+  url: string
+  events: string[]
+  /** Generated when omitted */
+  secret?: string
+  active?: boolean
+}
+
+export interface UpdateWebhookRequest {
+  url?: string
+  events?: string[]
+  secret?: string
+  active?: boolean
+}
+
+export interface WebhookDelivery {
+  id: string
+  webhook_id: string
+  event_id: number
+  topic: string
+  payload: unknown
+  status: WebhookDeliveryStatus
+  attempts: number
+  next_attempt_at?: string
+  status_code?: number
+  error?: string
+  response?: string
+  redelivery_of?: string
+  created_at: string
+  delivered_at?: string
+}
+
+export interface WebhookDeliveriesResponse {
+  deliveries: WebhookDelivery[]
+  meta?: ListMeta
+}
+
+// =============================================================================
+// Trash Types
+// =============================================================================
+
+export interface TrashItem {
+  id: string
+  name: string
+  /** Where the item was deleted from, and where restore puts it back */
+  original_path: string
+  type: 'file' | 'directory'
+  size: number
+  deleted_at: string
+}
+
+export interface TrashListResponse {
+  items: TrashItem[]
+  meta?: ListMeta
+}
+
+// =============================================================================
+// File History Types
+// =============================================================================
+
+export interface FileVersion {
+  id: string
+  path: string
+  /** SHA-256 of the content */
+  hash: string
+  size: number
+  /** What replaced this content */
+  op: 'write' | 'upload' | 'restore'
+  created_at: string
+}
+
+export interface FileVersionContent extends FileVersion {
+  content: string
+}
+
+export interface FileVersionsResponse {
+  versions: FileVersion[]
+}
+
+export interface ProcessesResponse {
+  processes: ProcessInfo[]
+  meta?: ListMeta
+}
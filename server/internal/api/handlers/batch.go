@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/batch"
+)
+
+// BatchIDsRequest represents a batch request over downloads or jobs
+type BatchIDsRequest struct {
+	Op          string   `json:"op"`
+	IDs         []string `json:"ids"`
+	Concurrency int      `json:"concurrency,omitempty"` // Default 4, max 16
+}
+
+// BatchItemResult is the outcome of one item of a batch request
+type BatchItemResult struct {
+	Index  int                 `json:"index"`
+	ID     string              `json:"id,omitempty"`
+	Path   string              `json:"path,omitempty"`
+	OK     bool                `json:"ok"`
+	Status int                 `json:"status"` // HTTP status the item would have had as a single request
+	Error  *response.ErrorBody `json:"error,omitempty"`
+}
+
+// BatchResponse reports the outcome of every item of a batch request, in
+// request order
+type BatchResponse struct {
+	Op        string            `json:"op"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// validBatchOp checks op against the supported operations, writing a 400 if it is not one
+func validBatchOp(w http.ResponseWriter, op string, ops ...string) bool {
+	for _, o := range ops {
+		if op == o {
+			return true
+		}
+	}
+	ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, "unsupported batch operation", map[string]interface{}{
+		"op":        op,
+		"supported": ops,
+	})
+	return false
+}
+
+// validBatchSize checks the item count, writing a 400 if it is out of range
+func validBatchSize(w http.ResponseWriter, n int) bool {
+	if n == 0 {
+		BadRequest(w, "no items given")
+		return false
+	}
+	if n > batch.MaxItems {
+		ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, "too many items in batch", map[string]int{
+			"max_items": batch.MaxItems,
+		})
+		return false
+	}
+	return true
+}
+
+// runBatch runs fn for each of the prepared results and fills in their
+// outcomes. action describes failures of untyped errors, like the context
+// passed to Fail.
+func runBatch(ctx context.Context, op string, results []BatchItemResult, concurrency int, action string, fn func(i int) error) *BatchResponse {
+	errs := batch.Run(ctx, len(results), concurrency, fn)
+
+	resp := &BatchResponse{Op: op, Results: results}
+	for i, err := range errs {
+		results[i].Index = i
+		if err == nil {
+			results[i].OK = true
+			results[i].Status = http.StatusOK
+			resp.Succeeded++
+			continue
+		}
+		status, body := response.Describe(err, action)
+		results[i].Status = status
+		results[i].Error = &body
+		resp.Failed++
+	}
+	return resp
+}
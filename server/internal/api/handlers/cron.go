@@ -25,7 +25,12 @@ func (h *CronHandler) Available() bool {
 // ListJobs handles GET /api/cron/jobs
 func (h *CronHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
 	if h.service == nil {
-		Error(w, http.StatusServiceUnavailable, "Cron not available")
+		Fail(w, cron.ErrCronNotFound, "")
+		return
+	}
+
+	filter, params, ok := parseListQuery(w, r, cron.ListSpec)
+	if !ok {
 		return
 	}
 
@@ -44,20 +49,22 @@ func (h *CronHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		InternalError(w, "failed to list cron jobs", err.Error())
+		Fail(w, err, "failed to list cron jobs")
 		return
 	}
 
+	jobs, meta := cron.Query(jobs, filter, params)
 	Success(w, map[string]interface{}{
 		"jobs":  jobs,
 		"count": len(jobs),
+		"meta":  meta,
 	})
 }
 
 // AddJob handles POST /api/cron/jobs
 func (h *CronHandler) AddJob(w http.ResponseWriter, r *http.Request) {
 	if h.service == nil {
-		Error(w, http.StatusServiceUnavailable, "Cron not available")
+		Fail(w, cron.ErrCronNotFound, "")
 		return
 	}
 
@@ -77,12 +84,12 @@ func (h *CronHandler) AddJob(w http.ResponseWriter, r *http.Request) {
 
 	// Validate schedule
 	if _, err := cron.ParseSchedule(req.Schedule); err != nil {
-		BadRequest(w, "invalid schedule format: "+err.Error())
+		Fail(w, err, "invalid schedule format")
 		return
 	}
 
 	if err := h.service.AddJob(req.Schedule, req.Command); err != nil {
-		InternalError(w, "failed to add cron job", err.Error())
+		Fail(w, err, "failed to add cron job")
 		return
 	}
 
@@ -92,7 +99,7 @@ func (h *CronHandler) AddJob(w http.ResponseWriter, r *http.Request) {
 // RemoveJob handles DELETE /api/cron/jobs
 func (h *CronHandler) RemoveJob(w http.ResponseWriter, r *http.Request) {
 	if h.service == nil {
-		Error(w, http.StatusServiceUnavailable, "Cron not available")
+		Fail(w, cron.ErrCronNotFound, "")
 		return
 	}
 
@@ -111,7 +118,7 @@ func (h *CronHandler) RemoveJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.service.RemoveJob(req.Schedule, req.Command); err != nil {
-		InternalError(w, "failed to remove cron job", err.Error())
+		Fail(w, err, "failed to remove cron job")
 		return
 	}
 
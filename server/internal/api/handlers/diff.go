@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/files"
+)
+
+// Diff handles GET /api/files/diff
+// Query params: old, new (two files or two directories), context (default 3),
+// ignore_whitespace, ignore_space_change (true to ignore all whitespace, or
+// changes in its amount), compare (size, mtime or hash; how directory
+// entries are compared, default size)
+func (h *FilesHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	oldPath, newPath := query.Get("old"), query.Get("new")
+	if oldPath == "" || newPath == "" {
+		BadRequest(w, "old and new are required")
+		return
+	}
+
+	opts := files.DiffOptions{
+		Context:           files.DefaultDiffContext,
+		IgnoreWhitespace:  query.Get("ignore_whitespace") == "true",
+		IgnoreSpaceChange: query.Get("ignore_space_change") == "true",
+		Compare:           query.Get("compare"),
+	}
+	if v := query.Get("context"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, "context must be a non-negative integer", nil)
+			return
+		}
+		opts.Context = n
+	}
+
+	result, err := h.fileService.Diff(r.Context(), oldPath, newPath, opts)
+	if err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+	Success(w, result)
+}
+
+// PatchRequest represents applying a unified diff
+type PatchRequest struct {
+	Base   string `json:"base"`            // Directory the names in the patch are relative to
+	Patch  string `json:"patch"`           // Unified diff of one or more files
+	Strip  *int   `json:"strip,omitempty"` // Leading path components to remove (default: git's a/ and b/)
+	Fuzz   *int   `json:"fuzz,omitempty"`  // Context lines that may be ignored (default 2)
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// Patch handles POST /api/files/patch
+// Either every file is patched or none is; a patch that doesn't apply
+// returns 409 patch_failed with the per-hunk result as details. Dry runs
+// return that result without writing.
+func (h *FilesHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	var req PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Base == "" || req.Patch == "" {
+		BadRequest(w, "base and patch are required")
+		return
+	}
+
+	opts := files.PatchOptions{Strip: -1, Fuzz: files.DefaultPatchFuzz, DryRun: req.DryRun}
+	if req.Strip != nil {
+		if *req.Strip < 0 {
+			ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, "strip must be a non-negative integer", nil)
+			return
+		}
+		opts.Strip = *req.Strip
+	}
+	if req.Fuzz != nil {
+		opts.Fuzz = *req.Fuzz
+	}
+
+	result, err := h.fileService.ApplyPatch(req.Base, req.Patch, opts)
+	if err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+	Success(w, result)
+}
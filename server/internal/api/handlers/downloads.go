@@ -4,22 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
-	"strconv"
-	"strings"
 
+	"github.com/ss497254/gloski/internal/apperr"
 	"github.com/ss497254/gloski/internal/downloads"
 )
 
-// sanitizeFilenameForHeader removes characters that could cause header injection
-func sanitizeFilenameForHeader(name string) string {
-	return strings.Map(func(r rune) rune {
-		if r == '"' || r == '\\' || r == '\n' || r == '\r' {
-			return '_'
-		}
-		return r
-	}, name)
-}
-
 type DownloadsHandler struct {
 	downloadService *downloads.Service
 }
@@ -28,11 +17,19 @@ func NewDownloadsHandler(downloadService *downloads.Service) *DownloadsHandler {
 	return &DownloadsHandler{downloadService: downloadService}
 }
 
-// List returns all downloads
+// List returns a page of downloads
+// Query params: status, name (glob on filename), created_after, created_before,
+// min_size, max_size, sort (created_at, filename, status, total, progress), order, limit, cursor
 func (h *DownloadsHandler) List(w http.ResponseWriter, r *http.Request) {
-	downloadList := h.downloadService.List()
+	filter, params, ok := parseListQuery(w, r, downloads.ListSpec)
+	if !ok {
+		return
+	}
+
+	downloadList, meta := h.downloadService.List(filter, params)
 	Success(w, map[string]interface{}{
 		"downloads": downloadList,
+		"meta":      meta,
 	})
 }
 
@@ -46,7 +43,7 @@ func (h *DownloadsHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 	download, err := h.downloadService.Get(id)
 	if err != nil {
-		NotFound(w, "download not found")
+		Fail(w, err, "failed to get download")
 		return
 	}
 
@@ -73,7 +70,7 @@ func (h *DownloadsHandler) Add(w http.ResponseWriter, r *http.Request) {
 
 	download, err := h.downloadService.Add(req.URL, req.Destination, req.Filename)
 	if err != nil {
-		InternalError(w, "failed to add download", err.Error())
+		Fail(w, err, "failed to add download")
 		return
 	}
 
@@ -89,7 +86,7 @@ func (h *DownloadsHandler) Pause(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.downloadService.Pause(id); err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to pause download")
 		return
 	}
 
@@ -105,7 +102,7 @@ func (h *DownloadsHandler) Resume(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.downloadService.Resume(id); err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to resume download")
 		return
 	}
 
@@ -121,7 +118,7 @@ func (h *DownloadsHandler) Cancel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.downloadService.Cancel(id); err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to cancel download")
 		return
 	}
 
@@ -137,7 +134,7 @@ func (h *DownloadsHandler) Retry(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.downloadService.Retry(id); err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to retry download")
 		return
 	}
 
@@ -156,13 +153,46 @@ func (h *DownloadsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	deleteFile := r.URL.Query().Get("delete_file") == "true"
 
 	if err := h.downloadService.Delete(id, deleteFile); err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to delete download")
 		return
 	}
 
 	Success(w, map[string]string{"status": "deleted"})
 }
 
+// Batch handles POST /api/downloads/batch
+// Supported ops: cancel, retry, delete (?delete_file=true also removes the files)
+func (h *DownloadsHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	var req BatchIDsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if !validBatchOp(w, req.Op, "cancel", "retry", "delete") || !validBatchSize(w, len(req.IDs)) {
+		return
+	}
+	deleteFile := r.URL.Query().Get("delete_file") == "true"
+
+	results := make([]BatchItemResult, len(req.IDs))
+	for i, id := range req.IDs {
+		results[i].ID = id
+	}
+
+	resp := runBatch(r.Context(), req.Op, results, req.Concurrency, "failed to "+req.Op+" download", func(i int) error {
+		id := req.IDs[i]
+		switch req.Op {
+		case "cancel":
+			return h.downloadService.Cancel(id)
+		case "retry":
+			return h.downloadService.Retry(id)
+		default: // delete
+			return h.downloadService.Delete(id, deleteFile)
+		}
+	})
+
+	Success(w, resp)
+}
+
 // DownloadFile serves the downloaded file (authenticated)
 func (h *DownloadsHandler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
@@ -173,26 +203,16 @@ func (h *DownloadsHandler) DownloadFile(w http.ResponseWriter, r *http.Request)
 
 	download, err := h.downloadService.Get(id)
 	if err != nil {
-		NotFound(w, "download not found")
+		Fail(w, err, "failed to get download")
 		return
 	}
 
 	if download.Status != downloads.StatusCompleted {
-		BadRequest(w, "download is not completed")
-		return
-	}
-
-	// Check if file exists
-	if _, err := os.Stat(download.FilePath); os.IsNotExist(err) {
-		NotFound(w, "file not found")
+		Fail(w, downloads.ErrInvalidState.WithMessage("download is not completed"), "")
 		return
 	}
 
-	// Set headers for download
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+sanitizeFilenameForHeader(download.Filename)+"\"")
-	w.Header().Set("Content-Type", "application/octet-stream")
-
-	http.ServeFile(w, r, download.FilePath)
+	serveDownload(w, r, download)
 }
 
 // CreateShareLink creates a share link for a download
@@ -211,7 +231,7 @@ func (h *DownloadsHandler) CreateShareLink(w http.ResponseWriter, r *http.Reques
 
 	shareLink, err := h.downloadService.CreateShareLink(id, req.ExpiresIn)
 	if err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to create share link")
 		return
 	}
 
@@ -229,7 +249,7 @@ func (h *DownloadsHandler) RevokeShareLink(w http.ResponseWriter, r *http.Reques
 	}
 
 	if err := h.downloadService.RevokeShareLink(id, token); err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to revoke share link")
 		return
 	}
 
@@ -255,27 +275,21 @@ func (h *ShareHandler) Download(w http.ResponseWriter, r *http.Request) {
 
 	download, err := h.downloadService.GetByShareToken(token)
 	if err != nil {
-		NotFound(w, "invalid or expired share link")
+		Fail(w, err, "failed to resolve share link")
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(download.FilePath); os.IsNotExist(err) {
-		NotFound(w, "file not found")
-		return
-	}
+	serveDownload(w, r, download)
+}
 
-	// Get file info for Content-Length
-	fileInfo, err := os.Stat(download.FilePath)
-	if err != nil {
-		InternalError(w, "failed to get file info", err.Error())
-		return
+// serveDownload serves a completed download's file, with Range and
+// conditional request support. Query param disposition=inline displays it.
+func serveDownload(w http.ResponseWriter, r *http.Request, download *downloads.Download) {
+	err := serveFile(w, r, download.FilePath, download.Filename, wantsInline(r))
+	switch {
+	case os.IsNotExist(err):
+		ErrorWithCode(w, http.StatusNotFound, apperr.CodePathNotFound, "file not found", nil)
+	case err != nil:
+		Fail(w, err, "failed to open file")
 	}
-
-	// Set headers for download
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+sanitizeFilenameForHeader(download.Filename)+"\"")
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
-
-	http.ServeFile(w, r, download.FilePath)
 }
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ss497254/gloski/internal/auth"
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+const (
+	// eventsKeepAlive is how often an idle SSE stream sends a comment line
+	eventsKeepAlive = 25 * time.Second
+
+	// eventsRetry is the reconnect delay suggested to EventSource clients
+	eventsRetry = 3 * time.Second
+)
+
+// EventsHandler streams bus events over Server-Sent Events or WebSocket
+type EventsHandler struct {
+	bus         *events.Bus
+	authService *auth.Service
+}
+
+// NewEventsHandler creates a new events handler
+func NewEventsHandler(bus *events.Bus, authService *auth.Service) *EventsHandler {
+	return &EventsHandler{
+		bus:         bus,
+		authService: authService,
+	}
+}
+
+// eventsMessage is a control message sent by WebSocket clients
+type eventsMessage struct {
+	Action string   `json:"action"` // "subscribe" or "unsubscribe"
+	Topics []string `json:"topics"`
+}
+
+// Stream handles GET /api/events
+//
+// Clients pick topics with ?topics=jobs,downloads.progress (default: all)
+// and resume with the Last-Event-ID header or ?last_event_id=. Requests with
+// an Upgrade: websocket header are served over WebSocket, everything else as
+// text/event-stream. Auth is accepted from headers or the api_key/token query
+// parameters, since neither EventSource nor browser WebSockets can set headers.
+func (h *EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	identity, err := h.authenticate(r)
+	if err != nil {
+		Unauthorized(w, "invalid or missing authentication")
+		return
+	}
+
+	q := r.URL.Query()
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = q.Get("last_event_id")
+	}
+	var afterID uint64
+	if lastID != "" {
+		if afterID, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			BadRequest(w, "invalid last event id")
+			return
+		}
+	}
+
+	topics := events.ParseTopics(q.Get("topics"))
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(w, r, identity, topics, afterID)
+		return
+	}
+	h.serveSSE(w, r, identity, topics, afterID)
+}
+
+func (h *EventsHandler) authenticate(r *http.Request) (*auth.Identity, error) {
+	return authenticateStream(h.authService, r)
+}
+
+// authenticateStream authenticates a streaming request from its headers or
+// the api_key/token query parameters
+func authenticateStream(authService *auth.Service, r *http.Request) (*auth.Identity, error) {
+	q := r.URL.Query()
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		apiKey = q.Get("api_key")
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = q.Get("token")
+	}
+	return authService.Authenticate(apiKey, token)
+}
+
+func (h *EventsHandler) serveSSE(w http.ResponseWriter, r *http.Request, identity *auth.Identity, topics []string, afterID uint64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		InternalError(w, "streaming not supported", "")
+		return
+	}
+
+	sub, replay, truncated := h.bus.Subscribe(topics, afterID, identity.AllowsTopic)
+	defer sub.Close()
+
+	hdr := w.Header()
+	hdr.Set("Content-Type", "text/event-stream")
+	hdr.Set("Cache-Control", "no-cache")
+	hdr.Set("Connection", "keep-alive")
+	hdr.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds())
+	if truncated {
+		// Tell the client that events between its last ID and the replay were lost
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, ev := range replay {
+		if err := writeSSEEvent(w, ev); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				// Dropped for falling behind; the client reconnects and replays
+				return
+			}
+			if err := writeSSEEvent(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSEEvent(w http.ResponseWriter, ev events.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		logger.Error("Failed to encode event: %v", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Topic, data)
+	return err
+}
+
+var eventsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true // Origin checking handled by auth - requires valid API key or JWT
+	},
+}
+
+func (h *EventsHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, identity *auth.Identity, topics []string, afterID uint64) {
+	conn, err := eventsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Error("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub, replay, truncated := h.bus.Subscribe(topics, afterID, identity.AllowsTopic)
+	defer sub.Close()
+
+	// Read control messages; the reader exits when the connection closes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		conn.SetPongHandler(func(string) error {
+			conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+			return nil
+		})
+		for {
+			var msg eventsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					logger.Debug("Events WebSocket error: %v", err)
+				}
+				return
+			}
+			switch msg.Action {
+			case "subscribe":
+				sub.SetTopics(append(sub.Topics(), msg.Topics...))
+			case "unsubscribe":
+				remaining := []string{}
+				for _, t := range sub.Topics() {
+					if !slices.Contains(msg.Topics, t) {
+						remaining = append(remaining, t)
+					}
+				}
+				sub.SetTopics(remaining)
+			}
+		}
+	}()
+
+	write := func(v interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteJSON(v)
+	}
+
+	if truncated {
+		if err := write(map[string]string{"topic": "reset"}); err != nil {
+			return
+		}
+	}
+	for _, ev := range replay {
+		if err := write(ev); err != nil {
+			return
+		}
+	}
+
+	ping := time.NewTicker(54 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow"))
+				return
+			}
+			if err := write(ev); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+// ChmodRequest represents a permission change
+type ChmodRequest struct {
+	Path      string `json:"path"`
+	Mode      string `json:"mode"`               // Octal, such as "644"
+	DirMode   string `json:"dir_mode,omitempty"` // recursive: mode for directories, defaults to mode
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+// ChownRequest represents an owner or group change
+type ChownRequest struct {
+	Path      string `json:"path"`
+	Owner     string `json:"owner,omitempty"` // User name or uid, empty keeps the owner
+	Group     string `json:"group,omitempty"` // Group name or gid, empty keeps the group
+	Recursive bool   `json:"recursive,omitempty"`
+}
+
+// LinkRequest represents a symlink or hard link creation
+type LinkRequest struct {
+	Target string `json:"target"`
+	Path   string `json:"path"`
+}
+
+// XattrRequest represents setting an extended attribute
+type XattrRequest struct {
+	Path     string `json:"path"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Encoding string `json:"encoding,omitempty"` // "utf8" (default) or "base64"
+}
+
+// ACLRequest represents an ACL change. Omitted lists are left unchanged.
+type ACLRequest struct {
+	Path    string    `json:"path"`
+	Access  *[]string `json:"access,omitempty"`
+	Default *[]string `json:"default,omitempty"`
+}
+
+// Chmod handles POST /api/files/chmod
+func (h *FilesHandler) Chmod(w http.ResponseWriter, r *http.Request) {
+	var req ChmodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+
+	mode, err := files.ParseMode(req.Mode)
+	if err != nil {
+		Fail(w, err, "")
+		return
+	}
+
+	if !req.Recursive {
+		if err := h.fileService.Chmod(req.Path, mode); err != nil {
+			h.handleFileError(w, err)
+			return
+		}
+		SuccessWithMessage(w, nil)
+		return
+	}
+
+	dirMode := mode
+	if req.DirMode != "" {
+		if dirMode, err = files.ParseMode(req.DirMode); err != nil {
+			Fail(w, err, "")
+			return
+		}
+	}
+	changed, err := h.fileService.ChmodRecursive(req.Path, mode, dirMode)
+	if err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+
+	logger.Info("Chmod %s recursively: %d entries", req.Path, changed)
+	Success(w, map[string]int{"changed": changed})
+}
+
+// Chown handles POST /api/files/chown
+func (h *FilesHandler) Chown(w http.ResponseWriter, r *http.Request) {
+	var req ChownRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+	if req.Owner == "" && req.Group == "" {
+		BadRequest(w, "owner or group is required")
+		return
+	}
+
+	uid, err := files.ParseOwner(req.Owner)
+	if err != nil {
+		Fail(w, err, "")
+		return
+	}
+	gid, err := files.ParseGroup(req.Group)
+	if err != nil {
+		Fail(w, err, "")
+		return
+	}
+
+	if !req.Recursive {
+		if err := h.fileService.Chown(req.Path, uid, gid); err != nil {
+			h.handleFileError(w, err)
+			return
+		}
+		SuccessWithMessage(w, nil)
+		return
+	}
+
+	changed, err := h.fileService.ChownRecursive(req.Path, uid, gid)
+	if err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+
+	logger.Info("Chown %s recursively: %d entries", req.Path, changed)
+	Success(w, map[string]int{"changed": changed})
+}
+
+// Symlink handles POST /api/files/symlink
+func (h *FilesHandler) Symlink(w http.ResponseWriter, r *http.Request) {
+	h.link(w, r, h.fileService.Symlink)
+}
+
+// Link handles POST /api/files/link (hard link)
+func (h *FilesHandler) Link(w http.ResponseWriter, r *http.Request) {
+	h.link(w, r, h.fileService.Link)
+}
+
+func (h *FilesHandler) link(w http.ResponseWriter, r *http.Request, create func(target, path string) error) {
+	var req LinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Target == "" || req.Path == "" {
+		BadRequest(w, "target and path are required")
+		return
+	}
+
+	if err := create(req.Target, req.Path); err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+
+	SuccessWithMessage(w, nil)
+}
+
+// ListXattrs handles GET /api/files/xattrs
+func (h *FilesHandler) ListXattrs(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+
+	attrs, err := h.fileService.ListXattrs(path)
+	if err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+
+	Success(w, map[string]interface{}{"xattrs": attrs})
+}
+
+// SetXattr handles POST /api/files/xattrs
+func (h *FilesHandler) SetXattr(w http.ResponseWriter, r *http.Request) {
+	var req XattrRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Path == "" || req.Name == "" {
+		BadRequest(w, "path and name are required")
+		return
+	}
+
+	value, err := files.DecodeXattrValue(req.Value, req.Encoding)
+	if err != nil {
+		Fail(w, err, "")
+		return
+	}
+	if err := h.fileService.SetXattr(req.Path, req.Name, value); err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+
+	SuccessWithMessage(w, nil)
+}
+
+// RemoveXattr handles DELETE /api/files/xattrs
+// Query params: path, name
+func (h *FilesHandler) RemoveXattr(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	name := r.URL.Query().Get("name")
+	if path == "" || name == "" {
+		BadRequest(w, "path and name are required")
+		return
+	}
+
+	if err := h.fileService.RemoveXattr(path, name); err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+
+	Success(w, map[string]string{"status": "deleted"})
+}
+
+// GetACL handles GET /api/files/acl
+func (h *FilesHandler) GetACL(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+
+	acl, err := h.fileService.GetACL(path)
+	if err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+
+	Success(w, acl)
+}
+
+// SetACL handles POST /api/files/acl
+func (h *FilesHandler) SetACL(w http.ResponseWriter, r *http.Request) {
+	var req ACLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+	if req.Access == nil && req.Default == nil {
+		BadRequest(w, "access or default is required")
+		return
+	}
+
+	if err := h.fileService.SetACL(req.Path, req.Access, req.Default); err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+
+	SuccessWithMessage(w, nil)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ss497254/gloski/internal/files"
+)
+
+// FileOperationsHandler handles background copy, move and extract operations,
+// and checksums, which run in the background when large
+type FileOperationsHandler struct {
+	operations *files.Operations
+}
+
+// NewFileOperationsHandler creates a new file operations handler
+func NewFileOperationsHandler(operations *files.Operations) *FileOperationsHandler {
+	return &FileOperationsHandler{operations: operations}
+}
+
+// List handles GET /api/files/operations
+// Query params: status, type (copy, move, extract, checksum, verify), name
+// (glob on destination), created_after, created_before, sort (created_at,
+// status, type, total_bytes), order, limit, cursor
+func (h *FileOperationsHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter, params, ok := parseListQuery(w, r, files.OperationListSpec)
+	if !ok {
+		return
+	}
+
+	ops, meta := h.operations.List(filter, params)
+	Success(w, map[string]interface{}{
+		"operations": ops,
+		"meta":       meta,
+	})
+}
+
+// Start handles POST /api/files/operations
+// The operation runs in the background; poll it or follow files.operation events.
+func (h *FileOperationsHandler) Start(w http.ResponseWriter, r *http.Request) {
+	var req files.OperationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Destination == "" {
+		BadRequest(w, "destination is required")
+		return
+	}
+
+	op, err := h.operations.Start(req)
+	if err != nil {
+		Fail(w, err, "failed to start file operation")
+		return
+	}
+	Success(w, op)
+}
+
+// Get handles GET /api/files/operations/{id}
+func (h *FileOperationsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	op, err := h.operations.Get(r.PathValue("id"))
+	if err != nil {
+		Fail(w, err, "failed to get file operation")
+		return
+	}
+	Success(w, op)
+}
+
+// Cancel handles POST /api/files/operations/{id}/cancel
+func (h *FileOperationsHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	if err := h.operations.Cancel(r.PathValue("id")); err != nil {
+		Fail(w, err, "failed to cancel file operation")
+		return
+	}
+	Success(w, map[string]string{"status": "cancelling"})
+}
+
+// Delete handles DELETE /api/files/operations/{id}
+func (h *FileOperationsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.operations.Delete(r.PathValue("id")); err != nil {
+		Fail(w, err, "failed to delete file operation")
+		return
+	}
+	Success(w, map[string]string{"status": "deleted"})
+}
+
+// ChecksumResponse is either a checksum's result, or the background operation
+// computing it
+type ChecksumResponse struct {
+	Result    interface{}      `json:"result,omitempty"`
+	Operation *files.Operation `json:"operation,omitempty"`
+}
+
+// Checksum handles POST /api/files/checksum
+// Hashes a file, or builds a manifest of a directory tree, with md5, sha1,
+// sha256 (default), sha512 or blake3. Hashing more than 256MB, or asking for
+// background, starts a cancellable operation instead; its result is set on
+// the operation once completed.
+func (h *FileOperationsHandler) Checksum(w http.ResponseWriter, r *http.Request) {
+	var req files.ChecksumRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+
+	result, op, err := h.operations.Checksum(r.Context(), req)
+	if err != nil {
+		Fail(w, err, "failed to compute checksum")
+		return
+	}
+	if op != nil {
+		Success(w, ChecksumResponse{Operation: op})
+		return
+	}
+	Success(w, ChecksumResponse{Result: result})
+}
+
+// VerifyChecksums handles POST /api/files/checksum/verify
+// Checks files against a SHA256SUMS-style list, given as sums (with base) or
+// sums_path. Mismatches are reported per file, not as an error. Runs in the
+// background like Checksum.
+func (h *FileOperationsHandler) VerifyChecksums(w http.ResponseWriter, r *http.Request) {
+	var req files.VerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Sums == "" && req.SumsPath == "" {
+		BadRequest(w, "sums or sums_path is required")
+		return
+	}
+
+	result, op, err := h.operations.Verify(r.Context(), req)
+	if err != nil {
+		Fail(w, err, "failed to verify checksums")
+		return
+	}
+	if op != nil {
+		Success(w, ChecksumResponse{Operation: op})
+		return
+	}
+	Success(w, ChecksumResponse{Result: result})
+}
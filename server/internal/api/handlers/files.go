@@ -7,10 +7,10 @@ import (
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ss497254/gloski/internal/apperr"
 	"github.com/ss497254/gloski/internal/files"
 	"github.com/ss497254/gloski/internal/logger"
 )
@@ -19,6 +19,7 @@ import (
 type FilesHandler struct {
 	fileService *files.Service
 	db          *sql.DB
+	index       *files.SearchIndex
 }
 
 // NewFilesHandler creates a new files handler
@@ -33,14 +34,26 @@ func (h *FilesHandler) SetDB(db *sql.DB) {
 	h.db = db
 }
 
+// SetSearchIndex sets the index searches of the paths it covers use
+func (h *FilesHandler) SetSearchIndex(index *files.SearchIndex) {
+	h.index = index
+}
+
 // List handles GET /api/files
+// Query params: path, type (file, directory), name (glob), min_size, max_size,
+// modified_after, modified_before, sort (name, type, size, modified), order, limit, cursor
 func (h *FilesHandler) List(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
 		path = "~"
 	}
 
-	entries, err := h.fileService.List(path)
+	filter, params, ok := parseListQuery(w, r, files.ListSpec)
+	if !ok {
+		return
+	}
+
+	entries, err := h.fileService.ListWithContext(r.Context(), path, filter, params)
 	if err != nil {
 		h.handleFileError(w, err)
 		return
@@ -57,7 +70,7 @@ func (h *FilesHandler) Read(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	content, err := h.fileService.Read(path)
+	content, version, err := h.fileService.Read(path)
 	if err != nil {
 		h.handleFileError(w, err)
 		return
@@ -69,7 +82,54 @@ func (h *FilesHandler) Read(w http.ResponseWriter, r *http.Request) {
 		normalizedPath = path
 	}
 
-	Success(w, map[string]string{"content": content, "path": normalizedPath})
+	w.Header().Set("ETag", `"`+version+`"`)
+	Success(w, map[string]string{"content": content, "path": normalizedPath, "version": version})
+}
+
+// ReadRange handles GET /api/files/read/range
+// Reads part of a file of any size, text or binary. Query params: path
+// (required), and one of offset (bytes; negative counts from the end) with
+// length (default 64KB, max 1MB), line (from 1) with lines (default 100), or
+// tail (last N lines); with line or tail, length caps the bytes returned.
+// format is auto (hex for binary files, the default), text or hex. The
+// response has the detected encoding and the file size for paging.
+func (h *FilesHandler) ReadRange(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := files.RangeRequest{Path: query.Get("path"), Format: query.Get("format")}
+	if req.Path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, "offset must be an integer", nil)
+			return
+		}
+		req.Offset = offset
+	}
+	var ok bool
+	if req.Line, ok = intParam(w, query.Get("line"), "line", 0); !ok {
+		return
+	}
+	if req.Lines, ok = intParam(w, query.Get("lines"), "lines", 0); !ok {
+		return
+	}
+	if req.Tail, ok = intParam(w, query.Get("tail"), "tail", 0); !ok {
+		return
+	}
+	length, ok := intParam(w, query.Get("length"), "length", 0)
+	if !ok {
+		return
+	}
+	req.Length = int64(length)
+
+	result, err := h.fileService.ReadRange(r.Context(), req)
+	if err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+	Success(w, result)
 }
 
 // WriteRequest represents a file write request
@@ -79,6 +139,8 @@ type WriteRequest struct {
 }
 
 // Write handles POST /api/files/write
+// With an If-Match header holding the version from Read, the write is
+// refused with 409 version_conflict (including a diff) if the file changed.
 func (h *FilesHandler) Write(w http.ResponseWriter, r *http.Request) {
 	var req WriteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -91,12 +153,14 @@ func (h *FilesHandler) Write(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.fileService.Write(req.Path, req.Content); err != nil {
+	version, err := h.fileService.Write(req.Path, req.Content, files.ParseIfMatch(r.Header.Get("If-Match")))
+	if err != nil {
 		h.handleFileError(w, err)
 		return
 	}
 
-	SuccessWithMessage(w, nil)
+	w.Header().Set("ETag", `"`+version+`"`)
+	SuccessWithMessage(w, map[string]string{"version": version})
 }
 
 // MkdirRequest represents a mkdir request
@@ -132,6 +196,7 @@ func (h *FilesHandler) Mkdir(w http.ResponseWriter, r *http.Request) {
 }
 
 // Delete handles DELETE /api/files
+// Query params: path, permanent (true to bypass the trash)
 func (h *FilesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -139,7 +204,11 @@ func (h *FilesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.fileService.Delete(path); err != nil {
+	deleteFn := h.fileService.Delete
+	if r.URL.Query().Get("permanent") == "true" {
+		deleteFn = h.fileService.DeletePermanently
+	}
+	if err := deleteFn(path); err != nil {
 		h.handleFileError(w, err)
 		return
 	}
@@ -174,6 +243,70 @@ func (h *FilesHandler) Rename(w http.ResponseWriter, r *http.Request) {
 	SuccessWithMessage(w, nil)
 }
 
+// FilesBatchRequest represents a bulk file operation
+type FilesBatchRequest struct {
+	Op          string           `json:"op"` // delete, move, copy or chmod
+	Items       []FilesBatchItem `json:"items"`
+	Concurrency int              `json:"concurrency,omitempty"` // Default 4, max 16
+	Permanent   bool             `json:"permanent,omitempty"`   // delete: bypass the trash
+}
+
+// FilesBatchItem is one path of a bulk file operation
+type FilesBatchItem struct {
+	Path        string `json:"path"`
+	Destination string `json:"destination,omitempty"` // move and copy
+	Mode        string `json:"mode,omitempty"`        // chmod, octal such as "644"
+}
+
+// Batch handles POST /api/files/batch
+// Items are processed independently; the response reports each item's outcome.
+func (h *FilesHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	var req FilesBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if !validBatchOp(w, req.Op, "delete", "move", "copy", "chmod") || !validBatchSize(w, len(req.Items)) {
+		return
+	}
+
+	results := make([]BatchItemResult, len(req.Items))
+	for i, item := range req.Items {
+		results[i].Path = item.Path
+	}
+
+	resp := runBatch(r.Context(), req.Op, results, req.Concurrency, "file operation failed", func(i int) error {
+		item := req.Items[i]
+		if item.Path == "" {
+			return apperr.New(apperr.CodeBadRequest, "path is required")
+		}
+		switch req.Op {
+		case "delete":
+			if req.Permanent {
+				return h.fileService.DeletePermanently(item.Path)
+			}
+			return h.fileService.Delete(item.Path)
+		case "move", "copy":
+			if item.Destination == "" {
+				return apperr.New(apperr.CodeBadRequest, "destination is required")
+			}
+			if req.Op == "move" {
+				return h.fileService.Rename(item.Path, item.Destination)
+			}
+			return h.fileService.Copy(item.Path, item.Destination)
+		default: // chmod
+			mode, err := files.ParseMode(item.Mode)
+			if err != nil {
+				return err
+			}
+			return h.fileService.Chmod(item.Path, mode)
+		}
+	})
+
+	logger.Info("File batch %s: %d succeeded, %d failed", req.Op, resp.Succeeded, resp.Failed)
+	Success(w, resp)
+}
+
 // Upload handles POST /api/files/upload
 func (h *FilesHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	// Max 100MB
@@ -205,6 +338,8 @@ func (h *FilesHandler) Upload(w http.ResponseWriter, r *http.Request) {
 }
 
 // Download handles GET /api/files/download
+// Query params: path, disposition (attachment by default, or inline)
+// Supports Range and conditional requests.
 func (h *FilesHandler) Download(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 	if path == "" {
@@ -219,73 +354,126 @@ func (h *FilesHandler) Download(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if info.IsDir() {
-		BadRequest(w, "cannot download a directory")
+		Fail(w, files.ErrIsDirectory.WithMessage("cannot download a directory, use /api/files/archive"), "")
 		return
 	}
 
-	// Sanitize filename to prevent header injection
-	safeName := strings.Map(func(r rune) rune {
-		if r == '"' || r == '\\' || r == '\n' || r == '\r' {
-			return '_'
-		}
-		return r
-	}, info.Name())
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+safeName+"\"")
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	if err := serveFile(w, r, filePath, info.Name(), wantsInline(r)); err != nil {
+		h.handleFileError(w, err)
+	}
+}
+
+// Archive handles GET /api/files/archive
+// Query params: path (repeatable; files or directories), format (zip, tar,
+// tar.gz, tar.zst; default zip). The archive is streamed as it is built.
+func (h *FilesHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format, err := files.ParseArchiveFormat(query.Get("format"))
+	if err != nil {
+		Fail(w, err, "")
+		return
+	}
+
+	archive, err := h.fileService.NewArchive(query["path"], format)
+	if err != nil {
+		h.handleFileError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", archive.Name()))
+	w.Header().Set("Content-Type", archive.ContentType())
 
-	http.ServeFile(w, r, filePath)
+	// Large trees take longer than the server's write timeout to stream
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if err := archive.Write(r.Context(), w); err != nil {
+		// Headers are already sent; all we can do is cut the response short
+		if r.Context().Err() == nil {
+			logger.Error("Failed to stream archive: %v", err)
+		}
+		panic(http.ErrAbortHandler)
+	}
 }
 
 // Search handles GET /api/search
+// Query params: q (required), path (default ~), content (true to match lines
+// of text files), limit (max 500), mode (substring, glob or regex),
+// case_sensitive, ext, include and exclude (comma-separated or repeated),
+// hidden, no_ignore, max_matches, and type, name, min_size, max_size,
+// modified_after and modified_before as for listings. stream=ndjson or sse
+// (or a matching Accept header) sends results as they are found.
+// Plain searches of paths the search index covers are answered from it,
+// with ranked results and snippets; q may then use "phrases", prefix*, AND,
+// OR, NOT, parentheses and name: or content: filters, and offset applies.
+// index=false walks the tree instead.
 func (h *FilesHandler) Search(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Query().Get("path")
-	if path == "" {
-		path = "~"
+	req, ok := parseSearchRequest(w, r.URL.Query())
+	if !ok {
+		return
 	}
+	format := searchFormat(r)
 
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		BadRequest(w, "query (q) is required")
+	if h.index != nil && format == "" && req.Indexable() && r.URL.Query().Get("index") != "false" && h.index.Covers(req.Path) {
+		h.searchIndex(w, r, req)
+		return
+	}
+	if format != "" {
+		h.streamSearch(w, r, req, format)
 		return
 	}
 
-	searchContent := r.URL.Query().Get("content") == "true"
+	results := []files.SearchResult{}
+	summary, err := h.fileService.SearchStream(r.Context(), req, func(result files.SearchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		h.handleFileError(w, err)
+		return
+	}
 
-	limit := 100
-	if n := r.URL.Query().Get("limit"); n != "" {
-		if parsed, err := strconv.Atoi(n); err == nil && parsed > 0 && parsed <= 500 {
-			limit = parsed
-		}
+	Success(w, map[string]interface{}{
+		"results":   results,
+		"count":     len(results),
+		"indexed":   false,
+		"truncated": summary.Truncated,
+		"timed_out": summary.TimedOut,
+	})
+}
+
+// searchIndex answers a search from the search index
+func (h *FilesHandler) searchIndex(w http.ResponseWriter, r *http.Request, req files.SearchRequest) {
+	offset, ok := intParam(w, r.URL.Query().Get("offset"), "offset", 0)
+	if !ok {
+		return
 	}
 
-	// Use request context for cancellation
-	results, err := h.fileService.SearchWithContext(r.Context(), path, query, searchContent, limit)
+	results, err := h.index.Search(files.IndexQuery{
+		Query:   req.Query,
+		Path:    req.Path,
+		Type:    req.Filter.Type,
+		Content: req.Content,
+		Limit:   req.Limit,
+		Offset:  offset,
+	})
 	if err != nil {
 		h.handleFileError(w, err)
 		return
 	}
 
-	Success(w, map[string]interface{}{"results": results, "count": len(results)})
+	Success(w, map[string]interface{}{"results": results, "count": len(results), "indexed": true})
 }
 
 // handleFileError converts file service errors to HTTP responses
 func (h *FilesHandler) handleFileError(w http.ResponseWriter, err error) {
 	switch {
-	case errors.Is(err, files.ErrPathNotAllowed):
-		Forbidden(w, err.Error())
-	case errors.Is(err, files.ErrDangerousPath):
-		Forbidden(w, err.Error())
-	case errors.Is(err, files.ErrFileTooLarge):
-		BadRequest(w, err.Error())
-	case errors.Is(err, files.ErrBinaryFile):
-		BadRequest(w, err.Error())
 	case os.IsPermission(err):
-		Forbidden(w, "permission denied: check server user has write access to this directory")
+		ErrorWithCode(w, http.StatusForbidden, apperr.CodePermissionDenied,
+			"permission denied: check server user has write access to this directory", nil)
 	case os.IsNotExist(err):
-		BadRequest(w, "path does not exist")
+		ErrorWithCode(w, http.StatusBadRequest, apperr.CodePathNotFound, "path does not exist", nil)
 	default:
-		InternalError(w, "file operation failed", err.Error())
+		Fail(w, err, "file operation failed")
 	}
 }
 
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/auth"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+// followDefaultLines is how many last lines are sent first, as with tail
+const followDefaultLines = 10
+
+// FollowHandler streams lines appended to a file over Server-Sent Events or
+// WebSocket
+type FollowHandler struct {
+	fileService *files.Service
+	authService *auth.Service
+}
+
+// NewFollowHandler creates a new follow handler
+func NewFollowHandler(fileService *files.Service, authService *auth.Service) *FollowHandler {
+	return &FollowHandler{
+		fileService: fileService,
+		authService: authService,
+	}
+}
+
+// followError is sent when following stops on an error
+type followError struct {
+	Type  string             `json:"type"` // "error"
+	Error response.ErrorBody `json:"error"`
+}
+
+// Stream handles GET /api/files/follow
+//
+// Query: path (required), lines (last lines sent first, default 10, 0 starts
+// at the end), filter (regular expression lines must match). Messages are
+// files.FollowEvent values: "lines", "truncated", "rotated" and "skipped",
+// the last when the client fell too far behind. Requests with an Upgrade:
+// websocket header are served over WebSocket, everything else as
+// text/event-stream with the message type as the event name. Auth is
+// accepted from headers or the api_key/token query parameters.
+func (h *FollowHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateStream(h.authService, r); err != nil {
+		Unauthorized(w, "invalid or missing authentication")
+		return
+	}
+
+	q := r.URL.Query()
+	path := q.Get("path")
+	if path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+	lines, ok := intParam(w, q.Get("lines"), "lines", followDefaultLines)
+	if !ok {
+		return
+	}
+
+	follow, err := h.fileService.Follow(files.FollowRequest{
+		Path:   path,
+		Lines:  lines,
+		Filter: q.Get("filter"),
+	})
+	if err != nil {
+		Fail(w, err, "failed to follow file")
+		return
+	}
+	defer follow.Close()
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(w, r, follow)
+		return
+	}
+	h.serveSSE(w, r, follow)
+}
+
+func (h *FollowHandler) serveSSE(w http.ResponseWriter, r *http.Request, follow *files.Follow) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		InternalError(w, "streaming not supported", "")
+		return
+	}
+
+	hdr := w.Header()
+	hdr.Set("Content-Type", "text/event-stream")
+	hdr.Set("Cache-Control", "no-cache")
+	hdr.Set("Connection", "keep-alive")
+	hdr.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds())
+	flusher.Flush()
+
+	write := func(event string, v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			logger.Error("Failed to encode follow event: %v", err)
+			return nil
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-follow.Events():
+			if !ok {
+				if err := follow.Err(); err != nil {
+					_, body := response.Describe(err, "failed to follow file")
+					write("error", followError{Type: "error", Error: body})
+				}
+				return
+			}
+			if err := write(ev.Type, ev); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *FollowHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, follow *files.Follow) {
+	conn, err := eventsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Error("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Clients send nothing; reading notices when they go away
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		conn.SetPongHandler(func(string) error {
+			conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+			return nil
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					logger.Debug("Follow WebSocket error: %v", err)
+				}
+				return
+			}
+		}
+	}()
+
+	// Events are only taken as fast as they are written, so a slow client
+	// slows reading of the file instead of growing a queue
+	write := func(v interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteJSON(v)
+	}
+
+	ping := time.NewTicker(54 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case ev, ok := <-follow.Events():
+			if !ok {
+				if err := follow.Err(); err != nil {
+					_, body := response.Describe(err, "failed to follow file")
+					write(followError{Type: "error", Error: body})
+				}
+				conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := write(ev); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/files"
+)
+
+// HistoryHandler handles listing, viewing and restoring earlier versions of files
+type HistoryHandler struct {
+	history *files.History
+}
+
+// NewHistoryHandler creates a new file history handler
+func NewHistoryHandler(history *files.History) *HistoryHandler {
+	return &HistoryHandler{history: history}
+}
+
+// List handles GET /api/files/history
+// Query params: path (required)
+func (h *HistoryHandler) List(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+
+	versions, err := h.history.List(path)
+	if err != nil {
+		Fail(w, err, "failed to list file versions")
+		return
+	}
+	Success(w, map[string]interface{}{"versions": versions})
+}
+
+// Get handles GET /api/files/history/{id}
+// Returns the version with its content, which must be text; binary versions
+// can be fetched from the download route.
+func (h *HistoryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	version, content, err := h.history.Read(r.PathValue("id"))
+	if err != nil {
+		Fail(w, err, "failed to read file version")
+		return
+	}
+	Success(w, struct {
+		*files.FileVersion
+		Content string `json:"content"`
+	}{version, content})
+}
+
+// Download handles GET /api/files/history/{id}/download
+// Query params: disposition (attachment by default, or inline)
+func (h *HistoryHandler) Download(w http.ResponseWriter, r *http.Request) {
+	version, path, err := h.history.ContentPath(r.PathValue("id"))
+	if err != nil {
+		Fail(w, err, "failed to download file version")
+		return
+	}
+	if err := serveFile(w, r, path, filepath.Base(version.Path), wantsInline(r)); err != nil {
+		Fail(w, err, "failed to download file version")
+	}
+}
+
+// Diff handles GET /api/files/history/{id}/diff
+// Query params: context (unchanged lines around each change, default 3)
+// Returns a unified diff from the version to the file's current content.
+func (h *HistoryHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	context := files.DefaultDiffContext
+	if v := r.URL.Query().Get("context"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, "context must be a non-negative integer", nil)
+			return
+		}
+		context = n
+	}
+
+	diff, err := h.history.Diff(r.PathValue("id"), context)
+	if err != nil {
+		Fail(w, err, "failed to diff file version")
+		return
+	}
+	Success(w, map[string]string{"diff": diff})
+}
+
+// Restore handles POST /api/files/history/{id}/restore
+// The file's current content is kept as a new version before it is replaced.
+func (h *HistoryHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	version, err := h.history.Restore(r.PathValue("id"))
+	if err != nil {
+		Fail(w, err, "failed to restore file version")
+		return
+	}
+
+	w.Header().Set("ETag", `"`+version+`"`)
+	Success(w, map[string]string{"version": version})
+}
+
+// Delete handles DELETE /api/files/history/{id}
+func (h *HistoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.history.Delete(r.PathValue("id")); err != nil {
+		Fail(w, err, "failed to delete file version")
+		return
+	}
+	Success(w, map[string]string{"status": "deleted"})
+}
@@ -22,13 +22,23 @@ func NewJobsHandler(jobService *jobs.Service) *JobsHandler {
 }
 
 // List handles GET /api/jobs
+// Query params: status, name (glob on command), created_after, created_before,
+// sort (created_at, started_at, finished_at, status, command), order, limit, cursor
 func (h *JobsHandler) List(w http.ResponseWriter, r *http.Request) {
-	jobList, err := h.jobService.List()
+	filter, params, ok := parseListQuery(w, r, jobs.ListSpec)
+	if !ok {
+		return
+	}
+
+	jobList, meta, err := h.jobService.List(filter, params)
 	if err != nil {
-		InternalError(w, "failed to list jobs", err.Error())
+		Fail(w, err, "failed to list jobs")
 		return
 	}
-	Success(w, map[string]interface{}{"jobs": jobList})
+	Success(w, map[string]interface{}{
+		"jobs": jobList,
+		"meta": meta,
+	})
 }
 
 // StartJobRequest represents a job start request
@@ -53,7 +63,7 @@ func (h *JobsHandler) Start(w http.ResponseWriter, r *http.Request) {
 	id := uuid.New().String()
 	job, err := h.jobService.Start(id, req.Command, req.Cwd)
 	if err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to start job")
 		return
 	}
 
@@ -66,7 +76,7 @@ func (h *JobsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	job, err := h.jobService.Get(id)
 	if err != nil {
-		NotFound(w, "job not found")
+		Fail(w, err, "failed to get job")
 		return
 	}
 
@@ -78,7 +88,7 @@ func (h *JobsHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	output, err := h.jobService.GetLogs(id)
 	if err != nil {
-		NotFound(w, err.Error())
+		Fail(w, err, "failed to read job logs")
 		return
 	}
 
@@ -89,7 +99,7 @@ func (h *JobsHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
 func (h *JobsHandler) Stop(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if err := h.jobService.Stop(id); err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to stop job")
 		return
 	}
 
@@ -101,10 +111,38 @@ func (h *JobsHandler) Stop(w http.ResponseWriter, r *http.Request) {
 func (h *JobsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if err := h.jobService.Delete(id); err != nil {
-		BadRequest(w, err.Error())
+		Fail(w, err, "failed to delete job")
 		return
 	}
 
 	logger.Info("Job deleted: %s", id)
 	SuccessWithMessage(w, nil)
 }
+
+// Batch handles POST /api/jobs/batch
+// Supported ops: stop, delete
+func (h *JobsHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	var req BatchIDsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if !validBatchOp(w, req.Op, "stop", "delete") || !validBatchSize(w, len(req.IDs)) {
+		return
+	}
+
+	results := make([]BatchItemResult, len(req.IDs))
+	for i, id := range req.IDs {
+		results[i].ID = id
+	}
+
+	resp := runBatch(r.Context(), req.Op, results, req.Concurrency, "failed to "+req.Op+" job", func(i int) error {
+		if req.Op == "stop" {
+			return h.jobService.Stop(req.IDs[i])
+		}
+		return h.jobService.Delete(req.IDs[i])
+	})
+
+	logger.Info("Job batch %s: %d succeeded, %d failed", req.Op, resp.Succeeded, resp.Failed)
+	Success(w, resp)
+}
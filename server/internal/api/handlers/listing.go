@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ss497254/gloski/internal/listing"
+)
+
+// parseListQuery reads the pagination, sorting and filter query parameters
+// shared by list endpoints. On invalid input it writes a 400 response and
+// returns ok=false.
+func parseListQuery(w http.ResponseWriter, r *http.Request, spec listing.Spec) (listing.Filter, listing.Params, bool) {
+	q := r.URL.Query()
+
+	params, err := listing.ParseParams(q, spec)
+	if err != nil {
+		Fail(w, err, "invalid list parameters")
+		return listing.Filter{}, listing.Params{}, false
+	}
+
+	filter, err := listing.ParseFilter(q)
+	if err != nil {
+		Fail(w, err, "invalid list filter")
+		return listing.Filter{}, listing.Params{}, false
+	}
+
+	return filter, params, true
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/ss497254/gloski/internal/files"
+)
+
+// MediaHandler handles image thumbnails and media metadata
+type MediaHandler struct {
+	media *files.Media
+}
+
+// NewMediaHandler creates a new media handler
+func NewMediaHandler(media *files.Media) *MediaHandler {
+	return &MediaHandler{media: media}
+}
+
+// Thumbnail handles GET /api/files/thumbnail
+// Query params: path (required; a PNG, JPEG, GIF or WebP image), size
+// (longer side in pixels, 16-1024, default 256). Responds with a JPEG, or a
+// PNG for images with transparency. Supports conditional requests; the ETag
+// changes with the image.
+func (h *MediaHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	path := query.Get("path")
+	if path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+	size, ok := intParam(w, query.Get("size"), "size", files.DefaultThumbnailSize)
+	if !ok {
+		return
+	}
+
+	thumb, err := h.media.Thumbnail(r.Context(), path, size)
+	if err != nil {
+		Fail(w, err, "failed to generate thumbnail")
+		return
+	}
+	f, err := os.Open(thumb.Path)
+	if err != nil {
+		Fail(w, err, "failed to read thumbnail")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", thumb.ContentType)
+	w.Header().Set("ETag", thumb.ETag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", thumb.ModTime, f)
+}
+
+// Info handles GET /api/files/media
+// Query params: path (required). Returns the kind and MIME type of a file,
+// and what its headers say: dimensions and EXIF data (camera, date, GPS) of
+// images, and container, duration, tracks and tags of audio and video.
+func (h *MediaHandler) Info(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+
+	info, err := h.media.Info(path)
+	if err != nil {
+		Fail(w, err, "failed to read media metadata")
+		return
+	}
+	Success(w, info)
+}
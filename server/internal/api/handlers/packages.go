@@ -25,7 +25,7 @@ func (h *PackagesHandler) Available() bool {
 // Info handles GET /api/packages/info
 func (h *PackagesHandler) Info(w http.ResponseWriter, r *http.Request) {
 	if h.service == nil {
-		Error(w, http.StatusServiceUnavailable, "Package management not available")
+		Fail(w, packages.ErrNoPackageManager, "")
 		return
 	}
 
@@ -37,20 +37,18 @@ func (h *PackagesHandler) Info(w http.ResponseWriter, r *http.Request) {
 // ListInstalled handles GET /api/packages/installed
 func (h *PackagesHandler) ListInstalled(w http.ResponseWriter, r *http.Request) {
 	if h.service == nil {
-		Error(w, http.StatusServiceUnavailable, "Package management not available")
+		Fail(w, packages.ErrNoPackageManager, "")
 		return
 	}
 
-	limit := 100
-	if n := r.URL.Query().Get("limit"); n != "" {
-		if parsed, err := strconv.Atoi(n); err == nil && parsed > 0 {
-			limit = parsed
-		}
+	filter, params, ok := parseListQuery(w, r, packages.ListSpec)
+	if !ok {
+		return
 	}
 
-	pkgs, err := h.service.ListInstalled(limit)
+	pkgs, meta, err := h.service.ListInstalled(filter, params)
 	if err != nil {
-		InternalError(w, "failed to list installed packages", err.Error())
+		Fail(w, err, "failed to list installed packages")
 		return
 	}
 
@@ -58,19 +56,20 @@ func (h *PackagesHandler) ListInstalled(w http.ResponseWriter, r *http.Request)
 		"manager":  h.service.Manager(),
 		"packages": pkgs,
 		"count":    len(pkgs),
+		"meta":     meta,
 	})
 }
 
 // CheckUpgrades handles GET /api/packages/upgrades
 func (h *PackagesHandler) CheckUpgrades(w http.ResponseWriter, r *http.Request) {
 	if h.service == nil {
-		Error(w, http.StatusServiceUnavailable, "Package management not available")
+		Fail(w, packages.ErrNoPackageManager, "")
 		return
 	}
 
 	info, err := h.service.CheckUpgrades()
 	if err != nil {
-		InternalError(w, "failed to check upgrades", err.Error())
+		Fail(w, err, "failed to check upgrades")
 		return
 	}
 
@@ -80,7 +79,7 @@ func (h *PackagesHandler) CheckUpgrades(w http.ResponseWriter, r *http.Request)
 // Search handles GET /api/packages/search
 func (h *PackagesHandler) Search(w http.ResponseWriter, r *http.Request) {
 	if h.service == nil {
-		Error(w, http.StatusServiceUnavailable, "Package management not available")
+		Fail(w, packages.ErrNoPackageManager, "")
 		return
 	}
 
@@ -99,7 +98,7 @@ func (h *PackagesHandler) Search(w http.ResponseWriter, r *http.Request) {
 
 	pkgs, err := h.service.Search(query, limit)
 	if err != nil {
-		InternalError(w, "failed to search packages", err.Error())
+		Fail(w, err, "failed to search packages")
 		return
 	}
 
@@ -112,7 +111,7 @@ func (h *PackagesHandler) Search(w http.ResponseWriter, r *http.Request) {
 // GetPackageInfo handles GET /api/packages/{name}
 func (h *PackagesHandler) GetPackageInfo(w http.ResponseWriter, r *http.Request) {
 	if h.service == nil {
-		Error(w, http.StatusServiceUnavailable, "Package management not available")
+		Fail(w, packages.ErrNoPackageManager, "")
 		return
 	}
 
@@ -124,7 +123,7 @@ func (h *PackagesHandler) GetPackageInfo(w http.ResponseWriter, r *http.Request)
 
 	pkg, err := h.service.GetPackageInfo(name)
 	if err != nil {
-		InternalError(w, "failed to get package info", err.Error())
+		Fail(w, err, "failed to get package info")
 		return
 	}
 
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/apperr"
 )
 
 // Response type alias
@@ -30,6 +31,17 @@ func Error(w http.ResponseWriter, status int, message string) {
 	response.Error(w, status, message)
 }
 
+// ErrorWithCode writes an error JSON response with an explicit code and details
+func ErrorWithCode(w http.ResponseWriter, status int, code apperr.Code, message string, details interface{}) {
+	response.ErrorWithCode(w, status, code, message, details)
+}
+
+// Fail writes an error response for a service error, using its code if typed
+// context: brief description of what failed (used for untyped errors)
+func Fail(w http.ResponseWriter, err error, context string) {
+	response.Fail(w, err, context)
+}
+
 // BadRequest writes a 400 error response
 func BadRequest(w http.ResponseWriter, message string) {
 	response.BadRequest(w, message)
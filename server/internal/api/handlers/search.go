@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+// Search stream formats
+const (
+	searchNDJSON = "ndjson"
+	searchSSE    = "sse"
+)
+
+// parseSearchRequest reads the search query parameters. On invalid input it
+// writes a 400 response and returns ok=false.
+func parseSearchRequest(w http.ResponseWriter, q url.Values) (files.SearchRequest, bool) {
+	req := files.SearchRequest{
+		Path:          q.Get("path"),
+		Query:         q.Get("q"),
+		Mode:          q.Get("mode"),
+		CaseSensitive: q.Get("case_sensitive") == "true",
+		Content:       q.Get("content") == "true",
+		Extensions:    listParam(q, "ext"),
+		Include:       listParam(q, "include"),
+		Exclude:       listParam(q, "exclude"),
+		Hidden:        q.Get("hidden") == "true",
+		NoIgnore:      q.Get("no_ignore") == "true",
+		Limit:         100,
+	}
+	if req.Path == "" {
+		req.Path = "~"
+	}
+	if req.Query == "" {
+		BadRequest(w, "query (q) is required")
+		return req, false
+	}
+
+	if n := q.Get("limit"); n != "" {
+		if parsed, err := strconv.Atoi(n); err == nil && parsed > 0 && parsed <= 500 {
+			req.Limit = parsed
+		}
+	}
+	maxMatches, ok := intParam(w, q.Get("max_matches"), "max_matches", files.DefaultSearchMaxMatches)
+	if !ok {
+		return req, false
+	}
+	req.MaxMatches = maxMatches
+
+	filter, err := listing.ParseFilter(q)
+	if err != nil {
+		Fail(w, err, "invalid search filter")
+		return req, false
+	}
+	if filter.Type != "" && filter.Type != "file" && filter.Type != "directory" {
+		ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, "type must be file or directory", nil)
+		return req, false
+	}
+	req.Filter = filter
+	return req, true
+}
+
+// listParam returns the values of a parameter that may be repeated or hold
+// comma-separated values
+func listParam(q url.Values, name string) []string {
+	var values []string
+	for _, v := range q[name] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// searchFormat returns how results should be streamed, from the stream
+// parameter or the Accept header, or "" to return them all at once
+func searchFormat(r *http.Request) string {
+	switch format := r.URL.Query().Get("stream"); {
+	case format == searchNDJSON || format == searchSSE:
+		return format
+	case strings.Contains(r.Header.Get("Accept"), "text/event-stream"):
+		return searchSSE
+	case strings.Contains(r.Header.Get("Accept"), "application/x-ndjson"):
+		return searchNDJSON
+	}
+	return ""
+}
+
+// searchStream writes search events as NDJSON lines ({"type": ..., "data":
+// ...}) or server-sent events. The response starts with the first event, so
+// errors found before any result can still get a normal error response.
+type searchStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	format  string
+	started bool
+}
+
+func (s *searchStream) send(event string, data interface{}) error {
+	if !s.started {
+		hdr := s.w.Header()
+		if s.format == searchSSE {
+			hdr.Set("Content-Type", "text/event-stream")
+			hdr.Set("Cache-Control", "no-cache")
+			hdr.Set("X-Accel-Buffering", "no")
+		} else {
+			hdr.Set("Content-Type", "application/x-ndjson")
+		}
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+
+	var err error
+	if s.format == searchSSE {
+		var payload []byte
+		if payload, err = json.Marshal(data); err == nil {
+			_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+		}
+	} else {
+		err = json.NewEncoder(s.w).Encode(map[string]interface{}{"type": event, "data": data})
+	}
+	if err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// streamSearch runs a search, sending a result event per match and a done
+// event with the summary at the end
+func (h *FilesHandler) streamSearch(w http.ResponseWriter, r *http.Request, req files.SearchRequest, format string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		InternalError(w, "streaming not supported", "")
+		return
+	}
+	stream := &searchStream{w: w, flusher: flusher, format: format}
+
+	summary, err := h.fileService.SearchStream(r.Context(), req, func(result files.SearchResult) error {
+		return stream.send("result", result)
+	})
+	if err != nil {
+		if !stream.started {
+			h.handleFileError(w, err)
+			return
+		}
+		if r.Context().Err() == nil {
+			logger.Error("Search failed: %v", err)
+			stream.send("error", map[string]interface{}{"code": apperr.CodeOf(err), "message": err.Error()})
+		}
+		return
+	}
+	stream.send("done", summary)
+}
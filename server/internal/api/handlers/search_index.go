@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/ss497254/gloski/internal/files"
+)
+
+// SearchIndexHandler handles the status and rescans of the search index
+type SearchIndexHandler struct {
+	index *files.SearchIndex
+}
+
+// NewSearchIndexHandler creates a new search index handler
+func NewSearchIndexHandler(index *files.SearchIndex) *SearchIndexHandler {
+	return &SearchIndexHandler{index: index}
+}
+
+// Status handles GET /api/search/index
+func (h *SearchIndexHandler) Status(w http.ResponseWriter, r *http.Request) {
+	Success(w, h.index.Status())
+}
+
+// Rescan handles POST /api/search/index/rescan
+// Body (optional): {"path": "<root>"}; without a path every root is rescanned.
+// The scan is queued; follow its progress in the status.
+func (h *SearchIndexHandler) Rescan(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		BadRequest(w, "invalid request body")
+		return
+	}
+
+	if err := h.index.Rescan(req.Path); err != nil {
+		Fail(w, err, "failed to start rescan")
+		return
+	}
+	Success(w, map[string]string{"status": "queued"})
+}
@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"net/http"
 	"runtime"
-	"strconv"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -132,7 +131,7 @@ func (h *SystemHandler) Status(w http.ResponseWriter, r *http.Request) {
 func (h *SystemHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	stats, err := h.systemService.GetStats()
 	if err != nil {
-		InternalError(w, "failed to get system stats", err.Error())
+		Fail(w, err, "failed to get system stats")
 		return
 	}
 	Success(w, stats)
@@ -142,7 +141,7 @@ func (h *SystemHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 func (h *SystemHandler) GetInfo(w http.ResponseWriter, r *http.Request) {
 	info, err := h.systemService.GetServerInfo()
 	if err != nil {
-		InternalError(w, "failed to get server info", err.Error())
+		Fail(w, err, "failed to get server info")
 		return
 	}
 
@@ -155,21 +154,24 @@ func (h *SystemHandler) GetInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetProcesses handles GET /api/system/processes
+// Query params: name (glob), status (process state), sort (rss, vsz, pid, name, state),
+// order, limit, cursor
 func (h *SystemHandler) GetProcesses(w http.ResponseWriter, r *http.Request) {
-	limit := 100
-	if n := r.URL.Query().Get("limit"); n != "" {
-		if parsed, err := strconv.Atoi(n); err == nil && parsed > 0 {
-			limit = parsed
-		}
+	filter, params, ok := parseListQuery(w, r, system.ProcessListSpec)
+	if !ok {
+		return
 	}
 
-	processes, err := h.systemService.GetProcesses(limit)
+	processes, meta, err := h.systemService.GetProcesses(filter, params)
 	if err != nil {
-		InternalError(w, "failed to get processes", err.Error())
+		Fail(w, err, "failed to get processes")
 		return
 	}
 
-	Success(w, map[string]interface{}{"processes": processes})
+	Success(w, map[string]interface{}{
+		"processes": processes,
+		"meta":      meta,
+	})
 }
 
 // GetStatsHistory handles GET /api/system/stats/history
@@ -237,7 +239,7 @@ func (h *SystemHandler) StatsWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !authenticated {
-		http.Error(w, "invalid or missing authentication", http.StatusUnauthorized)
+		Unauthorized(w, "invalid or missing authentication")
 		return
 	}
 
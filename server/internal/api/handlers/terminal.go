@@ -8,6 +8,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/ss497254/gloski/internal/auth"
 	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/events"
 	"github.com/ss497254/gloski/internal/logger"
 	"github.com/ss497254/gloski/internal/terminal"
 )
@@ -23,6 +24,7 @@ type TerminalHandler struct {
 	config      *config.Config
 	authService *auth.Service
 	sessions    sync.Map // map[string]*terminal.Terminal - active terminal sessions
+	events      *events.Bus
 }
 
 // NewTerminalHandler creates a new terminal handler
@@ -33,6 +35,17 @@ func NewTerminalHandler(cfg *config.Config, authService *auth.Service) *Terminal
 	}
 }
 
+// SetEventBus sets the bus terminal session events are published on
+func (h *TerminalHandler) SetEventBus(bus *events.Bus) {
+	h.events = bus
+}
+
+// terminalEvent is published when a terminal session opens or closes
+type terminalEvent struct {
+	SessionID string `json:"session_id"`
+	Cwd       string `json:"cwd,omitempty"`
+}
+
 // Shutdown closes all active terminal sessions
 func (h *TerminalHandler) Shutdown() {
 	h.sessions.Range(func(key, value interface{}) bool {
@@ -75,7 +88,7 @@ func (h *TerminalHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !authenticated {
-		http.Error(w, "invalid or missing authentication", http.StatusUnauthorized)
+		Unauthorized(w, "invalid or missing authentication")
 		return
 	}
 
@@ -98,6 +111,7 @@ func (h *TerminalHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// Track the session
 	h.sessions.Store(sessionID, term)
 	logger.Debug("Terminal session started: %s", sessionID)
+	h.events.Publish(events.TopicTerminalOpened, terminalEvent{SessionID: sessionID, Cwd: cwd})
 
 	// Run terminal (blocks until closed)
 	term.Run()
@@ -105,4 +119,5 @@ func (h *TerminalHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	// Remove from tracking
 	h.sessions.Delete(sessionID)
 	logger.Debug("Terminal session ended: %s", sessionID)
+	h.events.Publish(events.TopicTerminalClosed, terminalEvent{SessionID: sessionID, Cwd: cwd})
 }
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/files"
+)
+
+// TrashHandler handles listing, restoring and purging trashed files
+type TrashHandler struct {
+	trash *files.Trash
+}
+
+// NewTrashHandler creates a new trash handler
+func NewTrashHandler(trash *files.Trash) *TrashHandler {
+	return &TrashHandler{trash: trash}
+}
+
+// RestoreRequest represents a request to restore a trashed item
+type RestoreRequest struct {
+	Destination string `json:"destination,omitempty"` // Default: the original path
+}
+
+// List handles GET /api/trash
+// Query params: type (file, directory), name (glob), min_size, max_size,
+// created_after, created_before (deletion time), sort (deleted_at, name,
+// size), order, limit, cursor
+func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter, params, ok := parseListQuery(w, r, files.TrashListSpec)
+	if !ok {
+		return
+	}
+
+	items, meta, err := h.trash.List(filter, params)
+	if err != nil {
+		Fail(w, err, "failed to list trash")
+		return
+	}
+	Success(w, map[string]interface{}{
+		"items": items,
+		"meta":  meta,
+	})
+}
+
+// Get handles GET /api/trash/{id}
+func (h *TrashHandler) Get(w http.ResponseWriter, r *http.Request) {
+	item, err := h.trash.Get(r.PathValue("id"))
+	if err != nil {
+		Fail(w, err, "failed to get trash item")
+		return
+	}
+	Success(w, item)
+}
+
+// Restore handles POST /api/trash/{id}/restore
+// The body is optional; without a destination the item goes back where it was.
+func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		BadRequest(w, "invalid request body")
+		return
+	}
+
+	item, err := h.trash.Restore(r.PathValue("id"), req.Destination)
+	if err != nil {
+		Fail(w, err, "failed to restore trash item")
+		return
+	}
+	Success(w, item)
+}
+
+// Purge handles DELETE /api/trash/{id}
+func (h *TrashHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	if err := h.trash.Purge(r.PathValue("id")); err != nil {
+		Fail(w, err, "failed to purge trash item")
+		return
+	}
+	Success(w, map[string]string{"status": "deleted"})
+}
+
+// Empty handles DELETE /api/trash
+// Query params: older_than_days (only purge items deleted longer ago; default: all)
+func (h *TrashHandler) Empty(w http.ResponseWriter, r *http.Request) {
+	var before time.Time
+	if v := r.URL.Query().Get("older_than_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, "older_than_days must be a non-negative integer", nil)
+			return
+		}
+		before = time.Now().AddDate(0, 0, -days)
+	}
+
+	purged, err := h.trash.Empty(before)
+	if err != nil {
+		Fail(w, err, "failed to empty trash")
+		return
+	}
+	Success(w, map[string]int{"purged": purged})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/files"
+)
+
+// DiskUsageHandler handles disk usage scans and queries of the cached results
+type DiskUsageHandler struct {
+	usage *files.DiskUsage
+}
+
+// NewDiskUsageHandler creates a new disk usage handler
+func NewDiskUsageHandler(usage *files.DiskUsage) *DiskUsageHandler {
+	return &DiskUsageHandler{usage: usage}
+}
+
+// Scan handles POST /api/files/usage/scan
+// The scan runs as a background file operation; poll it or follow
+// files.operation events. Scanning a directory inside a cached tree refreshes
+// just that directory.
+func (h *DiskUsageHandler) Scan(w http.ResponseWriter, r *http.Request) {
+	var req files.UsageScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+
+	op, err := h.usage.Scan(req)
+	if err != nil {
+		Fail(w, err, "failed to start disk usage scan")
+		return
+	}
+	Success(w, op)
+}
+
+// Tree handles GET /api/files/usage
+// Query params: path (required; a scanned directory or one inside it),
+// depth (levels of children, default 1), limit (children per level, max
+// 100), sort (size or allocated)
+func (h *DiskUsageHandler) Tree(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	path := query.Get("path")
+	if path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+	depth, ok := intParam(w, query.Get("depth"), "depth", 1)
+	if !ok {
+		return
+	}
+	limit, ok := intParam(w, query.Get("limit"), "limit", files.MaxUsageLimit)
+	if !ok {
+		return
+	}
+
+	tree, err := h.usage.Tree(path, depth, limit, query.Get("sort"))
+	if err != nil {
+		Fail(w, err, "failed to get disk usage")
+		return
+	}
+	Success(w, tree)
+}
+
+// Top handles GET /api/files/usage/top
+// Query params: path (required), type (directory, default, or file), limit
+// (default 20, max 100), sort (size or allocated)
+func (h *DiskUsageHandler) Top(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	path := query.Get("path")
+	if path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+	fileType := query.Get("type")
+	if fileType == "" {
+		fileType = "directory"
+	}
+	limit, ok := intParam(w, query.Get("limit"), "limit", 20)
+	if !ok {
+		return
+	}
+
+	entries, err := h.usage.Top(path, fileType, limit, query.Get("sort"))
+	if err != nil {
+		Fail(w, err, "failed to get largest entries")
+		return
+	}
+	Success(w, map[string]interface{}{"entries": entries})
+}
+
+// Cached handles GET /api/files/usage/cached
+func (h *DiskUsageHandler) Cached(w http.ResponseWriter, r *http.Request) {
+	Success(w, map[string]interface{}{"trees": h.usage.Cached()})
+}
+
+// Forget handles DELETE /api/files/usage
+// Query params: path (required; the root of a cached scan)
+func (h *DiskUsageHandler) Forget(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		BadRequest(w, "path is required")
+		return
+	}
+	if err := h.usage.Forget(path); err != nil {
+		Fail(w, err, "failed to delete disk usage scan")
+		return
+	}
+	Success(w, map[string]string{"status": "deleted"})
+}
+
+// intParam parses a non-negative integer query parameter, writing an error
+// response if it's invalid
+func intParam(w http.ResponseWriter, value, name string, def int) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, name+" must be a non-negative integer", nil)
+		return 0, false
+	}
+	return n, true
+}
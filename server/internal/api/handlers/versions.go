@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// VersionsHandler reports the API versions this server supports (public)
+type VersionsHandler struct {
+	current   string
+	supported []string
+	sunset    time.Time
+}
+
+// NewVersionsHandler creates a new versions handler
+func NewVersionsHandler(current string, supported []string, sunset time.Time) *VersionsHandler {
+	return &VersionsHandler{
+		current:   current,
+		supported: supported,
+		sunset:    sunset,
+	}
+}
+
+// VersionsResponse describes the available API versions
+type VersionsResponse struct {
+	Current      string     `json:"current"`
+	Supported    []string   `json:"supported"`
+	LegacySunset *time.Time `json:"legacy_sunset,omitempty"`
+}
+
+// List handles GET /api/versions
+func (h *VersionsHandler) List(w http.ResponseWriter, r *http.Request) {
+	resp := VersionsResponse{
+		Current:   h.current,
+		Supported: h.supported,
+	}
+	if !h.sunset.IsZero() {
+		resp.LegacySunset = &h.sunset
+	}
+	Success(w, resp)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/auth"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+// watchMaxDirs is how many directories one connection may watch
+const watchMaxDirs = 32
+
+// WatchHandler streams filesystem changes in watched directories over WebSocket
+type WatchHandler struct {
+	fileService *files.Service
+	authService *auth.Service
+}
+
+// NewWatchHandler creates a new watch handler
+func NewWatchHandler(fileService *files.Service, authService *auth.Service) *WatchHandler {
+	return &WatchHandler{
+		fileService: fileService,
+		authService: authService,
+	}
+}
+
+// watchRequest is a control message sent by clients
+type watchRequest struct {
+	Action string   `json:"action"` // "watch" or "unwatch"
+	Paths  []string `json:"paths"`
+}
+
+// watchMessage is sent to clients: acknowledgements ("watch", "unwatch"),
+// per-path failures ("error") and batches of changes ("events")
+type watchMessage struct {
+	Type   string              `json:"type"`
+	Path   string              `json:"path,omitempty"`
+	Error  *response.ErrorBody `json:"error,omitempty"`
+	Events []files.WatchEvent  `json:"events,omitempty"`
+}
+
+// Handle handles GET /api/files/watch (WebSocket upgrade)
+//
+// Directories are given as repeated ?path= parameters and changed later with
+// {"action": "watch"|"unwatch", "paths": [...]} messages. Changes made by any
+// process are reported as debounced batches of create, modify, delete and
+// rename events. Auth is accepted from headers or the api_key/token query
+// parameters.
+func (h *WatchHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if _, err := authenticateStream(h.authService, r); err != nil {
+		Unauthorized(w, "invalid or missing authentication")
+		return
+	}
+	if !websocket.IsWebSocketUpgrade(r) {
+		BadRequest(w, "websocket upgrade required")
+		return
+	}
+
+	watch, err := h.fileService.NewWatch(watchMaxDirs)
+	if err != nil {
+		Fail(w, err, "failed to start watching")
+		return
+	}
+	defer watch.Close()
+
+	conn, err := eventsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Error("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Replies from the reader go through the writer loop, the only goroutine
+	// allowed to write to the connection
+	replies := make(chan watchMessage, 64)
+	stop := make(chan struct{})
+	defer close(stop)
+	reply := func(msg watchMessage) {
+		select {
+		case replies <- msg:
+		case <-stop:
+		}
+	}
+	apply := func(action string, paths []string) {
+		for _, path := range paths {
+			msg := watchMessage{Type: action, Path: path}
+			var err error
+			if action == "watch" {
+				msg.Path, err = watch.Add(path)
+			} else {
+				err = watch.Remove(path)
+			}
+			if err != nil {
+				_, body := response.Describe(err, "failed to "+action+" directory")
+				msg = watchMessage{Type: "error", Path: path, Error: &body}
+			}
+			reply(msg)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		apply("watch", r.URL.Query()["path"])
+
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		conn.SetPongHandler(func(string) error {
+			conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+			return nil
+		})
+		for {
+			var req watchRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					logger.Debug("Watch WebSocket error: %v", err)
+				}
+				return
+			}
+			switch req.Action {
+			case "watch", "unwatch":
+				apply(req.Action, req.Paths)
+			default:
+				_, body := response.Describe(apperr.New(apperr.CodeBadRequest, "action must be watch or unwatch"), "")
+				reply(watchMessage{Type: "error", Error: &body})
+			}
+		}
+	}()
+
+	write := func(v interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteJSON(v)
+	}
+
+	ping := time.NewTicker(54 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-replies:
+			if err := write(msg); err != nil {
+				return
+			}
+		case batch, ok := <-watch.Events():
+			if !ok {
+				return
+			}
+			if err := write(watchMessage{Type: "events", Events: batch}); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
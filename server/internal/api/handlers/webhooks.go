@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ss497254/gloski/internal/webhooks"
+)
+
+// WebhooksHandler handles webhook subscription and delivery requests
+type WebhooksHandler struct {
+	service *webhooks.Service
+}
+
+// NewWebhooksHandler creates a new webhooks handler
+func NewWebhooksHandler(service *webhooks.Service) *WebhooksHandler {
+	return &WebhooksHandler{service: service}
+}
+
+// List handles GET /api/webhooks
+func (h *WebhooksHandler) List(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.service.List()
+	if err != nil {
+		Fail(w, err, "failed to list webhooks")
+		return
+	}
+	Success(w, map[string]interface{}{"webhooks": hooks})
+}
+
+// Create handles POST /api/webhooks
+// The response includes the signing secret, which is not returned again.
+func (h *WebhooksHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req webhooks.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+	if req.URL == "" {
+		BadRequest(w, "url is required")
+		return
+	}
+
+	hook, err := h.service.Create(req)
+	if err != nil {
+		Fail(w, err, "failed to create webhook")
+		return
+	}
+	Success(w, hook)
+}
+
+// Get handles GET /api/webhooks/{id}
+func (h *WebhooksHandler) Get(w http.ResponseWriter, r *http.Request) {
+	hook, err := h.service.Get(r.PathValue("id"))
+	if err != nil {
+		Fail(w, err, "failed to get webhook")
+		return
+	}
+	Success(w, hook)
+}
+
+// Update handles PUT /api/webhooks/{id}
+func (h *WebhooksHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req webhooks.UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		BadRequest(w, "invalid request body")
+		return
+	}
+
+	hook, err := h.service.Update(r.PathValue("id"), req)
+	if err != nil {
+		Fail(w, err, "failed to update webhook")
+		return
+	}
+	Success(w, hook)
+}
+
+// Delete handles DELETE /api/webhooks/{id}
+func (h *WebhooksHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Delete(r.PathValue("id")); err != nil {
+		Fail(w, err, "failed to delete webhook")
+		return
+	}
+	Success(w, map[string]string{"status": "deleted"})
+}
+
+// Ping handles POST /api/webhooks/{id}/ping
+func (h *WebhooksHandler) Ping(w http.ResponseWriter, r *http.Request) {
+	delivery, err := h.service.Ping(r.PathValue("id"))
+	if err != nil {
+		Fail(w, err, "failed to ping webhook")
+		return
+	}
+	Success(w, delivery)
+}
+
+// Deliveries handles GET /api/webhooks/{id}/deliveries
+// Query params: status, name (glob on topic), created_after, created_before,
+// sort (created_at, status, topic, attempts), order, limit, cursor
+func (h *WebhooksHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	filter, params, ok := parseListQuery(w, r, webhooks.DeliveryListSpec)
+	if !ok {
+		return
+	}
+
+	deliveries, meta, err := h.service.Deliveries(r.PathValue("id"), filter, params)
+	if err != nil {
+		Fail(w, err, "failed to list deliveries")
+		return
+	}
+	Success(w, map[string]interface{}{
+		"deliveries": deliveries,
+		"meta":       meta,
+	})
+}
+
+// Redeliver handles POST /api/webhooks/{id}/deliveries/{delivery}/redeliver
+func (h *WebhooksHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	delivery, err := h.service.Redeliver(r.PathValue("id"), r.PathValue("delivery"))
+	if err != nil {
+		Fail(w, err, "failed to redeliver")
+		return
+	}
+	Success(w, delivery)
+}
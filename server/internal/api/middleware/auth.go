@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/apperr"
 	"github.com/ss497254/gloski/internal/auth"
 )
 
@@ -15,6 +16,9 @@ type contextKey string
 const (
 	// UserContextKey is the context key for authenticated user info
 	UserContextKey contextKey = "user"
+
+	// IdentityContextKey is the context key for the authenticated *auth.Identity
+	IdentityContextKey contextKey = "identity"
 )
 
 // Auth returns a middleware that validates API keys or JWT tokens
@@ -29,24 +33,22 @@ func Auth(authService *auth.Service) func(http.Handler) http.Handler {
 			// Check auth rate limit before processing
 			if !authLimiter.Allow(ip) {
 				w.Header().Set("Retry-After", "60")
-				response.Error(w, http.StatusTooManyRequests, "too many authentication attempts")
+				response.ErrorWithCode(w, http.StatusTooManyRequests, apperr.CodeRateLimited, "too many authentication attempts", nil)
 				return
 			}
 
 			// Try API key first
 			if apiKey := extractAPIKey(r); apiKey != "" {
 				if err := authService.ValidateAPIKey(apiKey); err == nil {
-					ctx := context.WithValue(r.Context(), UserContextKey, "api_key")
-					next.ServeHTTP(w, r.WithContext(ctx))
+					next.ServeHTTP(w, r.WithContext(withIdentity(r.Context(), &auth.Identity{Method: auth.MethodAPIKey})))
 					return
 				}
 			}
 
 			// Try JWT token
 			if token := extractToken(r); token != "" {
-				if err := authService.ValidateToken(token); err == nil {
-					ctx := context.WithValue(r.Context(), UserContextKey, "jwt")
-					next.ServeHTTP(w, r.WithContext(ctx))
+				if identity, err := authService.ParseToken(token); err == nil {
+					next.ServeHTTP(w, r.WithContext(withIdentity(r.Context(), identity)))
 					return
 				}
 			}
@@ -56,6 +58,17 @@ func Auth(authService *auth.Service) func(http.Handler) http.Handler {
 	}
 }
 
+func withIdentity(ctx context.Context, identity *auth.Identity) context.Context {
+	ctx = context.WithValue(ctx, UserContextKey, identity.Method)
+	return context.WithValue(ctx, IdentityContextKey, identity)
+}
+
+// IdentityFromContext returns the identity authenticated for the request
+func IdentityFromContext(ctx context.Context) *auth.Identity {
+	identity, _ := ctx.Value(IdentityContextKey).(*auth.Identity)
+	return identity
+}
+
 // extractAPIKey extracts API key from request
 func extractAPIKey(r *http.Request) string {
 	// Check X-API-Key header first
@@ -7,6 +7,7 @@ import (
 	"sync/atomic"
 
 	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/apperr"
 )
 
 // Default JSON body limit (1MB)
@@ -62,7 +63,9 @@ func HandleMaxBytesError(w http.ResponseWriter, err error) bool {
 	if err != nil {
 		var maxBytesErr *http.MaxBytesError
 		if errors.As(err, &maxBytesErr) {
-			response.Error(w, http.StatusRequestEntityTooLarge, "request body too large")
+			response.ErrorWithCode(w, http.StatusRequestEntityTooLarge, apperr.CodePayloadTooLarge, "request body too large", map[string]int64{
+				"limit": maxBytesErr.Limit,
+			})
 			return true
 		}
 	}
@@ -0,0 +1,347 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionConfig holds response compression middleware configuration
+type CompressionConfig struct {
+	Level   int // 1 (fastest) to 9 (smallest)
+	MinSize int // Responses smaller than this are sent uncompressed
+}
+
+// DefaultCompressionConfig returns a default compression configuration
+func DefaultCompressionConfig() CompressionConfig {
+	return CompressionConfig{
+		Level:   5,
+		MinSize: 1024,
+	}
+}
+
+// compressibleTypes lists content type prefixes worth compressing.
+// Everything else (images, video, archives, octet-stream) is already dense
+// or opaque and is passed through untouched.
+var compressibleTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/x-ndjson",
+	"image/svg+xml",
+	"text/",
+}
+
+// Compress returns a middleware that compresses responses with zstd or gzip
+// depending on the client's Accept-Encoding header.
+func Compress(config CompressionConfig) Middleware {
+	if config.Level < 1 || config.Level > 9 {
+		config.Level = DefaultCompressionConfig().Level
+	}
+	if config.MinSize < 0 {
+		config.MinSize = 0
+	}
+
+	gzipPool := &sync.Pool{
+		New: func() interface{} {
+			zw, _ := gzip.NewWriterLevel(io.Discard, config.Level)
+			return zw
+		},
+	}
+	zstdPool := &sync.Pool{
+		New: func() interface{} {
+			zw, _ := zstd.NewWriter(io.Discard,
+				zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(config.Level)),
+				zstd.WithEncoderConcurrency(1),
+			)
+			return zw
+		},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			// Range responses must map to the identity representation and
+			// WebSocket upgrades need the raw connection
+			if r.Method == http.MethodHead || r.Header.Get("Range") != "" || isWebSocketUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				minSize:        config.MinSize,
+				gzipPool:       gzipPool,
+				zstdPool:       zstdPool,
+			}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the best supported encoding from an Accept-Encoding header.
+// zstd is preferred over gzip when both are accepted with the same quality.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, q := parseEncodingQuality(part)
+		if q <= 0 {
+			continue
+		}
+		switch name {
+		case "zstd":
+			if q > bestQ || (q == bestQ && best != "zstd") {
+				best, bestQ = "zstd", q
+			}
+		case "gzip", "*":
+			if q > bestQ {
+				best, bestQ = "gzip", q
+			}
+		}
+	}
+	return best
+}
+
+// parseEncodingQuality parses a single "name;q=0.5" Accept-Encoding element
+func parseEncodingQuality(part string) (string, float64) {
+	name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+	name = strings.ToLower(strings.TrimSpace(name))
+	q := 1.0
+	if params != "" {
+		for _, p := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if ok && strings.EqualFold(k, "q") {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+	}
+	return name, q
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// body is large enough and of a type worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	gzipPool *sync.Pool
+	zstdPool *sync.Pool
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	encoder     io.WriteCloser
+	hijacked    bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = code
+
+	// Informational and bodiless responses go straight through
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	// Content-Length known up front lets us decide without buffering
+	if cl := cw.Header().Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < cw.minSize {
+			cw.decide(false)
+			return cw.ResponseWriter.Write(p)
+		}
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.flushBuffer(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide writes the response header, enabling compression if appropriate
+func (cw *compressWriter) decide(sizeOK bool) {
+	cw.decided = true
+
+	if sizeOK && cw.shouldCompress() {
+		h := cw.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+
+		switch cw.encoding {
+		case "zstd":
+			zw := cw.zstdPool.Get().(*zstd.Encoder)
+			zw.Reset(cw.ResponseWriter)
+			cw.encoder = zw
+		default:
+			zw := cw.gzipPool.Get().(*gzip.Writer)
+			zw.Reset(cw.ResponseWriter)
+			cw.encoder = zw
+		}
+	}
+
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// shouldCompress checks the response headers for content that must not be re-encoded
+func (cw *compressWriter) shouldCompress() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if cw.status == http.StatusPartialContent {
+		return false
+	}
+	// File downloads advertise byte ranges and a strong ETag of the file
+	// itself, which a compressed body would no longer match
+	if h.Get("Accept-Ranges") != "" {
+		return false
+	}
+
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf)
+	}
+	contentType = strings.ToLower(contentType)
+
+	// Server-sent events must reach the client unbuffered
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cw *compressWriter) flushBuffer(sizeOK bool) error {
+	cw.decide(sizeOK)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return err
+}
+
+// Flush implements http.Flusher. Flushing before the size threshold is reached
+// sends whatever is buffered uncompressed.
+func (cw *compressWriter) Flush() {
+	if cw.hijacked {
+		return
+	}
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		cw.flushBuffer(len(cw.buf) >= cw.minSize)
+	}
+	if f, ok := cw.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker interface for WebSocket support
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		cw.hijacked = true
+		return hijacker.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close flushes any buffered data and returns the encoder to its pool
+func (cw *compressWriter) Close() error {
+	if cw.hijacked {
+		return nil
+	}
+	if !cw.decided {
+		if !cw.wroteHeader {
+			// Handler wrote nothing at all
+			return nil
+		}
+		if err := cw.flushBuffer(len(cw.buf) >= cw.minSize); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	switch zw := cw.encoder.(type) {
+	case *gzip.Writer:
+		zw.Reset(io.Discard)
+		cw.gzipPool.Put(zw)
+	case *zstd.Encoder:
+		zw.Reset(io.Discard)
+		cw.zstdPool.Put(zw)
+	}
+	cw.encoder = nil
+	return err
+}
@@ -94,3 +94,10 @@ func (w *hijackableResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error)
 	}
 	return nil, nil, http.ErrNotSupported
 }
+
+// Flush implements http.Flusher for streaming responses
+func (w *hijackableResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/idempotency"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client-chosen key for a mutating request
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader marks responses replayed from a previous request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+
+	// maxIdempotentResponseSize caps stored responses; larger ones are not replayable
+	maxIdempotentResponseSize = 1 * 1024 * 1024
+
+	idempotencyPruneInterval = time.Hour
+)
+
+// replayedHeaders lists the response headers stored and replayed with a response
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// IdempotencyConfig holds idempotency middleware configuration
+type IdempotencyConfig struct {
+	Store *idempotency.Store
+	TTL   time.Duration // How long responses are kept for replay
+}
+
+// Idempotency returns a middleware that makes POST requests carrying an
+// Idempotency-Key header safe to retry.
+//
+// The first response for a key is stored for config.TTL and replayed for
+// repeats of the same request, marked with Idempotent-Replayed: true. Reusing
+// a key with a different method, path or body is rejected with 422, and a
+// repeat arriving while the first request is still running gets 409. Server
+// errors and rate limiting responses are not stored, so those can be retried.
+// Keys are scoped to the authenticated identity, so the middleware must run
+// after Auth.
+func Idempotency(config IdempotencyConfig) Middleware {
+	var (
+		mu        sync.Mutex
+		inFlight  = make(map[string]struct{})
+		lastPrune time.Time
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				response.ErrorWithCode(w, http.StatusBadRequest, apperr.CodeInvalidParameter, "idempotency key too long", map[string]int{
+					"max_length": maxIdempotencyKeyLength,
+				})
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, jsonBodyLimit.Load()))
+			if err != nil {
+				if !HandleMaxBytesError(w, err) {
+					response.BadRequest(w, "failed to read request body")
+				}
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			scope := idempotencyScope(r)
+			lockKey := scope + "\x00" + key
+
+			mu.Lock()
+			if _, busy := inFlight[lockKey]; busy {
+				mu.Unlock()
+				response.ErrorWithCode(w, http.StatusConflict, apperr.CodeIdempotencyKeyInProgress,
+					"a request with this idempotency key is still in progress", nil)
+				return
+			}
+			inFlight[lockKey] = struct{}{}
+			prune := now.Sub(lastPrune) >= idempotencyPruneInterval
+			if prune {
+				lastPrune = now
+			}
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				delete(inFlight, lockKey)
+				mu.Unlock()
+			}()
+
+			if prune {
+				go func() {
+					if _, err := config.Store.Prune(now); err != nil {
+						logger.Error("Failed to prune idempotency keys: %v", err)
+					}
+				}()
+			}
+
+			fingerprint := requestFingerprint(r, body)
+
+			rec, err := config.Store.Get(scope, key, now)
+			if err != nil {
+				response.InternalError(w, "failed to look up idempotency key", err.Error())
+				return
+			}
+			if rec != nil {
+				if rec.Fingerprint != fingerprint {
+					response.ErrorWithCode(w, http.StatusUnprocessableEntity, apperr.CodeIdempotencyKeyReused,
+						"idempotency key was already used for a different request", nil)
+					return
+				}
+				replayResponse(w, rec)
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			var out http.ResponseWriter = rw
+			if response.IsEnveloped(w) {
+				out = response.WithEnvelope(rw)
+			}
+			next.ServeHTTP(out, r)
+
+			if rw.overflow || rw.status >= http.StatusInternalServerError || rw.status == http.StatusTooManyRequests {
+				return
+			}
+
+			header := http.Header{}
+			for _, name := range replayedHeaders {
+				if v := w.Header().Values(name); len(v) > 0 {
+					header[name] = v
+				}
+			}
+			err = config.Store.Put(&idempotency.Record{
+				Scope:       scope,
+				Key:         key,
+				Fingerprint: fingerprint,
+				StatusCode:  rw.status,
+				Header:      header,
+				Body:        rw.body.Bytes(),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(config.TTL),
+			})
+			if err != nil {
+				logger.Error("Failed to store idempotent response: %v", err)
+			}
+		})
+	}
+}
+
+// idempotencyScope identifies the caller so keys from different callers never collide
+func idempotencyScope(r *http.Request) string {
+	identity := IdentityFromContext(r.Context())
+	if identity == nil {
+		return ""
+	}
+	return identity.Method + ":" + identity.Subject
+}
+
+// requestFingerprint hashes everything that makes two requests "the same"
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, APIVersionFromContext(r.Context())} {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replayResponse(w http.ResponseWriter, rec *idempotency.Record) {
+	for name, values := range rec.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(rec.StatusCode)
+	w.Write(rec.Body)
+}
+
+// recordingWriter passes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if !w.overflow {
+		if w.body.Len()+len(b) > maxIdempotentResponseSize {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -28,6 +28,13 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return nil, nil, http.ErrNotSupported
 }
 
+// Flush implements http.Flusher for streaming responses
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // Logging returns a middleware that logs HTTP requests
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,6 +48,6 @@ func Logging(next http.Handler) http.Handler {
 
 		// Log request details
 		duration := time.Since(start)
-		logger.Debug("%s %s %d %v", r.Method, r.URL.Path, wrapped.status, duration)
+		logger.Debug("%s %s %d %v [%s]", r.Method, r.URL.Path, wrapped.status, duration, RequestIDFromContext(r.Context()))
 	})
 }
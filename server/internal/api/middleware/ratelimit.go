@@ -2,8 +2,12 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/apperr"
 )
 
 // RateLimiter implements a simple token bucket rate limiter.
@@ -103,8 +107,11 @@ func RateLimit(rate int, window time.Duration) func(http.Handler) http.Handler {
 			ip := getClientIP(r)
 
 			if !limiter.Allow(ip) {
-				w.Header().Set("Retry-After", "60")
-				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+				w.Header().Set("Retry-After", strconv.Itoa(int(window.Seconds())))
+				response.ErrorWithCode(w, http.StatusTooManyRequests, apperr.CodeRateLimited, "rate limit exceeded", map[string]interface{}{
+					"limit":          rate,
+					"window_seconds": int(window.Seconds()),
+				})
 				return
 			}
 
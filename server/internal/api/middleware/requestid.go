@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ss497254/gloski/internal/api/response"
+)
+
+const (
+	// RequestIDContextKey is the context key for the request ID
+	RequestIDContextKey contextKey = "request_id"
+
+	maxRequestIDLength = 128
+)
+
+// RequestID returns a middleware that assigns every request an ID.
+// A well-formed incoming X-Request-ID is reused so IDs can be correlated
+// across proxies; otherwise a new one is generated. The ID is echoed in the
+// response header and included in error bodies.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(response.RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(response.RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), RequestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDContextKey).(string)
+	return id
+}
+
+// validRequestID accepts short IDs made of URL-safe characters only,
+// so client-supplied values can't inject into headers or logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
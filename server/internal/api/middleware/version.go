@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+const (
+	// APIVersionHeader is the request/response header used to negotiate the API version
+	APIVersionHeader = "X-API-Version"
+
+	// CurrentAPIVersion is the newest stable API version
+	CurrentAPIVersion = "1"
+
+	// APIVersionContextKey is the context key for the resolved API version.
+	// Legacy (unversioned) requests resolve to an empty string.
+	APIVersionContextKey contextKey = "api_version"
+)
+
+// SupportedAPIVersions lists all API versions served under /api/v{N}
+var SupportedAPIVersions = []string{"1"}
+
+// vendorMediaType matches Accept: application/vnd.gloski.v1+json
+var vendorMediaType = regexp.MustCompile(`application/vnd\.gloski\.v(\d+)\+json`)
+
+// VersionConfig holds API versioning middleware configuration
+type VersionConfig struct {
+	// Prefix is the external API prefix (as configured by api_prefix), used
+	// to build successor links for legacy routes
+	Prefix string
+
+	// Deprecation is when unversioned /api routes were deprecated
+	Deprecation time.Time
+
+	// Sunset is when unversioned /api routes may stop working (zero = not announced)
+	Sunset time.Time
+}
+
+// APIVersion returns a middleware that routes /api/v{N}/... requests onto the
+// shared /api/... handlers and negotiates the response shape.
+//
+// A version is selected by path (/api/v1/files), the X-API-Version header, or
+// an Accept: application/vnd.gloski.v1+json media type. Versioned responses
+// always use the {success, data} envelope. Unversioned requests keep the
+// legacy response shapes and are marked with Deprecation/Sunset headers.
+func APIVersion(config VersionConfig) Middleware {
+	prefix := ""
+	if config.Prefix != "" {
+		prefix = "/" + strings.Trim(config.Prefix, "/")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			version, rest, fromPath := versionFromPath(r.URL.Path)
+			if !fromPath {
+				version = negotiatedVersion(r)
+			}
+
+			if version != "" && !isSupportedVersion(version) {
+				response.ErrorWithCode(w, http.StatusBadRequest, apperr.CodeUnsupportedVersion,
+					fmt.Sprintf("unsupported API version %q", version),
+					map[string]interface{}{"supported": SupportedAPIVersions})
+				return
+			}
+
+			if fromPath {
+				r.URL.Path = "/api" + rest
+				if r.URL.RawPath != "" {
+					_, rawRest, _ := versionFromPath(r.URL.RawPath)
+					r.URL.RawPath = "/api" + rawRest
+				}
+			}
+
+			w.Header().Add("Vary", APIVersionHeader)
+
+			if version == "" {
+				// Legacy route: keep the old response shapes but announce the successor
+				setDeprecationHeaders(w, config, prefix, r.URL.Path)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(APIVersionHeader, version)
+			ctx := context.WithValue(r.Context(), APIVersionContextKey, version)
+			next.ServeHTTP(response.WithEnvelope(w), r.WithContext(ctx))
+		})
+	}
+}
+
+// versionFromPath extracts the version from /api/v{N}/rest paths
+func versionFromPath(path string) (version, rest string, ok bool) {
+	after, found := strings.CutPrefix(path, "/api/v")
+	if !found {
+		return "", path, false
+	}
+
+	end := 0
+	for end < len(after) && after[end] >= '0' && after[end] <= '9' {
+		end++
+	}
+	if end == 0 || (end < len(after) && after[end] != '/') {
+		return "", path, false
+	}
+
+	return after[:end], after[end:], true
+}
+
+// negotiatedVersion reads the requested version from headers
+func negotiatedVersion(r *http.Request) string {
+	if v := strings.TrimSpace(r.Header.Get(APIVersionHeader)); v != "" {
+		return strings.TrimPrefix(strings.ToLower(v), "v")
+	}
+	if m := vendorMediaType.FindStringSubmatch(r.Header.Get("Accept")); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func isSupportedVersion(version string) bool {
+	for _, v := range SupportedAPIVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// setDeprecationHeaders marks a legacy response as deprecated (RFC 9745 / RFC 8594)
+func setDeprecationHeaders(w http.ResponseWriter, config VersionConfig, prefix, path string) {
+	h := w.Header()
+	if !config.Deprecation.IsZero() {
+		h.Set("Deprecation", fmt.Sprintf("@%d", config.Deprecation.Unix()))
+	} else {
+		h.Set("Deprecation", "true")
+	}
+	if !config.Sunset.IsZero() {
+		h.Set("Sunset", config.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	successor := prefix + "/api/v" + CurrentAPIVersion + strings.TrimPrefix(path, "/api")
+	h.Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+}
+
+// APIVersionFromContext returns the API version resolved for the request
+// (empty for legacy unversioned requests)
+func APIVersionFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(APIVersionContextKey).(string)
+	return v
+}
@@ -1,13 +1,20 @@
 package response
 
 import (
+	"bufio"
 	"encoding/json"
+	"net"
 	"net/http"
+	"os"
 	"sync/atomic"
 
+	"github.com/ss497254/gloski/internal/apperr"
 	"github.com/ss497254/gloski/internal/logger"
 )
 
+// RequestIDHeader carries the request ID set by the RequestID middleware
+const RequestIDHeader = "X-Request-ID"
+
 var detailedErrors atomic.Bool
 
 // SetDetailedErrors configures whether detailed error messages are included in responses
@@ -15,11 +22,17 @@ func SetDetailedErrors(enabled bool) {
 	detailedErrors.Store(enabled)
 }
 
-// Response represents a standard API response
+// Response represents a standard API response.
+// Error responses carry both the legacy "error" string and the structured
+// code/message/details/request_id fields.
 type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success   bool        `json:"success"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Code      apperr.Code `json:"code,omitempty"`
+	Message   string      `json:"message,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
 }
 
 // JSON writes a JSON response with the given status code
@@ -31,8 +44,14 @@ func JSON(w http.ResponseWriter, status int, data interface{}) {
 	}
 }
 
-// Success writes a successful JSON response
+// Success writes a successful JSON response.
+// Versioned API requests always get the {success, data} envelope;
+// legacy requests get the bare data for backwards compatibility.
 func Success(w http.ResponseWriter, data interface{}) {
+	if IsEnveloped(w) {
+		JSON(w, http.StatusOK, Response{Success: true, Data: data})
+		return
+	}
 	JSON(w, http.StatusOK, data)
 }
 
@@ -41,9 +60,67 @@ func SuccessWithMessage(w http.ResponseWriter, data interface{}) {
 	JSON(w, http.StatusOK, Response{Success: true, Data: data})
 }
 
-// Error writes an error JSON response
+// Error writes an error JSON response with the generic code for the status
 func Error(w http.ResponseWriter, status int, message string) {
-	JSON(w, status, Response{Success: false, Error: message})
+	ErrorWithCode(w, status, apperr.CodeForStatus(status), message, nil)
+}
+
+// ErrorWithCode writes an error JSON response with an explicit code and details
+func ErrorWithCode(w http.ResponseWriter, status int, code apperr.Code, message string, details interface{}) {
+	JSON(w, status, Response{
+		Success:   false,
+		Error:     message,
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: w.Header().Get(RequestIDHeader),
+	})
+}
+
+// ErrorBody is the machine-readable part of an error response
+type ErrorBody struct {
+	Code    apperr.Code `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Describe classifies a service error without writing a response.
+// Typed *apperr.Error values keep their code and status, common filesystem
+// errors are classified, and anything else becomes a 500 with context as the
+// message (plus the error itself when detailed errors are enabled).
+func Describe(err error, context string) (int, ErrorBody) {
+	if e, ok := apperr.As(err); ok {
+		message := e.Message
+		if message == "" {
+			message = context
+		}
+		return e.Code.HTTPStatus(), ErrorBody{Code: e.Code, Message: message, Details: e.Details}
+	}
+
+	switch {
+	case os.IsPermission(err):
+		return http.StatusForbidden, ErrorBody{Code: apperr.CodePermissionDenied, Message: "permission denied"}
+	case os.IsNotExist(err):
+		return http.StatusNotFound, ErrorBody{Code: apperr.CodePathNotFound, Message: "path does not exist"}
+	case os.IsExist(err):
+		return http.StatusConflict, ErrorBody{Code: apperr.CodePathExists, Message: "path already exists"}
+	}
+
+	message := context
+	if detailedErrors.Load() {
+		message = context + ": " + err.Error()
+	}
+	return http.StatusInternalServerError, ErrorBody{Code: apperr.CodeInternal, Message: message}
+}
+
+// Fail writes an error response for a service error as classified by
+// Describe. Server errors are logged.
+func Fail(w http.ResponseWriter, err error, context string) {
+	status, body := Describe(err, context)
+	if status >= http.StatusInternalServerError {
+		logger.Error("%s: %v", context, err)
+	}
+	ErrorWithCode(w, status, body.Code, body.Message, body.Details)
 }
 
 // BadRequest writes a 400 error response
@@ -78,3 +155,39 @@ func InternalError(w http.ResponseWriter, context string, detail string) {
 
 	Error(w, http.StatusInternalServerError, message)
 }
+
+// envelopeWriter marks a response as belonging to a versioned API request
+type envelopeWriter struct {
+	http.ResponseWriter
+}
+
+// WithEnvelope wraps a ResponseWriter so Success always uses the {success, data} envelope
+func WithEnvelope(w http.ResponseWriter) http.ResponseWriter {
+	return &envelopeWriter{ResponseWriter: w}
+}
+
+// IsEnveloped reports whether responses written to w use the {success, data} envelope
+func IsEnveloped(w http.ResponseWriter) bool {
+	_, ok := w.(*envelopeWriter)
+	return ok
+}
+
+// Flush implements http.Flusher for streaming responses
+func (w *envelopeWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker interface for WebSocket support
+func (w *envelopeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController
+func (w *envelopeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
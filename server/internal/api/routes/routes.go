@@ -3,6 +3,7 @@ package routes
 import (
 	"database/sql"
 	"net/http"
+	"time"
 
 	"github.com/ss497254/gloski/internal/api/handlers"
 	"github.com/ss497254/gloski/internal/api/middleware"
@@ -11,17 +12,29 @@ import (
 	"github.com/ss497254/gloski/internal/config"
 	"github.com/ss497254/gloski/internal/cron"
 	"github.com/ss497254/gloski/internal/downloads"
+	"github.com/ss497254/gloski/internal/events"
 	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/idempotency"
 	"github.com/ss497254/gloski/internal/jobs"
 	"github.com/ss497254/gloski/internal/packages"
 	"github.com/ss497254/gloski/internal/system"
+	"github.com/ss497254/gloski/internal/webhooks"
 )
 
+// legacyAPIDeprecation is when the unversioned /api routes were superseded by /api/v1
+var legacyAPIDeprecation = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
 // Config holds all dependencies needed for routing
 type Config struct {
 	Cfg         *config.Config
 	AuthService *auth.Service
 	FileService *files.Service
+	FileOps     *files.Operations
+	Trash       *files.Trash
+	History     *files.History
+	DiskUsage   *files.DiskUsage
+	Media       *files.Media
+	SearchIndex *files.SearchIndex
 	JobsService *jobs.Service
 	SysService  *system.Service
 
@@ -33,6 +46,11 @@ type Config struct {
 	CronService     *cron.Service
 	DownloadService *downloads.Service
 
+	// Event bus behind /api/events
+	EventBus *events.Bus
+
+	WebhooksService *webhooks.Service
+
 	// Features map for /api/system/info
 	Features map[string]bool
 
@@ -82,11 +100,28 @@ func Setup(cfg Config) (http.Handler, *RouteHandlers) {
 	// Auth middleware
 	requireAuth := middleware.Auth(cfg.AuthService)
 
+	// Idempotency-Key support for mutating routes. Responses are stored in the
+	// database, so retries are only deduplicated when one is configured.
+	idempotent := middleware.Middleware(func(next http.Handler) http.Handler { return next })
+	if cfg.DB != nil && cfg.Cfg.IdempotencyTTL > 0 {
+		idempotent = middleware.Idempotency(middleware.IdempotencyConfig{
+			Store: idempotency.NewStore(cfg.DB),
+			TTL:   cfg.Cfg.IdempotencyTTLDuration(),
+		})
+	}
+	requireAuthIdempotent := func(h http.HandlerFunc) http.Handler {
+		return requireAuth(idempotent(h))
+	}
+
 	// Health check (public)
 	mux.HandleFunc("GET /api/health", healthHandler.Check)
 	mux.HandleFunc("GET /api/health/ready", healthHandler.Ready)
 	mux.HandleFunc("GET /api/health/live", healthHandler.Live)
 
+	// API version discovery (public)
+	versionsHandler := handlers.NewVersionsHandler(middleware.CurrentAPIVersion, middleware.SupportedAPIVersions, cfg.Cfg.LegacyAPISunsetTime())
+	mux.HandleFunc("GET /api/versions", versionsHandler.List)
+
 	// Auth routes
 	mux.Handle("GET /api/auth/status", requireAuth(http.HandlerFunc(authHandler.Status)))
 
@@ -103,12 +138,76 @@ func Setup(cfg Config) (http.Handler, *RouteHandlers) {
 	// File routes (protected)
 	mux.Handle("GET /api/files", requireAuth(http.HandlerFunc(filesHandler.List)))
 	mux.Handle("GET /api/files/read", requireAuth(http.HandlerFunc(filesHandler.Read)))
-	mux.Handle("POST /api/files/write", requireAuth(http.HandlerFunc(filesHandler.Write)))
-	mux.Handle("POST /api/files/mkdir", requireAuth(http.HandlerFunc(filesHandler.Mkdir)))
-	mux.Handle("POST /api/files/rename", requireAuth(http.HandlerFunc(filesHandler.Rename)))
+	mux.Handle("GET /api/files/read/range", requireAuth(http.HandlerFunc(filesHandler.ReadRange)))
+	mux.Handle("POST /api/files/write", requireAuthIdempotent(filesHandler.Write))
+	mux.Handle("POST /api/files/mkdir", requireAuthIdempotent(filesHandler.Mkdir))
+	mux.Handle("POST /api/files/rename", requireAuthIdempotent(filesHandler.Rename))
+	mux.Handle("POST /api/files/batch", requireAuthIdempotent(filesHandler.Batch))
 	mux.Handle("DELETE /api/files", requireAuth(http.HandlerFunc(filesHandler.Delete)))
 	mux.Handle("POST /api/files/upload", requireAuth(http.HandlerFunc(filesHandler.Upload)))
 	mux.Handle("GET /api/files/download", requireAuth(http.HandlerFunc(filesHandler.Download)))
+	mux.Handle("GET /api/files/archive", requireAuth(http.HandlerFunc(filesHandler.Archive)))
+	mux.Handle("POST /api/files/chmod", requireAuthIdempotent(filesHandler.Chmod))
+	mux.Handle("POST /api/files/chown", requireAuthIdempotent(filesHandler.Chown))
+	mux.Handle("POST /api/files/symlink", requireAuthIdempotent(filesHandler.Symlink))
+	mux.Handle("POST /api/files/link", requireAuthIdempotent(filesHandler.Link))
+	mux.Handle("GET /api/files/xattrs", requireAuth(http.HandlerFunc(filesHandler.ListXattrs)))
+	mux.Handle("POST /api/files/xattrs", requireAuthIdempotent(filesHandler.SetXattr))
+	mux.Handle("DELETE /api/files/xattrs", requireAuth(http.HandlerFunc(filesHandler.RemoveXattr)))
+	mux.Handle("GET /api/files/acl", requireAuth(http.HandlerFunc(filesHandler.GetACL)))
+	mux.Handle("POST /api/files/acl", requireAuthIdempotent(filesHandler.SetACL))
+	mux.Handle("GET /api/files/diff", requireAuth(http.HandlerFunc(filesHandler.Diff)))
+	mux.Handle("POST /api/files/patch", requireAuthIdempotent(filesHandler.Patch))
+
+	// Background copy/move/extract and checksum operations (protected)
+	if cfg.FileOps != nil {
+		fileOpsHandler := handlers.NewFileOperationsHandler(cfg.FileOps)
+		mux.Handle("GET /api/files/operations", requireAuth(http.HandlerFunc(fileOpsHandler.List)))
+		mux.Handle("POST /api/files/operations", requireAuthIdempotent(fileOpsHandler.Start))
+		mux.Handle("GET /api/files/operations/{id}", requireAuth(http.HandlerFunc(fileOpsHandler.Get)))
+		mux.Handle("POST /api/files/operations/{id}/cancel", requireAuthIdempotent(fileOpsHandler.Cancel))
+		mux.Handle("DELETE /api/files/operations/{id}", requireAuth(http.HandlerFunc(fileOpsHandler.Delete)))
+		mux.Handle("POST /api/files/checksum", requireAuthIdempotent(fileOpsHandler.Checksum))
+		mux.Handle("POST /api/files/checksum/verify", requireAuthIdempotent(fileOpsHandler.VerifyChecksums))
+	}
+
+	// Trash routes (protected)
+	if cfg.Trash != nil {
+		trashHandler := handlers.NewTrashHandler(cfg.Trash)
+		mux.Handle("GET /api/trash", requireAuth(http.HandlerFunc(trashHandler.List)))
+		mux.Handle("DELETE /api/trash", requireAuth(http.HandlerFunc(trashHandler.Empty)))
+		mux.Handle("GET /api/trash/{id}", requireAuth(http.HandlerFunc(trashHandler.Get)))
+		mux.Handle("POST /api/trash/{id}/restore", requireAuthIdempotent(trashHandler.Restore))
+		mux.Handle("DELETE /api/trash/{id}", requireAuth(http.HandlerFunc(trashHandler.Purge)))
+	}
+
+	// File version history routes (protected)
+	if cfg.History != nil {
+		historyHandler := handlers.NewHistoryHandler(cfg.History)
+		mux.Handle("GET /api/files/history", requireAuth(http.HandlerFunc(historyHandler.List)))
+		mux.Handle("GET /api/files/history/{id}", requireAuth(http.HandlerFunc(historyHandler.Get)))
+		mux.Handle("GET /api/files/history/{id}/download", requireAuth(http.HandlerFunc(historyHandler.Download)))
+		mux.Handle("GET /api/files/history/{id}/diff", requireAuth(http.HandlerFunc(historyHandler.Diff)))
+		mux.Handle("POST /api/files/history/{id}/restore", requireAuthIdempotent(historyHandler.Restore))
+		mux.Handle("DELETE /api/files/history/{id}", requireAuth(http.HandlerFunc(historyHandler.Delete)))
+	}
+
+	// Disk usage routes (protected)
+	if cfg.DiskUsage != nil {
+		usageHandler := handlers.NewDiskUsageHandler(cfg.DiskUsage)
+		mux.Handle("GET /api/files/usage", requireAuth(http.HandlerFunc(usageHandler.Tree)))
+		mux.Handle("DELETE /api/files/usage", requireAuth(http.HandlerFunc(usageHandler.Forget)))
+		mux.Handle("GET /api/files/usage/top", requireAuth(http.HandlerFunc(usageHandler.Top)))
+		mux.Handle("GET /api/files/usage/cached", requireAuth(http.HandlerFunc(usageHandler.Cached)))
+		mux.Handle("POST /api/files/usage/scan", requireAuthIdempotent(usageHandler.Scan))
+	}
+
+	// Thumbnail and media metadata routes (protected)
+	if cfg.Media != nil {
+		mediaHandler := handlers.NewMediaHandler(cfg.Media)
+		mux.Handle("GET /api/files/thumbnail", requireAuth(http.HandlerFunc(mediaHandler.Thumbnail)))
+		mux.Handle("GET /api/files/media", requireAuth(http.HandlerFunc(mediaHandler.Info)))
+	}
 
 	// Chunked upload routes (for large files)
 	mux.Handle("POST /api/files/upload/init", requireAuth(http.HandlerFunc(filesHandler.InitChunkedUpload)))
@@ -120,24 +219,46 @@ func Setup(cfg Config) (http.Handler, *RouteHandlers) {
 	if cfg.DB != nil {
 		filesHandler.SetDB(cfg.DB)
 		mux.Handle("GET /api/files/pinned", requireAuth(http.HandlerFunc(filesHandler.ListPinned)))
-		mux.Handle("POST /api/files/pinned", requireAuth(http.HandlerFunc(filesHandler.CreatePinned)))
+		mux.Handle("POST /api/files/pinned", requireAuthIdempotent(filesHandler.CreatePinned))
 		mux.Handle("DELETE /api/files/pinned/{id}", requireAuth(http.HandlerFunc(filesHandler.DeletePinned)))
 	}
 
-	// Search route (protected)
+	// Search routes (protected)
 	mux.Handle("GET /api/search", requireAuth(http.HandlerFunc(filesHandler.Search)))
+	if cfg.SearchIndex != nil {
+		filesHandler.SetSearchIndex(cfg.SearchIndex)
+		indexHandler := handlers.NewSearchIndexHandler(cfg.SearchIndex)
+		mux.Handle("GET /api/search/index", requireAuth(http.HandlerFunc(indexHandler.Status)))
+		mux.Handle("POST /api/search/index/rescan", requireAuthIdempotent(indexHandler.Rescan))
+	}
 
 	// Terminal WebSocket (auth via query param)
+	terminalHandler.SetEventBus(cfg.EventBus)
 	mux.HandleFunc("GET /api/terminal", terminalHandler.Handle)
 
+	// Directory watch WebSocket (auth via header or query param)
+	watchHandler := handlers.NewWatchHandler(cfg.FileService, cfg.AuthService)
+	mux.HandleFunc("GET /api/files/watch", watchHandler.Handle)
+
+	// File follow (tail -f) over SSE or WebSocket (auth via header or query param)
+	followHandler := handlers.NewFollowHandler(cfg.FileService, cfg.AuthService)
+	mux.HandleFunc("GET /api/files/follow", followHandler.Stream)
+
+	// Event stream over SSE or WebSocket (auth via header or query param)
+	if cfg.EventBus != nil {
+		eventsHandler := handlers.NewEventsHandler(cfg.EventBus, cfg.AuthService)
+		mux.HandleFunc("GET /api/events", eventsHandler.Stream)
+	}
+
 	// Jobs routes (protected, optional)
 	if cfg.JobsService != nil {
 		jobsHandler := handlers.NewJobsHandler(cfg.JobsService)
 		mux.Handle("GET /api/jobs", requireAuth(http.HandlerFunc(jobsHandler.List)))
-		mux.Handle("POST /api/jobs", requireAuth(http.HandlerFunc(jobsHandler.Start)))
+		mux.Handle("POST /api/jobs", requireAuthIdempotent(jobsHandler.Start))
+		mux.Handle("POST /api/jobs/batch", requireAuthIdempotent(jobsHandler.Batch))
 		mux.Handle("GET /api/jobs/{id}", requireAuth(http.HandlerFunc(jobsHandler.Get)))
 		mux.Handle("GET /api/jobs/{id}/logs", requireAuth(http.HandlerFunc(jobsHandler.GetLogs)))
-		mux.Handle("POST /api/jobs/{id}/stop", requireAuth(http.HandlerFunc(jobsHandler.Stop)))
+		mux.Handle("POST /api/jobs/{id}/stop", requireAuthIdempotent(jobsHandler.Stop))
 		mux.Handle("DELETE /api/jobs/{id}", requireAuth(http.HandlerFunc(jobsHandler.Delete)))
 	}
 
@@ -150,7 +271,7 @@ func Setup(cfg Config) (http.Handler, *RouteHandlers) {
 
 	// Cron routes (protected, optional)
 	mux.Handle("GET /api/cron/jobs", requireAuth(http.HandlerFunc(cronHandler.ListJobs)))
-	mux.Handle("POST /api/cron/jobs", requireAuth(http.HandlerFunc(cronHandler.AddJob)))
+	mux.Handle("POST /api/cron/jobs", requireAuthIdempotent(cronHandler.AddJob))
 	mux.Handle("DELETE /api/cron/jobs", requireAuth(http.HandlerFunc(cronHandler.RemoveJob)))
 
 	// Download manager routes (protected, optional)
@@ -160,35 +281,68 @@ func Setup(cfg Config) (http.Handler, *RouteHandlers) {
 
 		// Download management (protected)
 		mux.Handle("GET /api/downloads", requireAuth(http.HandlerFunc(downloadsHandler.List)))
-		mux.Handle("POST /api/downloads", requireAuth(http.HandlerFunc(downloadsHandler.Add)))
+		mux.Handle("POST /api/downloads", requireAuthIdempotent(downloadsHandler.Add))
+		mux.Handle("POST /api/downloads/batch", requireAuthIdempotent(downloadsHandler.Batch))
 		mux.Handle("GET /api/downloads/{id}", requireAuth(http.HandlerFunc(downloadsHandler.Get)))
 		mux.Handle("DELETE /api/downloads/{id}", requireAuth(http.HandlerFunc(downloadsHandler.Delete)))
-		mux.Handle("POST /api/downloads/{id}/pause", requireAuth(http.HandlerFunc(downloadsHandler.Pause)))
-		mux.Handle("POST /api/downloads/{id}/resume", requireAuth(http.HandlerFunc(downloadsHandler.Resume)))
-		mux.Handle("POST /api/downloads/{id}/cancel", requireAuth(http.HandlerFunc(downloadsHandler.Cancel)))
-		mux.Handle("POST /api/downloads/{id}/retry", requireAuth(http.HandlerFunc(downloadsHandler.Retry)))
+		mux.Handle("POST /api/downloads/{id}/pause", requireAuthIdempotent(downloadsHandler.Pause))
+		mux.Handle("POST /api/downloads/{id}/resume", requireAuthIdempotent(downloadsHandler.Resume))
+		mux.Handle("POST /api/downloads/{id}/cancel", requireAuthIdempotent(downloadsHandler.Cancel))
+		mux.Handle("POST /api/downloads/{id}/retry", requireAuthIdempotent(downloadsHandler.Retry))
 		mux.Handle("GET /api/downloads/{id}/file", requireAuth(http.HandlerFunc(downloadsHandler.DownloadFile)))
-		mux.Handle("POST /api/downloads/{id}/share", requireAuth(http.HandlerFunc(downloadsHandler.CreateShareLink)))
+		mux.Handle("POST /api/downloads/{id}/share", requireAuthIdempotent(downloadsHandler.CreateShareLink))
 		mux.Handle("DELETE /api/downloads/{id}/share/{token}", requireAuth(http.HandlerFunc(downloadsHandler.RevokeShareLink)))
 
 		// Public share endpoint (no auth)
 		mux.HandleFunc("GET /api/share/{token}", shareHandler.Download)
 	}
 
+	// Webhook routes (protected, optional)
+	if cfg.WebhooksService != nil {
+		webhooksHandler := handlers.NewWebhooksHandler(cfg.WebhooksService)
+		mux.Handle("GET /api/webhooks", requireAuth(http.HandlerFunc(webhooksHandler.List)))
+		mux.Handle("POST /api/webhooks", requireAuthIdempotent(webhooksHandler.Create))
+		mux.Handle("GET /api/webhooks/{id}", requireAuth(http.HandlerFunc(webhooksHandler.Get)))
+		mux.Handle("PUT /api/webhooks/{id}", requireAuth(http.HandlerFunc(webhooksHandler.Update)))
+		mux.Handle("DELETE /api/webhooks/{id}", requireAuth(http.HandlerFunc(webhooksHandler.Delete)))
+		mux.Handle("POST /api/webhooks/{id}/ping", requireAuthIdempotent(webhooksHandler.Ping))
+		mux.Handle("GET /api/webhooks/{id}/deliveries", requireAuth(http.HandlerFunc(webhooksHandler.Deliveries)))
+		mux.Handle("POST /api/webhooks/{id}/deliveries/{delivery}/redeliver", requireAuthIdempotent(webhooksHandler.Redeliver))
+	}
+
 	// Apply global middleware
 	corsConfig := middleware.CORSConfig{
 		AllowedOrigins: cfg.Cfg.AllowedOrigins,
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID", "Last-Event-ID", middleware.IdempotencyKeyHeader, middleware.APIVersionHeader},
 		MaxAge:         86400,
 	}
 
-	router := middleware.Chain(
+	globalMiddleware := []middleware.Middleware{
+		middleware.RequestID,
 		middleware.Logging,
 		middleware.CORS(corsConfig),
+	}
+
+	// Compress large responses (JSON listings, stats history, etc.)
+	if cfg.Cfg.Compression.Enabled {
+		globalMiddleware = append(globalMiddleware, middleware.Compress(middleware.CompressionConfig{
+			Level:   cfg.Cfg.Compression.Level,
+			MinSize: cfg.Cfg.Compression.MinSize,
+		}))
+	}
+
+	globalMiddleware = append(globalMiddleware,
 		middleware.LimitJSONBody,             // Limit JSON request bodies (not file uploads)
 		middleware.Prefix(cfg.Cfg.APIPrefix), // Add API prefix if configured
-	)(mux)
+		middleware.APIVersion(middleware.VersionConfig{ // Serve /api/v1 on the same routes
+			Prefix:      cfg.Cfg.APIPrefix,
+			Deprecation: legacyAPIDeprecation,
+			Sunset:      cfg.Cfg.LegacyAPISunsetTime(),
+		}),
+	)
+
+	router := middleware.Chain(globalMiddleware...)(mux)
 
 	routeHandlers := &RouteHandlers{
 		TerminalHandler: terminalHandler,
@@ -31,12 +31,20 @@ func NewServer(application *app.App, opts ...ServerOptions) *Server {
 		Cfg:             application.Config,
 		AuthService:     application.Auth,
 		FileService:     application.Files,
+		FileOps:         application.FileOps,
+		Trash:           application.Trash,
+		History:         application.History,
+		DiskUsage:       application.DiskUsage,
+		Media:           application.Media,
+		SearchIndex:     application.SearchIndex,
 		JobsService:     application.Jobs,
 		SysService:      application.System,
 		DB:              application.DB.DB(),
 		PackagesService: application.Packages,
 		CronService:     application.Cron,
 		DownloadService: application.Downloads,
+		EventBus:        application.Events,
+		WebhooksService: application.Webhooks,
 		Features:        application.Features(),
 		Version:         version,
 	})
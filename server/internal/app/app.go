@@ -12,11 +12,13 @@ import (
 	"github.com/ss497254/gloski/internal/cron"
 	"github.com/ss497254/gloski/internal/database"
 	"github.com/ss497254/gloski/internal/downloads"
+	"github.com/ss497254/gloski/internal/events"
 	"github.com/ss497254/gloski/internal/files"
 	"github.com/ss497254/gloski/internal/jobs"
 	"github.com/ss497254/gloski/internal/logger"
 	"github.com/ss497254/gloski/internal/packages"
 	"github.com/ss497254/gloski/internal/system"
+	"github.com/ss497254/gloski/internal/webhooks"
 )
 
 // App is the main application container that holds all services.
@@ -27,11 +29,21 @@ type App struct {
 	DB *database.Database
 
 	// Core services
-	Auth      *auth.Service
-	Files     *files.Service
-	System    *system.Service
-	Jobs      *jobs.Service
-	Downloads *downloads.Service
+	Auth        *auth.Service
+	Files       *files.Service
+	FileOps     *files.Operations
+	DiskUsage   *files.DiskUsage
+	Media       *files.Media
+	Trash       *files.Trash
+	History     *files.History
+	SearchIndex *files.SearchIndex
+	System      *system.Service
+	Jobs        *jobs.Service
+	Downloads   *downloads.Service
+	Webhooks    *webhooks.Service
+
+	// Events is the bus services publish real-time events on
+	Events *events.Bus
 
 	// Background services
 	statsCollector *system.Collector
@@ -60,6 +72,8 @@ func New(cfg *config.Config) (*App, error) {
 	app.DB = db
 	logger.Info("Database initialized at %s", cfg.DatabasePath())
 
+	app.Events = events.NewBus(events.DefaultHistorySize)
+
 	// Initialize system stats store, hub, and collector
 	// Store capacity uses default from NewStore (300 samples = 10 minutes at 2s interval)
 	statsStore := system.NewStore(0) // 0 = use default capacity
@@ -80,6 +94,24 @@ func New(cfg *config.Config) (*App, error) {
 	}
 	app.Auth = authService
 	app.Files = files.NewService(cfg)
+	app.Files.SetEventBus(app.Events)
+	app.FileOps = files.NewOperations(app.Files)
+	app.DiskUsage = files.NewDiskUsage(app.FileOps)
+	app.Media = files.NewMedia(app.Files, cfg.ThumbnailsDir(), cfg.Media)
+	if cfg.Trash.Enabled {
+		app.Trash = files.NewTrash(app.Files, db, cfg.TrashRetention())
+		app.Files.SetTrash(app.Trash)
+		app.Trash.Start()
+	}
+	if cfg.History.Enabled {
+		app.History = files.NewHistory(app.Files, db, cfg.HistoryDir(), cfg.History)
+		app.Files.SetHistory(app.History)
+	}
+	if cfg.SearchIndex.Enabled {
+		app.SearchIndex = files.NewSearchIndex(app.Files, db, cfg.SearchIndex)
+		app.SearchIndex.Start()
+		logger.Info("Search index initialized")
+	}
 	app.System = system.NewService(statsStore, app.statsHub)
 
 	// Initialize jobs service if enabled
@@ -87,6 +119,7 @@ func New(cfg *config.Config) (*App, error) {
 		jobsService, err := jobs.NewService(db, jobs.Config{
 			LogsDir: cfg.LogsDir(),
 			MaxJobs: cfg.Jobs.MaxJobs,
+			Events:  app.Events,
 		})
 		if err != nil {
 			logger.Warn("Failed to initialize jobs service: %v", err)
@@ -102,6 +135,7 @@ func New(cfg *config.Config) (*App, error) {
 			MaxConcurrent: cfg.Downloads.MaxConcurrent,
 			MaxRetries:    cfg.Downloads.MaxRetries,
 			BaseURL:       cfg.BaseURL,
+			Events:        app.Events,
 		})
 		if err != nil {
 			logger.Warn("Failed to initialize downloads service: %v", err)
@@ -111,6 +145,22 @@ func New(cfg *config.Config) (*App, error) {
 		}
 	}
 
+	// Initialize webhooks service if enabled
+	if cfg.Webhooks.Enabled {
+		webhooksService, err := webhooks.NewService(db, webhooks.Config{
+			MaxAttempts: cfg.Webhooks.MaxAttempts,
+			Timeout:     time.Duration(cfg.Webhooks.Timeout) * time.Second,
+			Events:      app.Events,
+		})
+		if err != nil {
+			logger.Warn("Failed to initialize webhooks service: %v", err)
+		} else {
+			webhooksService.Start()
+			app.Webhooks = webhooksService
+			logger.Info("Webhooks service initialized")
+		}
+	}
+
 	// Initialize optional services (may fail gracefully)
 	app.initOptionalServices()
 
@@ -134,6 +184,7 @@ func (a *App) initOptionalServices() {
 	if err != nil {
 		logger.Debug("Cron service not available: %v", err)
 	} else {
+		cronSvc.SetEventBus(a.Events)
 		a.Cron = cronSvc
 		logger.Info("Cron service initialized")
 	}
@@ -161,6 +212,21 @@ func (a *App) Shutdown(ctx context.Context) error {
 		a.statsHub.Stop()
 	}
 
+	// Cancel running copy/move operations
+	if a.FileOps != nil {
+		a.FileOps.Shutdown()
+	}
+
+	// Stop purging expired trash items
+	if a.Trash != nil {
+		a.Trash.Shutdown()
+	}
+
+	// Stop indexing before the database closes
+	if a.SearchIndex != nil {
+		a.SearchIndex.Shutdown()
+	}
+
 	// Shutdown jobs service (kills running jobs)
 	if a.Jobs != nil {
 		if err := a.Jobs.Shutdown(); err != nil {
@@ -181,6 +247,11 @@ func (a *App) Shutdown(ctx context.Context) error {
 		a.Downloads.Shutdown(timeout)
 	}
 
+	// Stop webhook delivery (pending deliveries resume on next start)
+	if a.Webhooks != nil {
+		a.Webhooks.Shutdown()
+	}
+
 	// Close database
 	if a.DB != nil {
 		if err := a.DB.Close(); err != nil {
@@ -216,12 +287,36 @@ func (a *App) HasJobs() bool {
 	return a.Jobs != nil
 }
 
+// HasWebhooks returns true if webhooks feature is available.
+func (a *App) HasWebhooks() bool {
+	return a.Webhooks != nil
+}
+
+// HasTrash returns true if deletes go to the trash.
+func (a *App) HasTrash() bool {
+	return a.Trash != nil
+}
+
+// HasHistory returns true if overwritten files are kept as versions.
+func (a *App) HasHistory() bool {
+	return a.History != nil
+}
+
+// HasSearchIndex returns true if searches can use the full-text index.
+func (a *App) HasSearchIndex() bool {
+	return a.SearchIndex != nil
+}
+
 // Features returns a map of available features.
 func (a *App) Features() map[string]bool {
 	return map[string]bool{
-		"packages":  a.HasPackages(),
-		"cron":      a.HasCron(),
-		"downloads": a.HasDownloads(),
-		"jobs":      a.HasJobs(),
+		"packages":     a.HasPackages(),
+		"cron":         a.HasCron(),
+		"downloads":    a.HasDownloads(),
+		"jobs":         a.HasJobs(),
+		"webhooks":     a.HasWebhooks(),
+		"trash":        a.HasTrash(),
+		"history":      a.HasHistory(),
+		"search_index": a.HasSearchIndex(),
 	}
 }
@@ -0,0 +1,288 @@
+// Package apperr defines typed application errors carrying machine-readable codes.
+//
+// Services return *Error values (usually package-level sentinels) so that API
+// handlers can render a consistent {code, message, details, request_id} body
+// without string-matching error messages.
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Code is a stable, machine-readable error identifier exposed to API clients
+type Code string
+
+// Generic codes
+const (
+	CodeBadRequest         Code = "bad_request"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeForbidden          Code = "forbidden"
+	CodeNotFound           Code = "not_found"
+	CodeConflict           Code = "conflict"
+	CodePayloadTooLarge    Code = "payload_too_large"
+	CodeRateLimited        Code = "rate_limited"
+	CodeInternal           Code = "internal_error"
+	CodeUnavailable        Code = "service_unavailable"
+	CodeUnsupportedVersion Code = "unsupported_version"
+)
+
+// Idempotency codes
+const (
+	CodeIdempotencyKeyReused     Code = "idempotency_key_reused"
+	CodeIdempotencyKeyInProgress Code = "idempotency_key_in_progress"
+)
+
+// Listing codes
+const (
+	CodeInvalidCursor    Code = "invalid_cursor"
+	CodeInvalidParameter Code = "invalid_parameter"
+)
+
+// File codes
+const (
+	CodePathNotAllowed     Code = "path_not_allowed"
+	CodeDangerousPath      Code = "dangerous_path"
+	CodePathNotFound       Code = "path_not_found"
+	CodePathExists         Code = "path_exists"
+	CodePermissionDenied   Code = "permission_denied"
+	CodeFileTooLarge       Code = "file_too_large"
+	CodeBinaryFile         Code = "binary_file"
+	CodeIsDirectory        Code = "is_directory"
+	CodeNotDirectory       Code = "not_directory"
+	CodeInvalidFilename    Code = "invalid_filename"
+	CodeUploadIncomplete   Code = "upload_incomplete"
+	CodeOperationNotFound  Code = "operation_not_found"
+	CodeUnsupportedArchive Code = "unsupported_archive"
+	CodeUnsafeArchive      Code = "unsafe_archive"
+	CodeArchiveTooLarge    Code = "archive_too_large"
+	CodeTrashItemNotFound  Code = "trash_item_not_found"
+	CodeTrashUnavailable   Code = "trash_unavailable"
+	CodeXattrNotFound      Code = "xattr_not_found"
+	CodeXattrUnsupported   Code = "xattr_unsupported"
+	CodeUnknownOwner       Code = "unknown_owner"
+	CodeTooManyWatches     Code = "too_many_watches"
+	CodeVersionConflict    Code = "version_conflict"
+	CodeVersionNotFound    Code = "version_not_found"
+	CodeInvalidPatch       Code = "invalid_patch"
+	CodePatchFailed        Code = "patch_failed"
+	CodeUsageNotScanned    Code = "usage_not_scanned"
+	CodeNotIndexed         Code = "not_indexed"
+	CodeUnsupportedMedia   Code = "unsupported_media"
+	CodeImageTooLarge      Code = "image_too_large"
+)
+
+// Job codes
+const (
+	CodeJobNotFound   Code = "job_not_found"
+	CodeJobNotRunning Code = "job_not_running"
+	CodeEmptyCommand  Code = "empty_command"
+)
+
+// Download codes
+const (
+	CodeDownloadNotFound  Code = "download_not_found"
+	CodeInvalidState      Code = "invalid_state"
+	CodeQueueFull         Code = "queue_full"
+	CodeInvalidURL        Code = "invalid_url"
+	CodeShareLinkNotFound Code = "share_link_not_found"
+)
+
+// Cron and package codes
+const (
+	CodeInvalidSchedule Code = "invalid_schedule"
+	CodeInvalidCommand  Code = "invalid_command"
+	CodeNotAvailable    Code = "not_available"
+)
+
+// Webhook codes
+const (
+	CodeWebhookNotFound  Code = "webhook_not_found"
+	CodeDeliveryNotFound Code = "delivery_not_found"
+)
+
+// statusByCode maps codes to HTTP status codes. Codes not listed map to 500.
+var statusByCode = map[Code]int{
+	CodeBadRequest:         http.StatusBadRequest,
+	CodeUnauthorized:       http.StatusUnauthorized,
+	CodeForbidden:          http.StatusForbidden,
+	CodeNotFound:           http.StatusNotFound,
+	CodeConflict:           http.StatusConflict,
+	CodePayloadTooLarge:    http.StatusRequestEntityTooLarge,
+	CodeRateLimited:        http.StatusTooManyRequests,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+	CodeUnsupportedVersion: http.StatusBadRequest,
+
+	CodeIdempotencyKeyReused:     http.StatusUnprocessableEntity,
+	CodeIdempotencyKeyInProgress: http.StatusConflict,
+
+	CodeInvalidCursor:    http.StatusBadRequest,
+	CodeInvalidParameter: http.StatusBadRequest,
+
+	CodePathNotAllowed:     http.StatusForbidden,
+	CodeDangerousPath:      http.StatusForbidden,
+	CodePathNotFound:       http.StatusNotFound,
+	CodePathExists:         http.StatusConflict,
+	CodePermissionDenied:   http.StatusForbidden,
+	CodeFileTooLarge:       http.StatusBadRequest,
+	CodeBinaryFile:         http.StatusBadRequest,
+	CodeIsDirectory:        http.StatusBadRequest,
+	CodeNotDirectory:       http.StatusBadRequest,
+	CodeInvalidFilename:    http.StatusBadRequest,
+	CodeUploadIncomplete:   http.StatusBadRequest,
+	CodeOperationNotFound:  http.StatusNotFound,
+	CodeUnsupportedArchive: http.StatusBadRequest,
+	CodeUnsafeArchive:      http.StatusBadRequest,
+	CodeArchiveTooLarge:    http.StatusRequestEntityTooLarge,
+	CodeTrashItemNotFound:  http.StatusNotFound,
+	CodeTrashUnavailable:   http.StatusConflict,
+	CodeXattrNotFound:      http.StatusNotFound,
+	CodeXattrUnsupported:   http.StatusUnprocessableEntity,
+	CodeUnknownOwner:       http.StatusBadRequest,
+	CodeTooManyWatches:     http.StatusConflict,
+	CodeVersionConflict:    http.StatusConflict,
+	CodeVersionNotFound:    http.StatusNotFound,
+	CodeInvalidPatch:       http.StatusBadRequest,
+	CodePatchFailed:        http.StatusConflict,
+	CodeUsageNotScanned:    http.StatusNotFound,
+	CodeNotIndexed:         http.StatusBadRequest,
+	CodeUnsupportedMedia:   http.StatusUnsupportedMediaType,
+	CodeImageTooLarge:      http.StatusRequestEntityTooLarge,
+
+	CodeJobNotFound:   http.StatusNotFound,
+	CodeJobNotRunning: http.StatusConflict,
+	CodeEmptyCommand:  http.StatusBadRequest,
+
+	CodeDownloadNotFound:  http.StatusNotFound,
+	CodeInvalidState:      http.StatusConflict,
+	CodeQueueFull:         http.StatusServiceUnavailable,
+	CodeInvalidURL:        http.StatusBadRequest,
+	CodeShareLinkNotFound: http.StatusNotFound,
+
+	CodeInvalidSchedule: http.StatusBadRequest,
+	CodeInvalidCommand:  http.StatusBadRequest,
+	CodeNotAvailable:    http.StatusServiceUnavailable,
+
+	CodeWebhookNotFound:  http.StatusNotFound,
+	CodeDeliveryNotFound: http.StatusNotFound,
+}
+
+// HTTPStatus returns the HTTP status code associated with the code
+func (c Code) HTTPStatus() int {
+	if status, ok := statusByCode[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeForStatus returns the generic code for an HTTP status
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// Error is an application error with a machine-readable code
+type Error struct {
+	Code    Code
+	Message string
+	Details interface{}
+	Err     error // Underlying cause, if any
+
+	origin *Error // Sentinel this is a copy of, if any
+}
+
+// New creates a new error with the given code and message
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap creates a new error with the given code and message wrapping a cause
+func Wrap(err error, code Code, message string) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Err != nil && e.Message == "" {
+		return e.Err.Error()
+	}
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the same sentinel, so copies made with
+// WithDetails or WithMessage still match it. Distinct errors sharing a code
+// don't match; compare CodeOf for that.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.sentinel() == e.sentinel()
+}
+
+// sentinel returns the error e was copied from, or e itself
+func (e *Error) sentinel() *Error {
+	if e.origin != nil {
+		return e.origin
+	}
+	return e
+}
+
+// WithDetails returns a copy of the error carrying structured details
+func (e *Error) WithDetails(details interface{}) *Error {
+	cp := *e
+	cp.Details = details
+	cp.origin = e.sentinel()
+	return &cp
+}
+
+// WithMessage returns a copy of the error with a more specific message
+func (e *Error) WithMessage(message string) *Error {
+	cp := *e
+	cp.Message = message
+	cp.origin = e.sentinel()
+	return &cp
+}
+
+// As returns the first *Error in err's chain
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// CodeOf returns the code of err, or CodeInternal if err is not an *Error
+func CodeOf(err error) Code {
+	if e, ok := As(err); ok {
+		return e.Code
+	}
+	return CodeInternal
+}
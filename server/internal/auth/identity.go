@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ss497254/gloski/internal/events"
+)
+
+// Authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Identity describes an authenticated caller
+type Identity struct {
+	Method  string // MethodAPIKey or MethodJWT
+	Subject string // JWT "sub" claim
+
+	// Topics restricts which event topics the caller may receive (JWT
+	// "topics" claim). Nil means unrestricted.
+	Topics []string
+}
+
+// AllowsTopic reports whether the identity may receive events on topic.
+// Entries in Topics match the topic itself and everything below it, so
+// "jobs" grants "jobs.started" and "jobs.finished".
+func (i *Identity) AllowsTopic(topic string) bool {
+	if i == nil {
+		return false
+	}
+	if i.Topics == nil {
+		return true
+	}
+	for _, allowed := range i.Topics {
+		if events.Match(allowed, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func identityFromClaims(claims jwt.MapClaims) *Identity {
+	id := &Identity{Method: MethodJWT}
+	id.Subject, _ = claims.GetSubject()
+
+	switch v := claims["topics"].(type) {
+	case string:
+		id.Topics = strings.Fields(strings.ReplaceAll(v, ",", " "))
+	case []interface{}:
+		id.Topics = make([]string, 0, len(v))
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				id.Topics = append(id.Topics, s)
+			}
+		}
+	}
+	return id
+}
@@ -52,11 +52,18 @@ func (s *Service) ValidateAPIKey(key string) error {
 
 // ValidateToken validates a JWT token using the configured public key
 func (s *Service) ValidateToken(tokenString string) error {
+	_, err := s.ParseToken(tokenString)
+	return err
+}
+
+// ParseToken validates a JWT token and returns the identity it carries
+func (s *Service) ParseToken(tokenString string) (*Identity, error) {
 	if s.jwtPublicKey == nil {
-		return ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		// Ensure the signing method is RS256
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 			return nil, errors.New("unexpected signing method")
@@ -66,16 +73,33 @@ func (s *Service) ValidateToken(tokenString string) error {
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
-			return ErrTokenExpired
+			return nil, ErrTokenExpired
 		}
-		return ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
 	if !token.Valid {
-		return ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
-	return nil
+	return identityFromClaims(claims), nil
+}
+
+// Authenticate resolves the identity behind an API key or JWT token.
+// The API key is tried first; either may be empty.
+func (s *Service) Authenticate(apiKey, token string) (*Identity, error) {
+	if apiKey != "" {
+		if err := s.ValidateAPIKey(apiKey); err == nil {
+			return &Identity{Method: MethodAPIKey}, nil
+		}
+	}
+	if token != "" {
+		return s.ParseToken(token)
+	}
+	if apiKey != "" {
+		return nil, ErrInvalidAPIKey
+	}
+	return nil, ErrNoAuthMethod
 }
 
 // HasAPIKey returns true if API key authentication is configured
@@ -0,0 +1,69 @@
+// Package batch runs bulk operations over many items with bounded concurrency.
+package batch
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// DefaultConcurrency is used when a request does not ask for a specific one
+	DefaultConcurrency = 4
+
+	// MaxConcurrency caps how many items of one batch run at the same time
+	MaxConcurrency = 16
+
+	// MaxItems caps the number of items in one batch
+	MaxItems = 1000
+)
+
+// Concurrency clamps a requested concurrency to [1, MaxConcurrency],
+// using DefaultConcurrency when none was requested
+func Concurrency(requested int) int {
+	switch {
+	case requested <= 0:
+		return DefaultConcurrency
+	case requested > MaxConcurrency:
+		return MaxConcurrency
+	}
+	return requested
+}
+
+// Run calls fn for every index in [0, n) using at most concurrency workers
+// and returns the error of each item by index. Items not yet started when ctx
+// is cancelled are not run and fail with ctx.Err().
+func Run(ctx context.Context, n, concurrency int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	if n == 0 {
+		return errs
+	}
+	concurrency = min(Concurrency(concurrency), n)
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			for j := i; j < n; j++ {
+				errs[j] = ctx.Err()
+			}
+			break dispatch
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	return errs
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -37,14 +38,37 @@ type Config struct {
 	LogLevel string `json:"log_level"` // debug, info, warn, error
 
 	// API settings
-	DetailedErrors  bool  `json:"detailed_errors"`    // Include detailed error messages in API responses (useful for development)
-	MaxJSONBodySize int64 `json:"max_json_body_size"` // Maximum size for JSON request bodies in bytes (default: 1MB)
+	DetailedErrors  bool   `json:"detailed_errors"`    // Include detailed error messages in API responses (useful for development)
+	MaxJSONBodySize int64  `json:"max_json_body_size"` // Maximum size for JSON request bodies in bytes (default: 1MB)
+	LegacyAPISunset string `json:"legacy_api_sunset"`  // Date (YYYY-MM-DD) after which unversioned /api routes may be removed
+	IdempotencyTTL  int    `json:"idempotency_ttl"`    // Hours a response is kept for replay under its Idempotency-Key (default: 24, 0 disables)
+
+	// Response compression
+	Compression CompressionConfig `json:"compression"`
 
 	// Downloads
 	Downloads DownloadsConfig `json:"downloads"`
 
 	// Jobs
 	Jobs JobsConfig `json:"jobs"`
+
+	// Webhooks
+	Webhooks WebhooksConfig `json:"webhooks"`
+
+	// Archive extraction limits
+	Archive ArchiveConfig `json:"archive"`
+
+	// Trash
+	Trash TrashConfig `json:"trash"`
+
+	// File version history
+	History HistoryConfig `json:"history"`
+
+	// Full-text search index
+	SearchIndex SearchIndexConfig `json:"search_index"`
+
+	// Image thumbnails and media metadata
+	Media MediaConfig `json:"media"`
 }
 
 // DownloadsConfig holds configuration for the download manager
@@ -54,12 +78,65 @@ type DownloadsConfig struct {
 	MaxRetries    int  `json:"max_retries"`    // Maximum retry attempts (default: 3)
 }
 
+// CompressionConfig holds configuration for HTTP response compression
+type CompressionConfig struct {
+	Enabled bool `json:"enabled"`
+	Level   int  `json:"level"`    // Compression level from 1 (fastest) to 9 (smallest) (default: 5)
+	MinSize int  `json:"min_size"` // Minimum response size in bytes before compressing (default: 1024)
+}
+
 // JobsConfig holds configuration for the jobs manager
 type JobsConfig struct {
 	Enabled bool `json:"enabled"`
 	MaxJobs int  `json:"max_jobs"` // Maximum number of jobs to keep (default: 100)
 }
 
+// WebhooksConfig holds configuration for outbound webhooks
+type WebhooksConfig struct {
+	Enabled     bool `json:"enabled"`
+	MaxAttempts int  `json:"max_attempts"` // Delivery attempts before giving up (default: 8)
+	Timeout     int  `json:"timeout"`      // Per-attempt timeout in seconds (default: 10)
+}
+
+// ArchiveConfig holds limits applied when extracting archives
+type ArchiveConfig struct {
+	MaxExtractSize  int64 `json:"max_extract_size"`  // Maximum total bytes written per extraction (default: 10GB, 0 disables)
+	MaxExtractFiles int   `json:"max_extract_files"` // Maximum number of entries per extraction (default: 100000, 0 disables)
+}
+
+// TrashConfig holds configuration for the trash deleted files are moved to
+type TrashConfig struct {
+	Enabled       bool `json:"enabled"`        // When false, deletes are permanent
+	RetentionDays int  `json:"retention_days"` // Items older than this are purged automatically (default: 30, 0 keeps them)
+}
+
+// HistoryConfig holds configuration for the snapshots taken of files before
+// they are overwritten
+type HistoryConfig struct {
+	Enabled      bool  `json:"enabled"`
+	MaxVersions  int   `json:"max_versions"`   // Versions kept per file (default: 20, 0 disables the limit)
+	MaxTotalSize int64 `json:"max_total_size"` // Total bytes of stored content, oldest versions go first (default: 1GB, 0 disables the limit)
+	MaxFileSize  int64 `json:"max_file_size"`  // Larger files are not snapshotted (default: 10MB, 0 disables the limit)
+}
+
+// SearchIndexConfig holds configuration for the persistent full-text index
+// searches use for the directories it covers
+type SearchIndexConfig struct {
+	Enabled      bool     `json:"enabled"`       // When false, searches walk the tree (default: false)
+	Roots        []string `json:"roots"`         // Directories to index
+	ScanInterval int      `json:"scan_interval"` // Minutes between rescans for changes (default: 60, 0 scans only at startup)
+	Watch        bool     `json:"watch"`         // Follow changes with inotify between rescans (default: true)
+	MaxFileSize  int64    `json:"max_file_size"` // Content of larger files is not indexed, only names (default: 1MB, 0 indexes names only)
+}
+
+// MediaConfig holds limits for thumbnail generation and metadata extraction
+type MediaConfig struct {
+	MaxFileSize    int64 `json:"max_file_size"`   // Larger images get no thumbnail (default: 50MB)
+	MaxPixels      int64 `json:"max_pixels"`      // Larger images aren't decoded (default: 40 megapixels)
+	Workers        int   `json:"workers"`         // Images decoded at once (default: 2)
+	ThumbnailCache int64 `json:"thumbnail_cache"` // Bytes of thumbnails kept, oldest used dropped first (default: 256MB)
+}
+
 func DefaultConfig() *Config {
 	homeDir, _ := os.UserHomeDir()
 	dataDir := filepath.Join(homeDir, ".gloski", "data")
@@ -73,6 +150,8 @@ func DefaultConfig() *Config {
 		AllowedPaths:    []string{},
 		Shell:           getDefaultShell(),
 		LogLevel:        "info",
+		LegacyAPISunset: "2027-04-30",
+		IdempotencyTTL:  24,
 		Downloads: DownloadsConfig{
 			Enabled:       true,
 			MaxConcurrent: 3,
@@ -82,6 +161,42 @@ func DefaultConfig() *Config {
 			Enabled: true,
 			MaxJobs: 100,
 		},
+		Webhooks: WebhooksConfig{
+			Enabled:     true,
+			MaxAttempts: 8,
+			Timeout:     10,
+		},
+		Compression: CompressionConfig{
+			Enabled: true,
+			Level:   5,
+			MinSize: 1024,
+		},
+		Archive: ArchiveConfig{
+			MaxExtractSize:  10 * 1024 * 1024 * 1024,
+			MaxExtractFiles: 100000,
+		},
+		Trash: TrashConfig{
+			Enabled:       true,
+			RetentionDays: 30,
+		},
+		History: HistoryConfig{
+			Enabled:      true,
+			MaxVersions:  20,
+			MaxTotalSize: 1024 * 1024 * 1024,
+			MaxFileSize:  10 * 1024 * 1024,
+		},
+		SearchIndex: SearchIndexConfig{
+			Roots:        []string{},
+			ScanInterval: 60,
+			Watch:        true,
+			MaxFileSize:  1024 * 1024,
+		},
+		Media: MediaConfig{
+			MaxFileSize:    50 * 1024 * 1024,
+			MaxPixels:      40 * 1000 * 1000,
+			Workers:        2,
+			ThumbnailCache: 256 * 1024 * 1024,
+		},
 	}
 }
 
@@ -95,6 +210,16 @@ func (c *Config) LogsDir() string {
 	return filepath.Join(c.DataDir, "logs")
 }
 
+// HistoryDir returns the path to the file version history store
+func (c *Config) HistoryDir() string {
+	return filepath.Join(c.DataDir, "history")
+}
+
+// ThumbnailsDir returns the path to the image thumbnail cache
+func (c *Config) ThumbnailsDir() string {
+	return filepath.Join(c.DataDir, "thumbnails")
+}
+
 func Load(path string) (*Config, error) {
 	cfg := DefaultConfig()
 
@@ -171,6 +296,84 @@ func (c *Config) loadFromEnv() {
 			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_JOBS_MAX_JOBS value %q: %v\n", v, err)
 		}
 	}
+	if v := os.Getenv("GLOSKI_WEBHOOKS_ENABLED"); v != "" {
+		c.Webhooks.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GLOSKI_TRASH_ENABLED"); v != "" {
+		c.Trash.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GLOSKI_TRASH_RETENTION_DAYS"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.Trash.RetentionDays); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_TRASH_RETENTION_DAYS value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_HISTORY_ENABLED"); v != "" {
+		c.History.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GLOSKI_HISTORY_MAX_VERSIONS"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.History.MaxVersions); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_HISTORY_MAX_VERSIONS value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_HISTORY_MAX_TOTAL_SIZE"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.History.MaxTotalSize); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_HISTORY_MAX_TOTAL_SIZE value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_HISTORY_MAX_FILE_SIZE"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.History.MaxFileSize); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_HISTORY_MAX_FILE_SIZE value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_SEARCH_INDEX_ENABLED"); v != "" {
+		c.SearchIndex.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GLOSKI_SEARCH_INDEX_ROOTS"); v != "" {
+		c.SearchIndex.Roots = strings.Split(v, ",")
+	}
+	if v := os.Getenv("GLOSKI_SEARCH_INDEX_SCAN_INTERVAL"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.SearchIndex.ScanInterval); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_SEARCH_INDEX_SCAN_INTERVAL value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_SEARCH_INDEX_WATCH"); v != "" {
+		c.SearchIndex.Watch = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GLOSKI_SEARCH_INDEX_MAX_FILE_SIZE"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.SearchIndex.MaxFileSize); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_SEARCH_INDEX_MAX_FILE_SIZE value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_MEDIA_MAX_FILE_SIZE"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.Media.MaxFileSize); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_MEDIA_MAX_FILE_SIZE value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_MEDIA_MAX_PIXELS"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.Media.MaxPixels); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_MEDIA_MAX_PIXELS value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_MEDIA_WORKERS"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.Media.Workers); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_MEDIA_WORKERS value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_MEDIA_THUMBNAIL_CACHE"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.Media.ThumbnailCache); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_MEDIA_THUMBNAIL_CACHE value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_ARCHIVE_MAX_EXTRACT_SIZE"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.Archive.MaxExtractSize); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_ARCHIVE_MAX_EXTRACT_SIZE value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_ARCHIVE_MAX_EXTRACT_FILES"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.Archive.MaxExtractFiles); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_ARCHIVE_MAX_EXTRACT_FILES value %q: %v\n", v, err)
+		}
+	}
 	if v := os.Getenv("GLOSKI_SHUTDOWN_TIMEOUT"); v != "" {
 		if _, err := fmt.Sscanf(v, "%d", &c.ShutdownTimeout); err != nil {
 			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_SHUTDOWN_TIMEOUT value %q: %v\n", v, err)
@@ -184,6 +387,27 @@ func (c *Config) loadFromEnv() {
 			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_MAX_JSON_BODY_SIZE value %q: %v\n", v, err)
 		}
 	}
+	if v := os.Getenv("GLOSKI_LEGACY_API_SUNSET"); v != "" {
+		c.LegacyAPISunset = v
+	}
+	if v := os.Getenv("GLOSKI_IDEMPOTENCY_TTL"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.IdempotencyTTL); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_IDEMPOTENCY_TTL value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_COMPRESSION_ENABLED"); v != "" {
+		c.Compression.Enabled = v == "true" || v == "1"
+	}
+	if v := os.Getenv("GLOSKI_COMPRESSION_LEVEL"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.Compression.Level); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_COMPRESSION_LEVEL value %q: %v\n", v, err)
+		}
+	}
+	if v := os.Getenv("GLOSKI_COMPRESSION_MIN_SIZE"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &c.Compression.MinSize); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: invalid GLOSKI_COMPRESSION_MIN_SIZE value %q: %v\n", v, err)
+		}
+	}
 }
 
 func (c *Config) validate() error {
@@ -191,6 +415,43 @@ func (c *Config) validate() error {
 		return fmt.Errorf("invalid port: %d", c.Port)
 	}
 
+	if c.Compression.Enabled && (c.Compression.Level < 1 || c.Compression.Level > 9) {
+		return fmt.Errorf("invalid compression level: %d (must be 1-9)", c.Compression.Level)
+	}
+
+	if c.LegacyAPISunset != "" {
+		if _, err := time.Parse(time.DateOnly, c.LegacyAPISunset); err != nil {
+			return fmt.Errorf("invalid legacy_api_sunset %q: expected YYYY-MM-DD", c.LegacyAPISunset)
+		}
+	}
+
+	if c.IdempotencyTTL < 0 {
+		return fmt.Errorf("invalid idempotency_ttl: %d (must be >= 0)", c.IdempotencyTTL)
+	}
+
+	if c.Archive.MaxExtractSize < 0 || c.Archive.MaxExtractFiles < 0 {
+		return fmt.Errorf("invalid archive limits: max_extract_size and max_extract_files must be >= 0")
+	}
+
+	if c.Trash.RetentionDays < 0 {
+		return fmt.Errorf("invalid trash retention_days: %d (must be >= 0)", c.Trash.RetentionDays)
+	}
+
+	if c.History.MaxVersions < 0 || c.History.MaxTotalSize < 0 || c.History.MaxFileSize < 0 {
+		return fmt.Errorf("invalid history limits: max_versions, max_total_size and max_file_size must be >= 0")
+	}
+
+	if c.SearchIndex.ScanInterval < 0 || c.SearchIndex.MaxFileSize < 0 {
+		return fmt.Errorf("invalid search_index settings: scan_interval and max_file_size must be >= 0")
+	}
+	if c.SearchIndex.Enabled && len(c.SearchIndex.Roots) == 0 {
+		return fmt.Errorf("search_index is enabled but has no roots")
+	}
+
+	if c.Media.MaxFileSize <= 0 || c.Media.MaxPixels <= 0 || c.Media.Workers < 1 || c.Media.ThumbnailCache < 0 {
+		return fmt.Errorf("invalid media limits: max_file_size, max_pixels and workers must be > 0 and thumbnail_cache >= 0")
+	}
+
 	// At least one auth method is required
 	hasAPIKey := c.APIKey != ""
 	hasJWT := c.JWTPublicKey != "" || c.JWTPublicKeyFile != ""
@@ -269,3 +530,25 @@ func getDefaultShell() string {
 	}
 	return "/bin/bash"
 }
+
+// LegacyAPISunsetTime returns the parsed legacy API sunset date (zero if unset)
+func (c *Config) LegacyAPISunsetTime() time.Time {
+	if c.LegacyAPISunset == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.DateOnly, c.LegacyAPISunset)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// TrashRetention returns how long trashed items are kept (0 = forever)
+func (c *Config) TrashRetention() time.Duration {
+	return time.Duration(c.Trash.RetentionDays) * 24 * time.Hour
+}
+
+// IdempotencyTTLDuration returns how long idempotent responses are kept (0 = disabled)
+func (c *Config) IdempotencyTTLDuration() time.Duration {
+	return time.Duration(c.IdempotencyTTL) * time.Hour
+}
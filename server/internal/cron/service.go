@@ -3,22 +3,40 @@ package cron
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"strings"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/internal/listing"
 )
 
 var (
-	ErrCronNotFound = errors.New("cron not available")
+	ErrCronNotFound    = apperr.New(apperr.CodeNotAvailable, "cron not available")
+	ErrInvalidSchedule = apperr.New(apperr.CodeInvalidSchedule, "invalid schedule")
+	ErrInvalidCommand  = apperr.New(apperr.CodeInvalidCommand, "invalid command")
 )
 
 // Service provides cron job management operations.
 type Service struct {
 	available bool
+	events    *events.Bus
+}
+
+// SetEventBus sets the bus crontab edits are published on
+func (s *Service) SetEventBus(bus *events.Bus) {
+	s.events = bus
+}
+
+// ChangeEvent is published when a user crontab entry is added or removed
+type ChangeEvent struct {
+	Action   string `json:"action"` // "added" or "removed"
+	Schedule string `json:"schedule"`
+	Command  string `json:"command"`
 }
 
 // NewService creates a new cron service.
@@ -57,7 +75,7 @@ type CronSchedule struct {
 func ParseSchedule(schedule string) (*CronSchedule, error) {
 	parts := strings.Fields(schedule)
 	if len(parts) < 5 {
-		return nil, fmt.Errorf("invalid schedule: expected 5 fields, got %d", len(parts))
+		return nil, ErrInvalidSchedule.WithMessage(fmt.Sprintf("invalid schedule: expected 5 fields, got %d", len(parts)))
 	}
 
 	return &CronSchedule{
@@ -121,6 +139,38 @@ func (s *Service) ListSystemJobs() ([]CronJob, error) {
 	return jobs, nil
 }
 
+// ListSpec describes how cron job listings can be sorted. Without a sort,
+// jobs keep their crontab order.
+var ListSpec = listing.Spec{
+	SortFields: []string{"schedule", "command", "source", "user"},
+}
+
+var jobSortFields = map[string]listing.Key[CronJob]{
+	"schedule": func(job CronJob) any { return job.Schedule },
+	"command":  func(job CronJob) any { return job.Command },
+	"source":   func(job CronJob) any { return job.Source },
+	"user":     func(job CronJob) any { return job.User },
+}
+
+// jobID identifies a cron job by where it comes from and what it runs
+func jobID(job CronJob) string {
+	return strings.Join([]string{job.Source, job.User, job.Schedule, job.Command}, "\x00")
+}
+
+// Query filters, sorts and pages a list of cron jobs, returning the page and
+// its metadata. Name globs match the command and status filters match the
+// source ("user", "system", "etc").
+func Query(jobs []CronJob, f listing.Filter, p listing.Params) ([]CronJob, listing.Meta) {
+	matched := []CronJob{}
+	for _, job := range jobs {
+		if f.MatchName(job.Command) && f.MatchStatus(job.Source) {
+			matched = append(matched, job)
+		}
+	}
+
+	return listing.Page(matched, p, jobSortFields, jobID)
+}
+
 // ListAllJobs returns all cron jobs (user and system).
 func (s *Service) ListAllJobs() ([]CronJob, error) {
 	var allJobs []CronJob
@@ -293,7 +343,7 @@ func parseCronLine(line string) *CronJob {
 func (s *Service) AddJob(schedule, command string) error {
 	// Reject embedded newlines to prevent cron injection
 	if strings.ContainsAny(schedule, "\n\r") || strings.ContainsAny(command, "\n\r") {
-		return fmt.Errorf("schedule and command must not contain newlines")
+		return ErrInvalidCommand.WithMessage("schedule and command must not contain newlines")
 	}
 
 	// Get current crontab
@@ -308,7 +358,11 @@ func (s *Service) AddJob(schedule, command string) error {
 	newCrontab += fmt.Sprintf("%s %s\n", schedule, command)
 
 	// Write new crontab
-	return s.writeCrontab(newCrontab)
+	if err := s.writeCrontab(newCrontab); err != nil {
+		return err
+	}
+	s.events.Publish(events.TopicCronChanged, ChangeEvent{Action: "added", Schedule: schedule, Command: command})
+	return nil
 }
 
 // RemoveJob removes a cron job for the current user.
@@ -333,7 +387,11 @@ func (s *Service) RemoveJob(schedule, command string) error {
 	}
 
 	// Write new crontab
-	return s.writeCrontab(strings.Join(newLines, "\n") + "\n")
+	if err := s.writeCrontab(strings.Join(newLines, "\n") + "\n"); err != nil {
+		return err
+	}
+	s.events.Publish(events.TopicCronChanged, ChangeEvent{Action: "removed", Schedule: schedule, Command: command})
+	return nil
 }
 
 func (s *Service) writeCrontab(content string) error {
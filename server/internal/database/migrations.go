@@ -137,4 +137,117 @@ var migrations = []migration{
 			CREATE INDEX idx_pinned_folders_path ON pinned_folders(path);
 		`,
 	},
+	{
+		version: 4,
+		name:    "create_webhooks_tables",
+		sql: `
+			CREATE TABLE webhooks (
+				id TEXT PRIMARY KEY,
+				url TEXT NOT NULL,
+				secret TEXT NOT NULL,
+				events TEXT NOT NULL,
+				active INTEGER NOT NULL DEFAULT 1,
+				created_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL
+			);
+
+			CREATE TABLE webhook_deliveries (
+				id TEXT PRIMARY KEY,
+				webhook_id TEXT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+				event_id INTEGER NOT NULL,
+				topic TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending',
+				attempts INTEGER NOT NULL DEFAULT 0,
+				next_attempt_at DATETIME,
+				status_code INTEGER,
+				error TEXT,
+				response TEXT,
+				redelivery_of TEXT,
+				created_at DATETIME NOT NULL,
+				delivered_at DATETIME
+			);
+
+			CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+			CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at);
+		`,
+	},
+	{
+		version: 5,
+		name:    "create_idempotency_keys_table",
+		sql: `
+			CREATE TABLE idempotency_keys (
+				scope TEXT NOT NULL,
+				key TEXT NOT NULL,
+				fingerprint TEXT NOT NULL,
+				status_code INTEGER NOT NULL,
+				headers TEXT NOT NULL,
+				body BLOB NOT NULL,
+				created_at DATETIME NOT NULL,
+				expires_at DATETIME NOT NULL,
+				PRIMARY KEY (scope, key)
+			);
+
+			CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+		`,
+	},
+	{
+		version: 6,
+		name:    "create_trash_items_table",
+		sql: `
+			CREATE TABLE trash_items (
+				id TEXT PRIMARY KEY,
+				original_path TEXT NOT NULL,
+				trash_dir TEXT NOT NULL,
+				name TEXT NOT NULL,
+				type TEXT NOT NULL,
+				size INTEGER NOT NULL DEFAULT 0,
+				deleted_at DATETIME NOT NULL,
+				UNIQUE (trash_dir, name)
+			);
+
+			CREATE INDEX idx_trash_items_deleted_at ON trash_items(deleted_at);
+		`,
+	},
+	{
+		version: 7,
+		name:    "create_file_versions_table",
+		sql: `
+			CREATE TABLE file_versions (
+				id TEXT PRIMARY KEY,
+				path TEXT NOT NULL,
+				hash TEXT NOT NULL,
+				size INTEGER NOT NULL DEFAULT 0,
+				op TEXT NOT NULL,
+				created_at DATETIME NOT NULL
+			);
+
+			CREATE INDEX idx_file_versions_path ON file_versions(path, created_at);
+			CREATE INDEX idx_file_versions_hash ON file_versions(hash);
+			CREATE INDEX idx_file_versions_created_at ON file_versions(created_at);
+		`,
+	},
+	{
+		version: 8,
+		name:    "create_search_index_tables",
+		sql: `
+			CREATE TABLE search_files (
+				id INTEGER PRIMARY KEY,
+				root TEXT NOT NULL,
+				path TEXT NOT NULL UNIQUE,
+				name TEXT NOT NULL,
+				type TEXT NOT NULL,
+				size INTEGER NOT NULL DEFAULT 0,
+				mtime INTEGER NOT NULL DEFAULT 0
+			);
+
+			CREATE INDEX idx_search_files_root ON search_files(root);
+
+			CREATE VIRTUAL TABLE search_fts USING fts5(
+				name,
+				content,
+				tokenize = 'unicode61 remove_diacritics 2'
+			);
+		`,
+	},
 }
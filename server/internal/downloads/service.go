@@ -12,14 +12,26 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ss497254/gloski/internal/apperr"
 	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/internal/listing"
+)
+
+var (
+	ErrDownloadNotFound  = apperr.New(apperr.CodeDownloadNotFound, "download not found")
+	ErrInvalidState      = apperr.New(apperr.CodeInvalidState, "download is in the wrong state for this operation")
+	ErrQueueFull         = apperr.New(apperr.CodeQueueFull, "download queue is full, please try again later")
+	ErrInvalidURL        = apperr.New(apperr.CodeInvalidURL, "invalid URL")
+	ErrShareLinkNotFound = apperr.New(apperr.CodeShareLinkNotFound, "share link not found")
 )
 
 // Config holds configuration for the download service
 type Config struct {
-	MaxConcurrent int    // Maximum concurrent downloads
-	MaxRetries    int    // Maximum retry attempts
-	BaseURL       string // Base URL for share links
+	MaxConcurrent int         // Maximum concurrent downloads
+	MaxRetries    int         // Maximum retry attempts
+	BaseURL       string      // Base URL for share links
+	Events        *events.Bus // Bus progress and state events are published on (optional)
 }
 
 // Service manages downloads
@@ -43,6 +55,8 @@ type Service struct {
 	// Shutdown flag
 	shuttingDown bool
 	shutdownMu   sync.RWMutex
+
+	events *events.Bus
 }
 
 // speedTracker tracks download speed using a sliding window
@@ -114,6 +128,7 @@ func NewService(db *database.Database, config Config) (*Service, error) {
 		activeDownloads: make(map[string]context.CancelFunc),
 		queue:           make(chan string, 100),
 		speedTrackers:   make(map[string]*speedTracker),
+		events:          config.Events,
 	}
 
 	// Load existing downloads from database
@@ -154,6 +169,33 @@ func NewService(db *database.Database, config Config) (*Service, error) {
 	return s, nil
 }
 
+// publishState publishes a snapshot of the download after a state change.
+// Terminal outcomes are also published on their own topics so webhooks can
+// subscribe to just completions or failures.
+func (s *Service) publishState(id string) {
+	if s.events == nil {
+		return
+	}
+	s.mu.RLock()
+	d, ok := s.downloads[id]
+	var cp Download
+	if ok {
+		cp = *d
+	}
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	s.events.Publish(events.TopicDownloadState, cp)
+	switch cp.Status {
+	case StatusCompleted:
+		s.events.Publish(events.TopicDownloadCompleted, cp)
+	case StatusFailed:
+		s.events.Publish(events.TopicDownloadFailed, cp)
+	}
+}
+
 // worker processes downloads from the queue
 func (s *Service) worker() {
 	defer s.wg.Done()
@@ -191,6 +233,7 @@ func (s *Service) processDownload(id string) {
 
 	// Persist status change
 	s.store.Update(download)
+	s.publishState(id)
 
 	// Create cancellable context
 	ctx, cancel := context.WithCancel(context.Background())
@@ -222,8 +265,11 @@ func (s *Service) processDownload(id string) {
 	}
 
 	// Progress callback with throttling to reduce lock contention
-	var lastProgressUpdate time.Time
-	const progressUpdateInterval = 100 * time.Millisecond
+	var lastProgressUpdate, lastProgressEvent time.Time
+	const (
+		progressUpdateInterval = 100 * time.Millisecond
+		progressEventInterval  = time.Second
+	)
 	onProgress := func(downloaded int64) {
 		now := time.Now()
 		if now.Sub(lastProgressUpdate) < progressUpdateInterval {
@@ -242,7 +288,18 @@ func (s *Service) processDownload(id string) {
 		s.mu.Lock()
 		download.Progress = downloaded
 		download.Speed = speed
+		total := download.Total
 		s.mu.Unlock()
+
+		if now.Sub(lastProgressEvent) >= progressEventInterval {
+			lastProgressEvent = now
+			s.events.Publish(events.TopicDownloadProgress, ProgressEvent{
+				ID:       id,
+				Progress: downloaded,
+				Total:    total,
+				Speed:    speed,
+			})
+		}
 	}
 
 	// Execute download
@@ -255,6 +312,7 @@ func (s *Service) processDownload(id string) {
 			if download.Status == StatusPaused || download.Status == StatusCancelled {
 				s.mu.Unlock()
 				s.store.Update(download)
+				s.publishState(id)
 				return
 			}
 		}
@@ -270,6 +328,7 @@ func (s *Service) processDownload(id string) {
 			download.Error = ""
 			s.mu.Unlock()
 			s.store.Update(download)
+			s.publishState(id)
 			go func() { s.queue <- id }()
 			return
 		}
@@ -285,26 +344,27 @@ func (s *Service) processDownload(id string) {
 	s.mu.Unlock()
 
 	s.store.Update(download)
+	s.publishState(id)
 }
 
 // validateDownloadURL checks that the URL is safe to fetch (prevents SSRF)
 func validateDownloadURL(rawURL string) error {
 	u, err := neturl.Parse(rawURL)
 	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
+		return apperr.Wrap(err, apperr.CodeInvalidURL, "invalid URL")
 	}
 	// Only allow http and https schemes
 	if u.Scheme != "http" && u.Scheme != "https" {
-		return fmt.Errorf("unsupported URL scheme: %s (only http/https allowed)", u.Scheme)
+		return ErrInvalidURL.WithMessage(fmt.Sprintf("unsupported URL scheme: %s (only http/https allowed)", u.Scheme))
 	}
 	// Block requests to localhost and private IPs
 	host := u.Hostname()
 	if host == "localhost" || host == "127.0.0.1" || host == "::1" || host == "0.0.0.0" {
-		return fmt.Errorf("downloads from localhost are not allowed")
+		return ErrInvalidURL.WithMessage("downloads from localhost are not allowed")
 	}
 	// Block common private network ranges
 	if strings.HasPrefix(host, "10.") || strings.HasPrefix(host, "192.168.") || strings.HasPrefix(host, "169.254.") {
-		return fmt.Errorf("downloads from private networks are not allowed")
+		return ErrInvalidURL.WithMessage("downloads from private networks are not allowed")
 	}
 	// Block 172.16.0.0/12
 	if strings.HasPrefix(host, "172.") {
@@ -314,7 +374,7 @@ func validateDownloadURL(rawURL string) error {
 				var octet int
 				fmt.Sscanf(parts[1], "%d", &octet)
 				if octet >= 16 && octet <= 31 {
-					return fmt.Errorf("downloads from private networks are not allowed")
+					return ErrInvalidURL.WithMessage("downloads from private networks are not allowed")
 				}
 			}
 		}
@@ -387,6 +447,7 @@ func (s *Service) Add(url, destination, filename string) (*Download, error) {
 
 	s.mu.Lock()
 	s.downloads[download.ID] = download
+	added := *download
 	s.mu.Unlock()
 
 	// Add to queue with backpressure handling
@@ -399,24 +460,45 @@ func (s *Service) Add(url, destination, filename string) (*Download, error) {
 		delete(s.downloads, download.ID)
 		s.mu.Unlock()
 		s.store.Delete(download.ID)
-		return nil, fmt.Errorf("download queue is full, please try again later")
+		return nil, ErrQueueFull
 	}
 
+	s.events.Publish(events.TopicDownloadState, added)
 	return download, nil
 }
 
-// List returns all downloads
-func (s *Service) List() []*Download {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// ListSpec describes how download listings can be sorted
+var ListSpec = listing.Spec{
+	SortFields:   []string{"created_at", "filename", "status", "total", "progress"},
+	DefaultSort:  "created_at",
+	DefaultOrder: listing.OrderDesc,
+}
 
+var downloadSortFields = map[string]listing.Key[*Download]{
+	"created_at": func(d *Download) any { return d.CreatedAt },
+	"filename":   func(d *Download) any { return listing.Fold(d.Filename) },
+	"status":     func(d *Download) any { return string(d.Status) },
+	"total":      func(d *Download) any { return d.Total },
+	"progress":   func(d *Download) any { return d.Progress },
+}
+
+// List returns a page of downloads matching the filter and its metadata.
+// Name globs match the filename and size ranges the total size.
+func (s *Service) List(f listing.Filter, p listing.Params) ([]*Download, listing.Meta) {
+	s.mu.RLock()
 	downloads := make([]*Download, 0, len(s.downloads))
 	for _, d := range s.downloads {
+		if !f.MatchStatus(string(d.Status)) || !f.MatchName(d.Filename) ||
+			!f.MatchCreated(d.CreatedAt) || !f.MatchSize(d.Total) {
+			continue
+		}
 		// Return copies to avoid data races with worker goroutines
 		cp := *d
 		downloads = append(downloads, &cp)
 	}
-	return downloads
+	s.mu.RUnlock()
+
+	return listing.Page(downloads, p, downloadSortFields, func(d *Download) string { return d.ID })
 }
 
 // Get returns a download by ID
@@ -426,7 +508,7 @@ func (s *Service) Get(id string) (*Download, error) {
 
 	download, exists := s.downloads[id]
 	if !exists {
-		return nil, fmt.Errorf("download not found")
+		return nil, ErrDownloadNotFound
 	}
 	// Return a copy to avoid data races with worker goroutines
 	cp := *download
@@ -439,12 +521,12 @@ func (s *Service) Pause(id string) error {
 	download, exists := s.downloads[id]
 	if !exists {
 		s.mu.Unlock()
-		return fmt.Errorf("download not found")
+		return ErrDownloadNotFound
 	}
 
 	if !download.CanPause() {
 		s.mu.Unlock()
-		return fmt.Errorf("download cannot be paused")
+		return ErrInvalidState.WithMessage("download cannot be paused")
 	}
 
 	download.Status = StatusPaused
@@ -459,6 +541,7 @@ func (s *Service) Pause(id string) error {
 	s.activeMu.Unlock()
 
 	s.store.Update(download)
+	s.publishState(id)
 	return nil
 }
 
@@ -468,22 +551,23 @@ func (s *Service) Resume(id string) error {
 	download, exists := s.downloads[id]
 	if !exists {
 		s.mu.Unlock()
-		return fmt.Errorf("download not found")
+		return ErrDownloadNotFound
 	}
 
 	if !download.CanResume() {
 		s.mu.Unlock()
-		return fmt.Errorf("download cannot be resumed")
+		return ErrInvalidState.WithMessage("download cannot be resumed")
 	}
 
 	download.Status = StatusPending
 	s.mu.Unlock()
 
 	s.store.Update(download)
+	s.publishState(id)
 	select {
 	case s.queue <- id:
 	default:
-		return fmt.Errorf("download queue is full, please try again later")
+		return ErrQueueFull
 	}
 	return nil
 }
@@ -494,12 +578,12 @@ func (s *Service) Cancel(id string) error {
 	download, exists := s.downloads[id]
 	if !exists {
 		s.mu.Unlock()
-		return fmt.Errorf("download not found")
+		return ErrDownloadNotFound
 	}
 
 	if !download.CanCancel() {
 		s.mu.Unlock()
-		return fmt.Errorf("download cannot be cancelled")
+		return ErrInvalidState.WithMessage("download cannot be cancelled")
 	}
 
 	download.Status = StatusCancelled
@@ -517,6 +601,7 @@ func (s *Service) Cancel(id string) error {
 	CleanupPartialFile(download.Destination, download.Filename)
 
 	s.store.Update(download)
+	s.publishState(id)
 	return nil
 }
 
@@ -526,12 +611,12 @@ func (s *Service) Retry(id string) error {
 	download, exists := s.downloads[id]
 	if !exists {
 		s.mu.Unlock()
-		return fmt.Errorf("download not found")
+		return ErrDownloadNotFound
 	}
 
 	if !download.CanRetry() {
 		s.mu.Unlock()
-		return fmt.Errorf("download cannot be retried")
+		return ErrInvalidState.WithMessage("download cannot be retried")
 	}
 
 	download.Status = StatusPending
@@ -544,10 +629,11 @@ func (s *Service) Retry(id string) error {
 	CleanupPartialFile(download.Destination, download.Filename)
 
 	s.store.Update(download)
+	s.publishState(id)
 	select {
 	case s.queue <- id:
 	default:
-		return fmt.Errorf("download queue is full, please try again later")
+		return ErrQueueFull
 	}
 	return nil
 }
@@ -558,7 +644,7 @@ func (s *Service) Delete(id string, deleteFile bool) error {
 	download, exists := s.downloads[id]
 	if !exists {
 		s.mu.Unlock()
-		return fmt.Errorf("download not found")
+		return ErrDownloadNotFound
 	}
 
 	// Cancel if active
@@ -590,6 +676,7 @@ func (s *Service) Delete(id string, deleteFile bool) error {
 	delete(s.downloads, id)
 	s.mu.Unlock()
 
+	s.events.Publish(events.TopicDownloadState, DeletedEvent{ID: id, Deleted: true})
 	return nil
 }
 
@@ -599,12 +686,12 @@ func (s *Service) CreateShareLink(id string, expiresIn *int) (*ShareLink, error)
 	download, exists := s.downloads[id]
 	if !exists {
 		s.mu.Unlock()
-		return nil, fmt.Errorf("download not found")
+		return nil, ErrDownloadNotFound
 	}
 
 	if download.Status != StatusCompleted {
 		s.mu.Unlock()
-		return nil, fmt.Errorf("can only share completed downloads")
+		return nil, ErrInvalidState.WithMessage("can only share completed downloads")
 	}
 	s.mu.Unlock()
 
@@ -641,12 +728,12 @@ func (s *Service) RevokeShareLink(id, token string) error {
 	download, exists := s.downloads[id]
 	if !exists {
 		s.mu.Unlock()
-		return fmt.Errorf("download not found")
+		return ErrDownloadNotFound
 	}
 
 	if !download.RemoveShareLink(token) {
 		s.mu.Unlock()
-		return fmt.Errorf("share link not found")
+		return ErrShareLinkNotFound
 	}
 	s.mu.Unlock()
 
@@ -664,7 +751,7 @@ func (s *Service) GetByShareToken(token string) (*Download, error) {
 	download, _, err := s.store.GetByShareToken(token)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("invalid or expired share link")
+			return nil, ErrShareLinkNotFound.WithMessage("invalid or expired share link")
 		}
 		return nil, err
 	}
@@ -105,3 +105,17 @@ type AddDownloadRequest struct {
 type CreateShareRequest struct {
 	ExpiresIn *int `json:"expires_in,omitempty"` // Seconds, nil = never expires
 }
+
+// ProgressEvent is published while a download is transferring
+type ProgressEvent struct {
+	ID       string `json:"id"`
+	Progress int64  `json:"progress"`
+	Total    int64  `json:"total"`
+	Speed    int64  `json:"speed"`
+}
+
+// DeletedEvent is published when a download is removed
+type DeletedEvent struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted"`
+}
@@ -0,0 +1,247 @@
+// Package events implements the in-process event bus behind /api/events.
+//
+// Services publish events on dotted topics ("jobs.started",
+// "downloads.progress", ...). Subscribers select topics with patterns and can
+// resume after a disconnect by passing the ID of the last event they saw; the
+// bus keeps a bounded history for replay.
+package events
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Topics published by the server
+const (
+	TopicJobStarted  = "jobs.started"
+	TopicJobFinished = "jobs.finished"
+	TopicJobFailed   = "jobs.failed"
+
+	TopicDownloadProgress  = "downloads.progress"
+	TopicDownloadState     = "downloads.state"
+	TopicDownloadCompleted = "downloads.completed"
+	TopicDownloadFailed    = "downloads.failed"
+
+	TopicFileChanged   = "files.changed"
+	TopicFileOperation = "files.operation"
+
+	TopicCronChanged = "cron.changed"
+
+	TopicTerminalOpened = "terminal.opened"
+	TopicTerminalClosed = "terminal.closed"
+)
+
+const (
+	// DefaultHistorySize is the number of events kept for Last-Event-ID replay
+	DefaultHistorySize = 1024
+
+	// subscriberBuffer is the per-subscriber queue length. Subscribers that
+	// fall this far behind are closed and must resume from history.
+	subscriberBuffer = 256
+)
+
+// Event is a single message on the bus
+type Event struct {
+	ID    uint64      `json:"id"`
+	Topic string      `json:"topic"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+// Bus fans published events out to subscribers
+type Bus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event // ring buffer
+	head    int     // index of the oldest event
+	count   int
+	subs    map[*Subscription]struct{}
+}
+
+// NewBus creates a bus keeping historySize events for replay
+func NewBus(historySize int) *Bus {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Bus{
+		// Seed IDs from the clock so they keep increasing across restarts and
+		// a client resuming with a stale ID gets the full history instead of
+		// nothing.
+		nextID:  uint64(time.Now().UnixMilli()) * 1000,
+		history: make([]Event, historySize),
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends an event to all matching subscribers. Publishing on a nil bus
+// is a no-op, so services don't need to check whether events are wired up.
+func (b *Bus) Publish(topic string, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	ev := Event{ID: b.nextID, Topic: topic, Time: time.Now().UTC(), Data: data}
+
+	idx := (b.head + b.count) % len(b.history)
+	b.history[idx] = ev
+	if b.count < len(b.history) {
+		b.count++
+	} else {
+		b.head = (b.head + 1) % len(b.history)
+	}
+
+	for sub := range b.subs {
+		if !sub.matches(topic) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			// Too slow: drop the subscriber rather than block publishers.
+			// The client reconnects with Last-Event-ID and replays the gap.
+			b.removeLocked(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for events matching patterns (all events
+// if patterns is empty). Events published after afterID that are still in
+// history are returned for replay; truncated is true when history no longer
+// reaches back to afterID. allow, if non-nil, further restricts which topics
+// are delivered.
+func (b *Bus) Subscribe(patterns []string, afterID uint64, allow func(topic string) bool) (sub *Subscription, replay []Event, truncated bool) {
+	sub = &Subscription{
+		bus:   b,
+		ch:    make(chan Event, subscriberBuffer),
+		allow: allow,
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+	sub.SetTopics(patterns)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if afterID > 0 {
+		if b.count > 0 && b.history[b.head].ID > afterID+1 {
+			truncated = true
+		}
+		for i := 0; i < b.count; i++ {
+			ev := b.history[(b.head+i)%len(b.history)]
+			if ev.ID > afterID && sub.matches(ev.Topic) {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	b.subs[sub] = struct{}{}
+	return sub, replay, truncated
+}
+
+// LastID returns the ID of the most recently published event
+func (b *Bus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nextID
+}
+
+// SubscriberCount returns the number of active subscribers
+func (b *Bus) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+func (b *Bus) removeLocked(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Subscription is a live registration on the bus
+type Subscription struct {
+	bus   *Bus
+	ch    chan Event
+	allow func(topic string) bool
+
+	mu       sync.RWMutex
+	patterns []string
+}
+
+// C returns the channel events are delivered on. It is closed when the
+// subscription ends, either through Close or because the subscriber fell
+// too far behind.
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Topics returns the subscribed topic patterns
+func (s *Subscription) Topics() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.patterns...)
+}
+
+// SetTopics replaces the subscribed topic patterns
+func (s *Subscription) SetTopics(patterns []string) {
+	clean := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" && !slices.Contains(clean, p) {
+			clean = append(clean, p)
+		}
+	}
+
+	s.mu.Lock()
+	s.patterns = clean
+	s.mu.Unlock()
+}
+
+// Close unregisters the subscription
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.removeLocked(s)
+}
+
+func (s *Subscription) matches(topic string) bool {
+	if s.allow != nil && !s.allow(topic) {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.patterns {
+		if Match(p, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether topic matches pattern. "*" matches everything, and a
+// pattern matches its own topic and everything below it: "jobs" and "jobs.*"
+// both match "jobs.started".
+func Match(pattern, topic string) bool {
+	if pattern == "*" || pattern == topic {
+		return true
+	}
+	return strings.HasPrefix(topic, strings.TrimSuffix(pattern, ".*")+".")
+}
+
+// ParseTopics splits a comma-separated topic list
+func ParseTopics(s string) []string {
+	var topics []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
@@ -0,0 +1,335 @@
+package files
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+// POSIX ACLs are stored by Linux as extended attributes in this binary layout
+const (
+	aclAccessXattr  = "system.posix_acl_access"
+	aclDefaultXattr = "system.posix_acl_default"
+
+	aclVersion     = 2
+	aclUndefinedID = 0xFFFFFFFF
+
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclGroup    = 0x08
+	aclMask     = 0x10
+	aclOther    = 0x20
+)
+
+var aclTagNames = map[uint16]string{
+	aclUserObj:  "user",
+	aclUser:     "user",
+	aclGroupObj: "group",
+	aclGroup:    "group",
+	aclMask:     "mask",
+	aclOther:    "other",
+}
+
+// ACL is the POSIX access control list of a file, as getfacl prints it:
+// entries like "user::rw-", "user:alice:r--", "group::r--", "mask::r--" and
+// "other::---". Default entries only exist on directories.
+type ACL struct {
+	Access  []string `json:"access"`
+	Default []string `json:"default,omitempty"`
+}
+
+type aclEntry struct {
+	tag  uint16
+	perm uint16
+	id   uint32
+}
+
+// GetACL returns the ACL of a file or directory. Files without an extended
+// ACL report the entries equivalent to their mode.
+func (s *Service) GetACL(path string) (*ACL, error) {
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	acl := &ACL{}
+	raw, err := getXattr(absPath, aclAccessXattr)
+	switch {
+	case errors.Is(err, ErrXattrNotFound), errors.Is(err, ErrXattrUnsupported):
+		acl.Access = formatACL(modeACL(info.Mode()))
+	case err != nil:
+		return nil, err
+	default:
+		entries, err := decodeACL(raw)
+		if err != nil {
+			return nil, err
+		}
+		acl.Access = formatACL(entries)
+	}
+
+	if info.IsDir() {
+		raw, err := getXattr(absPath, aclDefaultXattr)
+		if err == nil {
+			entries, err := decodeACL(raw)
+			if err != nil {
+				return nil, err
+			}
+			acl.Default = formatACL(entries)
+		} else if !errors.Is(err, ErrXattrNotFound) && !errors.Is(err, ErrXattrUnsupported) {
+			return nil, err
+		}
+	}
+	return acl, nil
+}
+
+// SetACL replaces the access ACL and, for directories, the default ACL.
+// A nil list leaves that ACL unchanged; an empty default list removes the
+// default ACL. The mask entry is computed when named entries need one.
+func (s *Service) SetACL(path string, access, defaults *[]string) error {
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return err
+	}
+	if isProtectedPath(absPath) {
+		return ErrDangerousPath
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return err
+	}
+
+	if access != nil {
+		entries, err := parseACL(*access)
+		if err != nil {
+			return err
+		}
+		if err := setXattr(absPath, aclAccessXattr, encodeACL(entries)); err != nil {
+			return err
+		}
+	}
+
+	if defaults != nil {
+		if !info.IsDir() {
+			return ErrNotDirectory.WithMessage("only directories have a default ACL")
+		}
+		if len(*defaults) == 0 {
+			if err := removeXattr(absPath, aclDefaultXattr); err != nil && !errors.Is(err, ErrXattrNotFound) {
+				return err
+			}
+		} else {
+			entries, err := parseACL(*defaults)
+			if err != nil {
+				return err
+			}
+			if err := setXattr(absPath, aclDefaultXattr, encodeACL(entries)); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.changed(OpXattr, absPath, "")
+	return nil
+}
+
+// modeACL returns the minimal ACL equivalent to a file mode
+func modeACL(mode os.FileMode) []aclEntry {
+	perm := uint16(mode.Perm())
+	return []aclEntry{
+		{tag: aclUserObj, perm: perm >> 6 & 7, id: aclUndefinedID},
+		{tag: aclGroupObj, perm: perm >> 3 & 7, id: aclUndefinedID},
+		{tag: aclOther, perm: perm & 7, id: aclUndefinedID},
+	}
+}
+
+func decodeACL(raw []byte) ([]aclEntry, error) {
+	if len(raw) < 4 || (len(raw)-4)%8 != 0 || binary.LittleEndian.Uint32(raw) != aclVersion {
+		return nil, fmt.Errorf("malformed ACL attribute")
+	}
+	entries := make([]aclEntry, 0, (len(raw)-4)/8)
+	for b := raw[4:]; len(b) >= 8; b = b[8:] {
+		entries = append(entries, aclEntry{
+			tag:  binary.LittleEndian.Uint16(b),
+			perm: binary.LittleEndian.Uint16(b[2:]),
+			id:   binary.LittleEndian.Uint32(b[4:]),
+		})
+	}
+	return entries, nil
+}
+
+func encodeACL(entries []aclEntry) []byte {
+	raw := make([]byte, 4+8*len(entries))
+	binary.LittleEndian.PutUint32(raw, aclVersion)
+	for i, e := range entries {
+		b := raw[4+8*i:]
+		binary.LittleEndian.PutUint16(b, e.tag)
+		binary.LittleEndian.PutUint16(b[2:], e.perm)
+		binary.LittleEndian.PutUint32(b[4:], e.id)
+	}
+	return raw
+}
+
+func formatACL(entries []aclEntry) []string {
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		qualifier := ""
+		switch e.tag {
+		case aclUser:
+			qualifier = strconv.FormatUint(uint64(e.id), 10)
+			if u, err := user.LookupId(qualifier); err == nil {
+				qualifier = u.Username
+			}
+		case aclGroup:
+			qualifier = strconv.FormatUint(uint64(e.id), 10)
+			if g, err := user.LookupGroupId(qualifier); err == nil {
+				qualifier = g.Name
+			}
+		}
+		out = append(out, aclTagNames[e.tag]+":"+qualifier+":"+formatPerm(e.perm))
+	}
+	return out
+}
+
+func formatPerm(perm uint16) string {
+	b := []byte("---")
+	if perm&4 != 0 {
+		b[0] = 'r'
+	}
+	if perm&2 != 0 {
+		b[1] = 'w'
+	}
+	if perm&1 != 0 {
+		b[2] = 'x'
+	}
+	return string(b)
+}
+
+// parseACL parses getfacl-style entries, checks the required entries are
+// present once, adds a mask if named entries need one, and sorts the entries
+// the way the kernel expects
+func parseACL(lines []string) ([]aclEntry, error) {
+	invalid := func(line, reason string) error {
+		return apperr.New(apperr.CodeInvalidParameter, "invalid ACL entry: "+reason).
+			WithDetails(map[string]string{"entry": line})
+	}
+
+	var entries []aclEntry
+	seen := make(map[[2]uint32]bool)
+	for _, line := range lines {
+		parts := strings.Split(strings.TrimSpace(line), ":")
+		if len(parts) != 3 {
+			return nil, invalid(line, "expected type:qualifier:perms")
+		}
+		kind, qualifier, perms := parts[0], parts[1], parts[2]
+
+		e := aclEntry{id: aclUndefinedID}
+		switch kind {
+		case "user", "u":
+			e.tag = aclUserObj
+			if qualifier != "" {
+				uid, err := ParseOwner(qualifier)
+				if err != nil {
+					return nil, err
+				}
+				e.tag, e.id = aclUser, uint32(uid)
+			}
+		case "group", "g":
+			e.tag = aclGroupObj
+			if qualifier != "" {
+				gid, err := ParseGroup(qualifier)
+				if err != nil {
+					return nil, err
+				}
+				e.tag, e.id = aclGroup, uint32(gid)
+			}
+		case "mask", "m":
+			e.tag = aclMask
+		case "other", "o":
+			e.tag = aclOther
+		default:
+			return nil, invalid(line, "unknown entry type")
+		}
+		if (e.tag == aclMask || e.tag == aclOther) && qualifier != "" {
+			return nil, invalid(line, "mask and other entries take no qualifier")
+		}
+
+		perm, ok := parsePerm(perms)
+		if !ok {
+			return nil, invalid(line, "permissions must look like rwx, r-x or 5")
+		}
+		e.perm = perm
+
+		key := [2]uint32{uint32(e.tag), e.id}
+		if seen[key] {
+			return nil, invalid(line, "duplicate entry")
+		}
+		seen[key] = true
+		entries = append(entries, e)
+	}
+
+	var hasNamed, hasMask bool
+	var groupClass uint16
+	for _, e := range entries {
+		switch e.tag {
+		case aclUser, aclGroup:
+			hasNamed = true
+			groupClass |= e.perm
+		case aclGroupObj:
+			groupClass |= e.perm
+		case aclMask:
+			hasMask = true
+		}
+	}
+	for _, tag := range []uint16{aclUserObj, aclGroupObj, aclOther} {
+		if !seen[[2]uint32{uint32(tag), aclUndefinedID}] {
+			return nil, apperr.New(apperr.CodeInvalidParameter, "ACL needs user::, group:: and other:: entries")
+		}
+	}
+	if hasNamed && !hasMask {
+		entries = append(entries, aclEntry{tag: aclMask, perm: groupClass, id: aclUndefinedID})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].tag != entries[j].tag {
+			return entries[i].tag < entries[j].tag
+		}
+		return entries[i].id < entries[j].id
+	})
+	return entries, nil
+}
+
+// parsePerm accepts symbolic ("r-x", "rw") or octal ("5") permissions
+func parsePerm(s string) (uint16, bool) {
+	if len(s) == 1 && s[0] >= '0' && s[0] <= '7' {
+		return uint16(s[0] - '0'), true
+	}
+	if s == "" || len(s) > 3 {
+		return 0, false
+	}
+	var perm uint16
+	for _, c := range s {
+		switch c {
+		case 'r':
+			perm |= 4
+		case 'w':
+			perm |= 2
+		case 'x':
+			perm |= 1
+		case '-':
+		default:
+			return 0, false
+		}
+	}
+	return perm, true
+}
@@ -0,0 +1,740 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+var (
+	ErrUnsupportedArchive = apperr.New(apperr.CodeUnsupportedArchive, "unsupported archive format")
+	ErrUnsafeArchive      = apperr.New(apperr.CodeUnsafeArchive, "archive entry points outside the destination")
+	ErrArchiveTooLarge    = apperr.New(apperr.CodeArchiveTooLarge, "archive exceeds the extraction limits")
+)
+
+// ArchiveFormat is a supported archive container and compression
+type ArchiveFormat string
+
+const (
+	ArchiveZip    ArchiveFormat = "zip"
+	ArchiveTar    ArchiveFormat = "tar"
+	ArchiveTarGz  ArchiveFormat = "tar.gz"
+	ArchiveTarZst ArchiveFormat = "tar.zst"
+)
+
+// ParseArchiveFormat parses a format name, defaulting to zip
+func ParseArchiveFormat(s string) (ArchiveFormat, error) {
+	switch ArchiveFormat(s) {
+	case "", ArchiveZip:
+		return ArchiveZip, nil
+	case ArchiveTar, ArchiveTarGz, ArchiveTarZst:
+		return ArchiveFormat(s), nil
+	case "tgz":
+		return ArchiveTarGz, nil
+	}
+	return "", ErrUnsupportedArchive.WithDetails(map[string]interface{}{
+		"format":    s,
+		"supported": []ArchiveFormat{ArchiveZip, ArchiveTar, ArchiveTarGz, ArchiveTarZst},
+	})
+}
+
+// Ext returns the file extension for the format, including the dot
+func (f ArchiveFormat) Ext() string {
+	return "." + string(f)
+}
+
+// ContentType returns the MIME type for the format
+func (f ArchiveFormat) ContentType() string {
+	switch f {
+	case ArchiveTar:
+		return "application/x-tar"
+	case ArchiveTarGz:
+		return "application/gzip"
+	case ArchiveTarZst:
+		return "application/zstd"
+	}
+	return "application/zip"
+}
+
+// Archive is a set of validated paths ready to be streamed as one archive
+type Archive struct {
+	format  ArchiveFormat
+	sources []string
+}
+
+// NewArchive validates paths for archiving. Everything is checked up front,
+// so errors can still be reported before any of the archive is written.
+func (s *Service) NewArchive(paths []string, format ArchiveFormat) (*Archive, error) {
+	if len(paths) == 0 {
+		return nil, apperr.New(apperr.CodeBadRequest, "path is required")
+	}
+	sources := make([]string, 0, len(paths))
+	for _, p := range paths {
+		absPath, err := s.validatePath(p)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Lstat(absPath); os.IsNotExist(err) {
+			return nil, ErrPathNotFound.WithDetails(map[string]string{"path": p})
+		} else if err != nil {
+			return nil, err
+		}
+		sources = append(sources, absPath)
+	}
+	return &Archive{format: format, sources: sources}, nil
+}
+
+// Name suggests a file name: the source's name for a single path, otherwise "archive"
+func (a *Archive) Name() string {
+	name := "archive"
+	if len(a.sources) == 1 {
+		if base := filepath.Base(a.sources[0]); base != "/" && base != "." {
+			name = base
+		}
+	}
+	return name + a.format.Ext()
+}
+
+// ContentType returns the MIME type of the archive
+func (a *Archive) ContentType() string {
+	return a.format.ContentType()
+}
+
+// archiveWriter adds entries to an archive of one format
+type archiveWriter interface {
+	add(name string, info os.FileInfo, link string, r io.Reader) error
+	Close() error
+}
+
+// Write streams the archive to w without temporary files. Each source is
+// stored under its own name; symlinks are stored as links and not followed.
+// Entries that can't be read for lack of permission are left out.
+func (a *Archive) Write(ctx context.Context, w io.Writer) error {
+	var aw archiveWriter
+	var closers []io.Closer
+	switch a.format {
+	case ArchiveZip:
+		zw := zip.NewWriter(w)
+		aw = &zipArchiveWriter{zw}
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		closers = append(closers, gz)
+		aw = &tarArchiveWriter{tar.NewWriter(gz)}
+	case ArchiveTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		closers = append(closers, zw)
+		aw = &tarArchiveWriter{tar.NewWriter(zw)}
+	default:
+		aw = &tarArchiveWriter{tar.NewWriter(w)}
+	}
+
+	c := &copier{ctx: ctx}
+	for _, src := range a.sources {
+		if err := a.addTree(c, aw, src); err != nil {
+			return err
+		}
+	}
+
+	if err := aw.Close(); err != nil {
+		return err
+	}
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archive) addTree(c *copier, aw archiveWriter, src string) error {
+	root := filepath.Base(src)
+	if root == "/" || root == "." {
+		root = "root"
+	}
+
+	return filepath.WalkDir(src, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return err
+		}
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(root, filepath.ToSlash(rel))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return aw.add(name+"/", info, "", nil)
+
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return aw.add(name, info, link, nil)
+
+		case d.Type().IsRegular():
+			f, err := os.Open(p)
+			if errors.Is(err, fs.ErrPermission) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			defer f.Close()
+			return aw.add(name, info, "", &cancelReader{c: c, r: io.LimitReader(f, info.Size())})
+		}
+		return nil // Devices, sockets and pipes are left out
+	})
+}
+
+// cancelReader stops reading once the copier's context is done
+type cancelReader struct {
+	c *copier
+	r io.Reader
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	if err := r.c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipArchiveWriter) add(name string, info os.FileInfo, link string, r io.Reader) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if r != nil {
+		hdr.Method = zip.Deflate
+	} else {
+		hdr.Method = zip.Store
+	}
+
+	out, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	if link != "" {
+		_, err = io.WriteString(out, link) // Zip stores the link target as the content
+		return err
+	}
+	if r != nil {
+		_, err = io.Copy(out, r)
+	}
+	return err
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.zw.Close()
+}
+
+type tarArchiveWriter struct {
+	tw *tar.Writer
+}
+
+func (w *tarArchiveWriter) add(name string, info os.FileInfo, link string, r io.Reader) error {
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if r != nil {
+		_, err = io.Copy(w.tw, r)
+	}
+	return err
+}
+
+func (w *tarArchiveWriter) Close() error {
+	return w.tw.Close()
+}
+
+// sniffArchive detects the format of an archive from its first bytes.
+// Compressed streams are assumed to hold a tar archive.
+func sniffArchive(path string) (ArchiveFormat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return sniffArchiveHeader(head[:n])
+}
+
+func sniffArchiveHeader(head []byte) (ArchiveFormat, error) {
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ArchiveZip, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return ArchiveTarGz, nil
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return ArchiveTarZst, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return ArchiveTar, nil
+	}
+	return "", ErrUnsupportedArchive
+}
+
+// extractor unpacks archive entries below dest. Entry names must be local
+// paths, symlinks and hard links must point inside dest, and no write ever
+// goes through a symlink leading out of dest or outside the allowed paths.
+type extractor struct {
+	*copier
+	svc  *Service
+	dest string // Resolved destination directory
+
+	maxSize  int64 // 0 = unlimited
+	maxFiles int   // 0 = unlimited
+	written  int64
+	entries  int
+
+	dirs []dirMode
+}
+
+type dirMode struct {
+	path string
+	info os.FileInfo
+}
+
+// extractArchive unpacks the archive at src into dest
+func (e *extractor) extractArchive(src string) error {
+	format, err := sniffArchive(src)
+	if err != nil {
+		return err
+	}
+
+	if format == ArchiveZip {
+		err = e.extractZip(src)
+	} else {
+		err = e.extractTar(src, format)
+	}
+
+	// Directories get their mode and times once their contents are in place.
+	// The owner always keeps access so the result can be cleaned up.
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		d := e.dirs[i]
+		if chErr := os.Chmod(d.path, d.info.Mode().Perm()|0700); chErr != nil && err == nil {
+			err = chErr
+		}
+		os.Chtimes(d.path, d.info.ModTime(), d.info.ModTime())
+	}
+
+	if errors.Is(err, zip.ErrInsecurePath) || errors.Is(err, tar.ErrInsecurePath) {
+		return ErrUnsafeArchive
+	}
+	return err
+}
+
+// zipTotals returns the entry count and declared uncompressed size of a zip
+// archive. The sizes may lie, so writes are still limited as they happen.
+func zipTotals(src string) (int, int64, error) {
+	r, err := zip.OpenReader(src)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return 0, 0, err
+	}
+	defer r.Close()
+
+	var size int64
+	for _, f := range r.File {
+		size += int64(f.UncompressedSize64)
+	}
+	return len(r.File), size, nil
+}
+
+func (e *extractor) extractZip(src string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return err
+	}
+	defer r.Close()
+
+	if e.maxFiles > 0 && len(r.File) > e.maxFiles {
+		return e.tooLarge()
+	}
+
+	for _, f := range r.File {
+		if err := e.ctx.Err(); err != nil {
+			return err
+		}
+		info := f.FileInfo()
+
+		err := func() error {
+			var body io.Reader
+			if !info.IsDir() {
+				rc, err := f.Open()
+				if err != nil {
+					return err
+				}
+				defer rc.Close()
+				body = rc
+			}
+
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				target, err := io.ReadAll(io.LimitReader(body, 4096))
+				if err != nil {
+					return err
+				}
+				link = string(target)
+			}
+			return e.entry(f.Name, info, link, false, body)
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *extractor) extractTar(src string, format ArchiveFormat) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	switch format {
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case ArchiveTarZst:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		if err := e.ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		info := hdr.FileInfo()
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeDir, tar.TypeSymlink:
+			err = e.entry(hdr.Name, info, hdr.Linkname, false, tr)
+		case tar.TypeLink:
+			err = e.entry(hdr.Name, info, hdr.Linkname, true, nil)
+		default:
+			// Devices, pipes and unknown types are counted but not created
+			if err = e.count(); err == nil {
+				e.file(hdr.Name, true)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// count adds one entry against the file count limit
+func (e *extractor) count() error {
+	e.entries++
+	if e.maxFiles > 0 && e.entries > e.maxFiles {
+		return e.tooLarge()
+	}
+	return nil
+}
+
+func (e *extractor) tooLarge() error {
+	return ErrArchiveTooLarge.WithDetails(map[string]interface{}{
+		"max_files": e.maxFiles,
+		"max_size":  e.maxSize,
+	})
+}
+
+// entryPath checks an archive entry name and returns it as a clean relative path
+func entryPath(name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimSuffix(name, "/")))
+	if !filepath.IsLocal(rel) {
+		return "", ErrUnsafeArchive.WithDetails(map[string]string{"entry": name})
+	}
+	return rel, nil
+}
+
+// entry creates one archive entry. For hard links, link is the archive path
+// of the file being linked to.
+func (e *extractor) entry(name string, info os.FileInfo, link string, hardlink bool, body io.Reader) error {
+	if err := e.count(); err != nil {
+		return err
+	}
+	rel, err := entryPath(name)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		target := filepath.Join(e.dest, rel)
+		_, statErr := os.Lstat(target)
+		if _, err := e.mkdirs(rel); err != nil {
+			return err
+		}
+		if os.IsNotExist(statErr) {
+			e.dirs = append(e.dirs, dirMode{target, info})
+		}
+		return nil
+	}
+
+	parent, err := e.mkdirs(filepath.Dir(rel))
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(parent, filepath.Base(rel))
+
+	if existing, err := os.Lstat(target); err == nil {
+		switch {
+		case e.conflict == ConflictRename:
+			target = freeName(target)
+		case e.conflict == ConflictOverwrite && !existing.IsDir():
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		case e.conflict == ConflictFail:
+			return ErrPathExists.WithDetails(map[string]string{"path": ToTildePath(target)})
+		default:
+			e.file(target, true)
+			return nil
+		}
+	}
+
+	switch {
+	case hardlink:
+		linkRel, err := entryPath(link)
+		if err != nil {
+			return err
+		}
+		linkParent, err := e.resolveDir(filepath.Dir(linkRel))
+		if err != nil {
+			return err
+		}
+		existing := filepath.Join(linkParent, filepath.Base(linkRel))
+		if li, err := os.Lstat(existing); err != nil || !li.Mode().IsRegular() {
+			return ErrUnsafeArchive.WithDetails(map[string]string{"entry": name, "link": link})
+		}
+		if err := os.Link(existing, target); err != nil {
+			return err
+		}
+
+	case info.Mode()&os.ModeSymlink != 0:
+		resolved, ok := resolveLink(filepath.Dir(target), link)
+		if !ok || !e.inside(resolved) {
+			return ErrUnsafeArchive.WithDetails(map[string]string{"entry": name, "link": link})
+		}
+		if err := os.Symlink(link, target); err != nil {
+			return err
+		}
+
+	default:
+		if err := e.writeFile(target, info, body); err != nil {
+			return err
+		}
+	}
+
+	e.file(target, false)
+	return nil
+}
+
+// writeFile creates target with the entry's contents, never following an
+// existing file or link at target
+func (e *extractor) writeFile(target string, info os.FileInfo, body io.Reader) error {
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := e.stream(&limitedWriter{e: e, w: out}, body); err != nil {
+		out.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(target)
+		return err
+	}
+
+	if err := os.Chmod(target, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(target, info.ModTime(), info.ModTime())
+}
+
+// limitedWriter fails once the extraction writes more than the size limit
+type limitedWriter struct {
+	e *extractor
+	w io.Writer
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.e.maxSize > 0 && w.e.written+int64(len(p)) > w.e.maxSize {
+		return 0, w.e.tooLarge()
+	}
+	n, err := w.w.Write(p)
+	w.e.written += int64(n)
+	return n, err
+}
+
+// mkdirs creates the directories of rel below dest one at a time and returns
+// the resolved directory. Symlinked components must stay inside dest, so
+// nothing is ever created outside it.
+func (e *extractor) mkdirs(rel string) (string, error) {
+	return e.walkDirs(rel, true)
+}
+
+// resolveDir resolves an existing directory below dest like mkdirs, without
+// creating anything
+func (e *extractor) resolveDir(rel string) (string, error) {
+	return e.walkDirs(rel, false)
+}
+
+func (e *extractor) walkDirs(rel string, create bool) (string, error) {
+	dir := e.dest
+	if rel == "." {
+		return dir, nil
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		next := filepath.Join(dir, part)
+		info, err := os.Lstat(next)
+		switch {
+		case os.IsNotExist(err) && create:
+			if err := os.Mkdir(next, 0755); err != nil {
+				return "", err
+			}
+		case err != nil:
+			return "", err
+		case info.Mode()&os.ModeSymlink != 0:
+			resolved, err := filepath.EvalSymlinks(next)
+			if err != nil {
+				return "", err
+			}
+			if !e.inside(resolved) {
+				return "", ErrUnsafeArchive.WithDetails(map[string]string{"path": ToTildePath(next)})
+			}
+			if ri, err := os.Stat(resolved); err != nil || !ri.IsDir() {
+				return "", ErrNotDirectory.WithDetails(map[string]string{"path": ToTildePath(next)})
+			}
+			next = resolved
+		case !info.IsDir():
+			return "", ErrNotDirectory.WithDetails(map[string]string{"path": ToTildePath(next)})
+		}
+		dir = next
+	}
+	return dir, nil
+}
+
+// resolveLink returns where a symlink in dir reading link points, following
+// the links on the way that already exist, such as ones extracted earlier.
+// Past a missing component the rest is taken as it is, unless it goes back
+// up with "..", since a later entry could make that component a link. It
+// reports false for such links and for link loops.
+func resolveLink(dir, link string) (string, bool) {
+	cur := dir
+	if filepath.IsAbs(link) {
+		cur = string(filepath.Separator)
+	}
+	parts := strings.Split(link, string(filepath.Separator))
+	for hops := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			cur = filepath.Dir(cur)
+			continue
+		}
+
+		next := filepath.Join(cur, part)
+		info, err := os.Lstat(next)
+		switch {
+		case os.IsNotExist(err):
+			if slices.Contains(parts, "..") {
+				return "", false
+			}
+			return filepath.Join(append([]string{next}, parts...)...), true
+		case err != nil:
+			return "", false
+		case info.Mode()&os.ModeSymlink == 0:
+			cur = next
+			continue
+		}
+
+		if hops++; hops > 40 {
+			return "", false
+		}
+		dest, err := os.Readlink(next)
+		if err != nil {
+			return "", false
+		}
+		if filepath.IsAbs(dest) {
+			cur = string(filepath.Separator)
+		}
+		parts = append(strings.Split(dest, string(filepath.Separator)), parts...)
+	}
+	return cur, true
+}
+
+// inside reports whether an absolute path is within dest and allowed by config
+func (e *extractor) inside(p string) bool {
+	if p != e.dest && !strings.HasPrefix(p, e.dest+string(filepath.Separator)) {
+		return false
+	}
+	return e.svc.config.IsPathAllowed(p)
+}
@@ -0,0 +1,353 @@
+package files
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+var (
+	ErrXattrNotFound    = apperr.New(apperr.CodeXattrNotFound, "extended attribute not found")
+	ErrXattrUnsupported = apperr.New(apperr.CodeXattrUnsupported, "extended attributes or ACLs are not supported on this filesystem")
+	ErrXattrTooLarge    = apperr.New(apperr.CodeInvalidParameter, "extended attribute value too large")
+	ErrUnknownOwner     = apperr.New(apperr.CodeUnknownOwner, "unknown user or group")
+)
+
+// MaxXattrSize is the largest extended attribute value accepted (the Linux limit)
+const MaxXattrSize = 64 * 1024
+
+// ownerNameTTL is how long resolved user and group names are cached
+const ownerNameTTL = time.Minute
+
+// ownerNames caches uid and gid to name lookups, which read /etc/passwd and
+// /etc/group (or NSS) on every call
+var ownerNames = struct {
+	mu      sync.Mutex
+	users   map[uint32]string
+	groups  map[uint32]string
+	expires time.Time
+}{}
+
+// lookupOwnerNames returns the user and group names for uid and gid, or ""
+// for ids without a name
+func lookupOwnerNames(uid, gid uint32) (string, string) {
+	ownerNames.mu.Lock()
+	defer ownerNames.mu.Unlock()
+
+	if now := time.Now(); now.After(ownerNames.expires) {
+		ownerNames.users = make(map[uint32]string)
+		ownerNames.groups = make(map[uint32]string)
+		ownerNames.expires = now.Add(ownerNameTTL)
+	}
+
+	userName, ok := ownerNames.users[uid]
+	if !ok {
+		if u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10)); err == nil {
+			userName = u.Username
+		}
+		ownerNames.users[uid] = userName
+	}
+	groupName, ok := ownerNames.groups[gid]
+	if !ok {
+		if g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10)); err == nil {
+			groupName = g.Name
+		}
+		ownerNames.groups[gid] = groupName
+	}
+	return userName, groupName
+}
+
+// fillOwnership adds owner, link and inode details from a stat result to an entry
+func fillOwnership(e *FileEntry, absPath string, info os.FileInfo) {
+	if uid, gid, inode, nlink, ok := ownership(info); ok {
+		e.UID = uid
+		e.GID = gid
+		e.Owner, e.Group = lookupOwnerNames(uid, gid)
+		e.Inode = inode
+		e.Links = nlink
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		e.LinkTarget, _ = os.Readlink(absPath)
+	}
+}
+
+// ParseOwner resolves a user name or numeric uid. An empty string gives -1,
+// which leaves the owner unchanged.
+func ParseOwner(owner string) (int, error) {
+	if owner == "" {
+		return -1, nil
+	}
+	if id, err := strconv.Atoi(owner); err == nil && id >= 0 {
+		return id, nil
+	}
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return 0, ErrUnknownOwner.WithDetails(map[string]string{"user": owner})
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// ParseGroup resolves a group name or numeric gid. An empty string gives -1,
+// which leaves the group unchanged.
+func ParseGroup(group string) (int, error) {
+	if group == "" {
+		return -1, nil
+	}
+	if id, err := strconv.Atoi(group); err == nil && id >= 0 {
+		return id, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, ErrUnknownOwner.WithDetails(map[string]string{"group": group})
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// ChmodRecursive sets fileMode on every file and dirMode on every directory
+// in a tree and returns how many entries were changed. Symlinks are skipped:
+// chmod would change what they point to.
+func (s *Service) ChmodRecursive(path string, fileMode, dirMode os.FileMode) (int, error) {
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return 0, err
+	}
+	if isProtectedPath(absPath) {
+		return 0, ErrDangerousPath
+	}
+
+	changed := 0
+	err = filepath.WalkDir(absPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		mode := fileMode
+		switch {
+		case d.Type()&os.ModeSymlink != 0:
+			return nil
+		case d.IsDir():
+			mode = dirMode
+		}
+		if err := os.Chmod(p, mode.Perm()); err != nil {
+			return err
+		}
+		changed++
+		return nil
+	})
+	if changed > 0 {
+		s.changed(OpChmod, absPath, "")
+	}
+	return changed, err
+}
+
+// Chown changes the owner and group of a file or directory. Pass -1 to keep
+// either one.
+func (s *Service) Chown(path string, uid, gid int) error {
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return err
+	}
+	if isProtectedPath(absPath) {
+		return ErrDangerousPath
+	}
+
+	if err := os.Lchown(absPath, uid, gid); err != nil {
+		return err
+	}
+	s.changed(OpChown, absPath, "")
+	return nil
+}
+
+// ChownRecursive changes the owner and group of everything in a tree and
+// returns how many entries were changed. Symlinks themselves are changed,
+// never their targets.
+func (s *Service) ChownRecursive(path string, uid, gid int) (int, error) {
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return 0, err
+	}
+	if isProtectedPath(absPath) {
+		return 0, ErrDangerousPath
+	}
+
+	changed := 0
+	err = filepath.WalkDir(absPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := os.Lchown(p, uid, gid); err != nil {
+			return err
+		}
+		changed++
+		return nil
+	})
+	if changed > 0 {
+		s.changed(OpChown, absPath, "")
+	}
+	return changed, err
+}
+
+// Symlink creates a symbolic link at linkPath pointing to target. A relative
+// target is kept relative; either way what it points to must be an allowed path.
+func (s *Service) Symlink(target, linkPath string) error {
+	absLink, err := s.validatePath(linkPath)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return apperr.New(apperr.CodeBadRequest, "target is required")
+	}
+
+	if expanded, err := ExpandTilde(target); err == nil {
+		target = expanded
+	}
+	resolved := target
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(filepath.Dir(absLink), resolved)
+	}
+	if _, err := s.validatePath(resolved); err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(absLink); err == nil {
+		return ErrPathExists
+	}
+	if err := os.Symlink(target, absLink); err != nil {
+		return err
+	}
+	s.changed(OpSymlink, absLink, "")
+	return nil
+}
+
+// Link creates a hard link at newPath to the existing file at existingPath
+func (s *Service) Link(existingPath, newPath string) error {
+	absExisting, err := s.validatePath(existingPath)
+	if err != nil {
+		return err
+	}
+	absNew, err := s.validatePath(newPath)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(absExisting)
+	if os.IsNotExist(err) {
+		return ErrPathNotFound
+	} else if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return ErrIsDirectory.WithMessage("cannot hard link a directory")
+	}
+	if _, err := os.Lstat(absNew); err == nil {
+		return ErrPathExists
+	}
+
+	if err := os.Link(absExisting, absNew); err != nil {
+		return err
+	}
+	s.changed(OpLink, absNew, absExisting)
+	return nil
+}
+
+// Xattr is an extended attribute. Values that aren't valid UTF-8 are base64 encoded.
+type Xattr struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Encoding string `json:"encoding"` // "utf8" or "base64"
+}
+
+// EncodeXattr builds an Xattr for a raw value
+func EncodeXattr(name string, value []byte) Xattr {
+	if utf8.Valid(value) {
+		return Xattr{Name: name, Value: string(value), Encoding: "utf8"}
+	}
+	return Xattr{Name: name, Value: base64.StdEncoding.EncodeToString(value), Encoding: "base64"}
+}
+
+// DecodeXattrValue returns the raw value of an encoded attribute value
+func DecodeXattrValue(value, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "utf8":
+		return []byte(value), nil
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, apperr.New(apperr.CodeInvalidParameter, "value is not valid base64")
+		}
+		return b, nil
+	}
+	return nil, apperr.New(apperr.CodeInvalidParameter, "encoding must be utf8 or base64")
+}
+
+// ListXattrs returns the extended attributes of a file or directory, sorted by name
+func (s *Service) ListXattrs(path string) ([]Xattr, error) {
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := listXattr(absPath)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	attrs := make([]Xattr, 0, len(names))
+	for _, name := range names {
+		value, err := getXattr(absPath, name)
+		if errors.Is(err, ErrXattrNotFound) {
+			continue // Removed in the meantime
+		}
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, EncodeXattr(name, value))
+	}
+	return attrs, nil
+}
+
+// SetXattr creates or replaces an extended attribute
+func (s *Service) SetXattr(path, name string, value []byte) error {
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return apperr.New(apperr.CodeBadRequest, "name is required")
+	}
+	if len(value) > MaxXattrSize {
+		return ErrXattrTooLarge
+	}
+
+	if err := setXattr(absPath, name, value); err != nil {
+		return err
+	}
+	s.changed(OpXattr, absPath, "")
+	return nil
+}
+
+// RemoveXattr removes an extended attribute
+func (s *Service) RemoveXattr(path, name string) error {
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return apperr.New(apperr.CodeBadRequest, "name is required")
+	}
+
+	if err := removeXattr(absPath, name); err != nil {
+		return err
+	}
+	s.changed(OpXattr, absPath, "")
+	return nil
+}
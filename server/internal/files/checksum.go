@@ -0,0 +1,634 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/logger"
+	"lukechampine.com/blake3"
+)
+
+var (
+	ErrInvalidAlgorithm = apperr.New(apperr.CodeInvalidParameter, "algorithm must be md5, sha1, sha256, sha512 or blake3")
+	ErrInvalidChecksums = apperr.New(apperr.CodeInvalidParameter, "no checksums found")
+	ErrNotRegularFile   = apperr.New(apperr.CodeInvalidParameter, "only regular files and directories can be hashed")
+)
+
+// Checksum algorithms
+const (
+	ChecksumMD5    = "md5"
+	ChecksumSHA1   = "sha1"
+	ChecksumSHA256 = "sha256"
+	ChecksumSHA512 = "sha512"
+	ChecksumBLAKE3 = "blake3"
+
+	DefaultChecksumAlgorithm = ChecksumSHA256
+)
+
+// ChecksumSyncLimit is the most bytes a checksum request hashes before
+// responding; larger ones run as background operations
+const ChecksumSyncLimit = 256 << 20
+
+// Statuses of a VerifyEntry
+const (
+	VerifyOK      = "ok"
+	VerifyFailed  = "failed"  // Content doesn't match, or couldn't be read
+	VerifyMissing = "missing" // No such file
+)
+
+// newHasher returns a hash for the algorithm
+func newHasher(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case ChecksumMD5:
+		return md5.New(), nil
+	case ChecksumSHA1:
+		return sha1.New(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumSHA512:
+		return sha512.New(), nil
+	case ChecksumBLAKE3:
+		return blake3.New(32, nil), nil
+	}
+	return nil, ErrInvalidAlgorithm
+}
+
+// ChecksumRequest represents a request to hash a file or a directory tree
+type ChecksumRequest struct {
+	Path       string `json:"path"`
+	Algorithm  string `json:"algorithm,omitempty"`  // Default: sha256
+	Background bool   `json:"background,omitempty"` // Run as a background operation regardless of size
+}
+
+// ChecksumResult is the hash of a file, or the manifest of a directory tree
+type ChecksumResult struct {
+	Path      string `json:"path"`
+	Algorithm string `json:"algorithm"`
+	Type      string `json:"type"` // "file" or "directory"
+	Size      int64  `json:"size"` // Bytes hashed
+
+	// Files: the hash of the content. Directories: the hash of the
+	// manifest, so trees with the same files have the same hash.
+	Hash string `json:"hash"`
+
+	// Directories: each regular file by path relative to the directory, and
+	// the same in sha256sum format. Symlinks and special files are skipped.
+	Files    []ChecksumEntry `json:"files,omitempty"`
+	Manifest string          `json:"manifest,omitempty"`
+}
+
+// ChecksumEntry is one file in a directory manifest
+type ChecksumEntry struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// VerifyRequest represents checking files against a SHA256SUMS-style list,
+// given as content or as the path of the list
+type VerifyRequest struct {
+	Sums     string `json:"sums,omitempty"`
+	SumsPath string `json:"sums_path,omitempty"`
+
+	// Directory the names in the list are relative to. Default: the
+	// directory of SumsPath.
+	Base string `json:"base,omitempty"`
+
+	// Default: from a BSD-style tag, the list's file name (MD5SUMS,
+	// SHA1SUMS, ...), or the length of the hashes, where 64 means sha256
+	Algorithm  string `json:"algorithm,omitempty"`
+	Background bool   `json:"background,omitempty"`
+}
+
+// VerifyResult is the outcome of checking files against a checksum list
+type VerifyResult struct {
+	Base      string        `json:"base"`
+	Algorithm string        `json:"algorithm"`
+	OK        bool          `json:"ok"` // Every file matched
+	Passed    int           `json:"passed"`
+	Failed    int           `json:"failed"`
+	Missing   int           `json:"missing"`
+	Malformed int           `json:"malformed"` // Lines that aren't checksums
+	Files     []VerifyEntry `json:"files"`
+}
+
+// VerifyEntry is the outcome of checking one file
+type VerifyEntry struct {
+	Path      string `json:"path"` // As listed
+	Algorithm string `json:"algorithm"`
+	Status    string `json:"status"`
+	Expected  string `json:"expected"`
+	Actual    string `json:"actual,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// hashProgress receives progress while hashing; either func may be nil
+type hashProgress struct {
+	onBytes func(n int64)
+	onFile  func(path string)
+}
+
+func (p hashProgress) bytes(n int64) {
+	if p.onBytes != nil {
+		p.onBytes(n)
+	}
+}
+
+func (p hashProgress) file(path string) {
+	if p.onFile != nil {
+		p.onFile(path)
+	}
+}
+
+// progressWriter reports bytes written to it, and stops once ctx is done
+type progressWriter struct {
+	ctx      context.Context
+	progress hashProgress
+}
+
+func (w progressWriter) Write(b []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	w.progress.bytes(int64(len(b)))
+	return len(b), nil
+}
+
+// hashContent returns the hex hash of a file's content and its size
+func hashContent(ctx context.Context, path, algorithm string, progress hashProgress) (string, int64, error) {
+	h, err := newHasher(algorithm)
+	if err != nil {
+		return "", 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(io.MultiWriter(h, progressWriter{ctx: ctx, progress: progress}), f)
+	if err != nil {
+		return "", 0, err
+	}
+	progress.file(path)
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// Checksum hashes a file, or every regular file under a directory
+func (s *Service) Checksum(ctx context.Context, path, algorithm string) (*ChecksumResult, error) {
+	if algorithm == "" {
+		algorithm = DefaultChecksumAlgorithm
+	}
+	absPath, _, _, err := s.checksumTarget(ctx, path, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return checksum(ctx, absPath, algorithm, hashProgress{})
+}
+
+// checksumTarget validates a checksum request and returns the path to hash,
+// and the number and total size of the files hashing it reads
+func (s *Service) checksumTarget(ctx context.Context, path, algorithm string) (string, int, int64, error) {
+	if _, err := newHasher(algorithm); err != nil {
+		return "", 0, 0, err
+	}
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() {
+			return "", 0, 0, ErrNotRegularFile
+		}
+		return absPath, 1, info.Size(), nil
+	}
+
+	var files int
+	var size int64
+	err = filepath.WalkDir(absPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	return absPath, files, size, err
+}
+
+func checksum(ctx context.Context, absPath, algorithm string, progress hashProgress) (*ChecksumResult, error) {
+	result := &ChecksumResult{Path: ToTildePath(absPath), Algorithm: algorithm, Type: "file"}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		result.Hash, result.Size, err = hashContent(ctx, absPath, algorithm, progress)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	result.Type = "directory"
+	result.Files = []ChecksumEntry{}
+	var manifest strings.Builder
+	err = filepath.WalkDir(absPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		sum, size, err := hashContent(ctx, p, algorithm, progress)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(absPath, p)
+		if err != nil {
+			return err
+		}
+		result.Files = append(result.Files, ChecksumEntry{Path: rel, Hash: sum, Size: size})
+		result.Size += size
+		manifest.WriteString(formatChecksumLine(sum, rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Manifest = manifest.String()
+	h, _ := newHasher(algorithm)
+	h.Write([]byte(result.Manifest))
+	result.Hash = hex.EncodeToString(h.Sum(nil))
+	return result, nil
+}
+
+// formatChecksumLine formats a checksum like sha256sum does, escaping names
+// with backslashes or newlines
+func formatChecksumLine(sum, name string) string {
+	if strings.ContainsAny(name, "\\\n") {
+		name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		return "\\" + sum + "  " + name + "\n"
+	}
+	return sum + "  " + name + "\n"
+}
+
+// checksumLine is one entry of a checksum list
+type checksumLine struct {
+	name      string
+	sum       string
+	algorithm string // From a BSD-style tag, if any
+}
+
+// checksumTags maps the tags of BSD-style lines, "SHA256 (name) = hash"
+var checksumTags = map[string]string{
+	"MD5":    ChecksumMD5,
+	"SHA1":   ChecksumSHA1,
+	"SHA256": ChecksumSHA256,
+	"SHA512": ChecksumSHA512,
+	"BLAKE3": ChecksumBLAKE3,
+}
+
+// parseChecksums parses GNU-style lines, "hash  name" or "hash *name", and
+// BSD-style tagged lines. Blank lines and # comments are ignored; it returns
+// how many other lines couldn't be parsed.
+func parseChecksums(sums string) ([]checksumLine, int) {
+	var lines []checksumLine
+	malformed := 0
+	scanner := bufio.NewScanner(strings.NewReader(sums))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		line, ok := parseChecksumLine(text)
+		if !ok {
+			malformed++
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, malformed
+}
+
+func parseChecksumLine(text string) (checksumLine, bool) {
+	escaped := strings.HasPrefix(text, "\\")
+	if escaped {
+		text = text[1:]
+	}
+
+	var line checksumLine
+	if tag, rest, ok := strings.Cut(text, " ("); ok && checksumTags[tag] != "" {
+		i := strings.LastIndex(rest, ") = ")
+		if i < 0 {
+			return line, false
+		}
+		line = checksumLine{name: rest[:i], sum: rest[i+4:], algorithm: checksumTags[tag]}
+	} else {
+		sum, name, ok := strings.Cut(text, " ")
+		if !ok || name == "" || (name[0] != ' ' && name[0] != '*') {
+			return line, false
+		}
+		line = checksumLine{name: name[1:], sum: sum}
+	}
+
+	line.sum = strings.ToLower(line.sum)
+	if _, err := hex.DecodeString(line.sum); err != nil || line.sum == "" || line.name == "" {
+		return line, false
+	}
+	if escaped {
+		line.name = unescapeChecksumName(line.name)
+	}
+	return line, true
+}
+
+func unescapeChecksumName(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+1 < len(name) {
+			i++
+			if name[i] == 'n' {
+				sb.WriteByte('\n')
+				continue
+			}
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
+
+// guessChecksumAlgorithm picks the algorithm of a list from its file name,
+// then from the length of its first hash
+func guessChecksumAlgorithm(sumsPath string, lines []checksumLine) string {
+	name := strings.ToLower(filepath.Base(sumsPath))
+	for _, alg := range []string{ChecksumMD5, ChecksumSHA1, ChecksumSHA256, ChecksumSHA512, ChecksumBLAKE3} {
+		if strings.Contains(name, alg) {
+			return alg
+		}
+	}
+	if strings.HasPrefix(name, "b3") {
+		return ChecksumBLAKE3
+	}
+
+	for _, line := range lines {
+		if line.algorithm != "" {
+			return line.algorithm
+		}
+		switch len(line.sum) {
+		case 32:
+			return ChecksumMD5
+		case 40:
+			return ChecksumSHA1
+		case 128:
+			return ChecksumSHA512
+		}
+		return ChecksumSHA256
+	}
+	return DefaultChecksumAlgorithm
+}
+
+// checksumVerify is a parsed verify request
+type checksumVerify struct {
+	base      string
+	algorithm string
+	lines     []checksumLine
+	malformed int
+	size      int64 // Bytes of the listed files that exist
+}
+
+// VerifyChecksums checks files against a checksum list
+func (s *Service) VerifyChecksums(ctx context.Context, req VerifyRequest) (*VerifyResult, error) {
+	v, err := s.checksumVerify(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.verify(ctx, v, hashProgress{})
+}
+
+// checksumVerify reads and parses a verify request
+func (s *Service) checksumVerify(req VerifyRequest) (*checksumVerify, error) {
+	if req.Algorithm != "" {
+		if _, err := newHasher(req.Algorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	sums, base := req.Sums, req.Base
+	if req.SumsPath != "" {
+		absSums, err := s.validatePath(req.SumsPath)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(absSums)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, ErrIsDirectory
+		}
+		if info.Size() > MaxFileSize {
+			return nil, ErrFileTooLarge
+		}
+		content, err := os.ReadFile(absSums)
+		if err != nil {
+			return nil, err
+		}
+		sums = string(content)
+		if base == "" {
+			base = filepath.Dir(absSums)
+		}
+	}
+	if base == "" {
+		return nil, apperr.New(apperr.CodeBadRequest, "base is required with sums")
+	}
+	absBase, err := s.validatePath(base)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(absBase); err != nil || !info.IsDir() {
+		return nil, ErrNotDirectory
+	}
+
+	lines, malformed := parseChecksums(sums)
+	if len(lines) == 0 {
+		return nil, ErrInvalidChecksums
+	}
+	v := &checksumVerify{base: absBase, algorithm: req.Algorithm, lines: lines, malformed: malformed}
+	if v.algorithm == "" {
+		v.algorithm = guessChecksumAlgorithm(req.SumsPath, lines)
+	}
+	for _, line := range lines {
+		if info, err := os.Stat(v.path(line)); err == nil && info.Mode().IsRegular() {
+			v.size += info.Size()
+		}
+	}
+	return v, nil
+}
+
+// path returns where a listed file is
+func (v *checksumVerify) path(line checksumLine) string {
+	if filepath.IsAbs(line.name) {
+		return filepath.Clean(line.name)
+	}
+	return filepath.Join(v.base, line.name)
+}
+
+func (s *Service) verify(ctx context.Context, v *checksumVerify, progress hashProgress) (*VerifyResult, error) {
+	result := &VerifyResult{
+		Base:      ToTildePath(v.base),
+		Algorithm: v.algorithm,
+		Malformed: v.malformed,
+		Files:     make([]VerifyEntry, 0, len(v.lines)),
+	}
+	for _, line := range v.lines {
+		entry := VerifyEntry{Path: line.name, Algorithm: v.algorithm, Expected: line.sum}
+		if line.algorithm != "" {
+			entry.Algorithm = line.algorithm
+		}
+
+		absPath, err := s.validatePath(v.path(line))
+		if err == nil {
+			entry.Actual, _, err = hashContent(ctx, absPath, entry.Algorithm, progress)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		switch {
+		case os.IsNotExist(err):
+			entry.Status = VerifyMissing
+			result.Missing++
+		case err != nil:
+			entry.Status = VerifyFailed
+			entry.Error = checksumError(err)
+			result.Failed++
+		case entry.Actual != entry.Expected:
+			entry.Status = VerifyFailed
+			result.Failed++
+		default:
+			entry.Status = VerifyOK
+			result.Passed++
+		}
+		result.Files = append(result.Files, entry)
+	}
+	result.OK = result.Failed == 0 && result.Missing == 0
+	return result, nil
+}
+
+// checksumError describes why a listed file couldn't be hashed
+func checksumError(err error) string {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// Checksum hashes a file or directory tree, returning the result when it's
+// at most ChecksumSyncLimit bytes. Larger requests, and those asking for it,
+// start a background operation instead, whose result is set on completion.
+func (o *Operations) Checksum(ctx context.Context, req ChecksumRequest) (*ChecksumResult, *Operation, error) {
+	if req.Algorithm == "" {
+		req.Algorithm = DefaultChecksumAlgorithm
+	}
+	absPath, files, size, err := o.svc.checksumTarget(ctx, req.Path, req.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !req.Background && size <= ChecksumSyncLimit {
+		result, err := checksum(ctx, absPath, req.Algorithm, hashProgress{})
+		return result, nil, err
+	}
+
+	op := &Operation{
+		ID:         uuid.New().String(),
+		Type:       OperationChecksum,
+		Sources:    []string{ToTildePath(absPath)},
+		Algorithm:  req.Algorithm,
+		TotalFiles: files,
+		TotalBytes: size,
+	}
+	logger.Info("File operation %s started: %s %s of %s", op.ID, op.Type, op.Algorithm, op.Sources[0])
+	return nil, o.launch(op, func(ctx context.Context) error {
+		result, err := checksum(ctx, absPath, req.Algorithm, o.hashProgress(op))
+		if err == nil {
+			o.update(op, false, func(op *Operation) { op.Result = result })
+		}
+		return err
+	}), nil
+}
+
+// Verify checks files against a checksum list, returning the result when
+// the files total at most ChecksumSyncLimit bytes. Like Checksum, larger
+// requests and those asking for it run as a background operation.
+func (o *Operations) Verify(ctx context.Context, req VerifyRequest) (*VerifyResult, *Operation, error) {
+	v, err := o.svc.checksumVerify(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !req.Background && v.size <= ChecksumSyncLimit {
+		result, err := o.svc.verify(ctx, v, hashProgress{})
+		return result, nil, err
+	}
+
+	op := &Operation{
+		ID:         uuid.New().String(),
+		Type:       OperationVerify,
+		Sources:    []string{ToTildePath(v.base)},
+		Algorithm:  v.algorithm,
+		TotalFiles: len(v.lines),
+		TotalBytes: v.size,
+	}
+	if req.SumsPath != "" {
+		op.Sources = []string{req.SumsPath}
+	}
+	logger.Info("File operation %s started: %s %d file(s) in %s", op.ID, op.Type, len(v.lines), ToTildePath(v.base))
+	return nil, o.launch(op, func(ctx context.Context) error {
+		result, err := o.svc.verify(ctx, v, o.hashProgress(op))
+		if err == nil {
+			o.update(op, false, func(op *Operation) { op.Result = result })
+		}
+		return err
+	}), nil
+}
+
+// hashProgress returns progress callbacks that report on op
+func (o *Operations) hashProgress(op *Operation) hashProgress {
+	return hashProgress{
+		onBytes: o.bytesProgress(op),
+		onFile: func(path string) {
+			o.update(op, false, func(op *Operation) {
+				op.FilesDone++
+				op.Current = ToTildePath(path)
+			})
+		},
+	}
+}
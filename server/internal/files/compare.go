@@ -0,0 +1,353 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+var (
+	ErrDiffMismatch   = apperr.New(apperr.CodeInvalidParameter, "cannot compare a file with a directory")
+	ErrInvalidCompare = apperr.New(apperr.CodeInvalidParameter, "compare must be size, mtime or hash")
+)
+
+// How directory comparisons decide whether a file changed
+const (
+	CompareSize  = "size"  // Sizes differ
+	CompareMtime = "mtime" // Sizes or modification times differ
+	CompareHash  = "hash"  // Contents differ, by SHA-256
+)
+
+// maxTreeDiffEntries bounds the entries a directory comparison returns
+const maxTreeDiffEntries = 10000
+
+// Statuses of a TreeDiffEntry
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// DiffOptions controls how Diff compares files and directories
+type DiffOptions struct {
+	Context           int    // Unchanged lines around each change
+	IgnoreWhitespace  bool   // Ignore all whitespace (diff -w)
+	IgnoreSpaceChange bool   // Ignore changes in the amount of whitespace (diff -b)
+	Compare           string // CompareSize (default), CompareMtime or CompareHash
+}
+
+// DiffResult is the comparison of two files or two directories
+type DiffResult struct {
+	Type      string `json:"type"` // "file" or "directory"
+	Old       string `json:"old"`
+	New       string `json:"new"`
+	Identical bool   `json:"identical"`
+
+	// Files: a unified diff, or for binary files and files too large to
+	// diff, only whether the bytes are identical
+	Binary bool   `json:"binary,omitempty"`
+	Diff   string `json:"diff,omitempty"`
+
+	// Directories: the entries that differ, by path
+	Entries   []TreeDiffEntry  `json:"entries,omitempty"`
+	Summary   *TreeDiffSummary `json:"summary,omitempty"`
+	Truncated bool             `json:"truncated,omitempty"` // More than maxTreeDiffEntries entries differ
+}
+
+// TreeDiffEntry is an entry that differs between two directory trees.
+// Only the top of an added or removed directory is listed.
+type TreeDiffEntry struct {
+	Path   string        `json:"path"` // Relative to both directories
+	Status string        `json:"status"`
+	Old    *TreeDiffSide `json:"old,omitempty"`
+	New    *TreeDiffSide `json:"new,omitempty"`
+}
+
+// TreeDiffSide describes an entry on one side of a directory comparison
+type TreeDiffSide struct {
+	Type     string    `json:"type"` // "file", "directory" or "symlink"
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Target   string    `json:"target,omitempty"` // Symlinks only
+}
+
+// TreeDiffSummary counts the outcome of a directory comparison
+type TreeDiffSummary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"` // Files and symlinks in both trees that compare equal
+}
+
+// Diff compares two files, giving a unified diff, or two directories,
+// listing the entries added, removed and changed from oldPath to newPath.
+// Symlinks inside directories are compared by target, not followed.
+func (s *Service) Diff(ctx context.Context, oldPath, newPath string, opts DiffOptions) (*DiffResult, error) {
+	switch opts.Compare {
+	case "", CompareSize, CompareMtime, CompareHash:
+	default:
+		return nil, ErrInvalidCompare
+	}
+
+	oldAbs, err := s.validatePath(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newAbs, err := s.validatePath(newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	oldInfo, err := os.Stat(oldAbs)
+	if err != nil {
+		return nil, err
+	}
+	newInfo, err := os.Stat(newAbs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DiffResult{Type: "file", Old: ToTildePath(oldAbs), New: ToTildePath(newAbs)}
+	switch {
+	case oldInfo.IsDir() && newInfo.IsDir():
+		result.Type = "directory"
+		result.Summary = &TreeDiffSummary{}
+		t := &treeDiff{ctx: ctx, opts: opts, result: result}
+		if err := t.compareDirs(oldAbs, newAbs, ""); err != nil {
+			return nil, err
+		}
+		result.Identical = len(result.Entries) == 0
+	case oldInfo.IsDir() || newInfo.IsDir():
+		return nil, ErrDiffMismatch
+	default:
+		if err := diffFiles(result, oldAbs, newAbs, oldInfo, newInfo, opts); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// diffFiles fills result with the unified diff of two files, or with a byte
+// comparison when either is binary or too large
+func diffFiles(result *DiffResult, oldAbs, newAbs string, oldInfo, newInfo os.FileInfo, opts DiffOptions) error {
+	if oldInfo.Size() > MaxFileSize || newInfo.Size() > MaxFileSize {
+		same, err := sameContent(oldAbs, newAbs, oldInfo, newInfo)
+		result.Binary, result.Identical = true, same
+		return err
+	}
+
+	oldContent, err := os.ReadFile(oldAbs)
+	if err != nil {
+		return err
+	}
+	newContent, err := os.ReadFile(newAbs)
+	if err != nil {
+		return err
+	}
+	if isBinary(oldContent) || isBinary(newContent) {
+		result.Binary, result.Identical = true, bytes.Equal(oldContent, newContent)
+		return nil
+	}
+
+	var normalize func(string) string
+	switch {
+	case opts.IgnoreWhitespace:
+		normalize = ignoreAllSpace
+	case opts.IgnoreSpaceChange:
+		normalize = ignoreSpaceChange
+	}
+	result.Diff = unifiedDiff(result.Old, result.New, string(oldContent), string(newContent), opts.Context, normalize)
+	result.Identical = result.Diff == ""
+	return nil
+}
+
+// sameContent reports whether two files have the same bytes
+func sameContent(a, b string, aInfo, bInfo os.FileInfo) (bool, error) {
+	if aInfo.Size() != bInfo.Size() {
+		return false, nil
+	}
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA, bufB := make([]byte, 64*1024), make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
+		}
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
+		}
+	}
+}
+
+// hashFile returns the hex SHA-256 of a file's content
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type treeDiff struct {
+	ctx    context.Context
+	opts   DiffOptions
+	result *DiffResult
+}
+
+func (t *treeDiff) add(entry TreeDiffEntry) {
+	switch entry.Status {
+	case DiffAdded:
+		t.result.Summary.Added++
+	case DiffRemoved:
+		t.result.Summary.Removed++
+	case DiffChanged:
+		t.result.Summary.Changed++
+	}
+	if len(t.result.Entries) >= maxTreeDiffEntries {
+		t.result.Truncated = true
+		return
+	}
+	t.result.Entries = append(t.result.Entries, entry)
+}
+
+// compareDirs compares two directories recursively; rel is their path
+// relative to the roots. Entries are visited depth first in name order.
+func (t *treeDiff) compareDirs(oldDir, newDir, rel string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	oldEntries, err := os.ReadDir(oldDir)
+	if err != nil {
+		return err
+	}
+	newEntries, err := os.ReadDir(newDir)
+	if err != nil {
+		return err
+	}
+
+	i, j := 0, 0
+	for i < len(oldEntries) || j < len(newEntries) {
+		var name string
+		switch {
+		case j >= len(newEntries) || (i < len(oldEntries) && oldEntries[i].Name() < newEntries[j].Name()):
+			name = oldEntries[i].Name()
+			i++
+			old, err := treeDiffSide(filepath.Join(oldDir, name))
+			if err != nil {
+				return err
+			}
+			t.add(TreeDiffEntry{Path: filepath.Join(rel, name), Status: DiffRemoved, Old: old})
+			continue
+		case i >= len(oldEntries) || newEntries[j].Name() < oldEntries[i].Name():
+			name = newEntries[j].Name()
+			j++
+			side, err := treeDiffSide(filepath.Join(newDir, name))
+			if err != nil {
+				return err
+			}
+			t.add(TreeDiffEntry{Path: filepath.Join(rel, name), Status: DiffAdded, New: side})
+			continue
+		default:
+			name = oldEntries[i].Name()
+			i++
+			j++
+		}
+
+		if err := t.compareEntry(filepath.Join(oldDir, name), filepath.Join(newDir, name), filepath.Join(rel, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compareEntry compares an entry present in both trees
+func (t *treeDiff) compareEntry(oldPath, newPath, rel string) error {
+	old, err := treeDiffSide(oldPath)
+	if err != nil {
+		return err
+	}
+	side, err := treeDiffSide(newPath)
+	if err != nil {
+		return err
+	}
+
+	changed := old.Type != side.Type
+	if !changed {
+		switch old.Type {
+		case "directory":
+			return t.compareDirs(oldPath, newPath, rel)
+		case "symlink":
+			changed = old.Target != side.Target
+		default:
+			changed = old.Size != side.Size
+			if !changed && t.opts.Compare == CompareMtime {
+				changed = !old.Modified.Equal(side.Modified)
+			}
+			if !changed && t.opts.Compare == CompareHash {
+				oldHash, err := hashFile(oldPath)
+				if err != nil {
+					return err
+				}
+				newHash, err := hashFile(newPath)
+				if err != nil {
+					return err
+				}
+				changed = oldHash != newHash
+			}
+		}
+	}
+
+	if !changed {
+		t.result.Summary.Unchanged++
+		return nil
+	}
+	t.add(TreeDiffEntry{Path: rel, Status: DiffChanged, Old: old, New: side})
+	return nil
+}
+
+func treeDiffSide(path string) (*TreeDiffSide, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	side := &TreeDiffSide{Type: "file", Size: info.Size(), Modified: info.ModTime()}
+	switch {
+	case info.IsDir():
+		side.Type, side.Size = "directory", 0
+	case info.Mode()&os.ModeSymlink != 0:
+		side.Type = "symlink"
+		if side.Target, err = os.Readlink(path); err != nil {
+			return nil, err
+		}
+	}
+	return side, nil
+}
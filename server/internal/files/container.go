@@ -0,0 +1,530 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// Bounds on what container parsing reads, so damaged or hostile files can't
+// make it read much or loop long
+const (
+	maxBoxes      = 1000      // MP4 boxes visited
+	maxBoxRead    = 64 * 1024 // Bytes of any MP4 box read into memory
+	maxTagSize    = 1 << 20   // Bytes of ID3 or Vorbis comment tags read
+	maxRIFFChunks = 64
+)
+
+// ContainerInfo is what an audio or video file's headers say about it
+type ContainerInfo struct {
+	Format   string            `json:"format"`             // mp4, mov, m4a, wav, flac, mp3, ogg, matroska or webm
+	Duration float64           `json:"duration,omitempty"` // Seconds
+	Bitrate  int               `json:"bitrate,omitempty"`  // Bits per second
+	Tracks   []MediaTrack      `json:"tracks,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"` // Title, artist, album and year, when tagged
+}
+
+// MediaTrack is an audio or video stream
+type MediaTrack struct {
+	Type          string `json:"type"` // audio or video
+	Codec         string `json:"codec,omitempty"`
+	Width         int    `json:"width,omitempty"`
+	Height        int    `json:"height,omitempty"`
+	SampleRate    int    `json:"sample_rate,omitempty"`
+	Channels      int    `json:"channels,omitempty"`
+	BitsPerSample int    `json:"bits_per_sample,omitempty"`
+}
+
+// readContainer identifies an audio or video container by its first bytes
+// and reads its headers, or returns nil if it isn't one
+func readContainer(r io.ReaderAt, head []byte, size int64) *ContainerInfo {
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		return readMP4(r, head, size)
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return readWAV(r, size)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return readFLAC(r)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return readOgg(head)
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return readMatroska(head)
+	case bytes.HasPrefix(head, []byte("ID3")) || (len(head) >= 4 && mp3Header(head) != nil):
+		return readMP3(r, head, size)
+	}
+	return nil
+}
+
+// readAt reads up to n bytes at off
+func readAt(r io.ReaderAt, off int64, n int) []byte {
+	buf := make([]byte, n)
+	read, _ := r.ReadAt(buf, off)
+	return buf[:read]
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// MP4 and QuickTime
+// ─────────────────────────────────────────────────────────────────────────────
+
+// mp4Containers are the boxes descended into on the way to track metadata
+var mp4Containers = map[string]bool{"moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true}
+
+func readMP4(r io.ReaderAt, head []byte, size int64) *ContainerInfo {
+	c := &ContainerInfo{Format: "mp4"}
+	switch brand := string(head[8:12]); {
+	case brand == "qt  ":
+		c.Format = "mov"
+	case strings.HasPrefix(brand, "M4A") || strings.HasPrefix(brand, "M4B"):
+		c.Format = "m4a"
+	}
+	visited := 0
+	var track *MediaTrack
+	var walk func(start, end int64)
+	walk = func(start, end int64) {
+		for off := start; off+8 <= end && visited < maxBoxes; visited++ {
+			header := readAt(r, off, 16)
+			if len(header) < 8 {
+				return
+			}
+			boxSize, typ, headerLen := int64(binary.BigEndian.Uint32(header)), string(header[4:8]), int64(8)
+			switch boxSize {
+			case 0: // Extends to the end of the file
+				boxSize = end - off
+			case 1: // 64-bit size follows
+				if len(header) < 16 {
+					return
+				}
+				boxSize, headerLen = int64(binary.BigEndian.Uint64(header[8:])), 16
+			}
+			if boxSize < headerLen || off+boxSize > end {
+				return
+			}
+			body := off + headerLen
+			switch {
+			case typ == "trak":
+				c.Tracks = append(c.Tracks, MediaTrack{})
+				track = &c.Tracks[len(c.Tracks)-1]
+				walk(body, off+boxSize)
+				track = nil
+			case mp4Containers[typ]:
+				walk(body, off+boxSize)
+			default:
+				data := readAt(r, body, int(min(boxSize-headerLen, maxBoxRead)))
+				parseMP4Box(c, track, typ, data)
+			}
+			off += boxSize
+		}
+	}
+	walk(0, size)
+
+	// Drop tracks that are neither audio nor video, like timecode and hints
+	tracks := c.Tracks[:0]
+	for _, t := range c.Tracks {
+		if t.Type == MediaAudio || t.Type == MediaVideo {
+			tracks = append(tracks, t)
+		}
+	}
+	c.Tracks = tracks
+	if c.Duration > 0 {
+		c.Bitrate = int(float64(size) * 8 / c.Duration)
+	}
+	return c
+}
+
+// parseMP4Box reads the fields of a leaf box; track is the track it belongs to
+func parseMP4Box(c *ContainerInfo, track *MediaTrack, typ string, data []byte) {
+	be := binary.BigEndian
+	switch typ {
+	case "mvhd":
+		// Version 1 has 64-bit times and duration
+		if len(data) >= 32 && data[0] == 1 {
+			if scale := be.Uint32(data[20:]); scale > 0 {
+				c.Duration = float64(be.Uint64(data[24:])) / float64(scale)
+			}
+		} else if len(data) >= 20 {
+			if scale := be.Uint32(data[12:]); scale > 0 {
+				c.Duration = float64(be.Uint32(data[16:])) / float64(scale)
+			}
+		}
+	case "tkhd":
+		// Width and height are 16.16 fixed point after the matrix
+		off := 76
+		if len(data) > 0 && data[0] == 1 {
+			off = 88
+		}
+		if track != nil && len(data) >= off+8 {
+			track.Width = int(be.Uint32(data[off:]) >> 16)
+			track.Height = int(be.Uint32(data[off+4:]) >> 16)
+		}
+	case "hdlr":
+		if track != nil && len(data) >= 12 {
+			switch string(data[8:12]) {
+			case "vide":
+				track.Type = MediaVideo
+			case "soun":
+				track.Type = MediaAudio
+			}
+		}
+	case "stsd":
+		// The first sample entry names the codec
+		if track == nil || len(data) < 16 {
+			return
+		}
+		entry := data[8:]
+		track.Codec = strings.TrimSpace(string(entry[4:8]))
+		switch {
+		case track.Type == MediaVideo && len(entry) >= 36:
+			track.Width = int(be.Uint16(entry[32:]))
+			track.Height = int(be.Uint16(entry[34:]))
+		case track.Type == MediaAudio && len(entry) >= 36:
+			track.Channels = int(be.Uint16(entry[24:]))
+			track.BitsPerSample = int(be.Uint16(entry[26:]))
+			track.SampleRate = int(be.Uint32(entry[32:]) >> 16)
+		}
+	}
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// WAV
+// ─────────────────────────────────────────────────────────────────────────────
+
+func readWAV(r io.ReaderAt, size int64) *ContainerInfo {
+	c := &ContainerInfo{Format: "wav"}
+	track := MediaTrack{Type: MediaAudio}
+	le := binary.LittleEndian
+	var byteRate uint32
+	off := int64(12)
+	for i := 0; i < maxRIFFChunks && off+8 <= size; i++ {
+		header := readAt(r, off, 8)
+		if len(header) < 8 {
+			break
+		}
+		id, length := string(header[:4]), int64(le.Uint32(header[4:]))
+		switch id {
+		case "fmt ":
+			if f := readAt(r, off+8, 16); len(f) == 16 {
+				track.Codec = wavCodec(le.Uint16(f))
+				track.Channels = int(le.Uint16(f[2:]))
+				track.SampleRate = int(le.Uint32(f[4:]))
+				byteRate = le.Uint32(f[8:])
+				track.BitsPerSample = int(le.Uint16(f[14:]))
+			}
+		case "data":
+			if byteRate > 0 {
+				c.Duration = float64(min(length, size-off-8)) / float64(byteRate)
+				c.Bitrate = int(byteRate) * 8
+			}
+		}
+		off += 8 + length + length%2
+	}
+	c.Tracks = []MediaTrack{track}
+	return c
+}
+
+func wavCodec(format uint16) string {
+	switch format {
+	case 1, 0xFFFE: // 0xFFFE is WAVE_FORMAT_EXTENSIBLE, nearly always PCM
+		return "pcm"
+	case 3:
+		return "pcm_float"
+	case 6:
+		return "alaw"
+	case 7:
+		return "mulaw"
+	case 0x55:
+		return "mp3"
+	}
+	return ""
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// FLAC
+// ─────────────────────────────────────────────────────────────────────────────
+
+func readFLAC(r io.ReaderAt) *ContainerInfo {
+	c := &ContainerInfo{Format: "flac"}
+	off := int64(4)
+	for i := 0; i < 64; i++ {
+		header := readAt(r, off, 4)
+		if len(header) < 4 {
+			break
+		}
+		last, typ := header[0]&0x80 != 0, header[0]&0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		switch typ {
+		case 0: // STREAMINFO
+			info := readAt(r, off+4, 18)
+			if len(info) < 18 {
+				return c
+			}
+			// 20 bits sample rate, 3 bits channels-1, 5 bits bits per sample-1, 36 bits samples
+			bits := binary.BigEndian.Uint64(info[10:])
+			rate := int(bits >> 44)
+			track := MediaTrack{
+				Type:          MediaAudio,
+				Codec:         "flac",
+				SampleRate:    rate,
+				Channels:      int(bits>>41&0x7) + 1,
+				BitsPerSample: int(bits>>36&0x1F) + 1,
+			}
+			if samples := bits & 0xFFFFFFFFF; rate > 0 && samples > 0 {
+				c.Duration = float64(samples) / float64(rate)
+			}
+			c.Tracks = []MediaTrack{track}
+		case 4: // VORBIS_COMMENT
+			if length <= maxTagSize {
+				c.Tags = parseVorbisComments(readAt(r, off+4, int(length)))
+			}
+		}
+		if last {
+			break
+		}
+		off += 4 + length
+	}
+	return c
+}
+
+// vorbisTags maps Vorbis comment fields to tag names
+var vorbisTags = map[string]string{"TITLE": "title", "ARTIST": "artist", "ALBUM": "album", "DATE": "year"}
+
+// parseVorbisComments reads the tags of a Vorbis comment block
+func parseVorbisComments(data []byte) map[string]string {
+	le := binary.LittleEndian
+	if len(data) < 8 {
+		return nil
+	}
+	vendor := int64(le.Uint32(data))
+	off := 4 + vendor
+	if off+4 > int64(len(data)) {
+		return nil
+	}
+	count := le.Uint32(data[off:])
+	off += 4
+	tags := make(map[string]string)
+	for i := uint32(0); i < count && i < 1000 && off+4 <= int64(len(data)); i++ {
+		length := int64(le.Uint32(data[off:]))
+		off += 4
+		if off+length > int64(len(data)) {
+			break
+		}
+		field, value, ok := strings.Cut(string(data[off:off+length]), "=")
+		if name := vorbisTags[strings.ToUpper(field)]; ok && name != "" && value != "" {
+			tags[name] = value
+		}
+		off += length
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Ogg and Matroska
+// ─────────────────────────────────────────────────────────────────────────────
+
+// readOgg identifies the codec from the first page, which holds its header
+func readOgg(head []byte) *ContainerInfo {
+	c := &ContainerInfo{Format: "ogg"}
+	if len(head) < 27 {
+		return c
+	}
+	packet := head[27+int(head[26]):] // After the segment table
+	le := binary.LittleEndian
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 16:
+		c.Tracks = []MediaTrack{{Type: MediaAudio, Codec: "vorbis", Channels: int(packet[11]), SampleRate: int(le.Uint32(packet[12:]))}}
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 16:
+		c.Tracks = []MediaTrack{{Type: MediaAudio, Codec: "opus", Channels: int(packet[9]), SampleRate: int(le.Uint32(packet[12:]))}}
+	case bytes.HasPrefix(packet, []byte("\x7fFLAC")):
+		c.Tracks = []MediaTrack{{Type: MediaAudio, Codec: "flac"}}
+	case bytes.HasPrefix(packet, []byte("\x80theora")):
+		c.Tracks = []MediaTrack{{Type: MediaVideo, Codec: "theora"}}
+	}
+	return c
+}
+
+// readMatroska reads the DocType of the EBML header, which tells WebM from
+// other Matroska files
+func readMatroska(head []byte) *ContainerInfo {
+	c := &ContainerInfo{Format: "matroska"}
+	if i := bytes.Index(head, []byte{0x42, 0x82}); i >= 0 && i+3 <= len(head) && head[i+2]&0x80 != 0 {
+		// One-byte size, the usual encoding of a short string
+		if end := i + 3 + int(head[i+2]&0x7F); end <= len(head) && string(head[i+3:end]) == "webm" {
+			c.Format = "webm"
+		}
+	}
+	return c
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// MP3
+// ─────────────────────────────────────────────────────────────────────────────
+
+// mpegFrame is a decoded MPEG audio Layer III frame header
+type mpegFrame struct {
+	mpeg1      bool
+	bitrate    int // Bits per second
+	sampleRate int
+	channels   int
+}
+
+// Layer III bitrates in kbit/s by index, and sample rates by index
+var (
+	mpeg1Bitrates  = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mpeg2Bitrates  = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mpegSampleRate = [4]int{44100, 48000, 32000, 0}
+)
+
+// mp3Header decodes an MPEG Layer III frame header, or returns nil
+func mp3Header(b []byte) *mpegFrame {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return nil
+	}
+	version, layer := b[1]>>3&0x3, b[1]>>1&0x3
+	bitrateIndex, rateIndex := b[2]>>4, b[2]>>2&0x3
+	if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return nil
+	}
+	f := &mpegFrame{mpeg1: version == 3, channels: 2}
+	f.sampleRate = mpegSampleRate[rateIndex]
+	switch version {
+	case 3:
+		f.bitrate = mpeg1Bitrates[bitrateIndex] * 1000
+	case 2: // MPEG 2
+		f.bitrate = mpeg2Bitrates[bitrateIndex] * 1000
+		f.sampleRate /= 2
+	case 0: // MPEG 2.5
+		f.bitrate = mpeg2Bitrates[bitrateIndex] * 1000
+		f.sampleRate /= 4
+	}
+	if b[3]>>6 == 3 {
+		f.channels = 1
+	}
+	return f
+}
+
+func readMP3(r io.ReaderAt, head []byte, size int64) *ContainerInfo {
+	c := &ContainerInfo{Format: "mp3"}
+	audioStart := int64(0)
+	if bytes.HasPrefix(head, []byte("ID3")) && len(head) >= 10 {
+		tagSize := int64(syncsafe(head[6:10]))
+		audioStart = 10 + tagSize
+		if head[5]&0x10 != 0 { // Footer
+			audioStart += 10
+		}
+		if tagSize <= maxTagSize {
+			c.Tags = parseID3(readAt(r, 10, int(tagSize)), head[3])
+		}
+	}
+
+	// The first frame may follow some padding
+	buf := readAt(r, audioStart, 4096)
+	i := 0
+	for ; i+4 <= len(buf) && mp3Header(buf[i:]) == nil; i++ {
+	}
+	frame := mp3Header(buf[i:])
+	if frame == nil {
+		return c
+	}
+	c.Tracks = []MediaTrack{{Type: MediaAudio, Codec: "mp3", SampleRate: frame.sampleRate, Channels: frame.channels}}
+	c.Bitrate = frame.bitrate
+
+	// A Xing or Info header after the side information gives the frame count
+	// of variable bitrate files; others are assumed to be constant bitrate
+	sideInfo := 32
+	switch {
+	case frame.mpeg1 && frame.channels == 1:
+		sideInfo = 17
+	case !frame.mpeg1 && frame.channels == 2:
+		sideInfo = 17
+	case !frame.mpeg1:
+		sideInfo = 9
+	}
+	xing := buf[i:]
+	if off := 4 + sideInfo; len(xing) >= off+12 && (string(xing[off:off+4]) == "Xing" || string(xing[off:off+4]) == "Info") {
+		if flags := binary.BigEndian.Uint32(xing[off+4:]); flags&1 != 0 {
+			samplesPerFrame := 576
+			if frame.mpeg1 {
+				samplesPerFrame = 1152
+			}
+			frames := binary.BigEndian.Uint32(xing[off+8:])
+			c.Duration = float64(frames) * float64(samplesPerFrame) / float64(frame.sampleRate)
+			if c.Duration > 0 {
+				c.Bitrate = int(float64(size-audioStart-int64(i)) * 8 / c.Duration)
+			}
+			return c
+		}
+	}
+	c.Duration = float64(size-audioStart-int64(i)) * 8 / float64(frame.bitrate)
+	return c
+}
+
+// syncsafe decodes an ID3v2 integer, which has 7 bits per byte
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+// id3Tags maps ID3v2.3 and 2.4 frame IDs to tag names
+var id3Tags = map[string]string{"TIT2": "title", "TPE1": "artist", "TALB": "album", "TYER": "year", "TDRC": "year"}
+
+// parseID3 reads the text frames of an ID3v2.3 or 2.4 tag
+func parseID3(data []byte, version byte) map[string]string {
+	if version != 3 && version != 4 {
+		return nil
+	}
+	tags := make(map[string]string)
+	for off := 0; off+10 <= len(data) && data[off] != 0; {
+		id := string(data[off : off+4])
+		length := int(binary.BigEndian.Uint32(data[off+4:]))
+		if version == 4 {
+			length = int(syncsafe(data[off+4 : off+8]))
+		}
+		off += 10
+		if length < 0 || off+length > len(data) {
+			break
+		}
+		if name := id3Tags[id]; name != "" && length > 1 {
+			if text := id3Text(data[off : off+length]); text != "" {
+				tags[name] = text
+			}
+		}
+		off += length
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// id3Text decodes a text frame: an encoding byte, then the text
+func id3Text(frame []byte) string {
+	text := frame[1:]
+	var s string
+	switch frame[0] {
+	case 0: // ISO-8859-1
+		runes := make([]rune, len(text))
+		for i, b := range text {
+			runes[i] = rune(b)
+		}
+		s = string(runes)
+	case 1, 2: // UTF-16 with a BOM, or big endian without one
+		var order binary.ByteOrder = binary.BigEndian
+		if len(text) >= 2 && text[0] == 0xFF && text[1] == 0xFE {
+			order, text = binary.LittleEndian, text[2:]
+		} else if len(text) >= 2 && text[0] == 0xFE && text[1] == 0xFF {
+			text = text[2:]
+		}
+		units := make([]uint16, len(text)/2)
+		for i := range units {
+			units[i] = order.Uint16(text[i*2:])
+		}
+		s = string(utf16.Decode(units))
+	default: // UTF-8
+		s = string(text)
+	}
+	s, _, _ = strings.Cut(s, "\x00")
+	return strings.TrimSpace(s)
+}
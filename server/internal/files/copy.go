@@ -0,0 +1,322 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ConflictPolicy decides what happens when a destination file already exists
+type ConflictPolicy string
+
+const (
+	ConflictFail      ConflictPolicy = "fail"      // Refuse when a destination exists
+	ConflictSkip      ConflictPolicy = "skip"      // Keep existing files; directories are merged
+	ConflictOverwrite ConflictPolicy = "overwrite" // Replace existing files; directories are merged
+	ConflictRename    ConflictPolicy = "rename"    // Copy to "name (1).ext" instead
+)
+
+// Valid reports whether p is a known policy
+func (p ConflictPolicy) Valid() bool {
+	switch p {
+	case ConflictFail, ConflictSkip, ConflictOverwrite, ConflictRename:
+		return true
+	}
+	return false
+}
+
+const copyBufferSize = 1 * 1024 * 1024
+
+// copier copies or moves file trees, preserving permission bits and
+// modification times. Symlinks are copied as links; devices, sockets and
+// pipes are skipped.
+type copier struct {
+	ctx      context.Context
+	conflict ConflictPolicy
+	move     bool // Remove sources once copied
+
+	// Optional progress callbacks
+	onBytes func(n int64)
+	onFile  func(path string, skipped bool)
+}
+
+// copyItem copies src to dst. Parent directories of dst must exist.
+// With ConflictRename an existing dst is replaced by a free "name (n)" path,
+// which is returned.
+func (c *copier) copyItem(src, dst string) (string, error) {
+	if _, err := os.Lstat(dst); err == nil {
+		switch c.conflict {
+		case ConflictRename:
+			dst = freeName(dst)
+		case ConflictSkip, ConflictOverwrite:
+			// Merged below, file by file
+		default:
+			return dst, ErrPathExists
+		}
+	}
+
+	if c.move {
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			// Same filesystem: a rename is instant and atomic
+			err := os.Rename(src, dst)
+			if err == nil {
+				c.countTree(dst)
+				return dst, nil
+			}
+			if !errors.Is(err, syscall.EXDEV) {
+				return dst, err
+			}
+		}
+	}
+
+	type dirTimes struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var created []dirTimes
+	var sourceDirs []string
+
+	err := filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		existing, statErr := os.Lstat(target)
+		exists := statErr == nil
+
+		switch {
+		case d.IsDir():
+			sourceDirs = append(sourceDirs, path)
+			if exists && existing.IsDir() {
+				return nil // Merge into the existing directory
+			}
+			if exists {
+				if c.conflict != ConflictOverwrite {
+					c.file(path, true)
+					return filepath.SkipDir
+				}
+				if err := os.Remove(target); err != nil {
+					return err
+				}
+			}
+			created = append(created, dirTimes{target, info.Mode().Perm(), info.ModTime()})
+			return os.Mkdir(target, 0700)
+
+		case d.Type()&os.ModeSymlink != 0:
+			if exists {
+				if c.conflict != ConflictOverwrite || existing.IsDir() {
+					c.file(path, true)
+					return nil
+				}
+				if err := os.Remove(target); err != nil {
+					return err
+				}
+			}
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+
+		case d.Type().IsRegular():
+			if exists && (c.conflict != ConflictOverwrite || existing.IsDir()) {
+				c.bytes(info.Size())
+				c.file(path, true)
+				return nil
+			}
+			if err := c.copyFile(path, target, info); err != nil {
+				return err
+			}
+
+		default:
+			c.file(path, true)
+			return nil
+		}
+
+		if c.move {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		c.file(path, false)
+		return nil
+	})
+
+	// Directories get their real mode and times once their contents are in
+	// place, even after a failure so nothing is left at 0700
+	for i := len(created) - 1; i >= 0; i-- {
+		d := created[i]
+		if chErr := os.Chmod(d.path, d.mode); chErr != nil && err == nil {
+			err = chErr
+		}
+		os.Chtimes(d.path, d.modTime, d.modTime)
+	}
+	if err != nil {
+		return dst, err
+	}
+
+	if c.move {
+		// Directories still holding skipped files are kept
+		for i := len(sourceDirs) - 1; i >= 0; i-- {
+			os.Remove(sourceDirs[i])
+		}
+	}
+	return dst, nil
+}
+
+// copyFile copies one regular file through a temporary file next to dst,
+// so an interrupted copy never leaves a truncated dst behind
+func (c *copier) copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := filepath.Join(filepath.Dir(dst), fmt.Sprintf(".%s.%d.part", filepath.Base(dst), time.Now().UnixNano()))
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := c.stream(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Chmod(tmp, info.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// stream copies in to out in chunks, checking for cancellation between them
+func (c *copier) stream(out io.Writer, in io.Reader) error {
+	buf := make([]byte, copyBufferSize)
+	for {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		n, err := in.Read(buf)
+		if n > 0 {
+			if _, werr := out.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			c.bytes(int64(n))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// countTree reports everything under path as done, for moves done by rename
+func (c *copier) countTree(path string) {
+	if c.onBytes == nil && c.onFile == nil {
+		return
+	}
+	filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && d.Type().IsRegular() {
+			c.bytes(info.Size())
+		}
+		c.file(p, false)
+		return nil
+	})
+}
+
+func (c *copier) bytes(n int64) {
+	if c.onBytes != nil {
+		c.onBytes(n)
+	}
+}
+
+func (c *copier) file(path string, skipped bool) {
+	if c.onFile != nil {
+		c.onFile(path, skipped)
+	}
+}
+
+// treeSize returns the number of non-directory entries under path and the
+// total size of its regular files
+func treeSize(ctx context.Context, path string) (files int, bytes int64, err error) {
+	err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		files++
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			bytes += info.Size()
+		}
+		return nil
+	})
+	return files, bytes, err
+}
+
+// freeName returns path, or "name (n).ext" for the first n that doesn't exist
+func freeName(path string) string {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" { // Dotfiles like ".bashrc" have no stem
+		stem, ext = base, ""
+	}
+	for n := 1; ; n++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, n, ext))
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package files
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DefaultDiffContext is the number of unchanged lines shown around each change
+const DefaultDiffContext = 3
+
+// maxDiffEdits bounds the work (and memory, which grows with its square) of
+// the line diff. Inputs that differ by more than this many inserted and
+// deleted lines are reported as one replacement of the differing middle.
+const maxDiffEdits = 2000
+
+type diffOp struct {
+	kind byte // ' ' equal, '-' delete, '+' insert
+	a, b int  // Line indexes in the old and new text
+}
+
+// UnifiedDiff returns the differences between two texts in unified diff
+// format, or "" if they are equal
+func UnifiedDiff(oldName, newName, oldText, newText string, context int) string {
+	return unifiedDiff(oldName, newName, oldText, newText, context, nil)
+}
+
+// unifiedDiff is UnifiedDiff comparing lines by normalize(line) when
+// normalize is set. Changed lines are shown as they are; unchanged ones as
+// in the old text.
+func unifiedDiff(oldName, newName, oldText, newText string, context int, normalize func(string) string) string {
+	if oldText == newText {
+		return ""
+	}
+	a, b := splitLines(oldText), splitLines(newText)
+	ka, kb := a, b
+	if normalize != nil {
+		ka, kb = mapLines(a, normalize), mapLines(b, normalize)
+	}
+	hunks := diffHunks(diffLines(ka, kb), context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		writeHunk(&sb, a, b, h)
+	}
+	return sb.String()
+}
+
+func mapLines(lines []string, f func(string) string) []string {
+	mapped := make([]string, len(lines))
+	for i, line := range lines {
+		mapped[i] = f(line)
+	}
+	return mapped
+}
+
+// ignoreAllSpace drops all whitespace from a line, like diff -w
+func ignoreAllSpace(line string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, line)
+}
+
+// ignoreSpaceChange turns each run of whitespace into one space and drops
+// trailing whitespace, like diff -b
+func ignoreSpaceChange(line string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range line {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			sb.WriteByte(' ')
+			space = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// splitLines splits text into lines that keep their "\n", so a missing
+// final newline is itself a difference
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script turning a into b
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b)-prefix-suffix)
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{' ', i, i})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := suffix; i > 0; i-- {
+		ops = append(ops, diffOp{' ', len(a) - i, len(b) - i})
+	}
+	return ops
+}
+
+// myersDiff is Myers' O(ND) algorithm. offA and offB are added to the
+// line indexes of the returned operations.
+func myersDiff(a, b []string, offA, offB int) []diffOp {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	max := n + m
+	off := max + 1
+	v := make([]int, 2*max+3) // Furthest x reached on each diagonal k, at v[off+k]
+	var trace [][]int         // Snapshot of v[-d-1 .. d+1] before each step d
+
+	for d := 0; d <= max && d <= maxDiffEdits; d++ {
+		snap := make([]int, 2*d+3)
+		copy(snap, v[off-d-1:off+d+2])
+		trace = append(trace, snap)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1] // Down: insert
+			} else {
+				x = v[off+k-1] + 1 // Right: delete
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return myersBacktrack(trace, n, m, offA, offB)
+			}
+		}
+	}
+
+	// Too different to diff exactly: replace everything
+	ops := make([]diffOp, 0, n+m)
+	for i := 0; i < n; i++ {
+		ops = append(ops, diffOp{'-', offA + i, offB})
+	}
+	for j := 0; j < m; j++ {
+		ops = append(ops, diffOp{'+', offA + n, offB + j})
+	}
+	return ops
+}
+
+func myersBacktrack(trace [][]int, x, y, offA, offB int) []diffOp {
+	var ops []diffOp
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', offA + x, offB + y})
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', offA + x, offB + prevY})
+			} else {
+				ops = append(ops, diffOp{'-', offA + prevX, offB + y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// diffHunks groups changes, with context lines around them, into hunks.
+// Changes closer than twice the context share a hunk.
+func diffHunks(ops []diffOp, context int) [][]diffOp {
+	var hunks [][]diffOp
+	start, end := -1, -1
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		lo, hi := max(i-context, 0), min(i+context+1, len(ops))
+		if start >= 0 && lo > end {
+			hunks = append(hunks, ops[start:end])
+			start = -1
+		}
+		if start < 0 {
+			start = lo
+		}
+		end = hi
+	}
+	if start >= 0 {
+		hunks = append(hunks, ops[start:end])
+	}
+	return hunks
+}
+
+func writeHunk(sb *strings.Builder, a, b []string, hunk []diffOp) {
+	var oldCount, newCount int
+	for _, op := range hunk {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(hunk[0].a, oldCount), hunkRange(hunk[0].b, newCount))
+
+	for _, op := range hunk {
+		line := ""
+		switch op.kind {
+		case '+':
+			line = b[op.b]
+		default:
+			line = a[op.a]
+		}
+		sb.WriteByte(op.kind)
+		sb.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats a hunk's start line and line count the way diff -u does
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,351 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxExifSize bounds how much of a file is read as EXIF data
+const maxExifSize = 256 * 1024
+
+// ExifInfo is camera metadata embedded in an image
+type ExifInfo struct {
+	Make         string   `json:"make,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Lens         string   `json:"lens,omitempty"`
+	Software     string   `json:"software,omitempty"`
+	DateTime     string   `json:"date_time,omitempty"` // When the photo was taken, as 2006-01-02T15:04:05 in the camera's local time
+	Orientation  int      `json:"orientation,omitempty"`
+	ExposureTime string   `json:"exposure_time,omitempty"` // Seconds, like 1/125
+	FNumber      float64  `json:"f_number,omitempty"`
+	ISO          int      `json:"iso,omitempty"`
+	FocalLength  float64  `json:"focal_length,omitempty"` // Millimeters
+	GPS          *GPSInfo `json:"gps,omitempty"`
+}
+
+// GPSInfo is where a photo was taken
+type GPSInfo struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"` // Meters above sea level
+}
+
+// EXIF tags read
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagExposureTime     = 0x829A
+	tagFNumber          = 0x829D
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagFocalLength      = 0x920A
+	tagLensModel        = 0xA434
+
+	tagGPSLatitudeRef  = 1
+	tagGPSLatitude     = 2
+	tagGPSLongitudeRef = 3
+	tagGPSLongitude    = 4
+	tagGPSAltitudeRef  = 5
+	tagGPSAltitude     = 6
+)
+
+// tiffTypeSizes are the sizes of TIFF field types by type number
+var tiffTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// tiffField is an IFD entry; value holds the field's bytes
+type tiffField struct {
+	typ   uint16
+	count int
+	value []byte
+}
+
+// tiffReader reads IFDs from TIFF-structured data, checking every offset
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func newTiffReader(data []byte) (*tiffReader, uint32, bool) {
+	if len(data) < 8 {
+		return nil, 0, false
+	}
+	t := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, 0, false
+	}
+	return t, t.order.Uint32(data[4:]), true
+}
+
+// ifd reads the fields of the IFD at offset
+func (t *tiffReader) ifd(offset uint32) map[uint16]tiffField {
+	fields := make(map[uint16]tiffField)
+	if int64(offset)+2 > int64(len(t.data)) {
+		return fields
+	}
+	n := int(t.order.Uint16(t.data[offset:]))
+	for i := 0; i < n && i < 500; i++ {
+		entry := int64(offset) + 2 + int64(i)*12
+		if entry+12 > int64(len(t.data)) {
+			break
+		}
+		e := t.data[entry : entry+12]
+		typ := t.order.Uint16(e[2:])
+		count := int64(t.order.Uint32(e[4:]))
+		size, ok := tiffTypeSizes[typ]
+		if !ok || count*int64(size) > maxExifSize {
+			continue
+		}
+		length := count * int64(size)
+		value := e[8:12]
+		if length > 4 {
+			start := int64(t.order.Uint32(e[8:]))
+			if start+length > int64(len(t.data)) {
+				continue
+			}
+			value = t.data[start : start+length]
+		}
+		fields[t.order.Uint16(e)] = tiffField{typ: typ, count: int(count), value: value[:length]}
+	}
+	return fields
+}
+
+func (t *tiffReader) str(f tiffField) string {
+	if f.typ != 2 {
+		return ""
+	}
+	s, _, _ := strings.Cut(string(f.value), "\x00")
+	return strings.TrimSpace(s)
+}
+
+// uint returns the first value of a BYTE, SHORT or LONG field
+func (t *tiffReader) uint(f tiffField) (uint32, bool) {
+	switch {
+	case f.typ == 1 && len(f.value) >= 1:
+		return uint32(f.value[0]), true
+	case f.typ == 3 && len(f.value) >= 2:
+		return uint32(t.order.Uint16(f.value)), true
+	case f.typ == 4 && len(f.value) >= 4:
+		return t.order.Uint32(f.value), true
+	}
+	return 0, false
+}
+
+// rational returns the i-th value of a RATIONAL or SRATIONAL field
+func (t *tiffReader) rational(f tiffField, i int) (num, den int64, ok bool) {
+	if (f.typ != 5 && f.typ != 10) || len(f.value) < (i+1)*8 {
+		return 0, 0, false
+	}
+	n, d := t.order.Uint32(f.value[i*8:]), t.order.Uint32(f.value[i*8+4:])
+	if f.typ == 10 {
+		return int64(int32(n)), int64(int32(d)), d != 0
+	}
+	return int64(n), int64(d), d != 0
+}
+
+func (t *tiffReader) float(f tiffField, i int) (float64, bool) {
+	num, den, ok := t.rational(f, i)
+	if !ok {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// parseExif reads the fields of ExifInfo from TIFF-structured EXIF data
+func parseExif(data []byte) *ExifInfo {
+	t, offset, ok := newTiffReader(data)
+	if !ok {
+		return nil
+	}
+	info := &ExifInfo{}
+	ifd0 := t.ifd(offset)
+	info.Make = t.str(ifd0[tagMake])
+	info.Model = t.str(ifd0[tagModel])
+	info.Software = t.str(ifd0[tagSoftware])
+	info.DateTime = exifTime(t.str(ifd0[tagDateTime]))
+	if o, ok := t.uint(ifd0[tagOrientation]); ok && o >= 1 && o <= 8 {
+		info.Orientation = int(o)
+	}
+
+	if sub, ok := t.uint(ifd0[tagExifIFD]); ok {
+		exif := t.ifd(sub)
+		if taken := exifTime(t.str(exif[tagDateTimeOriginal])); taken != "" {
+			info.DateTime = taken
+		}
+		info.Lens = t.str(exif[tagLensModel])
+		if num, den, ok := t.rational(exif[tagExposureTime], 0); ok {
+			if num == 1 || num == 0 || den%num != 0 {
+				info.ExposureTime = fmt.Sprintf("%d/%d", num, den)
+			} else {
+				info.ExposureTime = fmt.Sprintf("1/%d", den/num)
+			}
+			if num >= den {
+				info.ExposureTime = fmt.Sprintf("%g", float64(num)/float64(den))
+			}
+		}
+		info.FNumber, _ = t.float(exif[tagFNumber], 0)
+		info.FocalLength, _ = t.float(exif[tagFocalLength], 0)
+		if iso, ok := t.uint(exif[tagISO]); ok {
+			info.ISO = int(iso)
+		}
+	}
+
+	if sub, ok := t.uint(ifd0[tagGPSIFD]); ok {
+		info.GPS = parseGPS(t, t.ifd(sub))
+	}
+	return info
+}
+
+// parseGPS reads a position from a GPS IFD, or returns nil if it has none
+func parseGPS(t *tiffReader, gps map[uint16]tiffField) *GPSInfo {
+	coordinate := func(f tiffField, ref string, negative string) (float64, bool) {
+		deg, ok1 := t.float(f, 0)
+		min, ok2 := t.float(f, 1)
+		sec, ok3 := t.float(f, 2)
+		if !ok1 || !ok2 || !ok3 {
+			return 0, false
+		}
+		v := deg + min/60 + sec/3600
+		if strings.EqualFold(ref, negative) {
+			v = -v
+		}
+		return v, true
+	}
+	lat, ok1 := coordinate(gps[tagGPSLatitude], t.str(gps[tagGPSLatitudeRef]), "S")
+	lon, ok2 := coordinate(gps[tagGPSLongitude], t.str(gps[tagGPSLongitudeRef]), "W")
+	if !ok1 || !ok2 {
+		return nil
+	}
+	info := &GPSInfo{Latitude: lat, Longitude: lon}
+	if alt, ok := t.float(gps[tagGPSAltitude], 0); ok {
+		if ref, ok := t.uint(gps[tagGPSAltitudeRef]); ok && ref == 1 {
+			alt = -alt
+		}
+		info.Altitude = &alt
+	}
+	return info
+}
+
+// exifTime converts an EXIF "2006:01:02 15:04:05" time to ISO 8601
+func exifTime(s string) string {
+	if len(s) < 19 || s[4] != ':' || s[7] != ':' || strings.HasPrefix(s, "0000") {
+		return ""
+	}
+	return s[:4] + "-" + s[5:7] + "-" + s[8:10] + "T" + s[11:19]
+}
+
+// readExif finds the EXIF data of a JPEG, PNG or WebP image
+func readExif(r io.ReadSeeker, format string) []byte {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil
+	}
+	var data []byte
+	switch format {
+	case "jpeg":
+		data = jpegExif(r)
+	case "png":
+		data = pngExif(r)
+	case "webp":
+		data = webpExif(r)
+	}
+	return bytes.TrimPrefix(data, []byte("Exif\x00\x00"))
+}
+
+// jpegExif reads the APP1 Exif segment, which comes before the image data
+func jpegExif(r io.ReadSeeker) []byte {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:2]); err != nil || header[0] != 0xFF || header[1] != 0xD8 {
+		return nil
+	}
+	for i := 0; i < 64; i++ {
+		if _, err := io.ReadFull(r, header[:]); err != nil || header[0] != 0xFF {
+			return nil
+		}
+		marker, length := header[1], int64(binary.BigEndian.Uint16(header[2:]))-2
+		if marker == 0xDA || marker == 0xD9 || length < 0 {
+			return nil // Image data starts; metadata comes before it
+		}
+		if marker == 0xE1 && length >= 6 {
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil
+			}
+			if bytes.HasPrefix(data, []byte("Exif\x00\x00")) {
+				return data
+			}
+			continue
+		}
+		if _, err := r.Seek(length, io.SeekCurrent); err != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// pngExif reads the eXIf chunk
+func pngExif(r io.ReadSeeker) []byte {
+	if _, err := r.Seek(8, io.SeekStart); err != nil {
+		return nil
+	}
+	var header [8]byte
+	for i := 0; i < 256; i++ {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil
+		}
+		length, typ := int64(binary.BigEndian.Uint32(header[:])), string(header[4:])
+		switch {
+		case typ == "eXIf" && length <= maxExifSize:
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil
+			}
+			return data
+		case typ == "IEND":
+			return nil
+		}
+		if _, err := r.Seek(length+4, io.SeekCurrent); err != nil { // Data and CRC
+			return nil
+		}
+	}
+	return nil
+}
+
+// webpExif reads the EXIF chunk of an extended WebP file
+func webpExif(r io.ReadSeeker) []byte {
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil
+	}
+	var header [8]byte
+	for i := 0; i < 64; i++ {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil
+		}
+		length, typ := int64(binary.LittleEndian.Uint32(header[4:])), string(header[:4])
+		if typ == "EXIF" && length <= maxExifSize {
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil
+			}
+			return data
+		}
+		if _, err := r.Seek(length+length%2, io.SeekCurrent); err != nil {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+var ErrInvalidFollow = apperr.New(apperr.CodeInvalidParameter, "invalid follow request")
+
+const (
+	// followPollInterval is how often a followed file is checked for
+	// appends, truncation and rotation once everything was read
+	followPollInterval = 250 * time.Millisecond
+
+	// followBatchSize is how many bytes are read at a time; lines longer
+	// than this are split
+	followBatchSize = 64 * 1024
+
+	// MaxFollowLag is how far reading may fall behind the end of the file.
+	// Reads are paced by the client; a client this far behind skips ahead.
+	MaxFollowLag = 4 * 1024 * 1024
+)
+
+// Follow event types
+const (
+	FollowLines     = "lines"     // Lines appended, or the last lines at the start
+	FollowTruncated = "truncated" // The file shrank; following from its start
+	FollowRotated   = "rotated"   // The path is a new file; following it from its start
+	FollowSkipped   = "skipped"   // The client fell behind; lines were skipped
+)
+
+// FollowRequest describes a file to follow
+type FollowRequest struct {
+	Path   string
+	Lines  int    // Last lines to send first (0 starts at the end)
+	Filter string // Regular expression lines must match
+}
+
+// FollowEvent is a change in a followed file
+type FollowEvent struct {
+	Type    string   `json:"type"`
+	Lines   []string `json:"lines,omitempty"`
+	Offset  int64    `json:"offset"`            // Bytes of the current file read
+	Skipped int64    `json:"skipped,omitempty"` // Bytes skipped
+}
+
+// Follow streams lines appended to a file, like tail -F. Truncation and
+// rotation (the path naming a new file) are detected by polling; what was
+// left in a rotated file is read before the new one. Reading is paced by how
+// fast events are taken, so a slow client holds no more than one batch in
+// memory, and one that falls more than MaxFollowLag behind skips ahead.
+type Follow struct {
+	svc    *Service
+	path   string
+	filter *regexp.Regexp
+	events chan FollowEvent
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+
+	file       *os.File
+	info       os.FileInfo
+	offset     int64
+	partial    []byte // Start of a line not yet ended
+	skipToLine bool   // Discard up to the next line start, after skipping
+	buf        []byte
+	closeOnce  sync.Once
+}
+
+// Follow starts following a file. Close the Follow to stop.
+func (s *Service) Follow(req FollowRequest) (*Follow, error) {
+	if req.Lines < 0 || req.Lines > MaxRangeLines {
+		return nil, ErrInvalidFollow.WithMessage(fmt.Sprintf("lines must be between 0 and %d", MaxRangeLines))
+	}
+	var filter *regexp.Regexp
+	if req.Filter != "" {
+		var err error
+		if filter, err = regexp.Compile(req.Filter); err != nil {
+			return nil, ErrInvalidFollow.WithMessage("invalid filter: " + strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+		}
+	}
+
+	absPath, err := s.validatePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(absPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, ErrIsDirectory
+	}
+
+	f := &Follow{
+		svc:    s,
+		path:   absPath,
+		filter: filter,
+		events: make(chan FollowEvent, 1),
+		done:   make(chan struct{}),
+		file:   file,
+		info:   info,
+		offset: info.Size(),
+		buf:    make([]byte, followBatchSize),
+	}
+	var initial []string
+	if req.Lines > 0 {
+		result := &RangeResult{Size: info.Size()}
+		data, err := readTail(context.Background(), file, result, req.Lines, MaxRangeLength)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		initial = f.lines(data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	go f.run(ctx, initial)
+	return f, nil
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// Follow is closed or fails; Err tells which.
+func (f *Follow) Events() <-chan FollowEvent {
+	return f.events
+}
+
+// Err returns what stopped the Follow, once Events is closed
+func (f *Follow) Err() error {
+	return f.err
+}
+
+// Close stops following and releases the file
+func (f *Follow) Close() {
+	f.closeOnce.Do(func() {
+		f.cancel()
+		<-f.done
+	})
+}
+
+func (f *Follow) run(ctx context.Context, initial []string) {
+	defer close(f.done)
+	defer close(f.events)
+	defer func() { f.file.Close() }()
+
+	if len(initial) > 0 && !f.send(ctx, FollowEvent{Type: FollowLines, Lines: initial}) {
+		return
+	}
+
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+	for {
+		n, ok, err := f.read(ctx)
+		if err != nil {
+			f.err = err
+			return
+		}
+		if !ok {
+			return
+		}
+		if n > 0 {
+			continue // There may be more
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !f.check(ctx) {
+			return
+		}
+	}
+}
+
+// send delivers an event, waiting for the client; false means the Follow
+// was closed
+func (f *Follow) send(ctx context.Context, ev FollowEvent) bool {
+	if ev.Offset == 0 {
+		ev.Offset = f.offset - int64(len(f.partial))
+	}
+	select {
+	case f.events <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// read reads a batch of what was appended and sends its whole lines. It
+// returns the bytes read, and false if the Follow was closed.
+func (f *Follow) read(ctx context.Context) (int, bool, error) {
+	info, err := f.file.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	if lag := info.Size() - f.offset; lag > MaxFollowLag {
+		target := info.Size() - followBatchSize
+		skipped := target - f.offset + int64(len(f.partial))
+		f.offset, f.partial, f.skipToLine = target, nil, true
+		if !f.send(ctx, FollowEvent{Type: FollowSkipped, Skipped: skipped}) {
+			return 0, false, nil
+		}
+	}
+
+	n, err := f.file.ReadAt(f.buf, f.offset)
+	if err != nil && err != io.EOF {
+		return 0, false, err
+	}
+	if n == 0 {
+		return 0, true, nil
+	}
+	f.offset += int64(n)
+	data := f.buf[:n]
+	if f.skipToLine {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return n, true, nil
+		}
+		data, f.skipToLine = data[i+1:], false
+	}
+
+	lines := f.lines(append(f.partial, data...))
+	if len(lines) == 0 {
+		return n, true, nil
+	}
+	return n, f.send(ctx, FollowEvent{Type: FollowLines, Lines: lines}), nil
+}
+
+// lines splits data into whole lines that pass the filter, keeping an
+// unfinished last line for later. An unfinished line longer than a batch is
+// returned as it is.
+func (f *Follow) lines(data []byte) []string {
+	var lines []string
+	add := func(line []byte) {
+		s := strings.ToValidUTF8(string(bytes.TrimSuffix(line, []byte("\r"))), "�")
+		if f.filter == nil || f.filter.MatchString(s) {
+			lines = append(lines, s)
+		}
+	}
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		add(data[:i])
+		data = data[i+1:]
+	}
+	if len(data) > followBatchSize {
+		add(data)
+		data = nil
+	}
+	f.partial = append(f.partial[:0:0], data...)
+	return lines
+}
+
+// check detects truncation and rotation. It returns false if the Follow was
+// closed or failed.
+func (f *Follow) check(ctx context.Context) bool {
+	if pathInfo, err := os.Stat(f.path); err == nil && !os.SameFile(pathInfo, f.info) {
+		return f.rotate(ctx)
+	}
+
+	info, err := f.file.Stat()
+	if err == nil && info.Size() < f.offset {
+		f.offset, f.partial, f.skipToLine = 0, nil, false
+		return f.send(ctx, FollowEvent{Type: FollowTruncated})
+	}
+	return true
+}
+
+// rotate finishes the old file, including a last line without a newline,
+// then follows the new file at the path. The new file must be the path
+// itself and not a link out of the allowed paths.
+func (f *Follow) rotate(ctx context.Context) bool {
+	for {
+		n, ok, err := f.read(ctx)
+		if !ok || err != nil {
+			f.err = err
+			return false
+		}
+		if n == 0 {
+			break
+		}
+	}
+	if len(f.partial) > 0 {
+		line := f.partial
+		f.partial = nil
+		if lines := f.lines(append(line, '\n')); len(lines) > 0 && !f.send(ctx, FollowEvent{Type: FollowLines, Lines: lines}) {
+			return false
+		}
+	}
+
+	resolved, err := f.svc.validatePath(f.path)
+	if errors.Is(err, ErrPathNotAllowed) || (err == nil && resolved != f.path) {
+		f.err = ErrPathNotAllowed
+		return false
+	}
+	if err != nil {
+		return true // Try again at the next poll
+	}
+	next, err := os.Open(f.path)
+	if err != nil {
+		return true
+	}
+	nextInfo, err := next.Stat()
+	if err != nil {
+		next.Close()
+		return true
+	}
+	if pathInfo, err := os.Lstat(f.path); err != nil || !os.SameFile(pathInfo, nextInfo) {
+		next.Close()
+		return true // Replaced again while opening
+	}
+
+	f.file.Close()
+	f.file, f.info, f.offset, f.partial, f.skipToLine = next, nextInfo, 0, nil, false
+	return f.send(ctx, FollowEvent{Type: FollowRotated})
+}
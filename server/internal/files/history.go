@@ -0,0 +1,353 @@
+package files
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+var ErrFileVersionNotFound = apperr.New(apperr.CodeVersionNotFound, "file version not found")
+
+// historyPruneBatch is how many of the oldest versions are loaded at a time
+// when the total size limit is exceeded
+const historyPruneBatch = 100
+
+// FileVersion is content a file had before it was overwritten
+type FileVersion struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	Hash      string    `json:"hash"` // SHA-256 of the content
+	Size      int64     `json:"size"`
+	Op        string    `json:"op"` // What replaced the content: write, upload or restore
+	CreatedAt time.Time `json:"created_at"`
+
+	absPath string
+}
+
+// History snapshots files before Write, uploads and restores overwrite
+// them. Content is stored once per SHA-256 under dir/objects, so unchanged
+// content saved many times takes the space of one copy; which path had which
+// content when is recorded in the database. The oldest versions are dropped
+// once a file has more than the configured number, or all stored content
+// exceeds the total size limit.
+type History struct {
+	svc    *Service
+	store  *historyStore
+	dir    string
+	limits config.HistoryConfig
+
+	mu sync.Mutex // Serializes snapshots and removals, which share content files
+}
+
+// NewHistory creates a version history for the files service, stored in dir
+func NewHistory(svc *Service, db *database.Database, dir string, limits config.HistoryConfig) *History {
+	return &History{
+		svc:    svc,
+		store:  newHistoryStore(db),
+		dir:    dir,
+		limits: limits,
+	}
+}
+
+// objectPath returns where content with the given hash is stored
+func (h *History) objectPath(hash string) string {
+	return filepath.Join(h.dir, "objects", hash[:2], hash)
+}
+
+// snapshot saves the current content of absPath as a version before op
+// replaces it. Missing files, directories and other non-regular files, and
+// files over the size limit are skipped, as is content identical to the
+// path's latest version.
+func (h *History) snapshot(absPath, op string) error {
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || (h.limits.MaxFileSize > 0 && info.Size() > h.limits.MaxFileSize) {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hash, size, err := h.storeContent(absPath)
+	if err != nil {
+		return err
+	}
+	if latest, err := h.store.latest(absPath); err == nil && latest.Hash == hash {
+		return nil
+	}
+
+	v := &FileVersion{
+		ID:        uuid.New().String(),
+		Path:      ToTildePath(absPath),
+		Hash:      hash,
+		Size:      size,
+		Op:        op,
+		CreatedAt: time.Now(),
+		absPath:   absPath,
+	}
+	if err := h.store.insert(v); err != nil {
+		h.removeUnused(hash)
+		return err
+	}
+	h.prune(absPath)
+	return nil
+}
+
+// storeContent copies a file into the object store, unless its content is
+// already there, and returns the content's hash and size
+func (h *History) storeContent(absPath string) (string, int64, error) {
+	src, err := os.Open(absPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer src.Close()
+
+	objects := filepath.Join(h.dir, "objects")
+	if err := os.MkdirAll(objects, 0700); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(objects, ".tmp-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	obj := h.objectPath(hash)
+	if _, err := os.Stat(obj); err == nil {
+		return hash, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(obj), 0700); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), obj); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+// prune drops the versions of absPath past the per-file limit, then the
+// oldest versions of any file until stored content fits the total size limit.
+// Failures are logged: the snapshot itself was taken.
+func (h *History) prune(absPath string) {
+	if h.limits.MaxVersions > 0 {
+		excess, err := h.store.list(absPath, h.limits.MaxVersions)
+		if err != nil {
+			logger.Error("Failed to list file versions: %v", err)
+			return
+		}
+		for _, v := range excess {
+			if err := h.remove(v); err != nil {
+				logger.Error("Failed to remove file version %s: %v", v.ID, err)
+				return
+			}
+		}
+	}
+
+	if h.limits.MaxTotalSize <= 0 {
+		return
+	}
+	for {
+		total, err := h.store.totalSize()
+		if err != nil {
+			logger.Error("Failed to measure file history: %v", err)
+			return
+		}
+		if total <= h.limits.MaxTotalSize {
+			return
+		}
+		oldest, err := h.store.oldest(historyPruneBatch)
+		if err != nil || len(oldest) == 0 {
+			return
+		}
+		for _, v := range oldest {
+			if err := h.remove(v); err != nil {
+				logger.Error("Failed to remove file version %s: %v", v.ID, err)
+				return
+			}
+			if total, err = h.store.totalSize(); err != nil || total <= h.limits.MaxTotalSize {
+				return
+			}
+		}
+	}
+}
+
+// remove deletes a version, and its content once no version refers to it
+func (h *History) remove(v *FileVersion) error {
+	if err := h.store.delete(v.ID); err != nil {
+		return err
+	}
+	h.removeUnused(v.Hash)
+	return nil
+}
+
+func (h *History) removeUnused(hash string) {
+	if used, err := h.store.hashUsed(hash); err == nil && !used {
+		os.Remove(h.objectPath(hash))
+	}
+}
+
+// List returns the versions of a file, newest first. A deleted file's
+// versions are still listed.
+func (h *History) List(path string) ([]*FileVersion, error) {
+	absPath, err := h.svc.validatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := h.store.list(absPath, 0)
+	if versions == nil {
+		versions = []*FileVersion{}
+	}
+	return versions, err
+}
+
+// Get returns a version by ID
+func (h *History) Get(id string) (*FileVersion, error) {
+	v, err := h.store.get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFileVersionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !h.svc.config.IsPathAllowed(v.absPath) {
+		return nil, ErrPathNotAllowed
+	}
+	return v, nil
+}
+
+// ContentPath returns a version and the path its content is stored at, for
+// serving it as a download
+func (h *History) ContentPath(id string) (*FileVersion, string, error) {
+	v, err := h.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+	return v, h.objectPath(v.Hash), nil
+}
+
+// Read returns a version and its content, which must be text
+func (h *History) Read(id string) (*FileVersion, string, error) {
+	v, err := h.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if v.Size > MaxFileSize {
+		return nil, "", ErrFileTooLarge
+	}
+	content, err := h.content(v)
+	if err != nil {
+		return nil, "", err
+	}
+	if isBinary(content) {
+		return nil, "", ErrBinaryFile
+	}
+	return v, string(content), nil
+}
+
+func (h *History) content(v *FileVersion) ([]byte, error) {
+	content, err := os.ReadFile(h.objectPath(v.Hash))
+	if os.IsNotExist(err) {
+		return nil, ErrFileVersionNotFound.WithMessage("file version content is missing")
+	}
+	return content, err
+}
+
+// Diff returns a unified diff from a version to the file's current content,
+// or "" if they are the same. A file deleted since is diffed as empty.
+func (h *History) Diff(id string, context int) (string, error) {
+	v, old, err := h.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	// The path may have become a link out of the allowed paths since
+	absPath, err := h.svc.validateNewPath(v.absPath)
+	if err != nil {
+		return "", err
+	}
+
+	newName := v.Path + " (current)"
+	var current []byte
+	info, err := os.Stat(absPath)
+	switch {
+	case os.IsNotExist(err):
+		newName = "/dev/null"
+	case err != nil:
+		return "", err
+	case info.IsDir():
+		return "", ErrIsDirectory
+	case info.Size() > MaxFileSize:
+		return "", ErrFileTooLarge
+	default:
+		if current, err = os.ReadFile(absPath); err != nil {
+			return "", err
+		}
+		if isBinary(current) {
+			return "", ErrBinaryFile
+		}
+	}
+
+	oldName := v.Path + " (" + v.CreatedAt.Format(time.RFC3339) + ")"
+	return UnifiedDiff(oldName, newName, old, string(current), context), nil
+}
+
+// Restore writes a version's content back to its file, recreating the file
+// if it was deleted, and returns the file's new version token. The content
+// being replaced is snapshotted first, so a restore can itself be undone.
+func (h *History) Restore(id string) (string, error) {
+	v, err := h.Get(id)
+	if err != nil {
+		return "", err
+	}
+	content, err := h.content(v)
+	if err != nil {
+		return "", err
+	}
+	// A parent may have become a symlink since the version was taken, so
+	// directories are only created under the resolved path
+	absPath, err := h.svc.validateNewPath(v.absPath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return "", err
+	}
+	return h.svc.write(absPath, content, "", OpRestore)
+}
+
+// Delete removes a version
+func (h *History) Delete(id string) error {
+	v, err := h.Get(id)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.remove(v)
+}
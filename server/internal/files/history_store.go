@@ -0,0 +1,98 @@
+package files
+
+import (
+	"database/sql"
+
+	"github.com/ss497254/gloski/internal/database"
+)
+
+// historyStore handles persistence of file version metadata to SQLite
+// database. Paths are stored absolute; times are stored in UTC so they
+// compare correctly as text.
+type historyStore struct {
+	db *sql.DB
+}
+
+func newHistoryStore(database *database.Database) *historyStore {
+	return &historyStore{db: database.DB()}
+}
+
+const fileVersionColumns = "id, path, hash, size, op, created_at"
+
+// Versions taken within the same clock tick still list in insertion order
+const fileVersionOrder = " ORDER BY created_at DESC, rowid DESC"
+
+func scanFileVersion(row interface{ Scan(...any) error }) (*FileVersion, error) {
+	v := &FileVersion{}
+	err := row.Scan(&v.ID, &v.absPath, &v.Hash, &v.Size, &v.Op, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	v.Path = ToTildePath(v.absPath)
+	return v, nil
+}
+
+func (s *historyStore) insert(v *FileVersion) error {
+	_, err := s.db.Exec(`
+		INSERT INTO file_versions (`+fileVersionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, v.ID, v.absPath, v.Hash, v.Size, v.Op, v.CreatedAt.UTC())
+	return err
+}
+
+func (s *historyStore) get(id string) (*FileVersion, error) {
+	return scanFileVersion(s.db.QueryRow("SELECT "+fileVersionColumns+" FROM file_versions WHERE id = ?", id))
+}
+
+// latest returns the most recent version of a path
+func (s *historyStore) latest(absPath string) (*FileVersion, error) {
+	return scanFileVersion(s.db.QueryRow("SELECT "+fileVersionColumns+" FROM file_versions WHERE path = ?"+fileVersionOrder+" LIMIT 1", absPath))
+}
+
+// list returns the versions of a path, newest first, skipping the first offset
+func (s *historyStore) list(absPath string, offset int) ([]*FileVersion, error) {
+	return s.query("SELECT "+fileVersionColumns+" FROM file_versions WHERE path = ?"+fileVersionOrder+" LIMIT -1 OFFSET ?", absPath, offset)
+}
+
+// oldest returns up to limit versions of any path, oldest first
+func (s *historyStore) oldest(limit int) ([]*FileVersion, error) {
+	return s.query("SELECT "+fileVersionColumns+" FROM file_versions ORDER BY created_at, rowid LIMIT ?", limit)
+}
+
+func (s *historyStore) query(query string, args ...any) ([]*FileVersion, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*FileVersion
+	for rows.Next() {
+		v, err := scanFileVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// totalSize returns the bytes taken by stored content, counting content
+// shared by several versions once
+func (s *historyStore) totalSize() (int64, error) {
+	var total int64
+	err := s.db.QueryRow("SELECT COALESCE(SUM(size), 0) FROM (SELECT MAX(size) AS size FROM file_versions GROUP BY hash)").Scan(&total)
+	return total, err
+}
+
+// hashUsed reports whether any version still refers to the content hash
+func (s *historyStore) hashUsed(hash string) (bool, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM file_versions WHERE hash = ?", hash).Scan(&n)
+	return n > 0, err
+}
+
+func (s *historyStore) delete(id string) error {
+	_, err := s.db.Exec("DELETE FROM file_versions WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,203 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileNames are read in each directory searches descend into. Patterns
+// in .ignore take precedence over .gitignore.
+var ignoreFileNames = []string{".gitignore", ".ignore"}
+
+// pathPattern is a glob with gitignore semantics: "*" and "?" don't match
+// "/", "**" matches any number of directories, a pattern with a "/" other
+// than a trailing one is anchored to its base directory and any other
+// pattern matches names at any depth, and a trailing "/" matches only
+// directories.
+type pathPattern struct {
+	re      *regexp.Regexp
+	negate  bool // Re-includes what an earlier pattern excluded
+	dirOnly bool
+}
+
+// compilePathPattern compiles a glob matched against slash-separated paths
+// relative to its base directory
+func compilePathPattern(pattern string, caseSensitive bool) (pathPattern, error) {
+	var p pathPattern
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return p, errors.New("empty pattern")
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !caseSensitive {
+		b.WriteString("(?i)")
+	}
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		b.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if !strings.HasPrefix(pattern[i:], "**") {
+				b.WriteString("[^/]*")
+				break
+			}
+			atStart := i == 0 || pattern[i-1] == '/'
+			switch rest := pattern[i+2:]; {
+			case atStart && rest == "":
+				b.WriteString(".*")
+			case atStart && strings.HasPrefix(rest, "/"):
+				b.WriteString("(?:.*/)?")
+				i++ // Skip the slash too
+			default:
+				b.WriteString("[^/]*")
+			}
+			i++
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return p, errors.New("unterminated character class")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return p, err
+	}
+	p.re = re
+	return p, nil
+}
+
+// match reports whether the pattern matches a slash-separated relative path
+func (p pathPattern) match(rel string, isDir bool) bool {
+	return (isDir || !p.dirOnly) && p.re.MatchString(rel)
+}
+
+// parseIgnoreFile reads the patterns of a .gitignore-format file, skipping
+// invalid ones
+func parseIgnoreFile(data []byte) []pathPattern {
+	var patterns []pathPattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Trailing spaces are ignored unless escaped
+		if trimmed := strings.TrimRight(line, " "); !strings.HasSuffix(trimmed, `\`) {
+			line = trimmed
+		}
+		negate := strings.HasPrefix(line, "!")
+		if negate {
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+
+		p, err := compilePathPattern(line, true)
+		if err != nil {
+			continue
+		}
+		p.negate = negate
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// ignoreSet holds the ignore files of the directories a search has visited
+// and, inside a git repository, those of the directories above its root up
+// to the repository root
+type ignoreSet struct {
+	top      string                   // Highest directory whose files apply
+	patterns map[string][]pathPattern // By directory; nil if it has none
+}
+
+func newIgnoreSet(root string) *ignoreSet {
+	s := &ignoreSet{top: root, patterns: make(map[string][]pathPattern)}
+	for dir := root; ; {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			s.top = dir
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	for dir := root; ; dir = filepath.Dir(dir) {
+		s.load(dir)
+		if dir == s.top {
+			break
+		}
+	}
+	return s
+}
+
+// load reads the ignore files of a directory
+func (s *ignoreSet) load(dir string) {
+	var patterns []pathPattern
+	for _, name := range ignoreFileNames {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			patterns = append(patterns, parseIgnoreFile(data)...)
+		}
+	}
+	s.patterns[dir] = patterns
+}
+
+// ignored reports whether the ignore files above path exclude it. Deeper
+// files take precedence, as do later patterns within a file.
+func (s *ignoreSet) ignored(path string, isDir bool) bool {
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == s.top || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		patterns := s.patterns[dirs[i]]
+		if len(patterns) == 0 {
+			continue
+		}
+		rel := filepath.ToSlash(strings.TrimPrefix(path, strings.TrimSuffix(dirs[i], "/")+"/"))
+		for _, p := range patterns {
+			if p.match(rel, isDir) {
+				ignored = !p.negate
+			}
+		}
+	}
+	return ignored
+}
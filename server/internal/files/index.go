@@ -0,0 +1,751 @@
+package files
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+var (
+	ErrInvalidSearchQuery = apperr.New(apperr.CodeInvalidParameter, "invalid search query")
+	ErrNotIndexed         = apperr.New(apperr.CodeNotIndexed, "path is not covered by the search index")
+)
+
+// Index root states
+const (
+	IndexPending  = "pending"
+	IndexScanning = "scanning"
+	IndexReady    = "ready"
+	IndexFailed   = "failed"
+)
+
+// indexBatchSize is how many entries are written per transaction while
+// scanning, so other writers aren't held up for a whole scan
+const indexBatchSize = 500
+
+// SearchHighlight is a matched span, in characters
+type SearchHighlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// IndexQuery is a search of the index
+type IndexQuery struct {
+	Query   string // Terms, "phrases", prefix*, AND, OR, NOT, parentheses and name: or content: filters
+	Path    string // Only entries below this directory
+	Type    string // "file" or "directory", empty for both
+	Content bool   // Match text content as well as names
+	Limit   int
+	Offset  int
+}
+
+// IndexRootStatus is the state of an indexed directory
+type IndexRootStatus struct {
+	Root          string     `json:"root"`
+	State         string     `json:"state"`
+	Entries       int        `json:"entries"`
+	Scanned       int        `json:"scanned"` // Entries visited by the running or last scan
+	ScanStartedAt *time.Time `json:"scan_started_at,omitempty"`
+	LastScanAt    *time.Time `json:"last_scan_at,omitempty"` // When the last complete scan finished
+	LastScanMs    int64      `json:"last_scan_ms,omitempty"`
+	Error         string     `json:"error,omitempty"`
+
+	path string
+}
+
+// SearchIndexStatus is the state of the search index
+type SearchIndexStatus struct {
+	Roots        []IndexRootStatus `json:"roots"`
+	Watching     bool              `json:"watching"` // Changes are followed with inotify between scans
+	WatchedDirs  int               `json:"watched_dirs"`
+	WatchLimited bool              `json:"watch_limited,omitempty"` // Some directories couldn't be watched; their changes wait for the next scan
+	ScanInterval int               `json:"scan_interval"`           // Minutes between scans, 0 if only scanned at startup
+}
+
+// SearchIndex keeps the names and text content of everything below a set of
+// root directories in an SQLite FTS5 index, so searches there don't have to
+// walk the tree. Roots are scanned at startup and every scan interval,
+// indexing only entries whose size or modification time changed, and
+// changes in between are applied as inotify reports them. A root is used for
+// searches once its first scan completes.
+type SearchIndex struct {
+	svc   *Service
+	store *indexStore
+	cfg   config.SearchIndexConfig
+	roots []string // Absolute paths of the roots that can be indexed
+
+	mu           sync.Mutex
+	status       []*IndexRootStatus
+	watch        *Watch
+	watchLimited bool
+
+	writeMu sync.Mutex // Serializes scans and applying changes
+	rescan  chan string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewSearchIndex creates a search index of the configured roots, stored in
+// db. Roots that aren't allowed directories, or are inside another root,
+// are reported as failed in the status. Call Start to begin indexing.
+func NewSearchIndex(svc *Service, db *database.Database, cfg config.SearchIndexConfig) *SearchIndex {
+	ctx, cancel := context.WithCancel(context.Background())
+	x := &SearchIndex{
+		svc:    svc,
+		store:  newIndexStore(db),
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, root := range cfg.Roots {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+		st := &IndexRootStatus{Root: root, State: IndexPending}
+		x.status = append(x.status, st)
+
+		absPath, err := x.checkRoot(root)
+		if err != nil {
+			logger.Warn("Search index root %s skipped: %v", root, err)
+			st.State = IndexFailed
+			st.Error = err.Error()
+			continue
+		}
+		st.Root = ToTildePath(absPath)
+		st.path = absPath
+		x.roots = append(x.roots, absPath)
+	}
+	x.rescan = make(chan string, len(x.roots)+1)
+	return x
+}
+
+// checkRoot validates a configured root, returning its absolute path
+func (x *SearchIndex) checkRoot(root string) (string, error) {
+	absPath, err := x.svc.validatePath(root)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", ErrNotDirectory.WithMessage("search index roots must be directories")
+	}
+	for _, other := range x.roots {
+		if absPath == other || isWithin(absPath, other) || isWithin(other, absPath) {
+			return "", fmt.Errorf("overlaps root %s", ToTildePath(other))
+		}
+	}
+	return absPath, nil
+}
+
+// isWithin reports whether path is below dir
+func isWithin(path, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// Start removes entries of roots that are no longer configured, then scans
+// in the background and, if enabled, follows changes
+func (x *SearchIndex) Start() {
+	if err := x.store.keepRoots(x.roots); err != nil {
+		logger.Error("Failed to remove old search index entries: %v", err)
+	}
+	for _, st := range x.status {
+		if st.path != "" {
+			st.Entries, _ = x.store.count(st.path)
+		}
+	}
+
+	if x.cfg.Watch && len(x.roots) > 0 {
+		watch, err := x.svc.NewWatch(0)
+		if err != nil {
+			logger.Warn("Search index can't follow changes: %v", err)
+		} else {
+			x.watch = watch
+			x.wg.Add(1)
+			go x.follow()
+		}
+	}
+
+	x.wg.Add(1)
+	go x.run()
+}
+
+// Shutdown stops scanning and following changes
+func (x *SearchIndex) Shutdown() {
+	x.cancel()
+	if x.watch != nil {
+		x.watch.Close()
+	}
+	x.wg.Wait()
+}
+
+// run scans all roots, then again every scan interval or when a rescan is
+// requested
+func (x *SearchIndex) run() {
+	defer x.wg.Done()
+	x.scanAll()
+
+	var tick <-chan time.Time
+	if x.cfg.ScanInterval > 0 {
+		ticker := time.NewTicker(time.Duration(x.cfg.ScanInterval) * time.Minute)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-tick:
+			x.scanAll()
+		case root := <-x.rescan:
+			if root == "" {
+				x.scanAll()
+			} else {
+				x.scan(root)
+			}
+		case <-x.ctx.Done():
+			return
+		}
+	}
+}
+
+// Rescan queues a scan of an indexed root, or of all roots if path is empty
+func (x *SearchIndex) Rescan(path string) error {
+	root := ""
+	if path != "" {
+		absPath, err := x.svc.validatePath(path)
+		if err != nil {
+			return err
+		}
+		for _, r := range x.roots {
+			if r == absPath {
+				root = r
+			}
+		}
+		if root == "" {
+			return ErrNotIndexed.WithMessage("path is not a search index root")
+		}
+	}
+	select {
+	case x.rescan <- root:
+	default: // Enough scans are queued already
+	}
+	return nil
+}
+
+func (x *SearchIndex) scanAll() {
+	for _, root := range x.roots {
+		if x.ctx.Err() != nil {
+			return
+		}
+		x.scan(root)
+	}
+}
+
+// scan brings the index of a root up to date
+func (x *SearchIndex) scan(root string) {
+	started := time.Now()
+	x.updateStatus(root, func(st *IndexRootStatus) {
+		st.State = IndexScanning
+		st.Scanned = 0
+		st.ScanStartedAt = &started
+	})
+
+	err := x.index(root, root)
+	count, countErr := x.store.count(root)
+
+	x.updateStatus(root, func(st *IndexRootStatus) {
+		if countErr == nil {
+			st.Entries = count
+		}
+		switch {
+		case errors.Is(err, context.Canceled):
+			st.State = IndexPending
+		case err != nil:
+			logger.Error("Failed to index %s: %v", ToTildePath(root), err)
+			st.State = IndexFailed
+			st.Error = err.Error()
+		default:
+			finished := time.Now()
+			st.State = IndexReady
+			st.Error = ""
+			st.LastScanAt = &finished
+			st.LastScanMs = finished.Sub(started).Milliseconds()
+		}
+	})
+}
+
+// index walks start, inside root, writing entries whose size or modification
+// time changed and removing those that no longer exist
+func (x *SearchIndex) index(root, start string) error {
+	x.writeMu.Lock()
+	defer x.writeMu.Unlock()
+
+	known, err := x.store.entries(start)
+	if err != nil {
+		return err
+	}
+	batch := &indexBatch{store: x.store}
+	defer batch.rollback()
+
+	err = filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := x.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if path == start && !os.IsNotExist(err) {
+				return err
+			}
+			return nil // Skip what can't be read; it's removed from the index below
+		}
+		if d.IsDir() && path != root && skipSearchDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			x.addWatch(path)
+		}
+
+		x.updateStatus(root, func(st *IndexRootStatus) { st.Scanned++ })
+		f := indexedFile{
+			root:  root,
+			path:  path,
+			name:  d.Name(),
+			typ:   "file",
+			size:  info.Size(),
+			mtime: info.ModTime().UnixNano(),
+		}
+		if d.IsDir() {
+			f.typ = "directory"
+		}
+		old, ok := known[path]
+		delete(known, path)
+		if ok && old.size == f.size && old.mtime == f.mtime {
+			return nil
+		}
+		f.id = old.id
+		return batch.put(f, x.content(path, info))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, f := range known {
+		if err := batch.delete(f.id); err != nil {
+			return err
+		}
+	}
+	return batch.commit()
+}
+
+// content returns the text of a file to index, or "" for anything but small
+// text files
+func (x *SearchIndex) content(path string, info fs.FileInfo) string {
+	if !info.Mode().IsRegular() || info.Size() == 0 || info.Size() > x.cfg.MaxFileSize {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || isBinary(data) {
+		return ""
+	}
+	if !utf8.Valid(data) {
+		return strings.ToValidUTF8(string(data), " ")
+	}
+	return string(data)
+}
+
+// addWatch follows changes in a directory, if changes are followed at all
+func (x *SearchIndex) addWatch(dir string) {
+	if x.watch == nil {
+		return
+	}
+	if _, err := x.watch.Add(dir); err != nil && !os.IsNotExist(err) {
+		x.mu.Lock()
+		if !x.watchLimited {
+			logger.Warn("Search index can't watch %s, changes there wait for the next scan: %v", ToTildePath(dir), err)
+		}
+		x.watchLimited = true
+		x.mu.Unlock()
+	}
+}
+
+// follow applies changes reported by the watch until it is closed
+func (x *SearchIndex) follow() {
+	defer x.wg.Done()
+	for events := range x.watch.Events() {
+		for _, e := range events {
+			if err := x.apply(e); err != nil && x.ctx.Err() == nil {
+				logger.Error("Failed to update search index for %s: %v", e.Path, err)
+			}
+		}
+	}
+}
+
+// apply updates the index for a watch event
+func (x *SearchIndex) apply(e WatchEvent) error {
+	if e.Op == WatchOverflow {
+		return x.Rescan("")
+	}
+	if e.OldPath != "" {
+		if oldPath, err := ExpandTilde(e.OldPath); err == nil {
+			if root, ok := x.rootOf(oldPath); ok {
+				if err := x.remove(root, oldPath); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	path, err := ExpandTilde(e.Path)
+	if err != nil {
+		return err
+	}
+	root, ok := x.rootOf(path)
+	if !ok || x.skipped(root, path, e.IsDir) {
+		return nil
+	}
+	if e.Op == WatchDelete {
+		return x.remove(root, path)
+	}
+	if err := x.index(root, path); err != nil {
+		return err
+	}
+	x.refreshCount(root)
+	return nil
+}
+
+// remove drops path and everything below it from the index
+func (x *SearchIndex) remove(root, path string) error {
+	x.writeMu.Lock()
+	defer x.writeMu.Unlock()
+
+	tx, err := x.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := x.store.deleteTree(tx, path); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	x.refreshCount(root)
+	return nil
+}
+
+func (x *SearchIndex) refreshCount(root string) {
+	if count, err := x.store.count(root); err == nil {
+		x.updateStatus(root, func(st *IndexRootStatus) { st.Entries = count })
+	}
+}
+
+// rootOf returns the indexed root containing path
+func (x *SearchIndex) rootOf(path string) (string, bool) {
+	for _, root := range x.roots {
+		if path == root || isWithin(path, root) {
+			return root, true
+		}
+	}
+	return "", false
+}
+
+// skipped reports whether path is in, or is, a directory scans skip
+func (x *SearchIndex) skipped(root, path string, isDir bool) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, name := range parts {
+		if (i < len(parts)-1 || isDir) && skipSearchDir(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *SearchIndex) updateStatus(root string, fn func(st *IndexRootStatus)) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, st := range x.status {
+		if st.path == root {
+			fn(st)
+		}
+	}
+}
+
+// Status returns the state of each root and of change following
+func (x *SearchIndex) Status() SearchIndexStatus {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	status := SearchIndexStatus{
+		Roots:        make([]IndexRootStatus, 0, len(x.status)),
+		Watching:     x.watch != nil,
+		WatchLimited: x.watchLimited,
+		ScanInterval: x.cfg.ScanInterval,
+	}
+	for _, st := range x.status {
+		status.Roots = append(status.Roots, *st)
+	}
+	if x.watch != nil {
+		status.WatchedDirs = len(x.watch.Dirs())
+	}
+	return status
+}
+
+// Covers reports whether searches of path can use the index: it is inside a
+// root that has been scanned completely at least once
+func (x *SearchIndex) Covers(path string) bool {
+	absPath, err := x.svc.validatePath(path)
+	if err != nil {
+		return false
+	}
+	root, ok := x.rootOf(absPath)
+	if !ok {
+		return false
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, st := range x.status {
+		if st.path == root {
+			return st.LastScanAt != nil
+		}
+	}
+	return false
+}
+
+// Search returns the best matches for a query below q.Path, most relevant
+// first. Names weigh more than content. Matching content is returned as a
+// snippet, with the positions of matched terms in it and in the name.
+func (x *SearchIndex) Search(q IndexQuery) ([]SearchResult, error) {
+	absPath, err := x.svc.validatePath(q.Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := x.rootOf(absPath); !ok {
+		return nil, ErrNotIndexed
+	}
+	match, err := parseSearchQuery(q.Query)
+	if err != nil {
+		return nil, err
+	}
+	if !q.Content {
+		match = "name : (" + match + ")"
+	}
+
+	matches, err := x.store.search(match, absPath, q.Type, q.Limit, q.Offset)
+	if err != nil {
+		if strings.Contains(err.Error(), "fts5") {
+			return nil, ErrInvalidSearchQuery.WithMessage("invalid search query: check operators and parentheses")
+		}
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(matches))
+	for _, m := range matches {
+		_, nameHighlights := parseHighlights(m.highlighted)
+		snippet, highlights := parseHighlights(m.snippet)
+		results = append(results, SearchResult{
+			Path:           ToTildePath(m.path),
+			Name:           m.name,
+			Type:           m.typ,
+			Size:           m.size,
+			Snippet:        snippet,
+			Highlights:     highlights,
+			NameHighlights: nameHighlights,
+			Score:          -m.rank, // BM25 ranks better matches lower
+		})
+	}
+	return results, nil
+}
+
+// parseHighlights removes highlight markers from s, returning where they were
+func parseHighlights(s string) (string, []SearchHighlight) {
+	if !strings.Contains(s, highlightStart) {
+		return s, nil
+	}
+	var b strings.Builder
+	var highlights []SearchHighlight
+	pos, start := 0, 0
+	for _, r := range s {
+		switch string(r) {
+		case highlightStart:
+			start = pos
+		case highlightEnd:
+			highlights = append(highlights, SearchHighlight{Start: start, End: pos})
+		default:
+			b.WriteRune(r)
+			pos++
+		}
+	}
+	return b.String(), highlights
+}
+
+// parseSearchQuery converts a user query into an FTS5 expression. Words
+// and "quoted phrases" are matched as phrases, so punctuation in them has no
+// special meaning; a trailing * makes them prefixes. AND, OR and NOT
+// (uppercase) and parentheses are passed through, terms next to each other
+// must all match, and name: or content: limits the term or group after it
+// to that column.
+func parseSearchQuery(query string) (string, error) {
+	var parts []string
+	depth := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			parts = append(parts, "(")
+			depth++
+			i++
+		case c == ')':
+			if depth == 0 {
+				return "", ErrInvalidSearchQuery.WithMessage("unbalanced parentheses in search query")
+			}
+			parts = append(parts, ")")
+			depth--
+			i++
+		case c == '"':
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				return "", ErrInvalidSearchQuery.WithMessage("unterminated phrase in search query")
+			}
+			phrase := query[i+1 : i+1+end]
+			i += end + 2
+			prefix := i < len(query) && query[i] == '*'
+			if prefix {
+				i++
+			}
+			if term := searchTerm(phrase, prefix); term != "" {
+				parts = append(parts, term)
+			}
+		default:
+			end := strings.IndexAny(query[i:], " \t\n\r()\"")
+			if end < 0 {
+				end = len(query) - i
+			}
+			word := query[i : i+end]
+			i += end
+
+			if word == "AND" || word == "OR" || word == "NOT" {
+				parts = append(parts, word)
+				continue
+			}
+			if column, rest, ok := strings.Cut(word, ":"); ok && (column == "name" || column == "content") {
+				parts = append(parts, column+" :")
+				if word = rest; word == "" {
+					continue // Applies to the phrase or group that follows
+				}
+			}
+			prefix := strings.HasSuffix(word, "*")
+			if term := searchTerm(strings.TrimRight(word, "*"), prefix); term != "" {
+				parts = append(parts, term)
+			}
+		}
+	}
+	if depth != 0 {
+		return "", ErrInvalidSearchQuery.WithMessage("unbalanced parentheses in search query")
+	}
+	if len(parts) == 0 {
+		return "", ErrInvalidSearchQuery.WithMessage("search query has no terms")
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// searchTerm quotes text as an FTS5 phrase, or returns "" if it has no
+// letters or digits to match
+func searchTerm(text string, prefix bool) string {
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		return ""
+	}
+	term := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+	if prefix {
+		term += "*"
+	}
+	return term
+}
+
+// indexBatch writes index changes in transactions of indexBatchSize entries
+type indexBatch struct {
+	store *indexStore
+	tx    *sql.Tx
+	n     int
+}
+
+func (b *indexBatch) begin() error {
+	if b.tx != nil {
+		return nil
+	}
+	tx, err := b.store.db.Begin()
+	if err != nil {
+		return err
+	}
+	b.tx = tx
+	return nil
+}
+
+func (b *indexBatch) put(f indexedFile, content string) error {
+	if err := b.begin(); err != nil {
+		return err
+	}
+	if err := b.store.put(b.tx, f, content); err != nil {
+		return err
+	}
+	return b.written()
+}
+
+func (b *indexBatch) delete(id int64) error {
+	if err := b.begin(); err != nil {
+		return err
+	}
+	if err := b.store.delete(b.tx, id); err != nil {
+		return err
+	}
+	return b.written()
+}
+
+func (b *indexBatch) written() error {
+	if b.n++; b.n >= indexBatchSize {
+		return b.commit()
+	}
+	return nil
+}
+
+func (b *indexBatch) commit() error {
+	if b.tx == nil {
+		return nil
+	}
+	err := b.tx.Commit()
+	b.tx, b.n = nil, 0
+	return err
+}
+
+func (b *indexBatch) rollback() {
+	if b.tx != nil {
+		b.tx.Rollback()
+		b.tx = nil
+	}
+}
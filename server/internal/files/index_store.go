@@ -0,0 +1,200 @@
+package files
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/ss497254/gloski/internal/database"
+)
+
+// indexStore handles persistence of the search index to SQLite. Metadata is
+// kept in search_files and names and text in the search_fts FTS5 table,
+// whose rowids are the search_files ids. Paths are stored absolute.
+type indexStore struct {
+	db *sql.DB
+}
+
+func newIndexStore(database *database.Database) *indexStore {
+	return &indexStore{db: database.DB()}
+}
+
+// indexedFile is an entry's row in search_files
+type indexedFile struct {
+	id    int64
+	root  string
+	path  string
+	name  string
+	typ   string
+	size  int64
+	mtime int64 // Unix nanoseconds
+}
+
+// under returns a condition matching path and everything below it, and its
+// arguments. Ranges compare faster than LIKE and need no escaping: '0' sorts
+// right after '/'.
+func under(column, path string) (string, []any) {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	return "(" + column + " = ? OR (" + column + " >= ? AND " + column + " < ?))",
+		[]any{path, prefix, strings.TrimSuffix(prefix, "/") + "0"}
+}
+
+// entries returns the indexed entries at and below path, by path
+func (s *indexStore) entries(path string) (map[string]indexedFile, error) {
+	cond, args := under("path", path)
+	rows, err := s.db.Query("SELECT id, path, size, mtime FROM search_files WHERE "+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make(map[string]indexedFile)
+	for rows.Next() {
+		var f indexedFile
+		if err := rows.Scan(&f.id, &f.path, &f.size, &f.mtime); err != nil {
+			return nil, err
+		}
+		entries[f.path] = f
+	}
+	return entries, rows.Err()
+}
+
+// put inserts or replaces an entry with its text content
+func (s *indexStore) put(tx *sql.Tx, f indexedFile, content string) error {
+	if f.id == 0 {
+		err := tx.QueryRow("SELECT id FROM search_files WHERE path = ?", f.path).Scan(&f.id)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	if f.id != 0 {
+		if _, err := tx.Exec(`
+			UPDATE search_files SET root = ?, name = ?, type = ?, size = ?, mtime = ? WHERE id = ?
+		`, f.root, f.name, f.typ, f.size, f.mtime, f.id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM search_fts WHERE rowid = ?", f.id); err != nil {
+			return err
+		}
+	} else {
+		res, err := tx.Exec(`
+			INSERT INTO search_files (root, path, name, type, size, mtime) VALUES (?, ?, ?, ?, ?, ?)
+		`, f.root, f.path, f.name, f.typ, f.size, f.mtime)
+		if err != nil {
+			return err
+		}
+		if f.id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("INSERT INTO search_fts (rowid, name, content) VALUES (?, ?, ?)", f.id, f.name, content)
+	return err
+}
+
+// delete removes entries by id
+func (s *indexStore) delete(tx *sql.Tx, ids ...int64) error {
+	for _, id := range ids {
+		if _, err := tx.Exec("DELETE FROM search_fts WHERE rowid = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM search_files WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteTree removes the entry at path and everything indexed below it
+func (s *indexStore) deleteTree(tx *sql.Tx, path string) error {
+	cond, args := under("path", path)
+	if _, err := tx.Exec("DELETE FROM search_fts WHERE rowid IN (SELECT id FROM search_files WHERE "+cond+")", args...); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM search_files WHERE "+cond, args...)
+	return err
+}
+
+// keepRoots removes the entries of roots no longer indexed
+func (s *indexStore) keepRoots(roots []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	args := make([]any, len(roots))
+	for i, root := range roots {
+		args[i] = root
+	}
+	cond := "root NOT IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(roots)), ", ") + ")"
+	if len(roots) == 0 {
+		cond = "1"
+	}
+	if _, err := tx.Exec("DELETE FROM search_fts WHERE rowid IN (SELECT id FROM search_files WHERE "+cond+")", args...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM search_files WHERE "+cond, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// count returns how many entries of a root are indexed
+func (s *indexStore) count(root string) (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM search_files WHERE root = ?", root).Scan(&n)
+	return n, err
+}
+
+// indexMatch is a search hit
+type indexMatch struct {
+	indexedFile
+	highlighted string // The name, highlighted
+	snippet     string // Highlighted, empty if the content doesn't match
+	rank        float64
+}
+
+// Highlight markers in names and snippets, replaced before results are returned
+const (
+	highlightStart = "\x02"
+	highlightEnd   = "\x03"
+)
+
+// search runs an FTS5 query below path. Name matches weigh ten times as much
+// as content matches in the BM25 ranking.
+func (s *indexStore) search(match, path, typ string, limit, offset int) ([]indexMatch, error) {
+	cond, args := under("f.path", path)
+	query := `
+		SELECT f.id, f.path, f.name, f.type, f.size, f.mtime,
+			highlight(search_fts, 0, char(2), char(3)),
+			snippet(search_fts, 1, char(2), char(3), '…', 24),
+			bm25(search_fts, 10.0, 1.0) AS score
+		FROM search_fts
+		JOIN search_files f ON f.id = search_fts.rowid
+		WHERE search_fts MATCH ? AND ` + cond
+	args = append([]any{match}, args...)
+	if typ != "" {
+		query += " AND f.type = ?"
+		args = append(args, typ)
+	}
+	query += " ORDER BY score LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []indexMatch
+	for rows.Next() {
+		var m indexMatch
+		if err := rows.Scan(&m.id, &m.path, &m.name, &m.typ, &m.size, &m.mtime, &m.highlighted, &m.snippet, &m.rank); err != nil {
+			return nil, err
+		}
+		if !strings.Contains(m.snippet, highlightStart) {
+			m.snippet = ""
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
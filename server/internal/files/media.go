@@ -0,0 +1,411 @@
+package files
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // Registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Registers the WebP decoder
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+var (
+	ErrUnsupportedImage = apperr.New(apperr.CodeUnsupportedMedia, "not a PNG, JPEG, GIF or WebP image")
+	ErrImageTooLarge    = apperr.New(apperr.CodeImageTooLarge, "image too large to decode")
+	ErrInvalidThumbSize = apperr.New(apperr.CodeInvalidParameter,
+		fmt.Sprintf("size must be between %d and %d", MinThumbnailSize, MaxThumbnailSize))
+)
+
+// Thumbnail sizes, in pixels of the longer side
+const (
+	DefaultThumbnailSize = 256
+	MinThumbnailSize     = 16
+	MaxThumbnailSize     = 1024
+)
+
+// thumbnailQuality is the JPEG quality of opaque thumbnails; thumbnails with
+// transparency are PNG
+const thumbnailQuality = 80
+
+// Media kinds
+const (
+	MediaImage = "image"
+	MediaAudio = "audio"
+	MediaVideo = "video"
+	MediaOther = "other"
+)
+
+// MediaInfo is what a file's headers say about its content
+type MediaInfo struct {
+	Path      string         `json:"path"`
+	Kind      string         `json:"kind"` // image, audio, video or other
+	MimeType  string         `json:"mime_type"`
+	Size      int64          `json:"size"`
+	Image     *ImageInfo     `json:"image,omitempty"`
+	Exif      *ExifInfo      `json:"exif,omitempty"`
+	Container *ContainerInfo `json:"container,omitempty"` // Audio and video
+}
+
+// ImageInfo is the format and dimensions of an image
+type ImageInfo struct {
+	Format string `json:"format"` // png, jpeg, gif or webp
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Thumbnail is a cached, downscaled copy of an image
+type Thumbnail struct {
+	Path        string // Cache file
+	ContentType string
+	ETag        string
+	ModTime     time.Time // The image's
+}
+
+// Media generates image thumbnails and reads image, audio and video metadata.
+// Thumbnails are cached under dir by the image's path, modification time and
+// size and the thumbnail size, so changed images get new ones; the least
+// recently used are dropped once the cache exceeds its limit. Decoding is
+// bounded by the file size and pixel limits and runs on a fixed number of
+// workers; metadata is read from headers only.
+type Media struct {
+	svc     *Service
+	dir     string
+	limits  config.MediaConfig
+	workers chan struct{}
+
+	mu        sync.Mutex // Guards cacheSize and serializes pruning
+	cacheSize int64      // Bytes in dir; -1 until measured
+}
+
+// NewMedia creates a media service for the files service, caching thumbnails in dir
+func NewMedia(svc *Service, dir string, limits config.MediaConfig) *Media {
+	return &Media{
+		svc:       svc,
+		dir:       dir,
+		limits:    limits,
+		workers:   make(chan struct{}, max(limits.Workers, 1)),
+		cacheSize: -1,
+	}
+}
+
+// Thumbnail returns a thumbnail of an image whose longer side is at most size
+// pixels, generating it if it isn't cached. Images are never upscaled, and
+// the EXIF orientation is applied.
+func (m *Media) Thumbnail(ctx context.Context, path string, size int) (*Thumbnail, error) {
+	if size == 0 {
+		size = DefaultThumbnailSize
+	}
+	if size < MinThumbnailSize || size > MaxThumbnailSize {
+		return nil, ErrInvalidThumbSize
+	}
+	absPath, err := m.svc.validatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrIsDirectory
+	}
+	if !info.Mode().IsRegular() {
+		return nil, ErrUnsupportedImage
+	}
+	if info.Size() > m.limits.MaxFileSize {
+		return nil, ErrImageTooLarge.WithMessage(fmt.Sprintf("image is larger than %d bytes", m.limits.MaxFileSize))
+	}
+
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%d\x00%d\x00%d", absPath, info.ModTime().UnixNano(), info.Size(), size))
+	key := hex.EncodeToString(sum[:])
+	thumb := &Thumbnail{ETag: `"` + key[:32] + `"`, ModTime: info.ModTime()}
+	for _, ext := range []string{".jpg", ".png"} {
+		cached := filepath.Join(m.dir, key[:2], key+ext)
+		if _, err := os.Stat(cached); err == nil {
+			now := time.Now()
+			os.Chtimes(cached, now, now) // Marks it recently used
+			thumb.Path, thumb.ContentType = cached, mime.TypeByExtension(ext)
+			return thumb, nil
+		}
+	}
+
+	select {
+	case m.workers <- struct{}{}:
+		defer func() { <-m.workers }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	img, orientation, err := m.decode(absPath)
+	if err != nil {
+		return nil, err
+	}
+	// Orienting after scaling moves far fewer pixels; the box is square, so
+	// the result is the same
+	scaled := orient(scaleImage(img, size), orientation)
+
+	ext := ".jpg"
+	if !scaled.Opaque() {
+		ext = ".png"
+	}
+	thumb.Path = filepath.Join(m.dir, key[:2], key+ext)
+	thumb.ContentType = mime.TypeByExtension(ext)
+	written, err := writeThumbnail(thumb.Path, scaled)
+	if err != nil {
+		return nil, err
+	}
+	m.cached(written)
+	return thumb, nil
+}
+
+// decode decodes an image after checking its dimensions against the pixel
+// limit, and returns it with its EXIF orientation
+func (m *Media) decode(absPath string) (image.Image, int, error) {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, 0, ErrUnsupportedImage
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > m.limits.MaxPixels {
+		return nil, 0, ErrImageTooLarge.WithMessage(fmt.Sprintf("image has more than %d pixels", m.limits.MaxPixels))
+	}
+	orientation := 1
+	if exif := parseExif(readExif(f, format)); exif != nil && exif.Orientation != 0 {
+		orientation = exif.Orientation
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, 0, ErrUnsupportedImage.WithMessage("invalid " + format + " image: " + err.Error())
+	}
+	return img, orientation, nil
+}
+
+// scaleImage fits an image into a size×size box, keeping its aspect ratio
+func scaleImage(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(h*size/w, 1)
+		} else {
+			w, h = max(w*size/h, 1), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	// BiLinear widens its kernel when downscaling, so every source pixel counts
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// orient transforms an image as its EXIF orientation (1-8) says to display it
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // Rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // Mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // Mirrored along the top-left diagonal
+				dx, dy = y, x
+			case 6: // Rotated 90° clockwise to display
+				dx, dy = h-1-y, x
+			case 7: // Mirrored along the top-right diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // Rotated 90° counterclockwise to display
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], img.Pix[img.PixOffset(b.Min.X+x, b.Min.Y+y):][:4])
+		}
+	}
+	return dst
+}
+
+// writeThumbnail encodes a thumbnail to path through a temporary file, so
+// readers never see a partial one, and returns its size
+func writeThumbnail(path string, img *image.RGBA) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".thumb-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if strings.HasSuffix(path, ".png") {
+		err = png.Encode(tmp, img)
+	} else {
+		err = jpeg.Encode(tmp, img, &jpeg.Options{Quality: thumbnailQuality})
+	}
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(tmp.Name(), path)
+}
+
+// cached accounts for a new thumbnail of n bytes, dropping the least recently
+// used ones once the cache exceeds its limit
+func (m *Media) cached(n int64) {
+	if m.limits.ThumbnailCache <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type entry struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var entries []entry
+	scan := func() {
+		entries, m.cacheSize = nil, 0
+		filepath.WalkDir(m.dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				entries = append(entries, entry{path, info.Size(), info.ModTime()})
+				m.cacheSize += info.Size()
+			}
+			return nil
+		})
+	}
+
+	if m.cacheSize < 0 {
+		scan()
+	} else {
+		m.cacheSize += n
+	}
+	if m.cacheSize <= m.limits.ThumbnailCache {
+		return
+	}
+	if entries == nil {
+		scan()
+	}
+
+	// Prune to 90% of the limit so the next few thumbnails don't prune again
+	slices.SortFunc(entries, func(a, b entry) int { return a.used.Compare(b.used) })
+	target := m.limits.ThumbnailCache / 10 * 9
+	removed := 0
+	for _, e := range entries {
+		if m.cacheSize <= target {
+			break
+		}
+		if err := os.Remove(e.path); err == nil || os.IsNotExist(err) {
+			m.cacheSize -= e.size
+			removed++
+		}
+	}
+	logger.Debug("Pruned %d thumbnails", removed)
+}
+
+// Info reads what a file's headers say about its content: the dimensions and
+// EXIF metadata of images, and the container, duration and tracks of audio
+// and video files
+func (m *Media) Info(path string) (*MediaInfo, error) {
+	absPath, err := m.svc.validatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, ErrIsDirectory
+	}
+
+	info := &MediaInfo{
+		Path: ToTildePath(absPath),
+		Kind: MediaOther,
+		Size: stat.Size(),
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	info.MimeType = cmp.Or(mime.TypeByExtension(filepath.Ext(absPath)), http.DetectContentType(head))
+	if i := strings.IndexByte(info.MimeType, ';'); i >= 0 {
+		info.MimeType = info.MimeType[:i]
+	}
+	if !stat.Mode().IsRegular() {
+		return info, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if cfg, format, err := image.DecodeConfig(f); err == nil {
+		info.Kind = MediaImage
+		info.MimeType = "image/" + format
+		info.Image = &ImageInfo{Format: format, Width: cfg.Width, Height: cfg.Height}
+		info.Exif = parseExif(readExif(f, format))
+		return info, nil
+	}
+
+	if c := readContainer(f, head, stat.Size()); c != nil {
+		info.Container = c
+		info.Kind = MediaAudio
+		if slices.ContainsFunc(c.Tracks, func(t MediaTrack) bool { return t.Type == MediaVideo }) ||
+			(len(c.Tracks) == 0 && c.Format == "matroska") {
+			info.Kind = MediaVideo
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,479 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+var (
+	ErrOperationNotFound = apperr.New(apperr.CodeOperationNotFound, "operation not found")
+	ErrOperationFinished = apperr.New(apperr.CodeInvalidState, "operation already finished")
+	ErrOperationRunning  = apperr.New(apperr.CodeInvalidState, "operation is still running")
+)
+
+// OperationType is the kind of a background file operation
+type OperationType string
+
+const (
+	OperationCopy    OperationType = "copy"
+	OperationMove    OperationType = "move"
+	OperationExtract OperationType = "extract"
+
+	OperationChecksum OperationType = "checksum"
+	OperationVerify   OperationType = "verify"
+	OperationUsage    OperationType = "usage"
+)
+
+// OperationStatus represents the state of a background file operation
+type OperationStatus string
+
+const (
+	OperationRunning   OperationStatus = "running"
+	OperationCompleted OperationStatus = "completed"
+	OperationFailed    OperationStatus = "failed"
+	OperationCancelled OperationStatus = "cancelled"
+)
+
+const (
+	// maxFinishedOperations is how many finished operations are kept for listing
+	maxFinishedOperations = 100
+
+	// operationProgressInterval throttles progress events per operation
+	operationProgressInterval = time.Second
+)
+
+// Operation is a copy or move of files and directory trees, the extraction
+// of an archive, the hashing or checksum verification of files, or a disk
+// usage scan, running in the background
+type Operation struct {
+	ID          string          `json:"id"`
+	Type        OperationType   `json:"type"`
+	Sources     []string        `json:"sources"`
+	Destination string          `json:"destination,omitempty"` // Directory the sources are placed in
+	Conflict    ConflictPolicy  `json:"conflict,omitempty"`
+	Algorithm   string          `json:"algorithm,omitempty"` // Checksums only
+	Status      OperationStatus `json:"status"`
+	Error       string          `json:"error,omitempty"`
+
+	// A completed checksum's *ChecksumResult, verify's *VerifyResult, or
+	// usage scan's *UsageEntry for the scanned directory
+	Result any `json:"result,omitempty"`
+
+	// Progress. Totals are known once the sources have been scanned; tar
+	// archives can't be scanned cheaply, so extracting one reports no totals.
+	TotalFiles   int    `json:"total_files"`
+	TotalBytes   int64  `json:"total_bytes"`
+	FilesDone    int    `json:"files_done"`
+	FilesSkipped int    `json:"files_skipped"`
+	BytesDone    int64  `json:"bytes_done"`
+	Current      string `json:"current,omitempty"` // Last file processed
+
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// OperationRequest represents a request to start a copy, move or extract.
+// An extract takes exactly one source, the archive.
+type OperationRequest struct {
+	Type        OperationType  `json:"type"`
+	Sources     []string       `json:"sources"`
+	Destination string         `json:"destination"`
+	Conflict    ConflictPolicy `json:"conflict,omitempty"` // Default: fail
+}
+
+// OperationListSpec describes how operation listings can be sorted
+var OperationListSpec = listing.Spec{
+	SortFields:   []string{"created_at", "status", "type", "total_bytes"},
+	DefaultSort:  "created_at",
+	DefaultOrder: listing.OrderDesc,
+}
+
+var operationSortFields = map[string]listing.Key[*Operation]{
+	"created_at":  func(op *Operation) any { return op.CreatedAt },
+	"status":      func(op *Operation) any { return string(op.Status) },
+	"type":        func(op *Operation) any { return string(op.Type) },
+	"total_bytes": func(op *Operation) any { return op.TotalBytes },
+}
+
+func operationID(op *Operation) string {
+	return op.ID
+}
+
+// Operations runs and tracks background file operations.
+// Operations are kept in memory only; running ones are cancelled on shutdown.
+type Operations struct {
+	svc *Service
+
+	mu      sync.RWMutex
+	ops     map[string]*Operation
+	cancels map[string]context.CancelFunc
+
+	wg sync.WaitGroup
+}
+
+// NewOperations creates an operation manager for the files service
+func NewOperations(svc *Service) *Operations {
+	return &Operations{
+		svc:     svc,
+		ops:     make(map[string]*Operation),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// operationItem is one source and where it goes
+type operationItem struct {
+	src, dst string
+}
+
+// Start validates a request and starts the operation in the background.
+// Checksum operations are started by Checksum and Verify.
+func (o *Operations) Start(req OperationRequest) (*Operation, error) {
+	if req.Type != OperationCopy && req.Type != OperationMove && req.Type != OperationExtract {
+		return nil, apperr.New(apperr.CodeInvalidParameter, "type must be copy, move or extract")
+	}
+	if req.Conflict == "" {
+		req.Conflict = ConflictFail
+	}
+	if !req.Conflict.Valid() {
+		return nil, apperr.New(apperr.CodeInvalidParameter, "conflict must be one of fail, skip, overwrite, rename")
+	}
+	if len(req.Sources) == 0 {
+		return nil, apperr.New(apperr.CodeBadRequest, "sources are required")
+	}
+	if req.Type == OperationExtract && len(req.Sources) != 1 {
+		return nil, apperr.New(apperr.CodeInvalidParameter, "extract takes exactly one archive")
+	}
+
+	absDest, err := o.svc.validatePath(req.Destination)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(absDest); err != nil || !info.IsDir() {
+		return nil, ErrNotDirectory
+	}
+
+	items := make([]operationItem, 0, len(req.Sources))
+	for _, source := range req.Sources {
+		absSrc, err := o.svc.validatePath(source)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Lstat(absSrc)
+		if os.IsNotExist(err) {
+			return nil, ErrPathNotFound.WithDetails(map[string]string{"path": source})
+		} else if err != nil {
+			return nil, err
+		}
+		if req.Type == OperationExtract {
+			if !info.Mode().IsRegular() {
+				return nil, ErrUnsupportedArchive.WithDetails(map[string]string{"path": source})
+			}
+			if _, err := sniffArchive(absSrc); err != nil {
+				return nil, err
+			}
+			items = append(items, operationItem{src: absSrc, dst: absDest})
+			continue
+		}
+		if req.Type == OperationMove && isProtectedPath(absSrc) {
+			return nil, ErrDangerousPath
+		}
+		if info.IsDir() && (absDest == absSrc || strings.HasPrefix(absDest, absSrc+"/")) {
+			return nil, ErrDangerousPath.WithMessage("cannot copy a directory into itself")
+		}
+
+		dst := filepath.Join(absDest, filepath.Base(absSrc))
+		if dst == absSrc && req.Conflict != ConflictRename {
+			return nil, ErrPathExists.WithMessage("source and destination are the same")
+		}
+		if req.Conflict == ConflictFail {
+			if _, err := os.Lstat(dst); err == nil {
+				return nil, ErrPathExists.WithDetails(map[string]string{"path": ToTildePath(dst)})
+			}
+		}
+		items = append(items, operationItem{src: absSrc, dst: dst})
+	}
+
+	op := &Operation{
+		ID:          uuid.New().String(),
+		Type:        req.Type,
+		Sources:     req.Sources,
+		Destination: ToTildePath(absDest),
+		Conflict:    req.Conflict,
+	}
+	logger.Info("File operation %s started: %s %d item(s) to %s", op.ID, op.Type, len(items), op.Destination)
+	return o.launch(op, func(ctx context.Context) error {
+		if op.Type == OperationExtract {
+			return o.runExtract(ctx, op, items[0])
+		}
+		return o.runCopy(ctx, op, items)
+	}), nil
+}
+
+// launch registers an operation and runs work for it in the background
+func (o *Operations) launch(op *Operation, work func(ctx context.Context) error) *Operation {
+	op.Status = OperationRunning
+	op.CreatedAt = time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	o.mu.Lock()
+	o.ops[op.ID] = op
+	o.cancels[op.ID] = cancel
+	o.pruneLocked()
+	snapshot := *op
+	o.mu.Unlock()
+
+	o.publish(&snapshot)
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		o.run(ctx, op, work)
+	}()
+
+	return &snapshot
+}
+
+func (o *Operations) run(ctx context.Context, op *Operation, work func(ctx context.Context) error) {
+	runErr := work(ctx)
+
+	cancelled := ctx.Err() != nil
+	o.mu.Lock()
+	cancel := o.cancels[op.ID]
+	delete(o.cancels, op.ID)
+	o.mu.Unlock()
+	cancel()
+
+	o.update(op, true, func(op *Operation) {
+		now := time.Now()
+		op.FinishedAt = &now
+		op.Current = ""
+		switch {
+		case cancelled:
+			op.Status = OperationCancelled
+		case runErr != nil:
+			op.Status = OperationFailed
+			op.Error = runErr.Error()
+		default:
+			op.Status = OperationCompleted
+		}
+	})
+
+	if runErr != nil && !cancelled {
+		logger.Error("File operation %s failed: %v", op.ID, runErr)
+	} else {
+		logger.Info("File operation %s %s", op.ID, op.Status)
+	}
+}
+
+func (o *Operations) runCopy(ctx context.Context, op *Operation, items []operationItem) error {
+	// Scan first so clients can show a percentage
+	var totalFiles int
+	var totalBytes int64
+	for _, item := range items {
+		files, bytes, err := treeSize(ctx, item.src)
+		if err != nil {
+			return err
+		}
+		totalFiles += files
+		totalBytes += bytes
+	}
+	o.update(op, true, func(op *Operation) {
+		op.TotalFiles = totalFiles
+		op.TotalBytes = totalBytes
+	})
+
+	c := o.copier(ctx, op)
+	for _, item := range items {
+		dst, err := c.copyItem(item.src, item.dst)
+		if err != nil {
+			return err
+		}
+		if c.move {
+			o.svc.changed(OpRename, dst, item.src)
+		} else {
+			o.svc.changed(OpCopy, dst, item.src)
+		}
+	}
+	return nil
+}
+
+func (o *Operations) runExtract(ctx context.Context, op *Operation, item operationItem) error {
+	format, err := sniffArchive(item.src)
+	if err != nil {
+		return err
+	}
+	if format == ArchiveZip {
+		files, bytes, err := zipTotals(item.src)
+		if err != nil {
+			return err
+		}
+		o.update(op, true, func(op *Operation) {
+			op.TotalFiles = files
+			op.TotalBytes = bytes
+		})
+	}
+
+	limits := o.svc.config.Archive
+	e := &extractor{
+		copier:   o.copier(ctx, op),
+		svc:      o.svc,
+		dest:     item.dst,
+		maxSize:  limits.MaxExtractSize,
+		maxFiles: limits.MaxExtractFiles,
+	}
+	err = e.extractArchive(item.src)
+	if e.entries > 0 {
+		o.svc.changed(OpExtract, item.dst, item.src)
+	}
+	return err
+}
+
+// copier returns a copier for op that reports progress on it
+func (o *Operations) copier(ctx context.Context, op *Operation) *copier {
+	return &copier{
+		ctx:      ctx,
+		conflict: op.Conflict,
+		move:     op.Type == OperationMove,
+		onBytes:  o.bytesProgress(op),
+		onFile: func(path string, skipped bool) {
+			o.update(op, false, func(op *Operation) {
+				if skipped {
+					op.FilesSkipped++
+				} else {
+					op.FilesDone++
+				}
+				op.Current = ToTildePath(path)
+			})
+		},
+	}
+}
+
+// bytesProgress returns a func that adds to op's bytes done, publishing the
+// operation at most once per operationProgressInterval
+func (o *Operations) bytesProgress(op *Operation) func(n int64) {
+	var lastPublish time.Time
+	return func(n int64) {
+		force := time.Since(lastPublish) >= operationProgressInterval
+		if force {
+			lastPublish = time.Now()
+		}
+		o.update(op, force, func(op *Operation) { op.BytesDone += n })
+	}
+}
+
+// update changes an operation under the lock and optionally publishes it
+func (o *Operations) update(op *Operation, publish bool, fn func(op *Operation)) {
+	o.mu.Lock()
+	fn(op)
+	snapshot := *op
+	o.mu.Unlock()
+
+	if publish {
+		o.publish(&snapshot)
+	}
+}
+
+func (o *Operations) publish(op *Operation) {
+	o.svc.events.Publish(events.TopicFileOperation, op)
+}
+
+// pruneLocked drops the oldest finished operations beyond the retention limit
+func (o *Operations) pruneLocked() {
+	var finished []*Operation
+	for _, op := range o.ops {
+		if op.Status != OperationRunning {
+			finished = append(finished, op)
+		}
+	}
+	if len(finished) <= maxFinishedOperations {
+		return
+	}
+	listing.Sort(finished, listing.Params{Sort: "created_at", Order: listing.OrderAsc}, operationSortFields, operationID)
+	for _, op := range finished[:len(finished)-maxFinishedOperations] {
+		delete(o.ops, op.ID)
+	}
+}
+
+// List returns a page of operations matching the filter and its metadata.
+// Name globs match the destination.
+func (o *Operations) List(f listing.Filter, p listing.Params) ([]*Operation, listing.Meta) {
+	o.mu.RLock()
+	ops := make([]*Operation, 0, len(o.ops))
+	for _, op := range o.ops {
+		if !f.MatchStatus(string(op.Status)) || !f.MatchType(string(op.Type)) ||
+			!f.MatchName(op.Destination) || !f.MatchCreated(op.CreatedAt) {
+			continue
+		}
+		cp := *op
+		ops = append(ops, &cp)
+	}
+	o.mu.RUnlock()
+
+	return listing.Page(ops, p, operationSortFields, operationID)
+}
+
+// Get returns an operation by ID
+func (o *Operations) Get(id string) (*Operation, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	op, ok := o.ops[id]
+	if !ok {
+		return nil, ErrOperationNotFound
+	}
+	cp := *op
+	return &cp, nil
+}
+
+// Cancel stops a running operation. Files already copied are kept; for moves
+// the remaining sources stay where they were.
+func (o *Operations) Cancel(id string) error {
+	o.mu.RLock()
+	_, exists := o.ops[id]
+	cancel, running := o.cancels[id]
+	o.mu.RUnlock()
+
+	if !exists {
+		return ErrOperationNotFound
+	}
+	if !running {
+		return ErrOperationFinished
+	}
+	cancel()
+	return nil
+}
+
+// Delete removes a finished operation from the list
+func (o *Operations) Delete(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.ops[id]; !ok {
+		return ErrOperationNotFound
+	}
+	if _, running := o.cancels[id]; running {
+		return ErrOperationRunning
+	}
+	delete(o.ops, id)
+	return nil
+}
+
+// Shutdown cancels running operations and waits for them to stop
+func (o *Operations) Shutdown() {
+	o.mu.RLock()
+	for _, cancel := range o.cancels {
+		cancel()
+	}
+	o.mu.RUnlock()
+	o.wg.Wait()
+}
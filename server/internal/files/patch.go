@@ -0,0 +1,527 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+var (
+	ErrInvalidPatch = apperr.New(apperr.CodeInvalidPatch, "invalid patch")
+	ErrPatchFailed  = apperr.New(apperr.CodePatchFailed, "patch does not apply")
+)
+
+// DefaultPatchFuzz is how many context lines may be ignored at each end of a
+// hunk that doesn't apply as it is, as with patch(1)
+const DefaultPatchFuzz = 2
+
+const devNull = "/dev/null"
+
+// What a patch does to each file
+const (
+	PatchModify = "modify"
+	PatchCreate = "create"
+	PatchDelete = "delete"
+)
+
+// PatchOptions controls how ApplyPatch applies a patch
+type PatchOptions struct {
+	Strip  int  // Leading path components removed from names in the patch, or -1 to strip git's a/ and b/
+	Fuzz   int  // Context lines that may be ignored at each end of a hunk
+	DryRun bool // Only report whether each hunk would apply
+}
+
+// PatchResult reports how a patch applied, file by file
+type PatchResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Applied bool              `json:"applied"` // Every file was changed; false for dry runs and failures
+	Files   []PatchFileResult `json:"files"`
+}
+
+// PatchFileResult reports how a patch applied to one file
+type PatchFileResult struct {
+	Path  string            `json:"path"`
+	Op    string            `json:"op"` // modify, create or delete
+	OK    bool              `json:"ok"` // Every hunk applies
+	Error string            `json:"error,omitempty"`
+	Hunks []PatchHunkResult `json:"hunks"`
+}
+
+// PatchHunkResult reports how one hunk applied
+type PatchHunkResult struct {
+	Hunk    int  `json:"hunk"` // 1-based, in patch order
+	Applied bool `json:"applied"`
+	Line    int  `json:"line,omitempty"`   // Line of the original file the hunk matched at (1-based)
+	Offset  int  `json:"offset,omitempty"` // Lines away from where the hunk header placed it
+	Fuzz    int  `json:"fuzz,omitempty"`   // Context lines ignored at each end
+}
+
+type filePatch struct {
+	oldName, newName string
+	hunks            []*patchHunk
+}
+
+type patchHunk struct {
+	oldStart, oldCount int
+	newStart, newCount int
+	lines              []diffLine
+}
+
+type diffLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parsePatch parses a unified diff of one or more files. Lines outside file
+// sections, such as git's diff and index headers, are ignored.
+func parsePatch(patch string) ([]*filePatch, error) {
+	lines := splitLines(strings.ReplaceAll(patch, "\r\n", "\n"))
+	var patches []*filePatch
+
+	for i := 0; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "GIT binary patch") || strings.HasPrefix(lines[i], "Binary files ") {
+			return nil, ErrInvalidPatch.WithMessage("binary patches are not supported")
+		}
+		if !strings.HasPrefix(lines[i], "--- ") || i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+		fp := &filePatch{oldName: patchName(lines[i][4:]), newName: patchName(lines[i+1][4:])}
+		i += 2
+
+		for i < len(lines) && strings.HasPrefix(lines[i], "@@ ") {
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			fp.hunks = append(fp.hunks, h)
+			i = next
+		}
+		if len(fp.hunks) == 0 {
+			return nil, ErrInvalidPatch.WithMessage(fmt.Sprintf("no hunks for %s", fp.newName))
+		}
+		patches = append(patches, fp)
+		i--
+	}
+
+	if len(patches) == 0 {
+		return nil, ErrInvalidPatch.WithMessage("no file changes found in patch")
+	}
+	return patches, nil
+}
+
+// parseHunk parses the hunk whose header is lines[i] and returns the index
+// of the line after it
+func parseHunk(lines []string, i int) (*patchHunk, int, error) {
+	m := hunkHeader.FindStringSubmatch(lines[i])
+	if m == nil {
+		return nil, 0, ErrInvalidPatch.WithMessage(fmt.Sprintf("malformed hunk header %q", strings.TrimSpace(lines[i])))
+	}
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	header := strings.TrimSpace(lines[i])
+	h := &patchHunk{count(m[1]), count(m[2]), count(m[3]), count(m[4]), nil}
+
+	oldLeft, newLeft := h.oldCount, h.newCount
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, `\`) {
+			// "\ No newline at end of file" applies to the line before
+			if n := len(h.lines); n > 0 {
+				h.lines[n-1].text = strings.TrimSuffix(h.lines[n-1].text, "\n")
+			}
+			continue
+		}
+		if oldLeft == 0 && newLeft == 0 {
+			break
+		}
+
+		kind := line[0]
+		text := line[1:]
+		if line == "\n" {
+			// Context lines that were blank may have lost their space
+			kind, text = ' ', "\n"
+		}
+		switch kind {
+		case ' ':
+			oldLeft--
+			newLeft--
+		case '-':
+			oldLeft--
+		case '+':
+			newLeft--
+		default:
+			return nil, 0, ErrInvalidPatch.WithMessage(fmt.Sprintf("hunk %q ends early", header))
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return nil, 0, ErrInvalidPatch.WithMessage(fmt.Sprintf("hunk %q has more lines than its header says", header))
+		}
+		h.lines = append(h.lines, diffLine{kind, text})
+	}
+	if oldLeft != 0 || newLeft != 0 {
+		return nil, 0, ErrInvalidPatch.WithMessage(fmt.Sprintf("hunk %q has fewer lines than its header says", header))
+	}
+	return h, i, nil
+}
+
+// patchName extracts the file name from a ---/+++ line, dropping the
+// timestamp diff puts after a tab and unquoting git's quoted names
+func patchName(s string) string {
+	s = strings.TrimRight(s, "\n")
+	if tab := strings.IndexByte(s, '\t'); tab >= 0 {
+		s = s[:tab]
+	}
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			return unquoted
+		}
+	}
+	return strings.TrimRight(s, " ")
+}
+
+// stripName removes n leading components from a patch file name
+func stripName(name string, n int) (string, error) {
+	for ; n > 0; n-- {
+		slash := strings.IndexByte(name, '/')
+		if slash < 0 {
+			return "", ErrInvalidPatch.WithMessage(fmt.Sprintf("cannot strip %s", name))
+		}
+		name = strings.TrimLeft(name[slash+1:], "/")
+	}
+	return name, nil
+}
+
+// oldLines and newLines return the lines a hunk expects and produces
+func (h *patchHunk) oldLines() []string { return h.side('+') }
+func (h *patchHunk) newLines() []string { return h.side('-') }
+
+func (h *patchHunk) side(skip byte) []string {
+	lines := make([]string, 0, len(h.lines))
+	for _, l := range h.lines {
+		if l.kind != skip {
+			lines = append(lines, l.text)
+		}
+	}
+	return lines
+}
+
+// context returns the number of context lines at the start and end of a hunk
+func (h *patchHunk) context() (lead, trail int) {
+	for lead < len(h.lines) && h.lines[lead].kind == ' ' {
+		lead++
+	}
+	for trail < len(h.lines)-lead && h.lines[len(h.lines)-1-trail].kind == ' ' {
+		trail++
+	}
+	return lead, trail
+}
+
+// applyHunks applies hunks in order to lines. A hunk is looked for where its
+// header says, shifted by the offset the previous hunk applied at, then
+// ever further away, and never before the end of the previous hunk. If it
+// isn't found, up to fuzz context lines are ignored at each end.
+func applyHunks(lines []string, hunks []*patchHunk, fuzz int) ([]string, []PatchHunkResult, bool) {
+	out := make([]string, 0, len(lines))
+	results := make([]PatchHunkResult, len(hunks))
+	pos, offset, ok := 0, 0, true
+
+	for n, h := range hunks {
+		results[n].Hunk = n + 1
+		old, replacement := h.oldLines(), h.newLines()
+		lead, trail := h.context()
+
+		// Where the header puts the first old line
+		want := h.oldStart - 1
+		if h.oldCount == 0 {
+			want = h.oldStart
+		}
+
+		for f := 0; f <= fuzz; f++ {
+			dropLead, dropTrail := min(f, lead), min(f, trail)
+			if f > 0 && dropLead < f && dropTrail < f {
+				break // No more context to ignore
+			}
+			pattern := old[dropLead : len(old)-dropTrail]
+			at := findLines(lines, pattern, want+dropLead+offset, pos)
+			if at < 0 {
+				continue
+			}
+
+			out = append(out, lines[pos:at]...)
+			out = append(out, replacement[dropLead:len(replacement)-dropTrail]...)
+			pos = at + len(pattern)
+			offset = at - want - dropLead
+			results[n] = PatchHunkResult{Hunk: n + 1, Applied: true, Line: at + 1, Offset: offset, Fuzz: f}
+			break
+		}
+		if !results[n].Applied {
+			ok = false
+		}
+	}
+	return append(out, lines[pos:]...), results, ok
+}
+
+// findLines returns the index nearest to want, and not before from, at
+// which pattern occurs in lines, or -1
+func findLines(lines, pattern []string, want, from int) int {
+	last := len(lines) - len(pattern)
+	matches := func(at int) bool {
+		for i, p := range pattern {
+			if lines[at+i] != p {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; ; d++ {
+		before, after := want-d, want+d
+		if before < from && after > last {
+			return -1
+		}
+		if after >= from && after <= last && matches(after) {
+			return after
+		}
+		if d > 0 && before >= from && before <= last && matches(before) {
+			return before
+		}
+	}
+}
+
+// patchTarget is a file a patch changes
+type patchTarget struct {
+	absPath  string
+	result   *PatchFileResult
+	info     os.FileInfo // Before patching; nil for new files
+	original []byte
+	lines    []string
+}
+
+// ApplyPatch applies a unified diff of one or more files, whose names are
+// relative to the base directory. Every file is patched in memory first;
+// only if every hunk applies are the files written, each atomically and
+// snapshotted like Write, and if writing one fails those already written are
+// put back. A failed patch returns ErrPatchFailed with the per-hunk result
+// as details; a dry run reports the same without writing.
+func (s *Service) ApplyPatch(base, patch string, opts PatchOptions) (*PatchResult, error) {
+	baseAbs, err := s.validatePath(base)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(baseAbs); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, ErrNotDirectory
+	}
+	if opts.Fuzz < 0 {
+		return nil, ErrInvalidPatch.WithMessage("fuzz must be >= 0")
+	}
+
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold off other writes between reading the files and replacing them
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	result := &PatchResult{DryRun: opts.DryRun, Files: make([]PatchFileResult, len(patches))}
+	targets := map[string]*patchTarget{}
+	var order []*patchTarget
+	ok := true
+
+	for i, fp := range patches {
+		fr := &result.Files[i]
+		t, op, err := s.patchTarget(baseAbs, fp, opts.Strip, targets)
+		if err != nil {
+			return nil, err
+		}
+		fr.Path, fr.Op, fr.Hunks = ToTildePath(t.absPath), op, []PatchHunkResult{}
+		if targets[t.absPath] == nil {
+			targets[t.absPath] = t
+			order = append(order, t)
+		}
+		t.result = fr
+
+		switch {
+		case op == PatchCreate && t.lines != nil:
+			fr.Error = "file already exists"
+		case op != PatchCreate && t.lines == nil:
+			fr.Error = "file does not exist"
+		}
+		if fr.Error != "" {
+			ok = false
+			continue
+		}
+
+		patched, hunks, applied := applyHunks(t.lines, fp.hunks, opts.Fuzz)
+		fr.Hunks, fr.OK = hunks, applied
+		if op == PatchDelete && applied && len(patched) > 0 {
+			fr.OK, fr.Error = false, "file is not empty after patching"
+		}
+		if !fr.OK {
+			ok = false
+			continue
+		}
+		t.lines = patched
+		if op == PatchDelete {
+			t.lines = nil
+		}
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+	if !ok {
+		return nil, ErrPatchFailed.WithDetails(result)
+	}
+
+	var written []*patchTarget
+	for _, t := range order {
+		if err := s.commitPatch(t); err != nil {
+			for i := len(written) - 1; i >= 0; i-- {
+				written[i].rollback()
+			}
+			return nil, err
+		}
+		written = append(written, t)
+	}
+	for _, t := range order {
+		if t.lines == nil {
+			s.changed(OpDelete, t.absPath, "")
+		} else {
+			s.changed(OpPatch, t.absPath, "")
+		}
+	}
+	result.Applied = true
+	return result, nil
+}
+
+// patchTarget resolves the file a file patch changes, reading its content
+// unless an earlier patch of the same file already did
+func (s *Service) patchTarget(baseAbs string, fp *filePatch, strip int, targets map[string]*patchTarget) (*patchTarget, string, error) {
+	op, name := PatchModify, fp.newName
+	switch {
+	case fp.oldName == devNull && fp.newName == devNull:
+		return nil, "", ErrInvalidPatch.WithMessage("patch names neither an old nor a new file")
+	case fp.oldName == devNull:
+		op = PatchCreate
+	case fp.newName == devNull:
+		op, name = PatchDelete, fp.oldName
+	}
+
+	n := strip
+	if n < 0 {
+		n = 0
+		if (fp.oldName == devNull || strings.HasPrefix(fp.oldName, "a/")) && (fp.newName == devNull || strings.HasPrefix(fp.newName, "b/")) {
+			n = 1
+		}
+	}
+	name, err := stripName(name, n)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimLeft(name, "/")))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, "", ErrPathNotAllowed.WithMessage("patch names a file outside the base directory").WithDetails(map[string]string{"path": name})
+	}
+	absPath, err := s.validateNewPath(filepath.Join(baseAbs, rel))
+	if err != nil {
+		return nil, "", err
+	}
+	if !strings.HasPrefix(absPath, baseAbs+string(filepath.Separator)) {
+		return nil, "", ErrPathNotAllowed.WithMessage("patch names a file outside the base directory").WithDetails(map[string]string{"path": name})
+	}
+
+	if t := targets[absPath]; t != nil {
+		return t, op, nil
+	}
+	t := &patchTarget{absPath: absPath}
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		return t, op, nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	if info.IsDir() {
+		return nil, "", ErrIsDirectory.WithDetails(map[string]string{"path": ToTildePath(absPath)})
+	}
+	if info.Size() > MaxFileSize {
+		return nil, "", ErrFileTooLarge.WithDetails(map[string]string{"path": ToTildePath(absPath)})
+	}
+	if t.original, err = os.ReadFile(absPath); err != nil {
+		return nil, "", err
+	}
+	if isBinary(t.original) {
+		return nil, "", ErrBinaryFile.WithDetails(map[string]string{"path": ToTildePath(absPath)})
+	}
+	t.info = info
+	t.lines = splitLines(string(t.original))
+	if t.lines == nil {
+		t.lines = []string{}
+	}
+	return t, op, nil
+}
+
+// validateNewPath is validatePath for a file whose parent directories may
+// not exist yet: the nearest existing ancestor is resolved instead
+func (s *Service) validateNewPath(absPath string) (string, error) {
+	dir, rest := filepath.Dir(absPath), filepath.Base(absPath)
+	for {
+		if _, err := os.Lstat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = filepath.Dir(dir)
+	}
+	if dir == filepath.Dir(absPath) {
+		return s.validatePath(absPath)
+	}
+
+	resolved, err := s.validatePath(dir)
+	if err != nil {
+		return "", err
+	}
+	absPath = filepath.Join(resolved, rest)
+	if !s.config.IsPathAllowed(absPath) {
+		return "", ErrPathNotAllowed
+	}
+	return absPath, nil
+}
+
+// commitPatch writes or deletes a patched file
+func (s *Service) commitPatch(t *patchTarget) error {
+	if t.info != nil {
+		if err := s.snapshot(t.absPath, OpPatch); err != nil {
+			return err
+		}
+	}
+	if t.lines == nil {
+		return os.Remove(t.absPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.absPath), 0755); err != nil {
+		return err
+	}
+	return writeAtomic(t.absPath, []byte(strings.Join(t.lines, "")), t.info)
+}
+
+// rollback puts back the content a committed file had
+func (t *patchTarget) rollback() {
+	if t.info == nil {
+		os.Remove(t.absPath)
+		return
+	}
+	writeAtomic(t.absPath, t.original, t.info)
+}
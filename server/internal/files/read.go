@@ -0,0 +1,497 @@
+package files
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+var ErrInvalidRange = apperr.New(apperr.CodeInvalidParameter, "invalid range")
+
+// Range read limits
+const (
+	DefaultRangeLength = 64 * 1024   // Bytes of a byte range read
+	MaxRangeLength     = 1024 * 1024 // Bytes any range read returns
+	DefaultRangeLines  = 100
+	MaxRangeLines      = 10000
+
+	encodingSampleSize = 8000      // Bytes at the start of a file encodings are detected from
+	lineScanBlock      = 64 * 1024 // Bytes read at a time looking for line starts
+)
+
+// Range read formats
+const (
+	RangeFormatAuto = "auto" // Text, or hex for binary files
+	RangeFormatText = "text"
+	RangeFormatHex  = "hex"
+)
+
+// Detected encodings
+const (
+	EncodingASCII   = "ascii"
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "iso-8859-1" // Text that isn't valid UTF-8
+	EncodingBinary  = "binary"
+)
+
+// RangeRequest selects part of a file by bytes or by lines. Byte ranges are
+// the default; Line or Tail select lines instead.
+type RangeRequest struct {
+	Path   string
+	Offset int64  // First byte; negative counts from the end
+	Length int64  // Bytes (default: 64KB); with Line or Tail, a byte limit (default: 1MB)
+	Line   int    // First line, from 1
+	Lines  int    // Lines from Line (default: 100)
+	Tail   int    // Last lines
+	Format string // auto (default), text or hex
+}
+
+// RangeResult is part of a file
+type RangeResult struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"` // Of the whole file
+	ModTime   time.Time `json:"mod_time"`
+	Encoding  string    `json:"encoding"` // Detected from the start of the file
+	BOM       bool      `json:"bom,omitempty"`
+	Offset    int64     `json:"offset"` // Of the first byte read
+	Length    int64     `json:"length"` // Bytes read; the next page starts at offset+length
+	EOF       bool      `json:"eof"`
+	Format    string    `json:"format"` // text or hex
+	Content   string    `json:"content"`
+	StartLine int       `json:"start_line,omitempty"` // Line reads from Line: the first line's number
+	LineCount int       `json:"line_count,omitempty"` // Line reads: lines returned
+	Truncated bool      `json:"truncated,omitempty"`  // Line reads: fewer lines than asked for fit the byte limit
+}
+
+// ReadRange reads part of a file of any size or content. Byte ranges of text
+// are moved to character boundaries, so consecutive pages decode cleanly;
+// binary content is returned as a hex dump unless text is asked for. Line
+// reads scan for line starts, from the start of the file with Line and
+// backwards from the end with Tail.
+func (s *Service) ReadRange(ctx context.Context, req RangeRequest) (*RangeResult, error) {
+	switch {
+	case req.Line < 0 || req.Lines < 0 || req.Tail < 0 || req.Length < 0:
+		return nil, ErrInvalidRange.WithMessage("line, lines, tail and length must not be negative")
+	case req.Line > 0 && req.Tail > 0, (req.Line > 0 || req.Tail > 0) && req.Offset != 0:
+		return nil, ErrInvalidRange.WithMessage("use only one of offset, line and tail")
+	case req.Lines > 0 && req.Line == 0:
+		return nil, ErrInvalidRange.WithMessage("lines needs line")
+	case req.Lines > MaxRangeLines || req.Tail > MaxRangeLines:
+		return nil, ErrInvalidRange.WithMessage(fmt.Sprintf("at most %d lines can be read at once", MaxRangeLines))
+	case req.Length > MaxRangeLength:
+		return nil, ErrInvalidRange.WithMessage(fmt.Sprintf("at most %d bytes can be read at once", MaxRangeLength))
+	}
+	switch req.Format {
+	case "":
+		req.Format = RangeFormatAuto
+	case RangeFormatAuto, RangeFormatText, RangeFormatHex:
+	default:
+		return nil, ErrInvalidRange.WithMessage("format must be auto, text or hex")
+	}
+
+	absPath, err := s.validatePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrIsDirectory
+	}
+
+	sample := make([]byte, min(info.Size(), encodingSampleSize))
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	encoding, bom := detectEncoding(sample[:n], info.Size() > int64(n))
+
+	result := &RangeResult{
+		Path:     ToTildePath(absPath),
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Encoding: encoding,
+		BOM:      bom > 0,
+		Format:   req.Format,
+	}
+	if result.Format == RangeFormatAuto {
+		result.Format = RangeFormatText
+		if encoding == EncodingBinary {
+			result.Format = RangeFormatHex
+		}
+	}
+	text := result.Format == RangeFormatText
+	if (req.Line > 0 || req.Tail > 0) && (encoding == EncodingUTF16LE || encoding == EncodingUTF16BE) {
+		return nil, ErrInvalidRange.WithMessage("line reads of UTF-16 files are not supported")
+	}
+
+	var data []byte
+	switch {
+	case req.Line > 0:
+		data, err = readLines(ctx, f, result, req.Line, cmp.Or(req.Lines, DefaultRangeLines), cmp.Or(req.Length, MaxRangeLength))
+	case req.Tail > 0:
+		data, err = readTail(ctx, f, result, req.Tail, cmp.Or(req.Length, MaxRangeLength))
+	default:
+		data, err = readBytes(f, result, req.Offset, cmp.Or(req.Length, DefaultRangeLength), text, int64(bom))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.EOF = result.Offset+result.Length >= result.Size
+	if !text {
+		result.Content = hexDump(data, result.Offset)
+		return result, nil
+	}
+	if result.Offset == 0 && bom > 0 && len(data) >= bom {
+		data = data[bom:] // Content starts after the BOM; offset and length still cover it
+	}
+	result.Content = decodeText(data, encoding)
+	return result, nil
+}
+
+// readBytes reads length bytes at offset. For text, the range is moved off
+// partial characters: UTF-8 continuation bytes at the start are skipped and a
+// character the range ends inside is read to its end, while UTF-16 ranges
+// keep to whole code units and surrogate pairs.
+func readBytes(f *os.File, result *RangeResult, offset, length int64, text bool, bom int64) ([]byte, error) {
+	if offset < 0 {
+		offset = max(result.Size+offset, 0)
+	}
+	if offset > result.Size {
+		return nil, ErrInvalidRange.WithMessage("offset is past the end of the file")
+	}
+	utf16Text := text && (result.Encoding == EncodingUTF16LE || result.Encoding == EncodingUTF16BE)
+	if utf16Text && (offset-bom)%2 != 0 {
+		offset++ // Code units start at even offsets after the BOM
+	}
+
+	// A few extra bytes let a character that starts inside the range finish
+	data := make([]byte, min(length+3, max(result.Size-offset, 0)))
+	n, err := f.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data = data[:n]
+	end := min(int64(len(data)), length)
+
+	if text {
+		switch result.Encoding {
+		case EncodingUTF8:
+			skip := 0
+			for skip < 3 && skip < len(data) && !utf8.RuneStart(data[skip]) {
+				skip++
+			}
+			offset += int64(skip)
+			data = data[skip:]
+			end = max(end-int64(skip), 0)
+			// Extend to the end of a character the range ends inside of
+			for end < int64(len(data)) && !utf8.RuneStart(data[end]) {
+				end++
+			}
+		case EncodingUTF16LE, EncodingUTF16BE:
+			end -= end % 2
+			if end >= 2 {
+				var order binary.ByteOrder = binary.LittleEndian
+				if result.Encoding == EncodingUTF16BE {
+					order = binary.BigEndian
+				}
+				// Keep surrogate pairs together
+				if unit := order.Uint16(data[end-2:]); utf16.IsSurrogate(rune(unit)) && unit < 0xDC00 {
+					end -= 2
+				}
+			}
+		}
+	}
+	result.Offset, result.Length = offset, end
+	return data[:end], nil
+}
+
+// readLines reads count lines from line first (1-based), up to limit bytes
+func readLines(ctx context.Context, f *os.File, result *RangeResult, first, count int, limit int64) ([]byte, error) {
+	// Find where line first starts
+	line, start := 1, int64(0)
+	buf := make([]byte, lineScanBlock)
+	for off := int64(0); line < first && off < result.Size; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := f.ReadAt(buf, off)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n == 0 {
+			break
+		}
+		for i := 0; i < n && line < first; {
+			j := bytes.IndexByte(buf[i:n], '\n')
+			if j < 0 {
+				break
+			}
+			i += j + 1
+			line++
+			start = off + int64(i)
+		}
+		off += int64(n)
+	}
+	result.StartLine = first
+	if line < first || start >= result.Size {
+		// The file has fewer lines
+		result.Offset = result.Size
+		return nil, nil
+	}
+
+	data, err := readLinesFrom(f, start, count, limit, result)
+	if err != nil {
+		return nil, err
+	}
+	result.Offset = start
+	return data, nil
+}
+
+// readLinesFrom reads count lines at start, up to limit bytes; lines that
+// don't fit are left out, unless the first alone is over the limit
+func readLinesFrom(f *os.File, start int64, count int, limit int64, result *RangeResult) ([]byte, error) {
+	data := make([]byte, min(limit, result.Size-start))
+	n, err := f.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data = data[:n]
+
+	end, lines := 0, 0
+	for lines < count && end < len(data) {
+		j := bytes.IndexByte(data[end:], '\n')
+		if j < 0 {
+			// The last line ends at the end of the file, or goes on past the
+			// limit; then only a first line is returned, cut off
+			if start+int64(len(data)) == result.Size || lines == 0 {
+				end, lines = len(data), lines+1
+			}
+			break
+		}
+		end += j + 1
+		lines++
+	}
+	result.Truncated = lines < count && start+int64(end) < result.Size
+	result.Length, result.LineCount = int64(end), lines
+	return data[:end], nil
+}
+
+// readTail reads the last count lines, up to limit bytes. A newline ending
+// the file doesn't start another line.
+func readTail(ctx context.Context, f *os.File, result *RangeResult, count int, limit int64) ([]byte, error) {
+	end := result.Size
+	start := int64(0)
+	found := 0
+	buf := make([]byte, lineScanBlock)
+	skipLast := true // The file's last byte, if a newline, ends the last line
+scan:
+	for off := end; off > 0; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		blockStart := max(off-lineScanBlock, 0)
+		n, err := f.ReadAt(buf[:off-blockStart], blockStart)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		block := buf[:n]
+		for i := len(block) - 1; i >= 0; i-- {
+			if block[i] != '\n' {
+				continue
+			}
+			if skipLast && blockStart+int64(i) == end-1 {
+				continue
+			}
+			if found++; found == count {
+				start = blockStart + int64(i) + 1
+				break scan
+			}
+		}
+		skipLast = false
+		off = blockStart
+		if end-off > limit {
+			break
+		}
+	}
+
+	if end-start > limit {
+		// Keep the last lines that fit, starting at a line start
+		start = end - limit
+		data := make([]byte, limit)
+		n, err := f.ReadAt(data, start)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = data[:n]
+		if i := bytes.IndexByte(data, '\n'); i >= 0 && i+1 < len(data) {
+			data, start = data[i+1:], start+int64(i)+1
+		}
+		result.Offset, result.Length, result.Truncated = start, int64(len(data)), true
+		result.LineCount = countLines(data)
+		return data, nil
+	}
+
+	data := make([]byte, end-start)
+	n, err := f.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data = data[:n]
+	result.Offset, result.Length = start, int64(len(data))
+	result.LineCount = countLines(data)
+	return data, nil
+}
+
+// countLines counts lines, including a last one without a newline
+func countLines(data []byte) int {
+	n := bytes.Count(data, []byte{'\n'})
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// detectEncoding guesses the encoding of a file from its first bytes, and
+// returns the length of its byte order mark. truncated says the sample ends
+// before the file does, so it may end inside a character.
+func detectEncoding(sample []byte, truncated bool) (string, int) {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8, 3
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE, 2
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE, 2
+	}
+
+	// UTF-16 text without a BOM is mostly ASCII with a NUL in every other byte
+	if len(sample) >= 4 {
+		var even, odd int
+		for i, b := range sample[:len(sample)-len(sample)%2] {
+			if b == 0 {
+				if i%2 == 0 {
+					even++
+				} else {
+					odd++
+				}
+			}
+		}
+		pairs := len(sample) / 2
+		switch {
+		case odd*10 >= pairs*9 && even == 0:
+			return EncodingUTF16LE, 0
+		case even*10 >= pairs*9 && odd == 0:
+			return EncodingUTF16BE, 0
+		}
+	}
+
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return EncodingBinary, 0
+	}
+	valid := sample
+	if truncated {
+		// Drop a character cut off by the end of the sample
+		for i := 0; i < 3 && len(valid) > 0 && !utf8.RuneStart(valid[len(valid)-1]); i++ {
+			valid = valid[:len(valid)-1]
+		}
+		if len(valid) > 0 && valid[len(valid)-1] >= 0xC0 {
+			valid = valid[:len(valid)-1]
+		}
+	}
+	if utf8.Valid(valid) {
+		for _, b := range valid {
+			if b >= 0x80 {
+				return EncodingUTF8, 0
+			}
+		}
+		return EncodingASCII, 0
+	}
+
+	// Text in a legacy 8-bit encoding has few control characters
+	control := 0
+	for _, b := range sample {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != 0x1B {
+			control++
+		}
+	}
+	if control*10 > len(sample) {
+		return EncodingBinary, 0
+	}
+	return EncodingLatin1, 0
+}
+
+// decodeText converts content in a detected encoding to UTF-8. Invalid
+// sequences become U+FFFD.
+func decodeText(data []byte, encoding string) string {
+	switch encoding {
+	case EncodingLatin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	case EncodingUTF16LE, EncodingUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		if encoding == EncodingUTF16BE {
+			order = binary.BigEndian
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[i*2:])
+		}
+		return string(utf16.Decode(units))
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
+
+// hexDump formats data like hexdump -C, with offsets starting at offset
+func hexDump(data []byte, offset int64) string {
+	var b strings.Builder
+	const hexDigits = "0123456789abcdef"
+	for row := 0; row < len(data); row += 16 {
+		line := data[row:min(row+16, len(data))]
+		fmt.Fprintf(&b, "%08x  ", offset+int64(row))
+		for i := 0; i < 16; i++ {
+			if i < len(line) {
+				b.WriteByte(hexDigits[line[i]>>4])
+				b.WriteByte(hexDigits[line[i]&0xF])
+				b.WriteByte(' ')
+			} else {
+				b.WriteString("   ")
+			}
+			if i == 7 {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(" |")
+		for _, c := range line {
+			if c < 0x20 || c > 0x7E {
+				c = '.'
+			}
+			b.WriteByte(c)
+		}
+		b.WriteString("|\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,364 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/listing"
+)
+
+var ErrInvalidSearch = apperr.New(apperr.CodeInvalidParameter, "invalid search")
+
+// Search modes
+const (
+	SearchSubstring = "substring"
+	SearchGlob      = "glob"
+	SearchRegex     = "regex"
+)
+
+// Search defaults
+const (
+	DefaultSearchLimit      = 100
+	DefaultSearchMaxMatches = 3
+	maxSearchLineLength     = 200 // Longer matching lines are cut
+)
+
+// errSearchLimit stops a walk once enough results were found
+var errSearchLimit = errors.New("search limit reached")
+
+// SearchRequest describes a search of a directory tree
+type SearchRequest struct {
+	Path          string
+	Query         string
+	Mode          string // substring (default), glob or regex
+	CaseSensitive bool
+	Content       bool           // Also match lines of text files; not in glob mode
+	Extensions    []string       // Only files with one of these extensions
+	Filter        listing.Filter // Type, name glob, size and modification time
+	Include       []string       // Only files whose path below Path matches one of these globs
+	Exclude       []string       // Skip files and directories matching any of these globs
+	Hidden        bool           // Descend into hidden directories
+	NoIgnore      bool           // Don't skip what .gitignore and .ignore files list, nor node_modules, vendor and the like
+	Limit         int            // Maximum results (default: 100)
+	MaxMatches    int            // Content matches per file (default: 3)
+	MaxDepth      int            // Maximum directory depth (default: 20)
+	Timeout       time.Duration  // Maximum search duration (default: 30s)
+}
+
+// Indexable reports whether the search index can answer the request: it uses
+// no option only a walk of the tree supports
+func (r SearchRequest) Indexable() bool {
+	return (r.Mode == "" || r.Mode == SearchSubstring) && !r.CaseSensitive &&
+		len(r.Extensions) == 0 && len(r.Include) == 0 && len(r.Exclude) == 0 &&
+		!r.Hidden && !r.NoIgnore && r.Filter.Name == "" && !r.Filter.HasStatFilter()
+}
+
+// SearchSummary describes a finished search
+type SearchSummary struct {
+	Count      int   `json:"count"`
+	Scanned    int   `json:"scanned"`             // Entries visited
+	Truncated  bool  `json:"truncated,omitempty"` // Stopped at the limit
+	TimedOut   bool  `json:"timed_out,omitempty"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// treeSearch is a validated SearchRequest
+type treeSearch struct {
+	req     SearchRequest
+	root    string
+	re      *regexp.Regexp // Names and lines, in substring and regex modes
+	glob    pathPattern    // Paths, in glob mode
+	include []pathPattern
+	exclude []pathPattern
+	exts    []string
+	ignores *ignoreSet
+}
+
+func (s *Service) newTreeSearch(req SearchRequest) (*treeSearch, error) {
+	absPath, err := s.validatePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	if req.Query == "" {
+		return nil, ErrInvalidSearch.WithMessage("query is required")
+	}
+	if req.Limit <= 0 {
+		req.Limit = DefaultSearchLimit
+	}
+	if req.MaxMatches <= 0 {
+		req.MaxMatches = DefaultSearchMaxMatches
+	}
+	if req.MaxDepth <= 0 {
+		req.MaxDepth = DefaultSearchOptions().MaxDepth
+	}
+	if req.Timeout <= 0 {
+		req.Timeout = DefaultSearchOptions().Timeout
+	}
+
+	t := &treeSearch{req: req, root: absPath}
+	flags := "(?i)"
+	if req.CaseSensitive {
+		flags = ""
+	}
+	switch req.Mode {
+	case "", SearchSubstring:
+		t.re = regexp.MustCompile(flags + regexp.QuoteMeta(req.Query))
+	case SearchRegex:
+		if t.re, err = regexp.Compile(flags + req.Query); err != nil {
+			return nil, ErrInvalidSearch.WithMessage("invalid regular expression: " + strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+		}
+	case SearchGlob:
+		if req.Content {
+			return nil, ErrInvalidSearch.WithMessage("glob searches match names only")
+		}
+		if t.glob, err = compilePathPattern(req.Query, req.CaseSensitive); err != nil {
+			return nil, ErrInvalidSearch.WithMessage("invalid glob: " + err.Error())
+		}
+	default:
+		return nil, ErrInvalidSearch.WithMessage("mode must be substring, glob or regex")
+	}
+
+	for _, patterns := range []struct {
+		globs []string
+		dst   *[]pathPattern
+	}{{req.Include, &t.include}, {req.Exclude, &t.exclude}} {
+		for _, glob := range patterns.globs {
+			p, err := compilePathPattern(glob, true)
+			if err != nil {
+				return nil, ErrInvalidSearch.WithMessage("invalid pattern " + glob + ": " + err.Error())
+			}
+			*patterns.dst = append(*patterns.dst, p)
+		}
+	}
+	for _, ext := range req.Extensions {
+		if ext = strings.TrimPrefix(strings.TrimSpace(ext), "."); ext != "" {
+			t.exts = append(t.exts, strings.ToLower(ext))
+		}
+	}
+	if !req.NoIgnore {
+		t.ignores = newIgnoreSet(absPath)
+	}
+	return t, nil
+}
+
+// SearchStream searches a directory tree, passing each result to emit as it
+// is found. Names are matched in substring and regex modes, and paths below
+// req.Path in glob mode; with req.Content, lines of text files are matched
+// too. Hidden directories, directories like node_modules and what
+// .gitignore and .ignore files list are skipped unless the request says
+// otherwise. Reaching the limit or the timeout ends the search early
+// without an error; an error from emit ends it with that error.
+func (s *Service) SearchStream(ctx context.Context, req SearchRequest, emit func(SearchResult) error) (SearchSummary, error) {
+	t, err := s.newTreeSearch(req)
+	if err != nil {
+		return SearchSummary{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.req.Timeout)
+	defer cancel()
+	started := time.Now()
+	var summary SearchSummary
+
+	send := func(result SearchResult) error {
+		if summary.Count >= t.req.Limit {
+			summary.Truncated = true
+			return errSearchLimit
+		}
+		summary.Count++
+		return emit(result)
+	}
+
+	baseDepth := strings.Count(t.root, string(os.PathSeparator))
+	err = filepath.WalkDir(t.root, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if path == t.root {
+				return err
+			}
+			return nil // Skip errors (permission denied, etc.)
+		}
+		if path == t.root {
+			return nil
+		}
+		summary.Scanned++
+
+		depth := strings.Count(path, string(os.PathSeparator)) - baseDepth
+		if depth > t.req.MaxDepth {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel := filepath.ToSlash(strings.TrimPrefix(path, strings.TrimSuffix(t.root, "/")+"/"))
+		if t.skip(path, rel, d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() && t.ignores != nil {
+			t.ignores.load(path)
+		}
+		return t.visit(path, rel, d, send)
+	})
+	summary.DurationMs = time.Since(started).Milliseconds()
+
+	switch {
+	case errors.Is(err, errSearchLimit):
+		return summary, nil
+	case errors.Is(err, context.DeadlineExceeded):
+		// Results found before the timeout are what the search returns
+		summary.TimedOut = true
+		return summary, nil
+	}
+	return summary, err
+}
+
+// skip reports whether an entry is left out along with, for directories,
+// everything below it
+func (t *treeSearch) skip(path, rel string, d fs.DirEntry) bool {
+	isDir := d.IsDir()
+	if isDir && !t.req.Hidden && strings.HasPrefix(d.Name(), ".") {
+		return true
+	}
+	if isDir && !t.req.NoIgnore && slices.Contains(searchSkipDirs, d.Name()) {
+		return true
+	}
+	for _, p := range t.exclude {
+		if p.match(rel, isDir) {
+			return true
+		}
+	}
+	return t.ignores != nil && t.ignores.ignored(path, isDir)
+}
+
+// visit matches an entry against the request, sending what matches
+func (t *treeSearch) visit(path, rel string, d fs.DirEntry, send func(SearchResult) error) error {
+	isDir := d.IsDir()
+	entryType := "file"
+	if isDir {
+		entryType = "directory"
+	}
+	if !t.req.Filter.MatchType(entryType) || !t.req.Filter.MatchName(d.Name()) {
+		return nil
+	}
+	if (len(t.exts) > 0 || len(t.include) > 0) && isDir {
+		return nil
+	}
+	if len(t.exts) > 0 && !slices.Contains(t.exts, strings.ToLower(strings.TrimPrefix(filepath.Ext(d.Name()), "."))) {
+		return nil
+	}
+	if len(t.include) > 0 && !slices.ContainsFunc(t.include, func(p pathPattern) bool { return p.match(rel, false) }) {
+		return nil
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		return nil
+	}
+	if !t.req.Filter.MatchModified(info.ModTime()) || (!isDir && !t.req.Filter.MatchSize(info.Size())) {
+		return nil
+	}
+
+	result := SearchResult{
+		Path: ToTildePath(path),
+		Name: d.Name(),
+		Type: entryType,
+		Size: info.Size(),
+	}
+	if t.re == nil {
+		if t.glob.match(rel, isDir) {
+			return send(result)
+		}
+		return nil
+	}
+	if spans := matchSpans(t.re, d.Name()); spans != nil {
+		result.NameHighlights = spans
+		return send(result)
+	}
+
+	// Search content (only text files below the size limit)
+	if !t.req.Content || !info.Mode().IsRegular() || info.Size() > MaxFileSize {
+		return nil
+	}
+	return t.searchContent(path, result, send)
+}
+
+// searchContent sends the first lines of a text file that match
+func (t *treeSearch) searchContent(path string, result SearchResult, send func(SearchResult) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	head, _ := reader.Peek(8000)
+	if isBinary(head) {
+		return nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxFileSize)
+	lineNum, matches := 0, 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if !utf8.ValidString(line) {
+			line = strings.ToValidUTF8(line, "�")
+		}
+		spans := matchSpans(t.re, line)
+		if spans == nil {
+			continue
+		}
+
+		// Truncate long lines
+		if utf8.RuneCountInString(line) > maxSearchLineLength {
+			line = string([]rune(line)[:maxSearchLineLength]) + "..."
+			spans = slices.DeleteFunc(spans, func(h SearchHighlight) bool { return h.End > maxSearchLineLength })
+		}
+		match := result
+		match.Match = line
+		match.LineNum = lineNum
+		match.Highlights = spans
+		if err := send(match); err != nil {
+			return err
+		}
+		if matches++; matches >= t.req.MaxMatches {
+			break
+		}
+	}
+	return nil
+}
+
+// matchSpans returns where re matches s, in characters, or nil if it doesn't
+func matchSpans(re *regexp.Regexp, s string) []SearchHighlight {
+	locs := re.FindAllStringIndex(s, -1)
+	if locs == nil {
+		return nil
+	}
+	spans := make([]SearchHighlight, 0, len(locs))
+	for _, loc := range locs {
+		if loc[0] == loc[1] {
+			continue // Empty matches, like those of a*, highlight nothing
+		}
+		spans = append(spans, SearchHighlight{
+			Start: utf8.RuneCountInString(s[:loc[0]]),
+			End:   utf8.RuneCountInString(s[:loc[1]]),
+		})
+	}
+	return spans
+}
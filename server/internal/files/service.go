@@ -1,24 +1,36 @@
 package files
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ss497254/gloski/internal/apperr"
 	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/internal/listing"
 )
 
 var (
-	ErrPathNotAllowed = errors.New("path not allowed")
-	ErrFileTooLarge   = errors.New("file too large")
-	ErrBinaryFile     = errors.New("binary file not supported")
-	ErrDangerousPath  = errors.New("dangerous path operation")
+	ErrPathNotAllowed   = apperr.New(apperr.CodePathNotAllowed, "path not allowed")
+	ErrFileTooLarge     = apperr.New(apperr.CodeFileTooLarge, "file too large")
+	ErrBinaryFile       = apperr.New(apperr.CodeBinaryFile, "binary file not supported")
+	ErrDangerousPath    = apperr.New(apperr.CodeDangerousPath, "dangerous path operation")
+	ErrIsDirectory      = apperr.New(apperr.CodeIsDirectory, "cannot read directory")
+	ErrNotDirectory     = apperr.New(apperr.CodeNotDirectory, "destination must be a directory")
+	ErrInvalidFilename  = apperr.New(apperr.CodeInvalidFilename, "invalid filename")
+	ErrPathNotFound     = apperr.New(apperr.CodePathNotFound, "source path does not exist")
+	ErrPathExists       = apperr.New(apperr.CodePathExists, "destination path already exists")
+	ErrUploadIncomplete = apperr.New(apperr.CodeUploadIncomplete, "upload is missing chunks")
+	ErrInvalidMode      = apperr.New(apperr.CodeInvalidParameter, "mode must be an octal permission between 000 and 777")
 )
 
 const (
@@ -26,7 +38,11 @@ const (
 )
 
 type Service struct {
-	config *config.Config
+	config  *config.Config
+	events  *events.Bus
+	trash   *Trash
+	history *History
+	writeMu sync.Mutex
 }
 
 func NewService(cfg *config.Config) *Service {
@@ -35,6 +51,67 @@ func NewService(cfg *config.Config) *Service {
 	}
 }
 
+// SetEventBus sets the bus file change events are published on
+func (s *Service) SetEventBus(bus *events.Bus) {
+	s.events = bus
+}
+
+// SetTrash makes Delete move items to the trash instead of removing them
+func (s *Service) SetTrash(t *Trash) {
+	s.trash = t
+}
+
+// SetHistory makes Write and uploads snapshot files before overwriting them
+func (s *Service) SetHistory(h *History) {
+	s.history = h
+}
+
+// snapshot saves the current content of absPath to the version history, if
+// there is one, before op overwrites it
+func (s *Service) snapshot(absPath, op string) error {
+	if s.history == nil {
+		return nil
+	}
+	return s.history.snapshot(absPath, op)
+}
+
+// File change operations reported in ChangeEvent.Op
+const (
+	OpWrite   = "write"
+	OpMkdir   = "mkdir"
+	OpDelete  = "delete"
+	OpRename  = "rename"
+	OpUpload  = "upload"
+	OpCopy    = "copy"
+	OpChmod   = "chmod"
+	OpExtract = "extract"
+	OpRestore = "restore"
+	OpChown   = "chown"
+	OpSymlink = "symlink"
+	OpLink    = "link"
+	OpXattr   = "xattr"
+	OpPatch   = "patch"
+)
+
+// ChangeEvent is published when a file or directory is modified through the API
+type ChangeEvent struct {
+	Op      string `json:"op"`
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+}
+
+// changed publishes a file change for an absolute path
+func (s *Service) changed(op, absPath, absOldPath string) {
+	if s.events == nil {
+		return
+	}
+	ev := ChangeEvent{Op: op, Path: ToTildePath(absPath)}
+	if absOldPath != "" {
+		ev.OldPath = ToTildePath(absOldPath)
+	}
+	s.events.Publish(events.TopicFileChanged, ev)
+}
+
 type FileEntry struct {
 	Name        string    `json:"name"`
 	Path        string    `json:"path"`
@@ -42,11 +119,19 @@ type FileEntry struct {
 	Size        int64     `json:"size"`
 	Modified    time.Time `json:"modified"`
 	Permissions string    `json:"permissions"`
+	UID         uint32    `json:"uid"`
+	GID         uint32    `json:"gid"`
+	Owner       string    `json:"owner,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	LinkTarget  string    `json:"link_target,omitempty"` // Set for symlinks
+	Inode       uint64    `json:"inode"`
+	Links       uint64    `json:"nlink"`
 }
 
 type ListResponse struct {
-	Path    string      `json:"path"`
-	Entries []FileEntry `json:"entries"`
+	Path    string        `json:"path"`
+	Entries []FileEntry   `json:"entries"`
+	Meta    *listing.Meta `json:"meta,omitempty"`
 }
 
 // ExpandTilde expands ~ to the user's home directory
@@ -135,10 +220,34 @@ func (s *Service) validatePath(path string) (string, error) {
 }
 
 func (s *Service) List(path string) (*ListResponse, error) {
-	return s.ListWithContext(context.Background(), path)
+	return s.ListWithContext(context.Background(), path, listing.Filter{MinSize: -1, MaxSize: -1},
+		listing.Params{Sort: "name", Order: listing.OrderAsc})
+}
+
+// ListSpec describes how directory listings can be sorted. Without a limit
+// or cursor the whole directory is listed.
+var ListSpec = listing.Spec{
+	SortFields:   []string{"name", "type", "size", "modified"},
+	DefaultSort:  "name",
+	DefaultOrder: listing.OrderAsc,
+	DefaultLimit: listing.NoLimit,
+}
+
+var entrySortFields = map[string]listing.Key[FileEntry]{
+	"name":     func(e FileEntry) any { return e.Name },
+	"type":     func(e FileEntry) any { return e.Type },
+	"size":     func(e FileEntry) any { return e.Size },
+	"modified": func(e FileEntry) any { return e.Modified },
+}
+
+func entryName(e FileEntry) string {
+	return e.Name
 }
 
-func (s *Service) ListWithContext(ctx context.Context, path string) (*ListResponse, error) {
+// ListWithContext lists one page of a directory. Entries are filtered by name
+// and type before anything is stat'ed; when neither the sort nor the filter
+// needs size or mtime, only the entries on the returned page are stat'ed.
+func (s *Service) ListWithContext(ctx context.Context, path string, f listing.Filter, p listing.Params) (*ListResponse, error) {
 	// Check context before starting
 	select {
 	case <-ctx.Done():
@@ -156,8 +265,50 @@ func (s *Service) ListWithContext(ctx context.Context, path string) (*ListRespon
 		return nil, err
 	}
 
-	fileEntries := make([]FileEntry, 0, len(entries))
+	candidates := make([]FileEntry, 0, len(entries))
 	for _, entry := range entries {
+		entryType := "file"
+		if entry.IsDir() {
+			entryType = "directory"
+		}
+		if !f.MatchType(entryType) || !f.MatchName(entry.Name()) {
+			continue
+		}
+		candidates = append(candidates, FileEntry{
+			Name: entry.Name(),
+			Path: ToTildePath(filepath.Join(absPath, entry.Name())),
+			Type: entryType,
+		})
+	}
+
+	needStat := f.HasStatFilter() || p.Sort == "size" || p.Sort == "modified"
+	if needStat {
+		if candidates, err = s.statEntries(ctx, absPath, candidates, f); err != nil {
+			return nil, err
+		}
+	}
+
+	page, meta := listing.Page(candidates, p, entrySortFields, entryName)
+
+	if !needStat {
+		if page, err = s.statEntries(ctx, absPath, page, f); err != nil {
+			return nil, err
+		}
+		meta.Count = len(page)
+	}
+
+	return &ListResponse{
+		Path:    ToTildePath(absPath),
+		Entries: page,
+		Meta:    &meta,
+	}, nil
+}
+
+// statEntries fills in size, mtime and permissions, dropping entries that
+// vanished or fail the size/mtime filters
+func (s *Service) statEntries(ctx context.Context, dir string, entries []FileEntry, f listing.Filter) ([]FileEntry, error) {
+	kept := entries[:0]
+	for _, e := range entries {
 		// Check context periodically for large directories
 		select {
 		case <-ctx.Done():
@@ -165,77 +316,108 @@ func (s *Service) ListWithContext(ctx context.Context, path string) (*ListRespon
 		default:
 		}
 
-		info, err := entry.Info()
+		info, err := os.Lstat(filepath.Join(dir, e.Name))
 		if err != nil {
 			continue
 		}
-
-		entryType := "file"
-		if entry.IsDir() {
-			entryType = "directory"
+		if !f.MatchSize(info.Size()) || !f.MatchModified(info.ModTime()) {
+			continue
 		}
 
-		entryAbsPath := filepath.Join(absPath, entry.Name())
-		fileEntries = append(fileEntries, FileEntry{
-			Name:        entry.Name(),
-			Path:        ToTildePath(entryAbsPath),
-			Type:        entryType,
-			Size:        info.Size(),
-			Modified:    info.ModTime(),
-			Permissions: info.Mode().String(),
-		})
+		e.Size = info.Size()
+		e.Modified = info.ModTime()
+		e.Permissions = info.Mode().String()
+		fillOwnership(&e, filepath.Join(dir, e.Name), info)
+		kept = append(kept, e)
 	}
-
-	return &ListResponse{
-		Path:    ToTildePath(absPath),
-		Entries: fileEntries,
-	}, nil
+	return kept, nil
 }
 
-func (s *Service) Read(path string) (string, error) {
+// Read returns a text file's content and its version token, which Write
+// accepts to detect concurrent changes
+func (s *Service) Read(path string) (string, string, error) {
 	absPath, err := s.validatePath(path)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	info, err := os.Stat(absPath)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	if info.IsDir() {
-		return "", errors.New("cannot read directory")
+		return "", "", ErrIsDirectory
 	}
 
 	if info.Size() > MaxFileSize {
-		return "", ErrFileTooLarge
+		return "", "", ErrFileTooLarge
 	}
 
 	content, err := os.ReadFile(absPath)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	if isBinary(content) {
-		return "", ErrBinaryFile
+		return "", "", ErrBinaryFile
 	}
 
-	return string(content), nil
+	return string(content), contentVersion(content), nil
 }
 
-func (s *Service) Write(path, content string) error {
+// Write replaces a file's content atomically and returns its new version.
+// If ifMatch is set, the write only happens while the file still has that
+// version ("*" only requires the file to exist); otherwise ErrVersionConflict
+// is returned with the current version and a diff from it to content.
+func (s *Service) Write(path, content, ifMatch string) (string, error) {
 	absPath, err := s.validatePath(path)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Ensure parent directory exists
 	dir := filepath.Dir(absPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+		return "", err
+	}
+
+	return s.write(absPath, []byte(content), ifMatch, OpWrite)
+}
+
+// write does Write for a validated path, snapshotting the content it
+// replaces and reporting the change as op
+func (s *Service) write(absPath string, content []byte, ifMatch, op string) (string, error) {
+	// Holds the version check, the snapshot and the write together
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	existing, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		existing = nil
+	} else if err != nil {
+		return "", err
+	} else if existing.IsDir() {
+		return "", ErrIsDirectory
+	}
+
+	if ifMatch != "" {
+		if err := checkVersion(absPath, existing, string(content), ifMatch); err != nil {
+			return "", err
+		}
+	}
+
+	if existing != nil {
+		if err := s.snapshot(absPath, op); err != nil {
+			return "", err
+		}
 	}
 
-	return os.WriteFile(absPath, []byte(content), 0644)
+	if err := writeAtomic(absPath, content, existing); err != nil {
+		return "", err
+	}
+	s.changed(op, absPath, "")
+	return contentVersion(content), nil
 }
 
 func (s *Service) Mkdir(path string) error {
@@ -244,10 +426,25 @@ func (s *Service) Mkdir(path string) error {
 		return err
 	}
 
-	return os.MkdirAll(absPath, 0755)
+	if err := os.MkdirAll(absPath, 0755); err != nil {
+		return err
+	}
+	s.changed(OpMkdir, absPath, "")
+	return nil
 }
 
+// Delete moves a file or directory to the trash, or removes it when no trash
+// is set
 func (s *Service) Delete(path string) error {
+	return s.delete(path, false)
+}
+
+// DeletePermanently removes a file or directory, bypassing the trash
+func (s *Service) DeletePermanently(path string) error {
+	return s.delete(path, true)
+}
+
+func (s *Service) delete(path string, permanent bool) error {
 	absPath, err := s.validatePath(path)
 	if err != nil {
 		return err
@@ -271,7 +468,15 @@ func (s *Service) Delete(path string) error {
 		}
 	}
 
-	return os.RemoveAll(absPath)
+	if s.trash != nil && !permanent {
+		if _, err := s.trash.put(absPath); err != nil {
+			return err
+		}
+	} else if err := os.RemoveAll(absPath); err != nil {
+		return err
+	}
+	s.changed(OpDelete, absPath, "")
+	return nil
 }
 
 // Rename renames/moves a file or directory
@@ -287,29 +492,104 @@ func (s *Service) Rename(oldPath, newPath string) error {
 	}
 
 	// Safety checks - don't allow renaming system paths
-	dangerousPaths := []string{"/", "/etc", "/usr", "/bin", "/sbin", "/var", "/boot", "/lib", "/lib64"}
-	for _, dp := range dangerousPaths {
-		if absOldPath == dp || absNewPath == dp {
-			return ErrDangerousPath
-		}
-	}
-
-	homeDir := os.Getenv("HOME")
-	if homeDir != "" && (absOldPath == homeDir || absNewPath == homeDir) {
+	if isProtectedPath(absOldPath) || isProtectedPath(absNewPath) {
 		return ErrDangerousPath
 	}
 
 	// Check if source exists
 	if _, err := os.Stat(absOldPath); os.IsNotExist(err) {
-		return errors.New("source path does not exist")
+		return ErrPathNotFound
 	}
 
 	// Check if destination already exists
 	if _, err := os.Stat(absNewPath); err == nil {
-		return errors.New("destination path already exists")
+		return ErrPathExists
 	}
 
-	return os.Rename(absOldPath, absNewPath)
+	if err := os.Rename(absOldPath, absNewPath); err != nil {
+		return err
+	}
+	s.changed(OpRename, absNewPath, absOldPath)
+	return nil
+}
+
+// protectedPaths are system directories that must never be moved or have
+// their mode changed
+var protectedPaths = []string{"/", "/etc", "/usr", "/bin", "/sbin", "/var", "/boot", "/lib", "/lib64"}
+
+// isProtectedPath reports whether absPath is a system directory or the home directory
+func isProtectedPath(absPath string) bool {
+	for _, dp := range protectedPaths {
+		if absPath == dp {
+			return true
+		}
+	}
+	homeDir := os.Getenv("HOME")
+	return homeDir != "" && absPath == homeDir
+}
+
+// Copy copies a file or directory tree to a new path, preserving permission
+// bits and modification times. Symlinks are copied as links; devices, sockets
+// and pipes are skipped. Large trees should use Operations instead.
+func (s *Service) Copy(srcPath, dstPath string) error {
+	absSrc, err := s.validatePath(srcPath)
+	if err != nil {
+		return err
+	}
+
+	absDst, err := s.validatePath(dstPath)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(absSrc)
+	if os.IsNotExist(err) {
+		return ErrPathNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(absDst); err == nil {
+		return ErrPathExists
+	}
+
+	if info.IsDir() && (absDst == absSrc || strings.HasPrefix(absDst, absSrc+"/")) {
+		return ErrDangerousPath.WithMessage("cannot copy a directory into itself")
+	}
+
+	c := &copier{ctx: context.Background(), conflict: ConflictFail}
+	if _, err := c.copyItem(absSrc, absDst); err != nil {
+		return err
+	}
+	s.changed(OpCopy, absDst, absSrc)
+	return nil
+}
+
+// Chmod sets the permission bits of a file or directory
+func (s *Service) Chmod(path string, mode os.FileMode) error {
+	absPath, err := s.validatePath(path)
+	if err != nil {
+		return err
+	}
+
+	if isProtectedPath(absPath) {
+		return ErrDangerousPath
+	}
+
+	if err := os.Chmod(absPath, mode.Perm()); err != nil {
+		return err
+	}
+	s.changed(OpChmod, absPath, "")
+	return nil
+}
+
+// ParseMode parses an octal permission string such as "755" or "0644"
+func ParseMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, ErrInvalidMode
+	}
+	return os.FileMode(mode), nil
 }
 
 func (s *Service) Stat(path string) (os.FileInfo, error) {
@@ -358,13 +638,13 @@ func (s *Service) Upload(destPath, filename string, reader io.Reader) error {
 		return err
 	}
 	if !info.IsDir() {
-		return errors.New("destination must be a directory")
+		return ErrNotDirectory
 	}
 
 	// Clean filename to prevent path traversal
 	filename = filepath.Base(filename)
 	if filename == "" || filename == "." || filename == ".." {
-		return errors.New("invalid filename")
+		return ErrInvalidFilename
 	}
 
 	fullPath := filepath.Join(absPath, filename)
@@ -374,6 +654,10 @@ func (s *Service) Upload(destPath, filename string, reader io.Reader) error {
 		return err
 	}
 
+	if err := s.snapshot(fullPath, OpUpload); err != nil {
+		return err
+	}
+
 	// Create the file with explicit permissions
 	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
@@ -381,8 +665,11 @@ func (s *Service) Upload(destPath, filename string, reader io.Reader) error {
 	}
 	defer f.Close()
 
-	_, err = io.Copy(f, reader)
-	return err
+	if _, err := io.Copy(f, reader); err != nil {
+		return err
+	}
+	s.changed(OpUpload, fullPath, "")
+	return nil
 }
 
 // GetFileInfo returns the absolute path and file info for a given path
@@ -408,6 +695,20 @@ type SearchResult struct {
 	Size    int64  `json:"size"`
 	Match   string `json:"match,omitempty"`    // For content search: the matching line
 	LineNum int    `json:"line_num,omitempty"` // For content search: line number
+
+	Snippet        string            `json:"snippet,omitempty"`         // Index search: matching content, cut with …
+	Highlights     []SearchHighlight `json:"highlights,omitempty"`      // Matched text in the snippet or matching line
+	NameHighlights []SearchHighlight `json:"name_highlights,omitempty"` // Matched text in the name
+	Score          float64           `json:"score,omitempty"`           // Index search: relevance, higher is better
+}
+
+// searchSkipDirs are directories searches and the search index don't descend
+// into, besides hidden ones
+var searchSkipDirs = []string{"node_modules", ".git", "vendor", "__pycache__", ".cache", ".chunks"}
+
+// skipSearchDir reports whether searches skip a directory by its name
+func skipSearchDir(name string) bool {
+	return strings.HasPrefix(name, ".") || slices.Contains(searchSkipDirs, name)
 }
 
 // SearchOptions configures search behavior
@@ -436,112 +737,23 @@ func (s *Service) SearchWithContext(ctx context.Context, path, query string, sea
 
 // SearchWithOptions searches for files with custom options
 func (s *Service) SearchWithOptions(ctx context.Context, path, query string, searchContent bool, limit int, opts SearchOptions) ([]SearchResult, error) {
-	absPath, err := s.validatePath(path)
-	if err != nil {
-		return nil, err
-	}
-
-	// Create a context with timeout
-	if opts.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
-		defer cancel()
-	}
-
 	results := []SearchResult{}
-	query = strings.ToLower(query)
-
-	// Track directory depth
-	baseDepth := strings.Count(absPath, string(os.PathSeparator))
-
-	err = filepath.Walk(absPath, func(filePath string, info os.FileInfo, err error) error {
-		// Check for context cancellation/timeout
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		if err != nil {
-			return nil // Skip errors (permission denied, etc.)
-		}
-
-		// Check depth limit
-		currentDepth := strings.Count(filePath, string(os.PathSeparator)) - baseDepth
-		if opts.MaxDepth > 0 && currentDepth > opts.MaxDepth {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Skip hidden directories (but not hidden files)
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && filePath != absPath {
-			return filepath.SkipDir
-		}
-
-		// Skip node_modules and other large directories
-		skipDirs := []string{"node_modules", ".git", "vendor", "__pycache__", ".cache", ".chunks"}
-		if info.IsDir() {
-			for _, sd := range skipDirs {
-				if info.Name() == sd {
-					return filepath.SkipDir
-				}
-			}
-		}
-
-		if len(results) >= limit {
-			return filepath.SkipAll
-		}
-
-		// Search by filename
-		if strings.Contains(strings.ToLower(info.Name()), query) {
-			entryType := "file"
-			if info.IsDir() {
-				entryType = "directory"
-			}
-			results = append(results, SearchResult{
-				Path: ToTildePath(filePath),
-				Name: info.Name(),
-				Type: entryType,
-				Size: info.Size(),
-			})
-			return nil
-		}
-
-		// Search content (only for files, skip binary and large files)
-		if searchContent && !info.IsDir() && info.Size() < MaxFileSize {
-			matches := s.searchFileContent(filePath, query, 3) // Max 3 matches per file
-			for _, match := range matches {
-				if len(results) >= limit {
-					return filepath.SkipAll
-				}
-				results = append(results, SearchResult{
-					Path:    ToTildePath(filePath),
-					Name:    info.Name(),
-					Type:    "file",
-					Size:    info.Size(),
-					Match:   match.line,
-					LineNum: match.lineNum,
-				})
-			}
-		}
-
+	_, err := s.SearchStream(ctx, SearchRequest{
+		Path:     path,
+		Query:    query,
+		Content:  searchContent,
+		Filter:   listing.Filter{MinSize: -1, MaxSize: -1},
+		Limit:    limit,
+		MaxDepth: opts.MaxDepth,
+		Timeout:  opts.Timeout,
+	}, func(result SearchResult) error {
+		results = append(results, result)
 		return nil
 	})
-
-	// Don't return timeout/cancel errors as failures
-	if err == context.DeadlineExceeded || err == context.Canceled {
-		// Return partial results with no error - results are what we found before timeout
-		return results, nil
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return nil, err
 	}
-
-	return results, err
-}
-
-type contentMatch struct {
-	lineNum int
-	line    string
+	return results, nil
 }
 
 // ─────────────────────────────────────────────────────────────────────────────
@@ -571,13 +783,13 @@ func (s *Service) InitChunkedUpload(destPath, filename string, totalSize, chunkS
 		return nil, err
 	}
 	if !info.IsDir() {
-		return nil, errors.New("destination must be a directory")
+		return nil, ErrNotDirectory
 	}
 
 	// Clean filename
 	filename = filepath.Base(filename)
 	if filename == "" || filename == "." || filename == ".." {
-		return nil, errors.New("invalid filename")
+		return nil, ErrInvalidFilename
 	}
 
 	// Generate upload ID
@@ -636,12 +848,15 @@ func (s *Service) CompleteChunkedUpload(destPath, filename, uploadID string, tot
 	for i := 0; i < totalChunks; i++ {
 		chunkPath := filepath.Join(chunkDir, fmt.Sprintf("chunk_%06d", i))
 		if _, err := os.Stat(chunkPath); os.IsNotExist(err) {
-			return fmt.Errorf("missing chunk %d", i)
+			return ErrUploadIncomplete.WithMessage(fmt.Sprintf("missing chunk %d", i)).WithDetails(map[string]int{"chunk_index": i})
 		}
 	}
 
 	// Create final file
 	finalPath := filepath.Join(absPath, filename)
+	if err := s.snapshot(finalPath, OpUpload); err != nil {
+		return err
+	}
 	finalFile, err := os.Create(finalPath)
 	if err != nil {
 		return fmt.Errorf("failed to create final file: %w", err)
@@ -670,6 +885,7 @@ func (s *Service) CompleteChunkedUpload(destPath, filename, uploadID string, tot
 	// Cleanup chunk directory
 	os.RemoveAll(chunkDir)
 
+	s.changed(OpUpload, finalPath, "")
 	return nil
 }
 
@@ -683,36 +899,3 @@ func (s *Service) AbortChunkedUpload(destPath, uploadID string) error {
 	chunkDir := filepath.Join(absPath, ".chunks", uploadID)
 	return os.RemoveAll(chunkDir)
 }
-
-func (s *Service) searchFileContent(path, query string, maxMatches int) []contentMatch {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil
-	}
-	defer file.Close()
-
-	matches := []contentMatch{}
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
-
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-
-		if strings.Contains(strings.ToLower(line), query) {
-			// Truncate long lines
-			if len(line) > 200 {
-				line = line[:200] + "..."
-			}
-			matches = append(matches, contentMatch{
-				lineNum: lineNum,
-				line:    line,
-			})
-			if len(matches) >= maxMatches {
-				break
-			}
-		}
-	}
-
-	return matches
-}
@@ -0,0 +1,93 @@
+//go:build linux
+
+package files
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// deviceOf returns the ID of the device holding path, without following a
+// final symlink
+func deviceOf(path string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
+
+// ownership returns the owner, group, inode number and link count from a stat result
+func ownership(info os.FileInfo) (uid, gid uint32, inode, nlink uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	return st.Uid, st.Gid, uint64(st.Ino), uint64(st.Nlink), true
+}
+
+// listXattr returns the names of the extended attributes of path
+func listXattr(path string) ([]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil {
+		return nil, xattrError(err)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, xattrError(err)
+	}
+
+	var names []string
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+// getXattr returns the value of one extended attribute
+func getXattr(path, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil {
+		return nil, xattrError(err)
+	}
+	buf := make([]byte, size)
+	if size == 0 {
+		return buf, nil
+	}
+	size, err = syscall.Getxattr(path, name, buf)
+	if err != nil {
+		return nil, xattrError(err)
+	}
+	return buf[:size], nil
+}
+
+func setXattr(path, name string, value []byte) error {
+	return xattrError(syscall.Setxattr(path, name, value, 0))
+}
+
+func removeXattr(path, name string) error {
+	return xattrError(syscall.Removexattr(path, name))
+}
+
+// xattrError maps the errno values extended attribute calls report
+func xattrError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.ENODATA):
+		return ErrXattrNotFound
+	case errors.Is(err, syscall.ENOTSUP):
+		return ErrXattrUnsupported
+	case errors.Is(err, syscall.E2BIG), errors.Is(err, syscall.ERANGE):
+		return ErrXattrTooLarge
+	}
+	return err
+}
@@ -0,0 +1,447 @@
+package files
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+var (
+	ErrTrashItemNotFound = apperr.New(apperr.CodeTrashItemNotFound, "trash item not found")
+	ErrTrashUnavailable  = apperr.New(apperr.CodeTrashUnavailable, "no trash available on this filesystem, delete permanently instead")
+)
+
+// trashPurgeInterval is how often items past the retention period are purged
+const trashPurgeInterval = time.Hour
+
+// TrashItem is a file or directory in the trash
+type TrashItem struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	OriginalPath string    `json:"original_path"`
+	Type         string    `json:"type"` // "file" or "directory"
+	Size         int64     `json:"size"`
+	DeletedAt    time.Time `json:"deleted_at"`
+
+	originalPath string // Absolute original path
+	trashDir     string // Trash directory holding files/ and info/
+	trashName    string // Name of the item in files/ and info/
+}
+
+// path returns where the item is stored
+func (i *TrashItem) path() string {
+	return filepath.Join(i.trashDir, "files", i.trashName)
+}
+
+func (i *TrashItem) infoPath() string {
+	return filepath.Join(i.trashDir, "info", i.trashName+".trashinfo")
+}
+
+// TrashListSpec describes how trash listings can be sorted
+var TrashListSpec = listing.Spec{
+	SortFields:   []string{"deleted_at", "name", "size"},
+	DefaultSort:  "deleted_at",
+	DefaultOrder: listing.OrderDesc,
+}
+
+var trashSortFields = map[string]listing.Key[*TrashItem]{
+	"deleted_at": func(item *TrashItem) any { return item.DeletedAt },
+	"name":       func(item *TrashItem) any { return item.Name },
+	"size":       func(item *TrashItem) any { return item.Size },
+}
+
+// Trash moves deleted files to a trash directory on the same filesystem,
+// following the FreeDesktop.org Trash specification so desktop file managers
+// see and can restore the same items. Items in the home directory's
+// filesystem go to $XDG_DATA_HOME/Trash; items on other filesystems go to
+// $topdir/.Trash/$uid or $topdir/.Trash-$uid. The original paths are also
+// recorded in the database, which is what the API lists.
+type Trash struct {
+	svc       *Service
+	store     *trashStore
+	retention time.Duration // 0 = keep items until purged
+
+	mu sync.Mutex // Serializes purges and restores
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewTrash creates a trash for the files service. Items older than
+// retention are purged once Start is called.
+func NewTrash(svc *Service, db *database.Database, retention time.Duration) *Trash {
+	return &Trash{
+		svc:       svc,
+		store:     newTrashStore(db),
+		retention: retention,
+		done:      make(chan struct{}),
+	}
+}
+
+// Start begins purging items past the retention period in the background
+func (t *Trash) Start() {
+	if t.retention <= 0 {
+		return
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			n, err := t.Empty(time.Now().Add(-t.retention))
+			if err != nil {
+				logger.Error("Failed to purge expired trash items: %v", err)
+			} else if n > 0 {
+				logger.Info("Purged %d expired trash item(s)", n)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-t.done:
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the background purge
+func (t *Trash) Shutdown() {
+	close(t.done)
+	t.wg.Wait()
+}
+
+// Put moves a file or directory to the trash. Service.Delete goes through
+// here and adds its own checks for system directories.
+func (t *Trash) Put(path string) (*TrashItem, error) {
+	absPath, err := t.svc.validatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if isProtectedPath(absPath) {
+		return nil, ErrDangerousPath
+	}
+	item, err := t.put(absPath)
+	if err != nil {
+		return nil, err
+	}
+	t.svc.changed(OpDelete, absPath, "")
+	return item, nil
+}
+
+func (t *Trash) put(absPath string) (*TrashItem, error) {
+	info, err := os.Lstat(absPath)
+	if os.IsNotExist(err) {
+		return nil, ErrPathNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	trashDir, topDir, err := trashDirFor(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if absPath == trashDir || strings.HasPrefix(absPath, trashDir+string(filepath.Separator)) {
+		return nil, ErrDangerousPath.WithMessage("path is already in the trash")
+	}
+	if strings.HasPrefix(trashDir, absPath+string(filepath.Separator)) {
+		return nil, ErrDangerousPath.WithMessage("path contains the trash")
+	}
+
+	item := &TrashItem{
+		ID:           uuid.New().String(),
+		Name:         filepath.Base(absPath),
+		OriginalPath: ToTildePath(absPath),
+		Type:         "file",
+		DeletedAt:    time.Now().Truncate(time.Second),
+		originalPath: absPath,
+		trashDir:     trashDir,
+	}
+	if info.IsDir() {
+		item.Type = "directory"
+		_, item.Size, _ = treeSize(context.Background(), absPath)
+	} else {
+		item.Size = info.Size()
+	}
+
+	// The info file is created first and exclusively; that reserves the name
+	if err := t.writeInfo(item, topDir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(absPath, item.path()); err != nil {
+		os.Remove(item.infoPath())
+		return nil, err
+	}
+	if err := t.store.insert(item); err != nil {
+		// Put it back so nothing disappears without a record
+		if rbErr := os.Rename(item.path(), absPath); rbErr == nil {
+			os.Remove(item.infoPath())
+		}
+		return nil, err
+	}
+
+	logger.Info("Moved to trash: %s", item.OriginalPath)
+	return item, nil
+}
+
+// writeInfo picks a free name in the trash and writes its .trashinfo file.
+// Paths in a top directory trash are relative to that directory.
+func (t *Trash) writeInfo(item *TrashItem, topDir string) error {
+	infoPath := item.originalPath
+	if topDir != "" {
+		rel, err := filepath.Rel(topDir, item.originalPath)
+		if err != nil {
+			return err
+		}
+		infoPath = rel
+	}
+	content := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: infoPath}).EscapedPath(), item.DeletedAt.Format("2006-01-02T15:04:05"))
+
+	ext := filepath.Ext(item.Name)
+	stem := strings.TrimSuffix(item.Name, ext)
+	if stem == "" {
+		stem, ext = item.Name, ""
+	}
+	for n := 1; ; n++ {
+		item.trashName = item.Name
+		if n > 1 {
+			item.trashName = fmt.Sprintf("%s.%d%s", stem, n, ext)
+		}
+		// A desktop tool may have left a file without info behind
+		if _, err := os.Lstat(item.path()); err == nil {
+			continue
+		}
+		f, err := os.OpenFile(item.infoPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(item.infoPath())
+		}
+		return err
+	}
+}
+
+// trashDirFor returns the trash directory for absPath, creating it if
+// needed. topDir is the filesystem's top directory for top directory
+// trashes, or "" for the home trash.
+func trashDirFor(absPath string) (trashDir, topDir string, err error) {
+	parent := filepath.Dir(absPath)
+	dev, err := deviceOf(parent)
+	if err != nil {
+		return "", "", err
+	}
+
+	home, err := homeTrashDir()
+	if err != nil {
+		return "", "", err
+	}
+	if err := makeTrashDir(home); err == nil {
+		if homeDev, err := deviceOf(home); err == nil && homeDev == dev {
+			return home, "", nil
+		}
+	}
+
+	topDir = parent
+	for {
+		up := filepath.Dir(topDir)
+		if up == topDir {
+			break
+		}
+		if upDev, err := deviceOf(up); err != nil || upDev != dev {
+			break
+		}
+		topDir = up
+	}
+	uid := strconv.Itoa(os.Getuid())
+
+	// $topdir/.Trash is shared and only used when set up by an administrator:
+	// a real directory (not a symlink) with the sticky bit
+	shared := filepath.Join(topDir, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		dir := filepath.Join(shared, uid)
+		if err := makeTrashDir(dir); err == nil {
+			return dir, topDir, nil
+		}
+	}
+
+	dir := filepath.Join(topDir, ".Trash-"+uid)
+	if err := makeTrashDir(dir); err != nil {
+		return "", "", ErrTrashUnavailable.WithDetails(map[string]string{"path": ToTildePath(absPath)})
+	}
+	return dir, topDir, nil
+}
+
+// homeTrashDir returns the trash directory for the home filesystem
+func homeTrashDir() (string, error) {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dataHome) {
+		return filepath.Join(dataHome, "Trash"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
+
+// makeTrashDir creates a trash directory with its files and info
+// subdirectories, refusing one that is a symlink
+func makeTrashDir(dir string) error {
+	for _, sub := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return err
+		}
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ErrNotDirectory.WithDetails(map[string]string{"path": ToTildePath(dir)})
+	}
+	return nil
+}
+
+// List returns a page of trash items matching the filter and its metadata. Name globs match the item's name; created_after and created_before
+// match the deletion time. Items removed from the trash by other tools are
+// forgotten.
+func (t *Trash) List(f listing.Filter, p listing.Params) ([]*TrashItem, listing.Meta, error) {
+	all, err := t.store.list(time.Time{})
+	if err != nil {
+		return nil, listing.Meta{}, err
+	}
+
+	items := make([]*TrashItem, 0, len(all))
+	for _, item := range all {
+		if _, err := os.Lstat(item.path()); os.IsNotExist(err) {
+			t.store.delete(item.ID)
+			continue
+		}
+		if !f.MatchType(item.Type) || !f.MatchName(item.Name) || !f.MatchSize(item.Size) || !f.MatchCreated(item.DeletedAt) {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	page, meta := listing.Page(items, p, trashSortFields, func(item *TrashItem) string { return item.ID })
+	return page, meta, nil
+}
+
+// Get returns a trash item by ID
+func (t *Trash) Get(id string) (*TrashItem, error) {
+	item, err := t.store.get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTrashItemNotFound
+	}
+	return item, err
+}
+
+// Restore moves an item back to its original path, or to destination if
+// given. Missing parent directories of the original path are recreated; an
+// existing file at the target is never replaced.
+func (t *Trash) Restore(id, destination string) (*TrashItem, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	item, err := t.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	target := item.originalPath
+	if destination != "" {
+		if target, err = t.svc.validatePath(destination); err != nil {
+			return nil, err
+		}
+	} else {
+		// A parent may have become a symlink since the item was trashed, so
+		// directories are only created under the resolved path
+		if target, err = t.svc.validateNewPath(target); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := os.Lstat(target); err == nil {
+		return nil, ErrPathExists.WithDetails(map[string]string{"path": ToTildePath(target)})
+	}
+
+	// A different destination may be on another filesystem
+	c := &copier{ctx: context.Background(), conflict: ConflictFail, move: true}
+	if _, err := c.copyItem(item.path(), target); err != nil {
+		return nil, err
+	}
+	os.Remove(item.infoPath())
+	if err := t.store.delete(item.ID); err != nil {
+		return nil, err
+	}
+
+	item.originalPath = target
+	item.OriginalPath = ToTildePath(target)
+	t.svc.changed(OpRestore, target, "")
+	logger.Info("Restored from trash: %s", item.OriginalPath)
+	return item, nil
+}
+
+// Purge permanently deletes an item from the trash
+func (t *Trash) Purge(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	item, err := t.Get(id)
+	if err != nil {
+		return err
+	}
+	return t.purge(item)
+}
+
+func (t *Trash) purge(item *TrashItem) error {
+	if err := os.RemoveAll(item.path()); err != nil {
+		return err
+	}
+	os.Remove(item.infoPath())
+	return t.store.delete(item.ID)
+}
+
+// Empty permanently deletes the items deleted before the given time, or
+// every item for a zero time, and returns how many were purged
+func (t *Trash) Empty(before time.Time) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	items, err := t.store.list(before)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, item := range items {
+		if err := t.purge(item); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
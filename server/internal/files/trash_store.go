@@ -0,0 +1,76 @@
+package files
+
+import (
+	"database/sql"
+	"path/filepath"
+	"time"
+
+	"github.com/ss497254/gloski/internal/database"
+)
+
+// trashStore handles persistence of trash items to SQLite database.
+// Paths are stored absolute; times are stored in UTC so they compare
+// correctly as text.
+type trashStore struct {
+	db *sql.DB
+}
+
+func newTrashStore(database *database.Database) *trashStore {
+	return &trashStore{db: database.DB()}
+}
+
+const trashColumns = "id, original_path, trash_dir, name, type, size, deleted_at"
+
+func scanTrashItem(row interface{ Scan(...any) error }) (*TrashItem, error) {
+	item := &TrashItem{}
+	err := row.Scan(&item.ID, &item.originalPath, &item.trashDir, &item.trashName, &item.Type, &item.Size, &item.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	item.Name = filepath.Base(item.originalPath)
+	item.OriginalPath = ToTildePath(item.originalPath)
+	return item, nil
+}
+
+func (s *trashStore) insert(item *TrashItem) error {
+	_, err := s.db.Exec(`
+		INSERT INTO trash_items (`+trashColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, item.ID, item.originalPath, item.trashDir, item.trashName, item.Type, item.Size, item.DeletedAt.UTC())
+	return err
+}
+
+func (s *trashStore) get(id string) (*TrashItem, error) {
+	return scanTrashItem(s.db.QueryRow("SELECT "+trashColumns+" FROM trash_items WHERE id = ?", id))
+}
+
+// list returns the items deleted before the given time, or all items for a zero time
+func (s *trashStore) list(before time.Time) ([]*TrashItem, error) {
+	query := "SELECT " + trashColumns + " FROM trash_items"
+	var args []any
+	if !before.IsZero() {
+		query += " WHERE deleted_at < ?"
+		args = append(args, before.UTC())
+	}
+
+	rows, err := s.db.Query(query+" ORDER BY deleted_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*TrashItem
+	for rows.Next() {
+		item, err := scanTrashItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *trashStore) delete(id string) error {
+	_, err := s.db.Exec("DELETE FROM trash_items WHERE id = ?", id)
+	return err
+}
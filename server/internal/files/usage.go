@@ -0,0 +1,555 @@
+package files
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+var (
+	ErrUsageNotScanned  = apperr.New(apperr.CodeUsageNotScanned, "path has not been scanned")
+	ErrInvalidUsageSort = apperr.New(apperr.CodeInvalidParameter, "sort must be size or allocated")
+)
+
+const (
+	// maxUsageTrees is how many scanned trees are cached; the least recently
+	// scanned are dropped first
+	maxUsageTrees = 20
+
+	// MaxUsageLimit bounds the entries of a listing or top-N query. Each
+	// directory keeps only this many of its largest files, which is enough to
+	// answer any query up to the limit exactly.
+	MaxUsageLimit = 100
+)
+
+// How disk usage entries are ranked
+const (
+	UsageSortSize      = "size"      // Apparent size
+	UsageSortAllocated = "allocated" // Blocks allocated on disk
+)
+
+// UsageScanRequest represents a request to scan a directory tree
+type UsageScanRequest struct {
+	Path          string `json:"path"`
+	OneFileSystem bool   `json:"one_file_system,omitempty"` // Don't descend into other mounts (du -x)
+}
+
+// UsageEntry is the disk usage of a directory tree or a file
+type UsageEntry struct {
+	Path      string       `json:"path"`
+	Type      string       `json:"type"`      // "directory" or "file"
+	Size      int64        `json:"size"`      // Apparent size, as du --apparent-size
+	Allocated int64        `json:"allocated"` // Bytes of blocks allocated, as du
+	Files     int          `json:"files"`     // Directories: non-directory entries below
+	Dirs      int          `json:"dirs"`      // Directories: subdirectories below
+	Errors    int          `json:"errors,omitempty"`
+	Children  []UsageEntry `json:"children,omitempty"`
+}
+
+// UsageTree is a cached scan, or a directory within one
+type UsageTree struct {
+	Root          string     `json:"root"` // Directory the scan started at
+	OneFileSystem bool       `json:"one_file_system"`
+	ScannedAt     time.Time  `json:"scanned_at"`
+	RefreshedAt   *time.Time `json:"refreshed_at,omitempty"` // Last rescan of a directory inside it
+	UsageEntry
+}
+
+// usageTree is a cached scan
+type usageTree struct {
+	root        string
+	oneFS       bool
+	dev         uint64
+	scannedAt   time.Time
+	refreshedAt *time.Time
+	dir         *usageDir
+}
+
+// usageDir is a scanned directory. Totals include the directory itself and
+// everything below it; hard-linked files are counted once per scan.
+type usageDir struct {
+	name      string
+	size      int64
+	allocated int64
+	files     int
+	dirs      int
+	errors    int // Entries that couldn't be read
+	children  []*usageDir
+	largest   []usageFile // Largest files directly inside, by size and by allocation
+}
+
+type usageFile struct {
+	name      string
+	size      int64
+	allocated int64
+}
+
+func (d *usageDir) entry(path string) UsageEntry {
+	return UsageEntry{
+		Path:      ToTildePath(path),
+		Type:      "directory",
+		Size:      d.size,
+		Allocated: d.allocated,
+		Files:     d.files,
+		Dirs:      d.dirs,
+		Errors:    d.errors,
+	}
+}
+
+// add adds a subdirectory's totals, or subtracts them when sign is -1
+func (d *usageDir) add(child *usageDir, sign int) {
+	d.size += int64(sign) * child.size
+	d.allocated += int64(sign) * child.allocated
+	d.files += sign * child.files
+	d.dirs += sign * (child.dirs + 1)
+	d.errors += sign * child.errors
+}
+
+func (d *usageDir) child(name string) (int, *usageDir) {
+	for i, c := range d.children {
+		if c.name == name {
+			return i, c
+		}
+	}
+	return -1, nil
+}
+
+// DiskUsage scans directory trees du-style, as background file operations,
+// and caches the size trees in memory to answer listings and top-N queries.
+// Rescanning a directory inside a cached tree walks only that directory and
+// updates the totals above it.
+type DiskUsage struct {
+	ops *Operations
+
+	mu    sync.RWMutex
+	trees map[string]*usageTree
+}
+
+// NewDiskUsage creates a disk usage analyzer whose scans run as operations
+func NewDiskUsage(ops *Operations) *DiskUsage {
+	return &DiskUsage{ops: ops, trees: make(map[string]*usageTree)}
+}
+
+// Scan starts scanning a directory tree in the background. The operation
+// reports files and apparent bytes counted so far; it has no totals.
+func (u *DiskUsage) Scan(req UsageScanRequest) (*Operation, error) {
+	absPath, err := u.ops.svc.validatePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	var st syscall.Stat_t
+	if err := syscall.Lstat(absPath, &st); err != nil {
+		return nil, &os.PathError{Op: "lstat", Path: absPath, Err: err}
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		return nil, ErrNotDirectory.WithMessage("path must be a directory")
+	}
+
+	op := &Operation{
+		ID:      uuid.New().String(),
+		Type:    OperationUsage,
+		Sources: []string{ToTildePath(absPath)},
+	}
+	logger.Info("File operation %s started: %s of %s", op.ID, op.Type, op.Sources[0])
+	return u.ops.launch(op, func(ctx context.Context) error {
+		s := &usageScanner{
+			ctx:   ctx,
+			oneFS: req.OneFileSystem,
+			dev:   uint64(st.Dev),
+			seen:  make(map[[2]uint64]bool),
+			op:    op,
+			ops:   u.ops,
+		}
+		dir, err := s.scanDir(absPath, filepath.Base(absPath), &st)
+		if err != nil {
+			return err
+		}
+		s.flush(true)
+		u.store(absPath, req.OneFileSystem, s.dev, dir)
+		summary := dir.entry(absPath)
+		u.ops.update(op, false, func(op *Operation) { op.Result = &summary })
+		return nil
+	}), nil
+}
+
+// store caches a scanned directory, splicing it into a cached tree that
+// contains it when there is one, and drops cached trees it replaces
+func (u *DiskUsage) store(absPath string, oneFS bool, dev uint64, dir *usageDir) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	for root := range u.trees {
+		if strings.HasPrefix(root, strings.TrimSuffix(absPath, "/")+"/") {
+			delete(u.trees, root)
+		}
+	}
+	if tree := u.containing(absPath); tree != nil && tree.root != absPath &&
+		tree.oneFS == oneFS && (!oneFS || tree.dev == dev) {
+		if parents := tree.walk(filepath.Dir(absPath)); parents != nil {
+			parent := parents[len(parents)-1]
+			if i, old := parent.child(dir.name); old != nil {
+				parent.children[i] = dir
+				for _, p := range parents {
+					p.add(old, -1)
+				}
+			} else {
+				parent.children = append(parent.children, dir)
+			}
+			for _, p := range parents {
+				p.add(dir, 1)
+			}
+			tree.refreshedAt = &now
+			return
+		}
+	}
+
+	u.trees[absPath] = &usageTree{root: absPath, oneFS: oneFS, dev: dev, scannedAt: now, dir: dir}
+	if len(u.trees) > maxUsageTrees {
+		var oldest *usageTree
+		for _, t := range u.trees {
+			if oldest == nil || t.scannedAt.Before(oldest.scannedAt) {
+				oldest = t
+			}
+		}
+		delete(u.trees, oldest.root)
+	}
+}
+
+// containing returns the cached tree with the deepest root holding absPath
+func (u *DiskUsage) containing(absPath string) *usageTree {
+	var best *usageTree
+	for root, t := range u.trees {
+		if (absPath == root || strings.HasPrefix(absPath, strings.TrimSuffix(root, "/")+"/")) &&
+			(best == nil || len(root) > len(best.root)) {
+			best = t
+		}
+	}
+	return best
+}
+
+// walk returns the directories from the root down to absPath, or nil if
+// absPath isn't in the tree
+func (t *usageTree) walk(absPath string) []*usageDir {
+	dirs := []*usageDir{t.dir}
+	rel, err := filepath.Rel(t.root, absPath)
+	if err != nil {
+		return nil
+	}
+	if rel == "." {
+		return dirs
+	}
+	d := t.dir
+	for _, name := range strings.Split(rel, "/") {
+		if _, d = d.child(name); d == nil {
+			return nil
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs
+}
+
+// lookup finds the cached directory at path
+func (u *DiskUsage) lookup(path string) (*usageTree, *usageDir, string, error) {
+	absPath, err := u.ops.svc.validatePath(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	tree := u.containing(absPath)
+	if tree == nil {
+		return nil, nil, "", ErrUsageNotScanned
+	}
+	dirs := tree.walk(absPath)
+	if dirs == nil {
+		return nil, nil, "", ErrUsageNotScanned
+	}
+	return tree, dirs[len(dirs)-1], absPath, nil
+}
+
+// Tree returns the cached usage of a directory with its subdirectories and
+// largest files, down to depth levels, each level holding at most limit
+// entries ranked by sortBy
+func (u *DiskUsage) Tree(path string, depth, limit int, sortBy string) (*UsageTree, error) {
+	less, err := usageOrder(sortBy)
+	if err != nil {
+		return nil, err
+	}
+	limit = usageLimit(limit)
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	tree, dir, absPath, err := u.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	return &UsageTree{
+		Root:          ToTildePath(tree.root),
+		OneFileSystem: tree.oneFS,
+		ScannedAt:     tree.scannedAt,
+		RefreshedAt:   tree.refreshedAt,
+		UsageEntry:    usageEntryTree(dir, absPath, depth, limit, less),
+	}, nil
+}
+
+func usageEntryTree(d *usageDir, absPath string, depth, limit int, less func(a, b UsageEntry) int) UsageEntry {
+	e := d.entry(absPath)
+	if depth <= 0 {
+		return e
+	}
+
+	type child struct {
+		entry UsageEntry
+		dir   *usageDir
+	}
+	children := make([]child, 0, len(d.children)+len(d.largest))
+	for _, c := range d.children {
+		children = append(children, child{c.entry(filepath.Join(absPath, c.name)), c})
+	}
+	for _, f := range d.largest {
+		children = append(children, child{entry: f.entry(absPath)})
+	}
+	slices.SortFunc(children, func(a, b child) int { return less(a.entry, b.entry) })
+	if len(children) > limit {
+		children = children[:limit]
+	}
+
+	e.Children = make([]UsageEntry, len(children))
+	for i, c := range children {
+		e.Children[i] = c.entry
+		if c.dir != nil {
+			e.Children[i] = usageEntryTree(c.dir, filepath.Join(absPath, c.dir.name), depth-1, limit, less)
+		}
+	}
+	return e
+}
+
+func (f usageFile) entry(dir string) UsageEntry {
+	return UsageEntry{Path: ToTildePath(filepath.Join(dir, f.name)), Type: "file", Size: f.size, Allocated: f.allocated}
+}
+
+// Top returns the largest directories (fileType "directory") or files
+// ("file") below a cached directory, ranked by sortBy
+func (u *DiskUsage) Top(path, fileType string, limit int, sortBy string) ([]UsageEntry, error) {
+	less, err := usageOrder(sortBy)
+	if err != nil {
+		return nil, err
+	}
+	if fileType != "directory" && fileType != "file" {
+		return nil, apperr.New(apperr.CodeInvalidParameter, "type must be directory or file")
+	}
+	limit = usageLimit(limit)
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	_, dir, absPath, err := u.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []UsageEntry
+	var collect func(d *usageDir, path string)
+	collect = func(d *usageDir, path string) {
+		if fileType == "file" {
+			for _, f := range d.largest {
+				entries = append(entries, f.entry(path))
+			}
+		}
+		for _, c := range d.children {
+			childPath := filepath.Join(path, c.name)
+			if fileType == "directory" {
+				entries = append(entries, c.entry(childPath))
+			}
+			collect(c, childPath)
+		}
+		// Keep memory bounded on large trees
+		if len(entries) > 4*limit {
+			slices.SortFunc(entries, less)
+			entries = entries[:limit]
+		}
+	}
+	collect(dir, absPath)
+
+	slices.SortFunc(entries, less)
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	if entries == nil {
+		entries = []UsageEntry{}
+	}
+	return entries, nil
+}
+
+// Cached returns the cached trees, without children
+func (u *DiskUsage) Cached() []UsageTree {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	trees := make([]UsageTree, 0, len(u.trees))
+	for _, t := range u.trees {
+		trees = append(trees, UsageTree{
+			Root:          ToTildePath(t.root),
+			OneFileSystem: t.oneFS,
+			ScannedAt:     t.scannedAt,
+			RefreshedAt:   t.refreshedAt,
+			UsageEntry:    t.dir.entry(t.root),
+		})
+	}
+	slices.SortFunc(trees, func(a, b UsageTree) int { return strings.Compare(a.Root, b.Root) })
+	return trees
+}
+
+// Forget drops the cached tree rooted at path
+func (u *DiskUsage) Forget(path string) error {
+	absPath, err := u.ops.svc.validatePath(path)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.trees[absPath]; !ok {
+		return ErrUsageNotScanned
+	}
+	delete(u.trees, absPath)
+	return nil
+}
+
+// usageOrder returns a comparison ranking entries largest first
+func usageOrder(sortBy string) (func(a, b UsageEntry) int, error) {
+	switch sortBy {
+	case "", UsageSortSize:
+		return func(a, b UsageEntry) int {
+			return cmp.Or(cmp.Compare(b.Size, a.Size), strings.Compare(a.Path, b.Path))
+		}, nil
+	case UsageSortAllocated:
+		return func(a, b UsageEntry) int {
+			return cmp.Or(cmp.Compare(b.Allocated, a.Allocated), strings.Compare(a.Path, b.Path))
+		}, nil
+	}
+	return nil, ErrInvalidUsageSort
+}
+
+func usageLimit(limit int) int {
+	if limit <= 0 || limit > MaxUsageLimit {
+		return MaxUsageLimit
+	}
+	return limit
+}
+
+// usageScanner walks a directory tree for a scan operation
+type usageScanner struct {
+	ctx   context.Context
+	oneFS bool
+	dev   uint64
+	seen  map[[2]uint64]bool // Hard-linked files already counted
+
+	op  *Operation
+	ops *Operations
+
+	// Progress not yet reported
+	files       int
+	bytes       int64
+	current     string
+	lastPublish time.Time
+}
+
+// scanDir scans a directory given its lstat result. Unreadable entries are
+// counted as errors; only cancellation stops the scan.
+func (s *usageScanner) scanDir(path, name string, st *syscall.Stat_t) (*usageDir, error) {
+	d := &usageDir{name: name, size: st.Size, allocated: st.Blocks * 512}
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		d.errors++
+		return d, nil
+	}
+
+	var files []usageFile
+	for _, entry := range entries {
+		if err := s.ctx.Err(); err != nil {
+			return nil, err
+		}
+		p := filepath.Join(path, entry.Name())
+		var est syscall.Stat_t
+		if err := syscall.Lstat(p, &est); err != nil {
+			d.errors++
+			continue
+		}
+
+		if est.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+			if s.oneFS && uint64(est.Dev) != s.dev {
+				continue
+			}
+			child, err := s.scanDir(p, entry.Name(), &est)
+			if err != nil {
+				return nil, err
+			}
+			d.children = append(d.children, child)
+			d.add(child, 1)
+			continue
+		}
+
+		if uint64(est.Nlink) > 1 {
+			key := [2]uint64{uint64(est.Dev), uint64(est.Ino)}
+			if s.seen[key] {
+				continue
+			}
+			s.seen[key] = true
+		}
+		f := usageFile{name: entry.Name(), size: est.Size, allocated: est.Blocks * 512}
+		files = append(files, f)
+		d.files++
+		d.size += f.size
+		d.allocated += f.allocated
+		s.files++
+		s.bytes += f.size
+	}
+	d.largest = largestFiles(files)
+
+	s.current = path
+	s.flush(false)
+	return d, nil
+}
+
+// largestFiles returns the MaxUsageLimit largest files by size, and of the
+// rest, the MaxUsageLimit largest by allocation: sparse and compressed files
+// can rank differently by each
+func largestFiles(files []usageFile) []usageFile {
+	if len(files) <= MaxUsageLimit {
+		return files
+	}
+	slices.SortFunc(files, func(a, b usageFile) int { return cmp.Compare(b.size, a.size) })
+	rest := files[MaxUsageLimit:]
+	slices.SortFunc(rest, func(a, b usageFile) int { return cmp.Compare(b.allocated, a.allocated) })
+	return slices.Clone(files[:MaxUsageLimit+min(MaxUsageLimit, len(rest))])
+}
+
+// flush reports progress on the operation, publishing it at most once per
+// operationProgressInterval unless forced
+func (s *usageScanner) flush(force bool) {
+	if !force && time.Since(s.lastPublish) < operationProgressInterval {
+		return
+	}
+	s.lastPublish = time.Now()
+	files, bytes, current := s.files, s.bytes, s.current
+	s.files, s.bytes = 0, 0
+	s.ops.update(s.op, true, func(op *Operation) {
+		op.FilesDone += files
+		op.BytesDone += bytes
+		op.Current = ToTildePath(current)
+	})
+}
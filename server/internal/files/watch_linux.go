@@ -0,0 +1,391 @@
+//go:build linux
+
+package files
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+var (
+	ErrTooManyWatches = apperr.New(apperr.CodeTooManyWatches, "too many watched directories")
+	ErrWatchClosed    = apperr.New(apperr.CodeBadRequest, "watch is closed")
+)
+
+// watchDebounce is how long events are collected and coalesced before they
+// are delivered, so a burst of writes to one file becomes a single event
+const watchDebounce = 200 * time.Millisecond
+
+// File watch operations reported in WatchEvent.Op
+const (
+	WatchCreate   = "create"
+	WatchModify   = "modify"
+	WatchDelete   = "delete"
+	WatchRename   = "rename"
+	WatchOverflow = "overflow" // Events were lost; clients should reload
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR
+
+// WatchEvent is a change inside a watched directory
+type WatchEvent struct {
+	Op      string `json:"op"`
+	Dir     string `json:"dir"`                // The watched directory
+	Path    string `json:"path,omitempty"`     // Empty for overflow
+	OldPath string `json:"old_path,omitempty"` // Set for renames
+	IsDir   bool   `json:"is_dir,omitempty"`
+}
+
+// Watch delivers changes in a set of directories using inotify. Changes made
+// by any process are reported, not only those made through the API.
+type Watch struct {
+	svc     *Service
+	fd      int
+	file    *os.File
+	maxDirs int
+	events  chan []WatchEvent
+	closed  chan struct{}
+
+	mu   sync.Mutex
+	wds  map[int32]string // Watch descriptor to absolute directory
+	dirs map[string]int32
+	done bool
+}
+
+// NewWatch starts an inotify instance that can watch up to maxDirs
+// directories (0 for no limit). Close it to release the instance.
+func (s *Service) NewWatch(maxDirs int) (*Watch, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &Watch{
+		svc:     s,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"), // Non-blocking, so reads use the runtime poller
+		maxDirs: maxDirs,
+		events:  make(chan []WatchEvent, 16),
+		closed:  make(chan struct{}),
+		wds:     make(map[int32]string),
+		dirs:    make(map[string]int32),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Events returns the channel batches of events are delivered on. It is
+// closed when the watch is closed.
+func (w *Watch) Events() <-chan []WatchEvent {
+	return w.events
+}
+
+// Add starts watching a directory and returns its normalized path.
+// Adding a directory that is already watched is a no-op.
+func (w *Watch) Add(path string) (string, error) {
+	absPath, err := w.svc.validatePath(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		return "", ErrPathNotFound
+	} else if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", ErrNotDirectory
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return "", ErrWatchClosed
+	}
+	if _, ok := w.dirs[absPath]; ok {
+		return ToTildePath(absPath), nil
+	}
+	if w.maxDirs > 0 && len(w.dirs) >= w.maxDirs {
+		return "", ErrTooManyWatches.WithDetails(map[string]int{"max": w.maxDirs})
+	}
+
+	wd, err := syscall.InotifyAddWatch(w.fd, absPath, watchMask)
+	if err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			return "", ErrTooManyWatches.WithMessage("the system inotify watch limit is reached")
+		}
+		return "", os.NewSyscallError("inotify_add_watch", err)
+	}
+	w.wds[int32(wd)] = absPath
+	w.dirs[absPath] = int32(wd)
+	return ToTildePath(absPath), nil
+}
+
+// Remove stops watching a directory
+func (w *Watch) Remove(path string) error {
+	absPath, err := w.svc.validatePath(path)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return ErrWatchClosed
+	}
+	wd, ok := w.dirs[absPath]
+	if !ok {
+		return ErrPathNotFound.WithMessage("directory is not watched")
+	}
+	delete(w.dirs, absPath)
+	delete(w.wds, wd)
+	// The kernel may already have dropped it if the directory was removed
+	syscall.InotifyRmWatch(w.fd, uint32(wd))
+	return nil
+}
+
+// Dirs returns the normalized paths of the watched directories
+func (w *Watch) Dirs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dirs := make([]string, 0, len(w.dirs))
+	for dir := range w.dirs {
+		dirs = append(dirs, ToTildePath(dir))
+	}
+	return dirs
+}
+
+// Close removes all watches and closes the Events channel
+func (w *Watch) Close() error {
+	w.mu.Lock()
+	if w.done {
+		w.mu.Unlock()
+		return nil
+	}
+	w.done = true
+	w.mu.Unlock()
+	close(w.closed)
+	// Unblocks the reader, which closes the events channel
+	return w.file.Close()
+}
+
+// run reads raw inotify events and delivers them in debounced batches
+func (w *Watch) run() {
+	defer close(w.events)
+
+	raw := make(chan []rawWatchEvent)
+	go w.read(raw)
+
+	var pending watchBatch
+	var flush <-chan time.Time
+	for {
+		select {
+		case evs, ok := <-raw:
+			if !ok {
+				return
+			}
+			for _, ev := range evs {
+				w.collect(&pending, ev)
+			}
+			if flush == nil && len(pending.events) > 0 {
+				flush = time.After(watchDebounce)
+			}
+		case <-flush:
+			flush = nil
+			if batch := pending.take(); len(batch) > 0 {
+				select {
+				case w.events <- batch:
+				case <-w.closed:
+					return
+				}
+			}
+		}
+	}
+}
+
+type rawWatchEvent struct {
+	wd     int32
+	mask   uint32
+	cookie uint32
+	name   string
+}
+
+// read decodes inotify records until the file is closed
+func (w *Watch) read(out chan<- []rawWatchEvent) {
+	defer close(out)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		var evs []rawWatchEvent
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			hdr := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + syscall.SizeofInotifyEvent
+			name := buf[nameStart : nameStart+int(hdr.Len)]
+			evs = append(evs, rawWatchEvent{
+				wd:     hdr.Wd,
+				mask:   hdr.Mask,
+				cookie: hdr.Cookie,
+				name:   string(bytes.TrimRight(name, "\x00")),
+			})
+			off = nameStart + int(hdr.Len)
+		}
+		select {
+		case out <- evs:
+		case <-w.closed:
+			return
+		}
+	}
+}
+
+// collect turns a raw event into a pending change
+func (w *Watch) collect(b *watchBatch, ev rawWatchEvent) {
+	if ev.mask&syscall.IN_Q_OVERFLOW != 0 {
+		b.add(WatchEvent{Op: WatchOverflow})
+		return
+	}
+
+	w.mu.Lock()
+	dir, ok := w.wds[ev.wd]
+	if ok && ev.mask&(syscall.IN_IGNORED|syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+		// The watched directory itself went away
+		delete(w.wds, ev.wd)
+		delete(w.dirs, dir)
+		if ev.mask&syscall.IN_IGNORED == 0 && !w.done {
+			syscall.InotifyRmWatch(w.fd, uint32(ev.wd))
+		}
+	}
+	w.mu.Unlock()
+	if !ok {
+		return // Removed watch; the kernel may still have queued events
+	}
+
+	if ev.name == "" {
+		if ev.mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+			tilde := ToTildePath(dir)
+			b.add(WatchEvent{Op: WatchDelete, Dir: tilde, Path: tilde, IsDir: true})
+		}
+		return
+	}
+
+	absPath := filepath.Join(dir, ev.name)
+	if !w.svc.config.IsPathAllowed(absPath) {
+		return
+	}
+	e := WatchEvent{
+		Dir:   ToTildePath(dir),
+		Path:  ToTildePath(absPath),
+		IsDir: ev.mask&syscall.IN_ISDIR != 0,
+	}
+	switch {
+	case ev.mask&syscall.IN_CREATE != 0:
+		e.Op = WatchCreate
+	case ev.mask&syscall.IN_DELETE != 0:
+		e.Op = WatchDelete
+	case ev.mask&syscall.IN_MOVED_FROM != 0:
+		b.movedFrom(ev.cookie, e)
+		return
+	case ev.mask&syscall.IN_MOVED_TO != 0:
+		if from, ok := b.movedTo(ev.cookie); ok {
+			e.Op = WatchRename
+			e.OldPath = from.Path
+		} else {
+			e.Op = WatchCreate // Moved in from an unwatched directory
+		}
+	default: // IN_MODIFY, IN_CLOSE_WRITE, IN_ATTRIB
+		e.Op = WatchModify
+	}
+	b.add(e)
+}
+
+// watchBatch coalesces the events of one debounce window per path
+type watchBatch struct {
+	events []WatchEvent
+	index  map[string]int         // Path to position in events
+	moves  map[uint32]pendingMove // IN_MOVED_FROM waiting for its IN_MOVED_TO
+}
+
+type pendingMove struct {
+	event WatchEvent
+	index int
+}
+
+func (b *watchBatch) add(e WatchEvent) {
+	if b.index == nil {
+		b.index = make(map[string]int)
+	}
+	if e.Op == WatchOverflow {
+		b.events = append(b.events, e)
+		return
+	}
+
+	if i, ok := b.index[e.Path]; ok {
+		prev := &b.events[i]
+		switch {
+		case prev.Op == WatchCreate && e.Op == WatchModify:
+			return // Still a create
+		case prev.Op == WatchCreate && e.Op == WatchDelete:
+			prev.Op = "" // Never existed as far as the client is concerned
+			delete(b.index, e.Path)
+			return
+		case prev.Op == WatchModify && e.Op == WatchModify:
+			return
+		case prev.Op == WatchDelete && e.Op == WatchCreate:
+			prev.Op = WatchModify // Replaced
+			prev.IsDir = e.IsDir
+			return
+		}
+		// Anything else: report the latest state, after what came before
+		prev.Op = ""
+	}
+	b.index[e.Path] = len(b.events)
+	b.events = append(b.events, e)
+}
+
+func (b *watchBatch) movedFrom(cookie uint32, e WatchEvent) {
+	if b.moves == nil {
+		b.moves = make(map[uint32]pendingMove)
+	}
+	// Report a delete unless the matching IN_MOVED_TO turns it into a rename
+	e.Op = WatchDelete
+	b.add(e)
+	b.moves[cookie] = pendingMove{event: e, index: len(b.events) - 1}
+}
+
+func (b *watchBatch) movedTo(cookie uint32) (WatchEvent, bool) {
+	m, ok := b.moves[cookie]
+	if !ok {
+		return WatchEvent{}, false
+	}
+	delete(b.moves, cookie)
+	if b.events[m.index].Op == WatchDelete && b.events[m.index].Path == m.event.Path {
+		b.events[m.index].Op = ""
+		delete(b.index, m.event.Path)
+	}
+	return m.event, true
+}
+
+// take returns the coalesced events and resets the batch
+func (b *watchBatch) take() []WatchEvent {
+	out := make([]WatchEvent, 0, len(b.events))
+	for _, e := range b.events {
+		if e.Op != "" {
+			out = append(out, e)
+		}
+	}
+	*b = watchBatch{}
+	return out
+}
@@ -0,0 +1,133 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+var ErrVersionConflict = apperr.New(apperr.CodeVersionConflict, "file was changed since it was read")
+
+// contentVersion returns the version token of a file's content
+func contentVersion(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:12])
+}
+
+// ParseIfMatch extracts a version token from an If-Match header value,
+// dropping the quotes and weak prefix of an ETag
+func ParseIfMatch(header string) string {
+	v := strings.TrimSpace(header)
+	v = strings.TrimPrefix(v, "W/")
+	return strings.Trim(v, `"`)
+}
+
+// checkVersion returns ErrVersionConflict, with the current version and a
+// diff from the current content to content, if the file at absPath doesn't
+// have version ifMatch
+func checkVersion(absPath string, existing os.FileInfo, content, ifMatch string) error {
+	conflict := func(message, current, diff string) error {
+		details := map[string]string{"current_version": current}
+		if diff != "" {
+			details["diff"] = diff
+		}
+		return ErrVersionConflict.WithMessage(message).WithDetails(details)
+	}
+
+	name := ToTildePath(absPath)
+	if existing == nil {
+		if ifMatch == "*" {
+			return conflict("file no longer exists", "", "")
+		}
+		return conflict("file no longer exists", "", UnifiedDiff("/dev/null", name, "", content, DefaultDiffContext))
+	}
+	if ifMatch == "*" {
+		return nil
+	}
+	if existing.Size() > MaxFileSize {
+		return conflict(ErrVersionConflict.Message, "", "")
+	}
+
+	current, err := os.ReadFile(absPath)
+	if err != nil {
+		return err
+	}
+	version := contentVersion(current)
+	if version == ifMatch {
+		return nil
+	}
+	diff := ""
+	if !isBinary(current) {
+		diff = UnifiedDiff(name+" (current)", name+" (yours)", string(current), content, DefaultDiffContext)
+	}
+	return conflict(ErrVersionConflict.Message, version, diff)
+}
+
+// writeAtomic replaces absPath with data by renaming a fully written temporary
+// file over it, so a crash never leaves a truncated file. An existing file's
+// mode and ownership carry over. When the directory isn't writable, or the
+// file's owner can't be kept, the file is overwritten in place instead.
+// Like any save-by-rename, this gives the path a new inode: hard links to the
+// old file keep the old content.
+func writeAtomic(absPath string, data []byte, existing os.FileInfo) error {
+	mode := os.FileMode(0644)
+	if existing != nil {
+		mode = existing.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(absPath), "."+filepath.Base(absPath)+".*.tmp")
+	if errors.Is(err, os.ErrPermission) && existing != nil {
+		return os.WriteFile(absPath, data, mode)
+	} else if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if existing != nil {
+		if uid, gid, _, _, ok := ownership(existing); ok && (uid != uint32(os.Geteuid()) || gid != uint32(os.Getegid())) {
+			if err := tmp.Chown(int(uid), int(gid)); errors.Is(err, syscall.EPERM) {
+				return os.WriteFile(absPath, data, mode)
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+	// After chown, which clears the setuid and setgid bits
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, absPath); err != nil {
+		return err
+	}
+	committed = true
+
+	// Persist the rename itself
+	if dir, err := os.Open(filepath.Dir(absPath)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
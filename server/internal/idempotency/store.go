@@ -0,0 +1,80 @@
+// Package idempotency persists responses to requests carrying an
+// Idempotency-Key header so retried requests can be answered without running
+// them again.
+package idempotency
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Record is a stored response for an idempotency key
+type Record struct {
+	Scope       string // Who sent the key (keys from different callers never collide)
+	Key         string
+	Fingerprint string // Hash of the request the key was first used with
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Store handles persistence of idempotency records to SQLite database.
+// All times are stored in UTC so they compare correctly as text.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new store with the given database
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Get returns the unexpired record for scope and key, or nil if there is none
+func (s *Store) Get(scope, key string, now time.Time) (*Record, error) {
+	rec := &Record{Scope: scope, Key: key}
+	var headers string
+	err := s.db.QueryRow(`
+		SELECT fingerprint, status_code, headers, body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE scope = ? AND key = ? AND expires_at > ?
+	`, scope, key, now.UTC()).Scan(&rec.Fingerprint, &rec.StatusCode, &headers, &rec.Body, &rec.CreatedAt, &rec.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(headers), &rec.Header); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Put saves a record, replacing an expired record with the same key
+func (s *Store) Put(rec *Record) error {
+	headers, err := json.Marshal(rec.Header)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO idempotency_keys
+			(scope, key, fingerprint, status_code, headers, body, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, rec.Scope, rec.Key, rec.Fingerprint, rec.StatusCode, string(headers), rec.Body,
+		rec.CreatedAt.UTC(), rec.ExpiresAt.UTC())
+	return err
+}
+
+// Prune removes records that expired before now
+func (s *Store) Prune(now time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM idempotency_keys WHERE expires_at <= ?", now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -2,6 +2,8 @@ package jobs
 
 import (
 	"bufio"
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,14 +11,24 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ss497254/gloski/internal/apperr"
 	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/internal/listing"
 	"github.com/ss497254/gloski/internal/logger"
 )
 
+var (
+	ErrJobNotFound   = apperr.New(apperr.CodeJobNotFound, "job not found")
+	ErrJobNotRunning = apperr.New(apperr.CodeJobNotRunning, "job is not running")
+	ErrEmptyCommand  = apperr.New(apperr.CodeEmptyCommand, "empty command")
+)
+
 // Config holds configuration for the jobs service
 type Config struct {
-	LogsDir string // Directory for job log files
-	MaxJobs int    // Maximum number of jobs to keep (default: 100)
+	LogsDir string      // Directory for job log files
+	MaxJobs int         // Maximum number of jobs to keep (default: 100)
+	Events  *events.Bus // Bus lifecycle events are published on (optional)
 }
 
 // Service manages job execution
@@ -25,6 +37,7 @@ type Service struct {
 	store  *Store
 	jobs   map[string]*runningJob
 	mu     sync.RWMutex
+	events *events.Bus
 }
 
 // runningJob holds the runtime state of a running job
@@ -54,6 +67,7 @@ func NewService(db *database.Database, config Config) (*Service, error) {
 		config: config,
 		store:  store,
 		jobs:   make(map[string]*runningJob),
+		events: config.Events,
 	}
 
 	// Load existing jobs and mark running ones as stopped (server restarted)
@@ -81,7 +95,7 @@ func NewService(db *database.Database, config Config) (*Service, error) {
 // Commands are executed through the shell to support pipes, redirects, and shell features
 func (s *Service) Start(id, command, cwd string) (*Job, error) {
 	if command == "" {
-		return nil, fmt.Errorf("empty command")
+		return nil, ErrEmptyCommand
 	}
 
 	// Create job record
@@ -164,8 +178,11 @@ func (s *Service) Start(id, command, cwd string) (*Job, error) {
 
 	s.mu.Lock()
 	s.jobs[id] = rj
+	started := *job
 	s.mu.Unlock()
 
+	s.events.Publish(events.TopicJobStarted, started)
+
 	// Capture stdout in background
 	go func() {
 		scanner := bufio.NewScanner(stdout)
@@ -212,11 +229,18 @@ func (s *Service) Start(id, command, cwd string) (*Job, error) {
 
 		// Remove from running jobs
 		delete(s.jobs, id)
+		finished := *job
 		s.mu.Unlock()
 
 		// Update database
 		s.store.Update(job)
 
+		topic := events.TopicJobFinished
+		if finished.Status == StatusFailed {
+			topic = events.TopicJobFailed
+		}
+		s.events.Publish(topic, finished)
+
 		// Run cleanup after job finishes
 		s.cleanup()
 	}()
@@ -231,11 +255,14 @@ func (s *Service) Stop(id string) error {
 	s.mu.Unlock()
 
 	if !ok {
-		return fmt.Errorf("job not found or not running")
+		if _, err := s.getStored(id); err != nil {
+			return err
+		}
+		return ErrJobNotRunning
 	}
 
 	if rj.Status != StatusRunning {
-		return fmt.Errorf("job is not running")
+		return ErrJobNotRunning
 	}
 
 	if err := rj.cmd.Process.Kill(); err != nil {
@@ -245,9 +272,9 @@ func (s *Service) Stop(id string) error {
 	return nil
 }
 
-// List returns all jobs from the database
-func (s *Service) List() ([]*Job, error) {
-	return s.store.Load()
+// List returns a page of jobs matching the filter and its metadata
+func (s *Service) List(f listing.Filter, p listing.Params) ([]*Job, listing.Meta, error) {
+	return s.store.List(f, p)
 }
 
 // Get returns a job by ID
@@ -263,7 +290,16 @@ func (s *Service) Get(id string) (*Job, error) {
 	s.mu.RUnlock()
 
 	// Otherwise get from database
-	return s.store.Get(id)
+	return s.getStored(id)
+}
+
+// getStored loads a job from the database, mapping missing rows to ErrJobNotFound
+func (s *Service) getStored(id string) (*Job, error) {
+	job, err := s.store.Get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	return job, err
 }
 
 // GetLogs reads logs from the job's log file
@@ -313,7 +349,7 @@ func (s *Service) Delete(id string) error {
 	s.mu.Unlock()
 
 	// Get job to find log file
-	job, err := s.store.Get(id)
+	job, err := s.getStored(id)
 	if err != nil {
 		return err
 	}
@@ -2,10 +2,29 @@ package jobs
 
 import (
 	"database/sql"
+	"strings"
+	"time"
 
 	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/listing"
 )
 
+// ListSpec describes how job listings can be sorted
+var ListSpec = listing.Spec{
+	SortFields:   []string{"created_at", "started_at", "finished_at", "status", "command"},
+	DefaultSort:  "created_at",
+	DefaultOrder: listing.OrderDesc,
+}
+
+// sortColumns maps API sort fields to job columns
+var sortColumns = map[string]string{
+	"created_at":  "created_at",
+	"started_at":  "started_at",
+	"finished_at": "finished_at",
+	"status":      "status",
+	"command":     "command",
+}
+
 // Store handles persistence of jobs to SQLite database
 type Store struct {
 	db *sql.DB
@@ -69,6 +88,121 @@ func (s *Store) Load() ([]*Job, error) {
 	return jobs, rows.Err()
 }
 
+// List returns one page of jobs matching the filter and its metadata.
+// Name globs are matched against the command.
+func (s *Store) List(f listing.Filter, p listing.Params) ([]*Job, listing.Meta, error) {
+	var where []string
+	var args []interface{}
+
+	if len(f.Status) > 0 {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(f.Status)-1)+")")
+		for _, status := range f.Status {
+			args = append(args, status)
+		}
+	}
+	if f.Name != "" {
+		where = append(where, "command GLOB ?")
+		args = append(args, f.Name)
+	}
+	// created_at is stored in local time, so compare in the same zone
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.CreatedAfter.In(time.Local))
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.CreatedBefore.In(time.Local))
+	}
+
+	clause := ""
+	if len(where) > 0 {
+		clause = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM jobs"+clause, args...).Scan(&total); err != nil {
+		return nil, listing.Meta{}, err
+	}
+
+	column, ok := sortColumns[p.Sort]
+	if !ok {
+		column = "created_at"
+	}
+	// Unset times sort first, as NULLs would
+	key := "COALESCE(" + column + ", '')"
+	dir := "ASC"
+	if p.Desc() {
+		dir = "DESC"
+	}
+
+	if after, afterArgs := p.AfterClause(key, "id"); after != "" {
+		where = append(where, after)
+		args = append(args, afterArgs...)
+		clause = " WHERE " + strings.Join(where, " AND ")
+	}
+	// One row more than the page tells whether there is a next page
+	limit := -1
+	if p.Limit > 0 {
+		limit = p.Limit + 1
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, command, cwd, status, pid, exit_code, log_file,
+		       created_at, started_at, finished_at, `+key+`
+		FROM jobs`+clause+`
+		ORDER BY `+key+` `+dir+`, id `+dir+`
+		LIMIT ?
+	`, append(args, limit)...)
+	if err != nil {
+		return nil, listing.Meta{}, err
+	}
+	defer rows.Close()
+
+	jobs := []*Job{}
+	var next *listing.Position
+	var lastKey any
+	for rows.Next() {
+		if len(jobs) == p.Limit {
+			last := jobs[len(jobs)-1]
+			next = &listing.Position{Key: lastKey, ID: last.ID}
+			break
+		}
+		j := &Job{}
+		var pid, exitCode sql.NullInt64
+		var logFile sql.NullString
+		var startedAt, finishedAt sql.NullTime
+
+		err := rows.Scan(
+			&j.ID, &j.Command, &j.Cwd, &j.Status,
+			&pid, &exitCode, &logFile,
+			&j.CreatedAt, &startedAt, &finishedAt, &lastKey,
+		)
+		if err != nil {
+			return nil, listing.Meta{}, err
+		}
+
+		if pid.Valid {
+			j.PID = int(pid.Int64)
+		}
+		if exitCode.Valid {
+			j.ExitCode = int(exitCode.Int64)
+		}
+		if logFile.Valid {
+			j.LogFile = logFile.String
+		}
+		if startedAt.Valid {
+			j.StartedAt = &startedAt.Time
+		}
+		if finishedAt.Valid {
+			j.FinishedAt = &finishedAt.Time
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	return jobs, p.Meta(total, len(jobs), next), rows.Err()
+}
+
 // Get retrieves a single job by ID
 func (s *Store) Get(id string) (*Job, error) {
 	j := &Job{}
@@ -0,0 +1,199 @@
+package listing
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter holds the common filters accepted by list endpoints.
+// Zero values mean "no constraint"; each endpoint decides which fields apply.
+type Filter struct {
+	Status         []string  // ?status=running,failed
+	Type           string    // ?type=file|directory
+	Name           string    // ?name=*.log (shell glob)
+	CreatedAfter   time.Time // ?created_after=
+	CreatedBefore  time.Time // ?created_before=
+	ModifiedAfter  time.Time // ?modified_after=
+	ModifiedBefore time.Time // ?modified_before=
+	MinSize        int64     // ?min_size= (-1 = unset)
+	MaxSize        int64     // ?max_size= (-1 = unset)
+
+	nameRe *regexp.Regexp
+}
+
+// ParseFilter reads filter query parameters.
+// Times may be RFC 3339 timestamps, YYYY-MM-DD dates or unix seconds.
+func ParseFilter(q url.Values) (Filter, error) {
+	f := Filter{MinSize: -1, MaxSize: -1}
+
+	for _, v := range q["status"] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				f.Status = append(f.Status, s)
+			}
+		}
+	}
+
+	f.Type = q.Get("type")
+
+	if f.Name = q.Get("name"); f.Name != "" {
+		re, err := compileGlob(f.Name)
+		if err != nil {
+			return f, ErrInvalidParam.WithMessage("name is not a valid glob pattern")
+		}
+		f.nameRe = re
+	}
+
+	times := []struct {
+		key string
+		dst *time.Time
+	}{
+		{"created_after", &f.CreatedAfter},
+		{"created_before", &f.CreatedBefore},
+		{"modified_after", &f.ModifiedAfter},
+		{"modified_before", &f.ModifiedBefore},
+	}
+	for _, t := range times {
+		if v := q.Get(t.key); v != "" {
+			parsed, err := parseTime(v)
+			if err != nil {
+				return f, ErrInvalidParam.WithMessage(t.key + " must be an RFC 3339 time, a date or unix seconds")
+			}
+			*t.dst = parsed
+		}
+	}
+
+	sizes := []struct {
+		key string
+		dst *int64
+	}{
+		{"min_size", &f.MinSize},
+		{"max_size", &f.MaxSize},
+	}
+	for _, s := range sizes {
+		if v := q.Get(s.key); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return f, ErrInvalidParam.WithMessage(s.key + " must be a non-negative integer")
+			}
+			*s.dst = n
+		}
+	}
+
+	return f, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// MatchStatus reports whether status passes the status filter
+func (f Filter) MatchStatus(status string) bool {
+	return len(f.Status) == 0 || slices.Contains(f.Status, status)
+}
+
+// MatchType reports whether typ passes the type filter
+func (f Filter) MatchType(typ string) bool {
+	return f.Type == "" || f.Type == typ
+}
+
+// MatchName reports whether name matches the glob filter.
+// Like SQLite's GLOB, "*" also matches "/" so patterns work on commands.
+func (f Filter) MatchName(name string) bool {
+	if f.Name == "" {
+		return true
+	}
+	re := f.nameRe
+	if re == nil {
+		var err error
+		if re, err = compileGlob(f.Name); err != nil {
+			return false
+		}
+	}
+	return re.MatchString(name)
+}
+
+// compileGlob translates a shell glob (*, ?, [class], \escape) to a regexp
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, errors.New("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// MatchCreated reports whether t falls within the created range
+func (f Filter) MatchCreated(t time.Time) bool {
+	return inRange(t, f.CreatedAfter, f.CreatedBefore)
+}
+
+// MatchModified reports whether t falls within the modified range
+func (f Filter) MatchModified(t time.Time) bool {
+	return inRange(t, f.ModifiedAfter, f.ModifiedBefore)
+}
+
+// MatchSize reports whether size falls within the size range
+func (f Filter) MatchSize(size int64) bool {
+	if f.MinSize >= 0 && size < f.MinSize {
+		return false
+	}
+	if f.MaxSize >= 0 && size > f.MaxSize {
+		return false
+	}
+	return true
+}
+
+// HasStatFilter reports whether matching needs file metadata (size or mtime)
+func (f Filter) HasStatFilter() bool {
+	return f.MinSize >= 0 || f.MaxSize >= 0 || !f.ModifiedAfter.IsZero() || !f.ModifiedBefore.IsZero()
+}
+
+func inRange(t, after, before time.Time) bool {
+	if !after.IsZero() && t.Before(after) {
+		return false
+	}
+	if !before.IsZero() && !t.Before(before) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,383 @@
+// Package listing implements the cursor pagination, sorting and filtering
+// shared by all list endpoints.
+//
+// Cursors are opaque to clients: they record the sort key and ID of the last
+// item of a page together with the sort it was taken from, so the next page
+// starts right after that item even when items were added or removed in
+// between, and a cursor can't be replayed against a different ordering.
+package listing
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ss497254/gloski/internal/apperr"
+)
+
+const (
+	// DefaultLimit is the page size used when a spec doesn't set one
+	DefaultLimit = 100
+
+	// MaxLimit is the largest page size a client may request
+	MaxLimit = 1000
+
+	// NoLimit as a spec's DefaultLimit returns everything unless a limit is
+	// given
+	NoLimit = -1
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+var (
+	ErrInvalidCursor = apperr.New(apperr.CodeInvalidCursor, "invalid cursor")
+	ErrInvalidParam  = apperr.New(apperr.CodeInvalidParameter, "invalid parameter")
+)
+
+// Spec describes the sortable fields and defaults of a list endpoint
+type Spec struct {
+	SortFields   []string // Accepted values for ?sort=
+	DefaultSort  string
+	DefaultOrder string // OrderAsc or OrderDesc
+	DefaultLimit int    // 0 = DefaultLimit, NoLimit = everything
+}
+
+// Params holds the pagination and sorting options of a list request
+type Params struct {
+	Limit int // 0 = everything
+	Sort  string
+	Order string
+	After *Position // Where the page starts; nil = at the beginning
+}
+
+// Position is the place of an item in a sorted list: its sort key and its
+// ID, which breaks ties between equal keys. A page starts after it.
+type Position struct {
+	Key any    `json:"k"`
+	ID  string `json:"i"`
+}
+
+// Desc reports whether results are sorted in descending order
+func (p Params) Desc() bool {
+	return p.Order == OrderDesc
+}
+
+// Meta is the pagination metadata returned alongside list results
+type Meta struct {
+	Total      int    `json:"total"`
+	Count      int    `json:"count"`
+	Limit      int    `json:"limit"`
+	Sort       string `json:"sort"`
+	Order      string `json:"order"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Meta builds response metadata for a page of count items out of total.
+// next is the position of the page's last item, or nil on the last page.
+func (p Params) Meta(total, count int, next *Position) Meta {
+	m := Meta{
+		Total: total,
+		Count: count,
+		Limit: p.Limit,
+		Sort:  p.Sort,
+		Order: p.Order,
+	}
+	if next != nil {
+		m.NextCursor = encodeCursor(cursor{After: *next, Sort: p.Sort, Order: p.Order, Limit: p.Limit})
+	}
+	return m
+}
+
+// cursor is the decoded form of an opaque page cursor
+type cursor struct {
+	After Position `json:"a"`
+	Sort  string   `json:"s"`
+	Order string   `json:"d"`
+	Limit int      `json:"l"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || c.Limit < 0 {
+		return c, ErrInvalidCursor
+	}
+	key, ok := normalizeKey(c.After.Key)
+	if !ok {
+		return c, ErrInvalidCursor
+	}
+	c.After.Key = key
+	return c, nil
+}
+
+// ParseParams reads limit, cursor, sort and order from query parameters.
+// When a cursor is given, its sort, order and page size are used; an explicit
+// sort or order that disagrees with the cursor is rejected.
+func ParseParams(q url.Values, spec Spec) (Params, error) {
+	p := Params{
+		Limit: spec.DefaultLimit,
+		Sort:  spec.DefaultSort,
+		Order: spec.DefaultOrder,
+	}
+	if p.Limit == NoLimit {
+		p.Limit = 0
+	} else if p.Limit <= 0 {
+		p.Limit = DefaultLimit
+	}
+	if p.Order == "" {
+		p.Order = OrderAsc
+	}
+
+	limit := q.Get("limit")
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return p, ErrInvalidParam.WithMessage("limit must be a positive integer")
+		}
+		p.Limit = min(n, MaxLimit)
+	}
+
+	sortField := q.Get("sort")
+	// "-name" is shorthand for sort=name&order=desc
+	if rest, ok := strings.CutPrefix(sortField, "-"); ok {
+		sortField = rest
+		if q.Get("order") == "" {
+			q = cloneWith(q, "order", OrderDesc)
+		}
+	}
+	if sortField != "" {
+		if !slices.Contains(spec.SortFields, sortField) {
+			return p, ErrInvalidParam.WithMessage(fmt.Sprintf("cannot sort by %q", sortField)).
+				WithDetails(map[string][]string{"sort_fields": spec.SortFields})
+		}
+		p.Sort = sortField
+	}
+
+	if order := strings.ToLower(q.Get("order")); order != "" {
+		if order != OrderAsc && order != OrderDesc {
+			return p, ErrInvalidParam.WithMessage("order must be asc or desc")
+		}
+		p.Order = order
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			return p, err
+		}
+		if (sortField != "" && c.Sort != p.Sort) || (q.Get("order") != "" && c.Order != p.Order) {
+			return p, ErrInvalidCursor.WithMessage("cursor does not match the requested sort")
+		}
+		p.After, p.Sort, p.Order = &c.After, c.Sort, c.Order
+		if limit == "" {
+			p.Limit = min(c.Limit, MaxLimit)
+		}
+	}
+
+	return p, nil
+}
+
+func cloneWith(q url.Values, key, value string) url.Values {
+	c := make(url.Values, len(q)+1)
+	for k, v := range q {
+		c[k] = v
+	}
+	c.Set(key, value)
+	return c
+}
+
+// Key returns the value an item sorts by on one field: a string, an integer,
+// a float, a bool or a time.Time
+type Key[T any] func(T) any
+
+// Fold lowercases a string key so it sorts case-insensitively
+func Fold(s string) string {
+	return strings.ToLower(s)
+}
+
+// Sort orders items in place by the key of p.Sort, then by id so equal keys
+// have a stable order and cursors a unique position. Without a key for
+// p.Sort the order is left as it is.
+func Sort[T any](items []T, p Params, fields map[string]Key[T], id func(T) string) {
+	key, ok := fields[p.Sort]
+	if !ok {
+		return
+	}
+	type keyed struct {
+		item T
+		pos  Position
+	}
+	sorted := make([]keyed, len(items))
+	for i, item := range items {
+		sorted[i] = keyed{item, position(item, key, id)}
+	}
+	slices.SortStableFunc(sorted, func(a, b keyed) int {
+		return p.compare(a.pos, b.pos)
+	})
+	for i, k := range sorted {
+		items[i] = k.item
+	}
+}
+
+// Page sorts items like Sort and returns the page p selects, with its
+// metadata. Without a key for p.Sort, items keep their order and a cursor
+// resumes after the item with its ID, or at its index if that item is gone.
+func Page[T any](items []T, p Params, fields map[string]Key[T], id func(T) string) ([]T, Meta) {
+	key, sorted := fields[p.Sort]
+	if sorted {
+		Sort(items, p, fields, id)
+	} else {
+		key = nil
+	}
+
+	start := 0
+	if p.After != nil {
+		if sorted {
+			start, _ = slices.BinarySearchFunc(items, *p.After, func(item T, after Position) int {
+				if p.compare(position(item, key, id), after) <= 0 {
+					return -1
+				}
+				return 1
+			})
+		} else {
+			start = slices.IndexFunc(items, func(item T) bool { return id(item) == p.After.ID }) + 1
+			if n, ok := p.After.Key.(int64); start == 0 && ok {
+				start = int(min(max(n+1, 0), int64(len(items))))
+			}
+		}
+	}
+
+	page := items[start:]
+	if p.Limit > 0 && p.Limit < len(page) {
+		page = page[:p.Limit]
+	}
+
+	var next *Position
+	if end := start + len(page); len(page) > 0 && end < len(items) {
+		last := page[len(page)-1]
+		if sorted {
+			pos := position(last, key, id)
+			next = &pos
+		} else {
+			next = &Position{Key: int64(end - 1), ID: id(last)}
+		}
+	}
+	return page, p.Meta(len(items), len(page), next)
+}
+
+func position[T any](item T, key Key[T], id func(T) string) Position {
+	k, _ := normalizeKey(key(item))
+	return Position{Key: k, ID: id(item)}
+}
+
+// compare orders two positions by key then ID, in the order of p
+func (p Params) compare(a, b Position) int {
+	c := compareKeys(a.Key, b.Key)
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if p.Desc() {
+		return -c
+	}
+	return c
+}
+
+// normalizeKey turns a key into an int64, float64 or string, the forms it
+// takes after a round trip through a cursor
+func normalizeKey(k any) (any, bool) {
+	switch v := k.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case bool:
+		if v {
+			return int64(1), true
+		}
+		return int64(0), true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case float64:
+		return v, true
+	case time.Time:
+		return v.UnixNano(), true
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, true
+		}
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return nil, false
+}
+
+// compareKeys compares normalized keys. Numbers sort before strings, which
+// only meet when a cursor was made up.
+func compareKeys(a, b any) int {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmp.Compare(x, y)
+		case float64:
+			return cmp.Compare(float64(x), y)
+		}
+		return -1
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return cmp.Compare(x, float64(y))
+		case float64:
+			return cmp.Compare(x, y)
+		}
+		return -1
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+		return 1
+	}
+	return 0
+}
+
+// AfterClause returns a SQL condition, and its arguments, selecting the rows
+// after p.After for a query ordered by "ORDER BY key dir, id dir". key must
+// not be NULL (COALESCE it) and compare like the cursor's key values. It
+// returns an empty condition when the page starts at the beginning.
+func (p Params) AfterClause(key, id string) (string, []any) {
+	if p.After == nil {
+		return "", nil
+	}
+	op := ">"
+	if p.Desc() {
+		op = "<"
+	}
+	return "(" + key + " " + op + " ? OR (" + key + " = ? AND " + id + " " + op + " ?))",
+		[]any{p.After.Key, p.After.Key, p.After.ID}
+}
@@ -3,15 +3,17 @@ package packages
 
 import (
 	"bufio"
-	"errors"
 	"fmt"
 	"os/exec"
 	"runtime"
 	"strings"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/listing"
 )
 
 var (
-	ErrNoPackageManager = errors.New("no supported package manager found")
+	ErrNoPackageManager = apperr.New(apperr.CodeNotAvailable, "no supported package manager found")
 )
 
 // ManagerType represents the type of package manager.
@@ -85,18 +87,53 @@ type UpgradeInfo struct {
 	Packages        []Package `json:"packages"`
 }
 
-// ListInstalled returns a list of installed packages.
-func (s *Service) ListInstalled(limit int) ([]Package, error) {
+// ListSpec describes how installed package listings can be sorted.
+var ListSpec = listing.Spec{
+	SortFields:   []string{"name", "version", "architecture"},
+	DefaultSort:  "name",
+	DefaultOrder: listing.OrderAsc,
+}
+
+var packageSortFields = map[string]listing.Key[Package]{
+	"name":         func(pkg Package) any { return pkg.Name },
+	"version":      func(pkg Package) any { return pkg.Version },
+	"architecture": func(pkg Package) any { return pkg.Architecture },
+}
+
+// packageID tells apart packages installed for several architectures
+func packageID(pkg Package) string {
+	return pkg.Name + ":" + pkg.Architecture
+}
+
+// ListInstalled returns a page of installed packages and its metadata.
+// Name globs match the package name.
+func (s *Service) ListInstalled(f listing.Filter, p listing.Params) ([]Package, listing.Meta, error) {
+	var pkgs []Package
+	var err error
+
 	switch s.manager {
 	case ManagerAPT:
-		return s.listInstalledAPT(limit)
+		pkgs, err = s.listInstalledAPT(0)
 	case ManagerDNF, ManagerYUM:
-		return s.listInstalledDNF(limit)
+		pkgs, err = s.listInstalledDNF(0)
 	case ManagerAPK:
-		return s.listInstalledAPK(limit)
+		pkgs, err = s.listInstalledAPK(0)
 	default:
-		return nil, ErrNoPackageManager
+		return nil, listing.Meta{}, ErrNoPackageManager
+	}
+	if err != nil {
+		return nil, listing.Meta{}, err
 	}
+
+	matched := []Package{}
+	for _, pkg := range pkgs {
+		if f.MatchName(pkg.Name) {
+			matched = append(matched, pkg)
+		}
+	}
+
+	page, meta := listing.Page(matched, p, packageSortFields, packageID)
+	return page, meta, nil
 }
 
 func (s *Service) listInstalledAPT(limit int) ([]Package, error) {
@@ -398,7 +435,7 @@ func (s *Service) GetPackageInfo(name string) (*Package, error) {
 	case ManagerDNF, ManagerYUM:
 		return s.getPackageInfoDNF(name)
 	default:
-		return nil, fmt.Errorf("package info not supported for %s", s.manager)
+		return nil, ErrNoPackageManager.WithMessage(fmt.Sprintf("package info not supported for %s", s.manager))
 	}
 }
 
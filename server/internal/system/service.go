@@ -6,10 +6,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ss497254/gloski/internal/listing"
 )
 
 // Service provides system information by reading from the store.
@@ -180,14 +181,31 @@ type Process struct {
 	Command string  `json:"command"`
 }
 
-// GetProcesses returns a list of running processes sorted by memory usage (most useful first).
-func (s *Service) GetProcesses(limit int) ([]Process, error) {
+// ProcessListSpec describes how process listings can be sorted
+var ProcessListSpec = listing.Spec{
+	SortFields:   []string{"rss", "vsz", "pid", "name", "state"},
+	DefaultSort:  "rss",
+	DefaultOrder: listing.OrderDesc,
+}
+
+var processSortFields = map[string]listing.Key[Process]{
+	"rss":   func(proc Process) any { return proc.RSS },
+	"vsz":   func(proc Process) any { return proc.VSZ },
+	"pid":   func(proc Process) any { return proc.PID },
+	"name":  func(proc Process) any { return listing.Fold(proc.Name) },
+	"state": func(proc Process) any { return proc.State },
+}
+
+// GetProcesses returns a page of running processes and its metadata.
+// By default the most memory-hungry processes come first. Name globs match the
+// process name and status filters match the process state (R, S, Z...).
+func (s *Service) GetProcesses(f listing.Filter, p listing.Params) ([]Process, listing.Meta, error) {
 	entries, err := os.ReadDir("/proc")
 	if err != nil {
-		return nil, err
+		return nil, listing.Meta{}, err
 	}
 
-	var processes []Process
+	processes := []Process{}
 
 	for _, entry := range entries {
 		if !entry.IsDir() {
@@ -203,20 +221,17 @@ func (s *Service) GetProcesses(limit int) ([]Process, error) {
 		if err != nil {
 			continue
 		}
+		if !f.MatchName(proc.Name) || !f.MatchStatus(proc.State) {
+			continue
+		}
 
 		processes = append(processes, proc)
 	}
 
-	// Sort by RSS descending so most resource-consuming processes come first
-	sort.Slice(processes, func(i, j int) bool {
-		return processes[i].RSS > processes[j].RSS
+	page, meta := listing.Page(processes, p, processSortFields, func(proc Process) string {
+		return strconv.Itoa(proc.PID)
 	})
-
-	if limit > 0 && len(processes) > limit {
-		processes = processes[:limit]
-	}
-
-	return processes, nil
+	return page, meta, nil
 }
 
 func (s *Service) readProcess(pid int) (Process, error) {
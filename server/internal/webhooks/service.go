@@ -0,0 +1,605 @@
+// Package webhooks delivers server events to external HTTP endpoints.
+//
+// Webhooks subscribe to event topics on the event bus. Each matching event is
+// recorded as a delivery in SQLite and POSTed to the target URL with an
+// HMAC-SHA256 signature; failed attempts are retried with exponential backoff,
+// and pending deliveries survive restarts. Progress topics are only delivered
+// to webhooks that name them exactly, never through a wildcard.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/internal/logger"
+)
+
+var (
+	ErrWebhookNotFound  = apperr.New(apperr.CodeWebhookNotFound, "webhook not found")
+	ErrDeliveryNotFound = apperr.New(apperr.CodeDeliveryNotFound, "delivery not found")
+	ErrInvalidURL       = apperr.New(apperr.CodeInvalidURL, "invalid webhook URL")
+	ErrNoEvents         = apperr.New(apperr.CodeInvalidParameter, "at least one event topic is required")
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Gloski-Event"
+	HeaderDelivery  = "X-Gloski-Delivery"
+	HeaderTimestamp = "X-Gloski-Timestamp"
+	HeaderSignature = "X-Gloski-Signature"
+)
+
+// TopicPing is the topic of test deliveries sent with Ping
+const TopicPing = "webhooks.ping"
+
+const (
+	// retryBaseDelay is the delay before the first retry; it doubles per attempt
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = time.Hour
+
+	// deliveryRetention is how long finished deliveries are kept
+	deliveryRetention = 30 * 24 * time.Hour
+
+	// maxResponseBody is how much of a response body is kept for the delivery log
+	maxResponseBody = 2048
+
+	// dispatchBatch is the number of due deliveries sent per dispatcher pass
+	dispatchBatch = 20
+	// dispatchConcurrency is the number of deliveries sent in parallel
+	dispatchConcurrency = 4
+)
+
+// Config holds configuration for the webhooks service
+type Config struct {
+	MaxAttempts int           // Attempts before a delivery is marked failed (default: 8)
+	Timeout     time.Duration // Per-attempt HTTP timeout (default: 10s)
+	Events      *events.Bus   // Bus events are read from
+	Client      *http.Client  // HTTP client (default: one with Timeout)
+}
+
+// Service manages webhook subscriptions and deliveries
+type Service struct {
+	config Config
+	store  *Store
+	client *http.Client
+
+	// Active webhooks, refreshed after every change
+	hooks   []*Webhook
+	hooksMu sync.RWMutex
+
+	// ctx is cancelled on shutdown to abort in-flight requests
+	ctx    context.Context
+	cancel context.CancelFunc
+	wake   chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewService creates a new webhooks service
+func NewService(db *database.Database, config Config) (*Service, error) {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+
+	s := &Service{
+		config: config,
+		store:  NewStore(db),
+		client: client,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	if err := s.reload(); err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+
+	return s, nil
+}
+
+// Start begins consuming events and dispatching deliveries
+func (s *Service) Start() {
+	if s.config.Events != nil {
+		// Subscribe before returning so no event published after Start is missed
+		sub, _, _ := s.config.Events.Subscribe(nil, 0, nil)
+		s.wg.Add(1)
+		go s.consume(sub)
+	}
+	s.wg.Add(1)
+	go s.dispatch()
+}
+
+// Shutdown stops the service. Pending deliveries resume on next start.
+func (s *Service) Shutdown() {
+	s.cancel()
+	close(s.done)
+	s.wg.Wait()
+}
+
+// List returns all webhooks (without secrets)
+func (s *Service) List() ([]*Webhook, error) {
+	hooks, err := s.store.ListWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hooks {
+		h.Secret = ""
+	}
+	return hooks, nil
+}
+
+// Get returns a webhook by ID (without its secret)
+func (s *Service) Get(id string) (*Webhook, error) {
+	h, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	h.Secret = ""
+	return h, nil
+}
+
+func (s *Service) get(id string) (*Webhook, error) {
+	h, err := s.store.GetWebhook(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	return h, err
+}
+
+// Create adds a webhook. The returned webhook includes its secret, which is
+// not returned again.
+func (s *Service) Create(req CreateWebhookRequest) (*Webhook, error) {
+	if err := validateURL(req.URL); err != nil {
+		return nil, err
+	}
+	topics := cleanTopics(req.Events)
+	if len(topics) == 0 {
+		return nil, ErrNoEvents
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	h := &Webhook{
+		ID:        uuid.New().String(),
+		URL:       req.URL,
+		Events:    topics,
+		Active:    req.Active == nil || *req.Active,
+		Secret:    secret,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.store.InsertWebhook(h); err != nil {
+		return nil, fmt.Errorf("failed to save webhook: %w", err)
+	}
+	s.reloadOrWarn()
+	return h, nil
+}
+
+// Update changes the fields set in req
+func (s *Service) Update(id string, req UpdateWebhookRequest) (*Webhook, error) {
+	h, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		if err := validateURL(*req.URL); err != nil {
+			return nil, err
+		}
+		h.URL = *req.URL
+	}
+	if req.Events != nil {
+		topics := cleanTopics(req.Events)
+		if len(topics) == 0 {
+			return nil, ErrNoEvents
+		}
+		h.Events = topics
+	}
+	if req.Secret != nil && *req.Secret != "" {
+		h.Secret = *req.Secret
+	}
+	if req.Active != nil {
+		h.Active = *req.Active
+	}
+	h.UpdatedAt = time.Now().UTC()
+
+	if err := s.store.UpdateWebhook(h); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	s.reloadOrWarn()
+
+	h.Secret = ""
+	return h, nil
+}
+
+// Delete removes a webhook and its delivery history
+func (s *Service) Delete(id string) error {
+	found, err := s.store.DeleteWebhook(id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrWebhookNotFound
+	}
+	s.reloadOrWarn()
+	return nil
+}
+
+// Deliveries returns a page of a webhook's delivery history
+func (s *Service) Deliveries(id string, f listing.Filter, p listing.Params) ([]*Delivery, listing.Meta, error) {
+	if _, err := s.get(id); err != nil {
+		return nil, listing.Meta{}, err
+	}
+	return s.store.ListDeliveries(id, f, p)
+}
+
+// Redeliver queues a new delivery with the same payload as an earlier one
+func (s *Service) Redeliver(id, deliveryID string) (*Delivery, error) {
+	original, err := s.store.GetDelivery(id, deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d := newDelivery(id, original.EventID, original.Topic, original.Payload)
+	d.RedeliveryOf = original.ID
+	if err := s.store.InsertDelivery(d); err != nil {
+		return nil, fmt.Errorf("failed to save delivery: %w", err)
+	}
+	s.notify()
+	return d, nil
+}
+
+// Ping queues a test delivery to a webhook, regardless of its event filter
+func (s *Service) Ping(id string) (*Delivery, error) {
+	if _, err := s.get(id); err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.Marshal(events.Event{Topic: TopicPing, Time: time.Now().UTC(), Data: map[string]string{"webhook_id": id}})
+	d := newDelivery(id, 0, TopicPing, payload)
+	if err := s.store.InsertDelivery(d); err != nil {
+		return nil, fmt.Errorf("failed to save delivery: %w", err)
+	}
+	s.notify()
+	return d, nil
+}
+
+// Sign computes the signature header value for a delivery body.
+// Receivers should recompute it over "<timestamp>.<body>" with the shared
+// secret and compare in constant time.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// reload refreshes the in-memory list of active webhooks
+func (s *Service) reload() error {
+	hooks, err := s.store.ListWebhooks()
+	if err != nil {
+		return err
+	}
+	active := hooks[:0]
+	for _, h := range hooks {
+		if h.Active {
+			active = append(active, h)
+		}
+	}
+
+	s.hooksMu.Lock()
+	s.hooks = active
+	s.hooksMu.Unlock()
+	return nil
+}
+
+func (s *Service) reloadOrWarn() {
+	if err := s.reload(); err != nil {
+		logger.Warn("Failed to reload webhooks: %v", err)
+	}
+}
+
+// consume records a delivery for every event matching an active webhook.
+// If the bus drops the subscription for falling behind, it resubscribes and
+// replays from the last event seen.
+func (s *Service) consume(sub *events.Subscription) {
+	defer s.wg.Done()
+
+	var lastID uint64
+	for {
+	loop:
+		for {
+			select {
+			case <-s.done:
+				sub.Close()
+				return
+			case ev, ok := <-sub.C():
+				if !ok {
+					break loop
+				}
+				s.record(ev)
+				lastID = ev.ID
+			}
+		}
+		logger.Warn("Webhook event subscription dropped, resuming after event %d", lastID)
+
+		var replay []events.Event
+		sub, replay, _ = s.config.Events.Subscribe(nil, lastID, nil)
+		for _, ev := range replay {
+			s.record(ev)
+			lastID = ev.ID
+		}
+	}
+}
+
+// matchTopic reports whether a webhook pattern selects topic. Progress
+// topics fire every second or so while something runs, so only patterns
+// naming them exactly deliver them.
+func matchTopic(pattern, topic string) bool {
+	if strings.HasSuffix(topic, ".progress") {
+		return pattern == topic
+	}
+	return events.Match(pattern, topic)
+}
+
+// record queues deliveries of ev to matching webhooks
+func (s *Service) record(ev events.Event) {
+	s.hooksMu.RLock()
+	var targets []string
+	for _, h := range s.hooks {
+		for _, pattern := range h.Events {
+			if matchTopic(pattern, ev.Topic) {
+				targets = append(targets, h.ID)
+				break
+			}
+		}
+	}
+	s.hooksMu.RUnlock()
+
+	if len(targets) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		logger.Error("Failed to encode webhook payload: %v", err)
+		return
+	}
+	for _, id := range targets {
+		if err := s.store.InsertDelivery(newDelivery(id, ev.ID, ev.Topic, payload)); err != nil {
+			logger.Error("Failed to queue webhook delivery: %v", err)
+		}
+	}
+	s.notify()
+}
+
+// notify wakes the dispatcher
+func (s *Service) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch sends due deliveries until shutdown
+func (s *Service) dispatch() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-prune.C:
+			if n, err := s.store.PruneDeliveries(time.Now().Add(-deliveryRetention)); err != nil {
+				logger.Warn("Failed to prune webhook deliveries: %v", err)
+			} else if n > 0 {
+				logger.Debug("Pruned %d webhook deliveries", n)
+			}
+			continue
+		case <-ticker.C:
+		case <-s.wake:
+		}
+
+		for {
+			due, err := s.store.DueDeliveries(time.Now(), dispatchBatch)
+			if err != nil {
+				logger.Error("Failed to load webhook deliveries: %v", err)
+				break
+			}
+			if len(due) == 0 {
+				break
+			}
+			s.sendAll(due)
+			if len(due) < dispatchBatch {
+				break
+			}
+		}
+	}
+}
+
+func (s *Service) sendAll(deliveries []*Delivery) {
+	sem := make(chan struct{}, dispatchConcurrency)
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d *Delivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.attempt(d)
+		}(d)
+	}
+	wg.Wait()
+}
+
+// attempt sends a delivery once and records the outcome
+func (s *Service) attempt(d *Delivery) {
+	h, err := s.store.GetWebhook(d.WebhookID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return // Deleted while queued; its deliveries went with it
+		}
+		logger.Error("Failed to load webhook %s: %v", d.WebhookID, err)
+		return
+	}
+
+	d.Attempts++
+	d.StatusCode, d.Response, d.Error = 0, "", ""
+
+	statusCode, response, err := s.send(h, d)
+	if s.ctx.Err() != nil {
+		return // Interrupted by shutdown; leave pending so it is retried on start
+	}
+	d.StatusCode, d.Response = statusCode, response
+
+	now := time.Now().UTC()
+	switch {
+	case err == nil && statusCode >= 200 && statusCode < 300:
+		d.Status = DeliverySucceeded
+		d.DeliveredAt = &now
+		d.NextAttemptAt = nil
+	default:
+		if err != nil {
+			d.Error = err.Error()
+		} else {
+			d.Error = fmt.Sprintf("unexpected status %d", statusCode)
+		}
+		if d.Attempts >= s.config.MaxAttempts {
+			d.Status = DeliveryFailed
+			d.NextAttemptAt = nil
+			logger.Warn("Webhook delivery %s to %s failed after %d attempts: %s", d.ID, h.URL, d.Attempts, d.Error)
+		} else {
+			next := now.Add(backoff(d.Attempts))
+			d.NextAttemptAt = &next
+		}
+	}
+
+	if err := s.store.UpdateDelivery(d); err != nil {
+		logger.Error("Failed to update webhook delivery %s: %v", d.ID, err)
+	}
+}
+
+// send POSTs the delivery payload and returns the response status and body
+func (s *Service) send(h *Webhook, d *Delivery) (int, string, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Gloski-Webhooks/1")
+	req.Header.Set(HeaderEvent, d.Topic)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(h.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, string(body), nil
+}
+
+// backoff returns the delay before the next attempt after n failed attempts
+func backoff(n int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < n && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
+
+func newDelivery(webhookID string, eventID uint64, topic string, payload []byte) *Delivery {
+	now := time.Now().UTC()
+	return &Delivery{
+		ID:            uuid.New().String(),
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		Topic:         topic,
+		Payload:       payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+}
+
+func validateURL(raw string) error {
+	u, err := neturl.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ErrInvalidURL
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrInvalidURL.WithMessage(fmt.Sprintf("unsupported URL scheme: %s (only http/https allowed)", u.Scheme))
+	}
+	return nil
+}
+
+func cleanTopics(topics []string) []string {
+	var clean []string
+	for _, t := range topics {
+		if t = strings.TrimSpace(t); t != "" && !strings.Contains(t, ",") {
+			clean = append(clean, t)
+		}
+	}
+	return clean
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
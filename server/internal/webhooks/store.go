@@ -0,0 +1,317 @@
+package webhooks
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/listing"
+)
+
+// DeliveryListSpec describes how delivery listings can be sorted
+var DeliveryListSpec = listing.Spec{
+	SortFields:   []string{"created_at", "status", "topic", "attempts"},
+	DefaultSort:  "created_at",
+	DefaultOrder: listing.OrderDesc,
+}
+
+// deliverySortColumns maps API sort fields to delivery columns
+var deliverySortColumns = map[string]string{
+	"created_at": "created_at",
+	"status":     "status",
+	"topic":      "topic",
+	"attempts":   "attempts",
+}
+
+const deliveryColumns = `id, webhook_id, event_id, topic, payload, status, attempts,
+	next_attempt_at, status_code, error, response, redelivery_of, created_at, delivered_at`
+
+// Store handles persistence of webhooks and deliveries to SQLite database.
+// All times are stored in UTC so they compare correctly as text.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new store with the given database
+func NewStore(database *database.Database) *Store {
+	return &Store{
+		db: database.DB(),
+	}
+}
+
+// ListWebhooks returns all webhooks, oldest first
+func (s *Store) ListWebhooks() ([]*Webhook, error) {
+	rows, err := s.db.Query(`
+		SELECT id, url, secret, events, active, created_at, updated_at
+		FROM webhooks
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []*Webhook{}
+	for rows.Next() {
+		h, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// GetWebhook retrieves a single webhook by ID
+func (s *Store) GetWebhook(id string) (*Webhook, error) {
+	return scanWebhook(s.db.QueryRow(`
+		SELECT id, url, secret, events, active, created_at, updated_at
+		FROM webhooks WHERE id = ?
+	`, id))
+}
+
+// InsertWebhook adds a new webhook
+func (s *Store) InsertWebhook(h *Webhook) error {
+	_, err := s.db.Exec(`
+		INSERT INTO webhooks (id, url, secret, events, active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, h.ID, h.URL, h.Secret, strings.Join(h.Events, ","), h.Active, h.CreatedAt.UTC(), h.UpdatedAt.UTC())
+	return err
+}
+
+// UpdateWebhook updates an existing webhook
+func (s *Store) UpdateWebhook(h *Webhook) error {
+	_, err := s.db.Exec(`
+		UPDATE webhooks SET url = ?, secret = ?, events = ?, active = ?, updated_at = ?
+		WHERE id = ?
+	`, h.URL, h.Secret, strings.Join(h.Events, ","), h.Active, h.UpdatedAt.UTC(), h.ID)
+	return err
+}
+
+// DeleteWebhook removes a webhook and its deliveries
+func (s *Store) DeleteWebhook(id string) (bool, error) {
+	if _, err := s.db.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return false, err
+	}
+	res, err := s.db.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row scanner) (*Webhook, error) {
+	h := &Webhook{}
+	var events string
+	if err := row.Scan(&h.ID, &h.URL, &h.Secret, &events, &h.Active, &h.CreatedAt, &h.UpdatedAt); err != nil {
+		return nil, err
+	}
+	h.Events = strings.Split(events, ",")
+	return h, nil
+}
+
+// InsertDelivery adds a new delivery
+func (s *Store) InsertDelivery(d *Delivery) error {
+	_, err := s.db.Exec(`
+		INSERT INTO webhook_deliveries (`+deliveryColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		d.ID, d.WebhookID, d.EventID, d.Topic, string(d.Payload), d.Status, d.Attempts,
+		utcTime(d.NextAttemptAt), database.NullInt(d.StatusCode, d.StatusCode != 0),
+		database.NullString(d.Error), database.NullString(d.Response), database.NullString(d.RedeliveryOf),
+		d.CreatedAt.UTC(), utcTime(d.DeliveredAt),
+	)
+	return err
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (s *Store) UpdateDelivery(d *Delivery) error {
+	_, err := s.db.Exec(`
+		UPDATE webhook_deliveries SET
+			status = ?, attempts = ?, next_attempt_at = ?, status_code = ?,
+			error = ?, response = ?, delivered_at = ?
+		WHERE id = ?
+	`,
+		d.Status, d.Attempts, utcTime(d.NextAttemptAt), database.NullInt(d.StatusCode, d.StatusCode != 0),
+		database.NullString(d.Error), database.NullString(d.Response), utcTime(d.DeliveredAt),
+		d.ID,
+	)
+	return err
+}
+
+// GetDelivery retrieves a delivery of a webhook by ID
+func (s *Store) GetDelivery(webhookID, id string) (*Delivery, error) {
+	return scanDelivery(s.db.QueryRow(`
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries WHERE webhook_id = ? AND id = ?
+	`, webhookID, id))
+}
+
+// DueDeliveries returns pending deliveries whose next attempt is due
+func (s *Store) DueDeliveries(now time.Time, limit int) ([]*Delivery, error) {
+	rows, err := s.db.Query(`
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at
+		LIMIT ?
+	`, DeliveryPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*Delivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// ListDeliveries returns one page of a webhook's deliveries matching the
+// filter and its metadata. Name globs are matched against the topic.
+func (s *Store) ListDeliveries(webhookID string, f listing.Filter, p listing.Params) ([]*Delivery, listing.Meta, error) {
+	where := []string{"webhook_id = ?"}
+	args := []interface{}{webhookID}
+
+	if len(f.Status) > 0 {
+		where = append(where, "status IN (?"+strings.Repeat(", ?", len(f.Status)-1)+")")
+		for _, status := range f.Status {
+			args = append(args, status)
+		}
+	}
+	if f.Name != "" {
+		where = append(where, "topic GLOB ?")
+		args = append(args, f.Name)
+	}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.CreatedAfter.UTC())
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.CreatedBefore.UTC())
+	}
+	clause := " WHERE " + strings.Join(where, " AND ")
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM webhook_deliveries"+clause, args...).Scan(&total); err != nil {
+		return nil, listing.Meta{}, err
+	}
+
+	column, ok := deliverySortColumns[p.Sort]
+	if !ok {
+		column = "created_at"
+	}
+	dir := "ASC"
+	if p.Desc() {
+		dir = "DESC"
+	}
+
+	if after, afterArgs := p.AfterClause(column, "id"); after != "" {
+		clause += " AND " + after
+		args = append(args, afterArgs...)
+	}
+	// One row more than the page tells whether there is a next page
+	limit := -1
+	if p.Limit > 0 {
+		limit = p.Limit + 1
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+deliveryColumns+`, `+column+`
+		FROM webhook_deliveries`+clause+`
+		ORDER BY `+column+` `+dir+`, id `+dir+`
+		LIMIT ?
+	`, append(args, limit)...)
+	if err != nil {
+		return nil, listing.Meta{}, err
+	}
+	defer rows.Close()
+
+	deliveries := []*Delivery{}
+	var next *listing.Position
+	var lastKey any
+	for rows.Next() {
+		if len(deliveries) == p.Limit {
+			next = &listing.Position{Key: lastKey, ID: deliveries[len(deliveries)-1].ID}
+			break
+		}
+		d, err := scanDelivery(keyScanner{rows, &lastKey})
+		if err != nil {
+			return nil, listing.Meta{}, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, p.Meta(total, len(deliveries), next), rows.Err()
+}
+
+// keyScanner scans the sort key selected after a row's columns
+type keyScanner struct {
+	rows *sql.Rows
+	key  *any
+}
+
+func (k keyScanner) Scan(dest ...interface{}) error {
+	return k.rows.Scan(append(dest, k.key)...)
+}
+
+// PruneDeliveries removes finished deliveries created before cutoff
+func (s *Store) PruneDeliveries(cutoff time.Time) (int64, error) {
+	res, err := s.db.Exec(`
+		DELETE FROM webhook_deliveries
+		WHERE status != ? AND created_at < ?
+	`, DeliveryPending, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanDelivery(row scanner) (*Delivery, error) {
+	d := &Delivery{}
+	var payload string
+	var nextAttemptAt, deliveredAt sql.NullTime
+	var statusCode sql.NullInt64
+	var errMsg, response, redeliveryOf sql.NullString
+
+	err := row.Scan(
+		&d.ID, &d.WebhookID, &d.EventID, &d.Topic, &payload, &d.Status, &d.Attempts,
+		&nextAttemptAt, &statusCode, &errMsg, &response, &redeliveryOf, &d.CreatedAt, &deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Payload = []byte(payload)
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	d.StatusCode = int(statusCode.Int64)
+	d.Error = errMsg.String
+	d.Response = response.String
+	d.RedeliveryOf = redeliveryOf.String
+	return d, nil
+}
+
+// utcTime converts an optional time to a UTC sql.NullTime
+func utcTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	utc := t.UTC()
+	return database.NullTime(&utc)
+}
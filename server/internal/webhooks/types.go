@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeliveryStatus represents the state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Webhook is an outbound subscription to server events
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // Topic patterns, e.g. "jobs.failed" or "downloads.*"
+	Active bool     `json:"active"`
+
+	// Secret signs payloads. It is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Delivery is one attempt series to deliver an event to a webhook
+type Delivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	EventID       uint64          `json:"event_id"`
+	Topic         string          `json:"topic"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	StatusCode    int             `json:"status_code,omitempty"` // HTTP status of the last attempt
+	Error         string          `json:"error,omitempty"`       // Error of the last attempt
+	Response      string          `json:"response,omitempty"`    // Truncated response body of the last attempt
+	RedeliveryOf  string          `json:"redelivery_of,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// CreateWebhookRequest represents a request to create a webhook
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"` // Generated when empty
+	Active *bool    `json:"active,omitempty"` // Default: true
+}
+
+// UpdateWebhookRequest represents a partial update of a webhook
+type UpdateWebhookRequest struct {
+	URL    *string  `json:"url,omitempty"`
+	Events []string `json:"events,omitempty"`
+	Secret *string  `json:"secret,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
@@ -193,6 +193,60 @@ func TestValidateToken(t *testing.T) {
 	})
 }
 
+func TestParseToken_Identity(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	svc, err := auth.NewService(&config.Config{
+		APIKey:       "test",
+		JWTPublicKey: exportRSAPublicKeyAsPEM(&privateKey.PublicKey),
+	})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub":    "viewer",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"topics": []string{"jobs", "downloads.state"},
+	})
+	tokenString, err := token.SignedString(privateKey)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	identity, err := svc.ParseToken(tokenString)
+	if err != nil {
+		t.Fatalf("ParseToken() error = %v", err)
+	}
+	if identity.Method != auth.MethodJWT || identity.Subject != "viewer" {
+		t.Errorf("identity = %+v, want jwt identity for viewer", identity)
+	}
+
+	allowed := map[string]bool{
+		"jobs.started":       true,
+		"downloads.state":    true,
+		"downloads.progress": false,
+		"terminal.opened":    false,
+	}
+	for topic, want := range allowed {
+		if got := identity.AllowsTopic(topic); got != want {
+			t.Errorf("AllowsTopic(%q) = %v, want %v", topic, got, want)
+		}
+	}
+
+	// API keys are not restricted
+	keyIdentity, err := svc.Authenticate("test", "")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if !keyIdentity.AllowsTopic("terminal.opened") {
+		t.Error("API key identity should receive all topics")
+	}
+}
+
 func TestHasAPIKey(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -0,0 +1,76 @@
+package batch_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/batch"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func TestConcurrency(t *testing.T) {
+	testutil.AssertEqual(t, batch.Concurrency(0), batch.DefaultConcurrency)
+	testutil.AssertEqual(t, batch.Concurrency(-3), batch.DefaultConcurrency)
+	testutil.AssertEqual(t, batch.Concurrency(2), 2)
+	testutil.AssertEqual(t, batch.Concurrency(1000), batch.MaxConcurrency)
+}
+
+func TestRun(t *testing.T) {
+	t.Run("per item errors in order", func(t *testing.T) {
+		errs := batch.Run(context.Background(), 5, 2, func(i int) error {
+			if i%2 == 1 {
+				return errors.New("odd")
+			}
+			return nil
+		})
+
+		testutil.AssertEqual(t, len(errs), 5)
+		for i, err := range errs {
+			if (err != nil) != (i%2 == 1) {
+				t.Errorf("item %d: err = %v", i, err)
+			}
+		}
+	})
+
+	t.Run("bounded concurrency", func(t *testing.T) {
+		var running, peak atomic.Int32
+		batch.Run(context.Background(), 20, 3, func(i int) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+
+		if peak.Load() > 3 {
+			t.Errorf("peak concurrency = %d, want <= 3", peak.Load())
+		}
+	})
+
+	t.Run("cancelled items are not run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var ran atomic.Int32
+		errs := batch.Run(ctx, 10, 1, func(i int) error {
+			ran.Add(1)
+			if i == 2 {
+				cancel()
+			}
+			return nil
+		})
+
+		if ran.Load() >= 10 {
+			t.Errorf("ran %d items after cancel", ran.Load())
+		}
+		if !errors.Is(errs[9], context.Canceled) {
+			t.Errorf("last item err = %v, want context.Canceled", errs[9])
+		}
+	})
+}
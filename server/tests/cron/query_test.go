@@ -0,0 +1,64 @@
+package cron_test
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/cron"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+// queryAll pages through jobs from q, calling between before each page
+// after the first, and returns the commands in the order listed
+func queryAll(t *testing.T, jobs func() []cron.CronJob, q url.Values, between func()) []string {
+	t.Helper()
+	var commands []string
+	for range 20 {
+		p, err := listing.ParseParams(q, cron.ListSpec)
+		testutil.AssertNoError(t, err)
+		page, meta := cron.Query(jobs(), listing.Filter{}, p)
+		for _, job := range page {
+			commands = append(commands, job.Command)
+		}
+		if meta.NextCursor == "" {
+			return commands
+		}
+		q = url.Values{"cursor": {meta.NextCursor}}
+		if between != nil {
+			between()
+		}
+	}
+	t.Fatal("paging did not end")
+	return nil
+}
+
+func TestQuery_Paging(t *testing.T) {
+	crontab := []cron.CronJob{
+		{Schedule: "0 * * * *", Command: "c", Source: "user"},
+		{Schedule: "5 * * * *", Command: "a", Source: "user"},
+		{Schedule: "@daily", Command: "b", Source: "system"},
+		{Schedule: "0 * * * *", Command: "a", Source: "etc", User: "root"},
+		{Schedule: "@reboot", Command: "d", Source: "user"},
+	}
+	jobs := func() []cron.CronJob { return append([]cron.CronJob(nil), crontab...) }
+
+	t.Run("crontab order", func(t *testing.T) {
+		got := queryAll(t, jobs, url.Values{"limit": {"2"}}, nil)
+		testutil.AssertEqual(t, fmt.Sprint(got), "[c a b a d]")
+	})
+
+	t.Run("sorted with equal keys", func(t *testing.T) {
+		got := queryAll(t, jobs, url.Values{"limit": {"2"}, "sort": {"schedule"}}, nil)
+		testutil.AssertEqual(t, fmt.Sprint(got), "[a c a b d]")
+	})
+
+	t.Run("jobs added between pages", func(t *testing.T) {
+		current := jobs()
+		got := queryAll(t, func() []cron.CronJob { return current }, url.Values{"limit": {"2"}}, func() {
+			current = append([]cron.CronJob{{Schedule: "@hourly", Command: "new", Source: "user"}}, current...)
+		})
+		testutil.AssertEqual(t, fmt.Sprint(got), "[c a b a d]")
+	})
+}
@@ -0,0 +1,118 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func receive(t *testing.T, sub *events.Subscription) events.Event {
+	t.Helper()
+	select {
+	case ev := <-sub.C():
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return events.Event{}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"*", "jobs.started", true},
+		{"jobs", "jobs.started", true},
+		{"jobs.*", "jobs.finished", true},
+		{"jobs.started", "jobs.started", true},
+		{"jobs.started", "jobs.finished", false},
+		{"job", "jobs.started", false},
+		{"downloads", "jobs.started", false},
+	}
+	for _, tt := range tests {
+		if got := events.Match(tt.pattern, tt.topic); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestBus_Subscribe(t *testing.T) {
+	t.Run("delivers matching topics only", func(t *testing.T) {
+		bus := events.NewBus(16)
+		sub, _, _ := bus.Subscribe([]string{"jobs"}, 0, nil)
+		defer sub.Close()
+
+		bus.Publish(events.TopicDownloadState, nil)
+		bus.Publish(events.TopicJobStarted, "job-1")
+
+		ev := receive(t, sub)
+		testutil.AssertEqual(t, ev.Topic, events.TopicJobStarted)
+		testutil.AssertEqual(t, ev.Data, "job-1")
+	})
+
+	t.Run("allow restricts delivery", func(t *testing.T) {
+		bus := events.NewBus(16)
+		sub, _, _ := bus.Subscribe(nil, 0, func(topic string) bool {
+			return topic != events.TopicTerminalOpened
+		})
+		defer sub.Close()
+
+		bus.Publish(events.TopicTerminalOpened, nil)
+		bus.Publish(events.TopicCronChanged, nil)
+
+		testutil.AssertEqual(t, receive(t, sub).Topic, events.TopicCronChanged)
+	})
+
+	t.Run("replays after last event id", func(t *testing.T) {
+		bus := events.NewBus(16)
+		bus.Publish(events.TopicJobStarted, 1)
+		first := bus.LastID()
+		bus.Publish(events.TopicFileChanged, 2)
+		bus.Publish(events.TopicJobFinished, 3)
+
+		sub, replay, truncated := bus.Subscribe([]string{"jobs"}, first, nil)
+		defer sub.Close()
+
+		testutil.AssertEqual(t, truncated, false)
+		testutil.AssertEqual(t, len(replay), 1)
+		testutil.AssertEqual(t, replay[0].Topic, events.TopicJobFinished)
+	})
+
+	t.Run("reports truncated history", func(t *testing.T) {
+		bus := events.NewBus(2)
+		bus.Publish(events.TopicJobStarted, 1)
+		first := bus.LastID()
+		for i := 0; i < 4; i++ {
+			bus.Publish(events.TopicJobStarted, i)
+		}
+
+		sub, replay, truncated := bus.Subscribe(nil, first, nil)
+		defer sub.Close()
+
+		testutil.AssertEqual(t, truncated, true)
+		testutil.AssertEqual(t, len(replay), 2)
+	})
+
+	t.Run("slow subscribers are dropped", func(t *testing.T) {
+		bus := events.NewBus(16)
+		sub, _, _ := bus.Subscribe(nil, 0, nil)
+
+		for i := 0; i < 1000; i++ {
+			bus.Publish(events.TopicFileChanged, i)
+		}
+		testutil.AssertEqual(t, bus.SubscriberCount(), 0)
+
+		// Drain; the channel must be closed
+		for range sub.C() {
+		}
+		sub.Close()
+	})
+}
+
+func TestBus_NilPublish(t *testing.T) {
+	var bus *events.Bus
+	bus.Publish(events.TopicJobStarted, nil) // must not panic
+}
@@ -0,0 +1,270 @@
+package files_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func newArchiveTest(t *testing.T, limits config.ArchiveConfig) (*files.Service, *files.Operations, string) {
+	t.Helper()
+	cfg := testutil.TestConfig(t)
+	tmpDir := testutil.TestTempDir(t)
+	cfg.AllowedPaths = []string{tmpDir}
+	cfg.Archive = limits
+
+	svc := files.NewService(cfg)
+	ops := files.NewOperations(svc)
+	t.Cleanup(ops.Shutdown)
+	return svc, ops, tmpDir
+}
+
+// extract unpacks archive into a fresh directory under tmpDir and waits for it
+func extract(t *testing.T, ops *files.Operations, archive, dest string) *files.Operation {
+	t.Helper()
+	testutil.AssertNoError(t, os.MkdirAll(dest, 0755))
+	op, err := ops.Start(files.OperationRequest{
+		Type:        files.OperationExtract,
+		Sources:     []string{archive},
+		Destination: dest,
+	})
+	testutil.AssertNoError(t, err)
+	return waitForOperation(t, ops, op.ID)
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	svc, ops, tmpDir := newArchiveTest(t, config.ArchiveConfig{})
+	testutil.AssertNoError(t, os.Symlink("file1.txt", filepath.Join(tmpDir, "dir1", "link")))
+	testutil.AssertNoError(t, os.Chmod(filepath.Join(tmpDir, "dir1", "file2.txt"), 0600))
+
+	for _, format := range []files.ArchiveFormat{files.ArchiveZip, files.ArchiveTar, files.ArchiveTarGz, files.ArchiveTarZst} {
+		t.Run(string(format), func(t *testing.T) {
+			archive, err := svc.NewArchive([]string{filepath.Join(tmpDir, "dir1"), filepath.Join(tmpDir, "test.txt")}, format)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, archive.Name(), "archive"+format.Ext())
+
+			var buf bytes.Buffer
+			testutil.AssertNoError(t, archive.Write(context.Background(), &buf))
+			path := filepath.Join(tmpDir, "out"+format.Ext())
+			testutil.AssertNoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+			dest := filepath.Join(tmpDir, "extract-"+string(format))
+			op := extract(t, ops, path, dest)
+			testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+			testutil.AssertEqual(t, op.Error, "")
+
+			testutil.AssertEqual(t, readFile(t, filepath.Join(dest, "dir1", "file1.txt")), "file1 content")
+			testutil.AssertEqual(t, readFile(t, filepath.Join(dest, "test.txt")), "test content")
+
+			info, err := os.Stat(filepath.Join(dest, "dir1", "file2.txt"))
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, info.Mode().Perm(), os.FileMode(0600))
+
+			link, err := os.Readlink(filepath.Join(dest, "dir1", "link"))
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, link, "file1.txt")
+		})
+	}
+}
+
+func TestArchive_Validation(t *testing.T) {
+	svc, ops, tmpDir := newArchiveTest(t, config.ArchiveConfig{})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := files.ParseArchiveFormat("rar")
+		if !errors.Is(err, files.ErrUnsupportedArchive) {
+			t.Errorf("err = %v, want ErrUnsupportedArchive", err)
+		}
+	})
+
+	t.Run("path outside allowed paths", func(t *testing.T) {
+		_, err := svc.NewArchive([]string{"/etc"}, files.ArchiveZip)
+		if !errors.Is(err, files.ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+	})
+
+	t.Run("extract non-archive", func(t *testing.T) {
+		_, err := ops.Start(files.OperationRequest{
+			Type:        files.OperationExtract,
+			Sources:     []string{filepath.Join(tmpDir, "test.txt")},
+			Destination: tmpDir,
+		})
+		if !errors.Is(err, files.ErrUnsupportedArchive) {
+			t.Errorf("err = %v, want ErrUnsupportedArchive", err)
+		}
+	})
+}
+
+type tarEntry struct {
+	hdr  tar.Header
+	body string
+}
+
+func writeTar(t *testing.T, path string, entries ...tarEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		e.hdr.Size = int64(len(e.body))
+		if e.hdr.Mode == 0 {
+			e.hdr.Mode = 0644
+		}
+		testutil.AssertNoError(t, tw.WriteHeader(&e.hdr))
+		_, err := tw.Write([]byte(e.body))
+		testutil.AssertNoError(t, err)
+	}
+	testutil.AssertNoError(t, tw.Close())
+	testutil.AssertNoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestArchive_ExtractUnsafe(t *testing.T) {
+	_, ops, tmpDir := newArchiveTest(t, config.ArchiveConfig{})
+	outside := filepath.Join(tmpDir, "outside")
+	testutil.AssertNoError(t, os.Mkdir(outside, 0755))
+
+	assertUnsafe := func(t *testing.T, op *files.Operation) {
+		t.Helper()
+		testutil.AssertEqual(t, op.Status, files.OperationFailed)
+		if !strings.Contains(op.Error, "outside the destination") {
+			t.Errorf("error = %q, want unsafe archive error", op.Error)
+		}
+		entries, err := os.ReadDir(outside)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, len(entries), 0)
+	}
+
+	t.Run("zip slip", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create("../outside/evil.txt")
+		testutil.AssertNoError(t, err)
+		w.Write([]byte("evil"))
+		testutil.AssertNoError(t, zw.Close())
+		path := filepath.Join(tmpDir, "slip.zip")
+		testutil.AssertNoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+		assertUnsafe(t, extract(t, ops, path, filepath.Join(tmpDir, "x1")))
+	})
+
+	t.Run("absolute path", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "abs.tar")
+		writeTar(t, path, tarEntry{tar.Header{Name: filepath.Join(outside, "evil.txt"), Typeflag: tar.TypeReg}, "evil"})
+
+		assertUnsafe(t, extract(t, ops, path, filepath.Join(tmpDir, "x2")))
+	})
+
+	t.Run("symlink escape", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "link.tar")
+		writeTar(t, path,
+			tarEntry{tar.Header{Name: "out", Typeflag: tar.TypeSymlink, Linkname: "../outside"}, ""},
+			tarEntry{tar.Header{Name: "out/evil.txt", Typeflag: tar.TypeReg}, "evil"},
+		)
+
+		assertUnsafe(t, extract(t, ops, path, filepath.Join(tmpDir, "x3")))
+	})
+
+	t.Run("chained symlink escape", func(t *testing.T) {
+		// Each link looks inside the destination as text; a/.. is not
+		path := filepath.Join(tmpDir, "chain.tar")
+		writeTar(t, path,
+			tarEntry{tar.Header{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "."}, ""},
+			tarEntry{tar.Header{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "b/.."}, ""},
+			tarEntry{tar.Header{Name: "e", Typeflag: tar.TypeSymlink, Linkname: "a/outside"}, ""},
+		)
+		dest := filepath.Join(tmpDir, "x7")
+
+		assertUnsafe(t, extract(t, ops, path, dest))
+		if _, err := os.Lstat(filepath.Join(dest, "e")); !os.IsNotExist(err) {
+			t.Errorf("e was created: %v", err)
+		}
+	})
+
+	t.Run("symlink up through a later link", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "later.tar")
+		writeTar(t, path,
+			tarEntry{tar.Header{Name: "e", Typeflag: tar.TypeSymlink, Linkname: "x/../outside"}, ""},
+			tarEntry{tar.Header{Name: "x", Typeflag: tar.TypeSymlink, Linkname: "."}, ""},
+		)
+
+		assertUnsafe(t, extract(t, ops, path, filepath.Join(tmpDir, "x8")))
+	})
+
+	t.Run("write through existing symlink", func(t *testing.T) {
+		dest := filepath.Join(tmpDir, "x4")
+		testutil.AssertNoError(t, os.Mkdir(dest, 0755))
+		testutil.AssertNoError(t, os.Symlink(outside, filepath.Join(dest, "out")))
+		path := filepath.Join(tmpDir, "through.tar")
+		writeTar(t, path, tarEntry{tar.Header{Name: "out/sub/evil.txt", Typeflag: tar.TypeReg}, "evil"})
+
+		assertUnsafe(t, extract(t, ops, path, dest))
+	})
+
+	t.Run("hard link escape", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "hard.tar")
+		writeTar(t, path, tarEntry{tar.Header{Name: "passwd", Typeflag: tar.TypeLink, Linkname: "../test.txt"}, ""})
+
+		assertUnsafe(t, extract(t, ops, path, filepath.Join(tmpDir, "x5")))
+	})
+
+	t.Run("symlinks inside the destination are kept", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "ok.tar")
+		writeTar(t, path,
+			tarEntry{tar.Header{Name: "a/file.txt", Typeflag: tar.TypeReg}, "data"},
+			tarEntry{tar.Header{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "a"}, ""},
+			tarEntry{tar.Header{Name: "b/new.txt", Typeflag: tar.TypeReg}, "new"},
+		)
+		dest := filepath.Join(tmpDir, "x6")
+
+		op := extract(t, ops, path, dest)
+		testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+		testutil.AssertEqual(t, readFile(t, filepath.Join(dest, "a", "new.txt")), "new")
+	})
+}
+
+func TestArchive_ExtractLimits(t *testing.T) {
+	t.Run("file count", func(t *testing.T) {
+		_, ops, tmpDir := newArchiveTest(t, config.ArchiveConfig{MaxExtractFiles: 2})
+		path := filepath.Join(tmpDir, "many.tar")
+		writeTar(t, path,
+			tarEntry{tar.Header{Name: "a", Typeflag: tar.TypeReg}, "a"},
+			tarEntry{tar.Header{Name: "b", Typeflag: tar.TypeReg}, "b"},
+			tarEntry{tar.Header{Name: "c", Typeflag: tar.TypeReg}, "c"},
+		)
+
+		op := extract(t, ops, path, filepath.Join(tmpDir, "dest"))
+		testutil.AssertEqual(t, op.Status, files.OperationFailed)
+		if !strings.Contains(op.Error, "limits") {
+			t.Errorf("error = %q, want limit error", op.Error)
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		_, ops, tmpDir := newArchiveTest(t, config.ArchiveConfig{MaxExtractSize: 1024})
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create("bomb.txt")
+		testutil.AssertNoError(t, err)
+		w.Write(bytes.Repeat([]byte{0}, 1024*1024))
+		testutil.AssertNoError(t, zw.Close())
+		path := filepath.Join(tmpDir, "bomb.zip")
+		testutil.AssertNoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+		dest := filepath.Join(tmpDir, "dest")
+		op := extract(t, ops, path, dest)
+		testutil.AssertEqual(t, op.Status, files.OperationFailed)
+		if _, err := os.Stat(filepath.Join(dest, "bomb.txt")); !os.IsNotExist(err) {
+			t.Error("partial file left behind")
+		}
+	})
+}
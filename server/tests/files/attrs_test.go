@@ -0,0 +1,158 @@
+package files_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func listEntries(t *testing.T, svc *files.Service, dir string) map[string]files.FileEntry {
+	t.Helper()
+	resp, err := svc.ListWithContext(context.Background(), dir, listing.Filter{MinSize: -1, MaxSize: -1}, listing.Params{})
+	testutil.AssertNoError(t, err)
+	entries := make(map[string]files.FileEntry)
+	for _, e := range resp.Entries {
+		entries[e.Name] = e
+	}
+	return entries
+}
+
+func TestService_ListOwnership(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	testutil.AssertNoError(t, os.Symlink("test.txt", filepath.Join(tmpDir, "link.txt")))
+
+	entries := listEntries(t, svc, tmpDir)
+	file := entries["test.txt"]
+	testutil.AssertEqual(t, file.UID, uint32(os.Getuid()))
+	testutil.AssertEqual(t, file.GID, uint32(os.Getgid()))
+	testutil.AssertEqual(t, file.Links, uint64(1))
+	if file.Inode == 0 {
+		t.Error("inode not set")
+	}
+	testutil.AssertEqual(t, file.LinkTarget, "")
+	testutil.AssertEqual(t, entries["link.txt"].LinkTarget, "test.txt")
+}
+
+func TestService_ChmodRecursive(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	dir := filepath.Join(tmpDir, "dir1")
+
+	n, err := svc.ChmodRecursive(dir, 0600, 0700)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, n, 3)
+
+	info, err := os.Stat(dir)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, info.Mode().Perm(), os.FileMode(0700))
+	info, err = os.Stat(filepath.Join(dir, "file1.txt"))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, info.Mode().Perm(), os.FileMode(0600))
+}
+
+func TestService_Chown(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	path := filepath.Join(tmpDir, "test.txt")
+
+	// Changing to the current owner is always permitted
+	testutil.AssertNoError(t, svc.Chown(path, os.Getuid(), -1))
+	n, err := svc.ChownRecursive(filepath.Join(tmpDir, "dir1"), -1, os.Getgid())
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, n, 3)
+
+	if _, err := files.ParseOwner("no-such-user-gloski"); !errors.Is(err, files.ErrUnknownOwner) {
+		t.Errorf("err = %v, want ErrUnknownOwner", err)
+	}
+}
+
+func TestService_Links(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+
+	t.Run("symlink", func(t *testing.T) {
+		link := filepath.Join(tmpDir, "dir2", "test-link")
+		testutil.AssertNoError(t, svc.Symlink("../test.txt", link))
+		testutil.AssertEqual(t, readFile(t, link), "test content")
+
+		if err := svc.Symlink("test.txt", link); !errors.Is(err, files.ErrPathExists) {
+			t.Errorf("err = %v, want ErrPathExists", err)
+		}
+	})
+
+	t.Run("symlink outside allowed paths", func(t *testing.T) {
+		err := svc.Symlink("/etc/passwd", filepath.Join(tmpDir, "passwd"))
+		if !errors.Is(err, files.ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+	})
+
+	t.Run("hard link", func(t *testing.T) {
+		testutil.AssertNoError(t, svc.Link(filepath.Join(tmpDir, "dir1", "file1.txt"), filepath.Join(tmpDir, "hard.txt")))
+		testutil.AssertEqual(t, listEntries(t, svc, tmpDir)["hard.txt"].Links, uint64(2))
+
+		if err := svc.Link(filepath.Join(tmpDir, "dir1"), filepath.Join(tmpDir, "dir-link")); !errors.Is(err, files.ErrIsDirectory) {
+			t.Errorf("err = %v, want ErrIsDirectory", err)
+		}
+	})
+}
+
+func TestService_Xattrs(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	path := filepath.Join(tmpDir, "test.txt")
+
+	err := svc.SetXattr(path, "user.gloski.comment", []byte("hello"))
+	if errors.Is(err, files.ErrXattrUnsupported) {
+		t.Skip("filesystem has no user extended attributes")
+	}
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, svc.SetXattr(path, "user.gloski.raw", []byte{0xff, 0x00}))
+
+	attrs, err := svc.ListXattrs(path)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, len(attrs), 2)
+	testutil.AssertEqual(t, attrs[0], files.Xattr{Name: "user.gloski.comment", Value: "hello", Encoding: "utf8"})
+	testutil.AssertEqual(t, attrs[1], files.Xattr{Name: "user.gloski.raw", Value: "/wA=", Encoding: "base64"})
+
+	testutil.AssertNoError(t, svc.RemoveXattr(path, "user.gloski.raw"))
+	if err := svc.RemoveXattr(path, "user.gloski.raw"); !errors.Is(err, files.ErrXattrNotFound) {
+		t.Errorf("err = %v, want ErrXattrNotFound", err)
+	}
+}
+
+func TestService_ACL(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	path := filepath.Join(tmpDir, "test.txt")
+	testutil.AssertNoError(t, os.Chmod(path, 0640))
+
+	acl, err := svc.GetACL(path)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, len(acl.Access), 3)
+	testutil.AssertEqual(t, acl.Access[0], "user::rw-")
+	testutil.AssertEqual(t, acl.Access[1], "group::r--")
+	testutil.AssertEqual(t, acl.Access[2], "other::---")
+
+	t.Run("rejects incomplete ACL", func(t *testing.T) {
+		access := []string{"user::rw-", "other::---"}
+		if err := svc.SetACL(path, &access, nil); err == nil {
+			t.Error("expected error for ACL without group entry")
+		}
+	})
+
+	t.Run("named entry gets a mask", func(t *testing.T) {
+		access := []string{"user::rw-", "group::r--", "other::---", "user:0:r-x"}
+		err := svc.SetACL(path, &access, nil)
+		if errors.Is(err, files.ErrXattrUnsupported) {
+			t.Skip("filesystem has no POSIX ACLs")
+		}
+		testutil.AssertNoError(t, err)
+
+		acl, err := svc.GetACL(path)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, len(acl.Access), 5)
+		testutil.AssertEqual(t, acl.Access[3], "mask::r-x")
+	})
+}
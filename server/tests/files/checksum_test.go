@@ -0,0 +1,157 @@
+package files_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+const (
+	testContentSHA256 = "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+	file1SHA256       = "1705789d380ee110bc09231df8af42a0cc564a1510ebd2168516d4985c40a263"
+	file2SHA256       = "69d21bef329d53a0f62bf6f19faab99eab3dac4d53e43fbbb257fee4d14f60ab"
+)
+
+func TestService_ChecksumFile(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	path := filepath.Join(tmpDir, "test.txt")
+
+	tests := map[string]string{
+		files.ChecksumMD5:    "9473fdd0d880a43c21b7778d34872157",
+		files.ChecksumSHA1:   "1eebdf4fdc9fc7bf283031b93f9aef3338de9052",
+		files.ChecksumSHA256: testContentSHA256,
+		files.ChecksumSHA512: "0cbf4caef38047bba9a24e621a961484e5d2a92176a859e7eb27df343dd34eb98d538a6c5f4da1ce302ec250b821cc001e46cc97a704988297185a4df7e99602",
+	}
+	for algorithm, want := range tests {
+		t.Run(algorithm, func(t *testing.T) {
+			result, err := svc.Checksum(context.Background(), path, algorithm)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, result.Type, "file")
+			testutil.AssertEqual(t, result.Hash, want)
+			testutil.AssertEqual(t, result.Size, int64(len("test content")))
+		})
+	}
+
+	t.Run("blake3", func(t *testing.T) {
+		empty := filepath.Join(tmpDir, "empty")
+		testutil.AssertNoError(t, os.WriteFile(empty, nil, 0644))
+		result, err := svc.Checksum(context.Background(), empty, files.ChecksumBLAKE3)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Hash, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262")
+	})
+
+	t.Run("invalid algorithm", func(t *testing.T) {
+		_, err := svc.Checksum(context.Background(), path, "crc32")
+		if !errors.Is(err, files.ErrInvalidAlgorithm) {
+			t.Errorf("err = %v, want ErrInvalidAlgorithm", err)
+		}
+	})
+}
+
+func TestService_ChecksumDirectory(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	dir := filepath.Join(tmpDir, "dir1")
+	testutil.AssertNoError(t, os.Symlink("file1.txt", filepath.Join(dir, "link")))
+
+	result, err := svc.Checksum(context.Background(), dir, "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, result.Type, "directory")
+	testutil.AssertEqual(t, result.Algorithm, files.ChecksumSHA256)
+	testutil.AssertEqual(t, len(result.Files), 2)
+
+	manifest := file1SHA256 + "  file1.txt\n" + file2SHA256 + "  file2.txt\n"
+	testutil.AssertEqual(t, result.Manifest, manifest)
+	sum := sha256.Sum256([]byte(manifest))
+	testutil.AssertEqual(t, result.Hash, hex.EncodeToString(sum[:]))
+}
+
+func TestService_VerifyChecksums(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+
+	t.Run("sums", func(t *testing.T) {
+		sums := "# release checksums\n" +
+			testContentSHA256 + "  test.txt\n" +
+			file1SHA256 + " *dir1/file1.txt\n" +
+			file1SHA256 + "  dir1/file2.txt\n" +
+			"SHA256 (gone.txt) = " + testContentSHA256 + "\n" +
+			"not a checksum\n"
+		result, err := svc.VerifyChecksums(context.Background(), files.VerifyRequest{Sums: sums, Base: tmpDir})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Algorithm, files.ChecksumSHA256)
+		testutil.AssertEqual(t, result.OK, false)
+		testutil.AssertEqual(t, result.Passed, 2)
+		testutil.AssertEqual(t, result.Failed, 1)
+		testutil.AssertEqual(t, result.Missing, 1)
+		testutil.AssertEqual(t, result.Malformed, 1)
+		testutil.AssertEqual(t, result.Files[2].Status, files.VerifyFailed)
+		testutil.AssertEqual(t, result.Files[2].Actual, file2SHA256)
+		testutil.AssertEqual(t, result.Files[3].Status, files.VerifyMissing)
+	})
+
+	t.Run("sums file", func(t *testing.T) {
+		sumsPath := filepath.Join(tmpDir, "dir1", "MD5SUMS")
+		content := "bd2a3a2d4d34fb5a3b3b3e5a5ae3c3cd  file1.txt\n"
+		testutil.AssertNoError(t, os.WriteFile(sumsPath, []byte(content), 0644))
+
+		result, err := svc.VerifyChecksums(context.Background(), files.VerifyRequest{SumsPath: sumsPath})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Algorithm, files.ChecksumMD5)
+		testutil.AssertEqual(t, result.Files[0].Status, files.VerifyFailed)
+		testutil.AssertEqual(t, len(result.Files[0].Actual), 32)
+	})
+
+	t.Run("no checksums", func(t *testing.T) {
+		_, err := svc.VerifyChecksums(context.Background(), files.VerifyRequest{Sums: "\n# empty\n", Base: tmpDir})
+		if !errors.Is(err, files.ErrInvalidChecksums) {
+			t.Errorf("err = %v, want ErrInvalidChecksums", err)
+		}
+	})
+}
+
+func TestOperations_Checksum(t *testing.T) {
+	ops, tmpDir := newTestOperations(t)
+
+	t.Run("small requests return the result", func(t *testing.T) {
+		result, op, err := ops.Checksum(context.Background(), files.ChecksumRequest{Path: filepath.Join(tmpDir, "test.txt")})
+		testutil.AssertNoError(t, err)
+		if op != nil {
+			t.Fatal("started an operation for a small file")
+		}
+		testutil.AssertEqual(t, result.Hash, testContentSHA256)
+	})
+
+	t.Run("background", func(t *testing.T) {
+		_, op, err := ops.Checksum(context.Background(), files.ChecksumRequest{
+			Path:       filepath.Join(tmpDir, "dir1"),
+			Background: true,
+		})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, op.Type, files.OperationChecksum)
+		testutil.AssertEqual(t, op.TotalFiles, 2)
+
+		op = waitForOperation(t, ops, op.ID)
+		testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+		testutil.AssertEqual(t, op.FilesDone, 2)
+		testutil.AssertEqual(t, op.BytesDone, op.TotalBytes)
+		testutil.AssertEqual(t, len(op.Result.(*files.ChecksumResult).Files), 2)
+	})
+
+	t.Run("verify in the background", func(t *testing.T) {
+		_, op, err := ops.Verify(context.Background(), files.VerifyRequest{
+			Sums:       testContentSHA256 + "  test.txt\n",
+			Base:       tmpDir,
+			Background: true,
+		})
+		testutil.AssertNoError(t, err)
+		op = waitForOperation(t, ops, op.ID)
+		testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+		testutil.AssertEqual(t, op.Result.(*files.VerifyResult).OK, true)
+	})
+}
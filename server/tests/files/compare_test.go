@@ -0,0 +1,140 @@
+package files_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func TestService_DiffFiles(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	oldPath := filepath.Join(tmpDir, "old.conf")
+	newPath := filepath.Join(tmpDir, "new.conf")
+	testutil.AssertNoError(t, os.WriteFile(oldPath, []byte("listen 80;\nroot /srv;\n"), 0644))
+	testutil.AssertNoError(t, os.WriteFile(newPath, []byte("listen  443;\nroot /srv;  \n"), 0644))
+
+	t.Run("unified diff", func(t *testing.T) {
+		result, err := svc.Diff(context.Background(), oldPath, newPath, files.DiffOptions{Context: 3})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Type, "file")
+		testutil.AssertEqual(t, result.Identical, false)
+		testutil.AssertContains(t, result.Diff, "--- "+oldPath+"\n+++ "+newPath+"\n@@ -1,2 +1,2 @@\n")
+		testutil.AssertContains(t, result.Diff, "-listen 80;\n-root /srv;\n+listen  443;\n+root /srv;  \n")
+	})
+
+	t.Run("ignore space change", func(t *testing.T) {
+		result, err := svc.Diff(context.Background(), oldPath, newPath, files.DiffOptions{IgnoreSpaceChange: true})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Diff, "--- "+oldPath+"\n+++ "+newPath+"\n@@ -1 +1 @@\n-listen 80;\n+listen  443;\n")
+	})
+
+	t.Run("ignore all whitespace", func(t *testing.T) {
+		testutil.AssertNoError(t, os.WriteFile(newPath, []byte("listen 80 ;\n\troot /srv;"), 0644))
+		result, err := svc.Diff(context.Background(), oldPath, newPath, files.DiffOptions{IgnoreWhitespace: true})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Identical, true)
+		testutil.AssertEqual(t, result.Diff, "")
+	})
+
+	t.Run("binary files", func(t *testing.T) {
+		bin := filepath.Join(tmpDir, "a.bin")
+		testutil.AssertNoError(t, os.WriteFile(bin, []byte{0, 1, 2}, 0644))
+		result, err := svc.Diff(context.Background(), bin, oldPath, files.DiffOptions{})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Binary, true)
+		testutil.AssertEqual(t, result.Identical, false)
+
+		result, err = svc.Diff(context.Background(), bin, bin, files.DiffOptions{})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Identical, true)
+	})
+
+	t.Run("file against directory", func(t *testing.T) {
+		_, err := svc.Diff(context.Background(), oldPath, tmpDir, files.DiffOptions{})
+		if !errors.Is(err, files.ErrDiffMismatch) {
+			t.Errorf("err = %v, want ErrDiffMismatch", err)
+		}
+	})
+
+	t.Run("path outside allowed paths", func(t *testing.T) {
+		_, err := svc.Diff(context.Background(), oldPath, "/etc/hostname", files.DiffOptions{})
+		if !errors.Is(err, files.ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+	})
+}
+
+func TestService_DiffDirectories(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	oldDir := filepath.Join(tmpDir, "release-1")
+	newDir := filepath.Join(tmpDir, "release-2")
+	write := func(path, content string) {
+		t.Helper()
+		testutil.AssertNoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		testutil.AssertNoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write(filepath.Join(oldDir, "same.txt"), "same")
+	write(filepath.Join(newDir, "same.txt"), "same")
+	write(filepath.Join(oldDir, "lib", "resized.txt"), "short")
+	write(filepath.Join(newDir, "lib", "resized.txt"), "longer")
+	write(filepath.Join(oldDir, "lib", "edited.txt"), "aaaa")
+	write(filepath.Join(newDir, "lib", "edited.txt"), "bbbb")
+	write(filepath.Join(oldDir, "removed.txt"), "gone")
+	write(filepath.Join(newDir, "assets", "added.txt"), "new")
+
+	// Only edited.txt, which keeps its size, has a different mtime
+	past := time.Now().Add(-time.Hour)
+	for _, dir := range []string{oldDir, newDir} {
+		testutil.AssertNoError(t, os.Chtimes(filepath.Join(dir, "same.txt"), past, past))
+	}
+	testutil.AssertNoError(t, os.Chtimes(filepath.Join(oldDir, "lib", "edited.txt"), past, past))
+
+	statuses := func(result *files.DiffResult) map[string]string {
+		m := map[string]string{}
+		for _, e := range result.Entries {
+			m[e.Path] = e.Status
+		}
+		return m
+	}
+
+	t.Run("by size", func(t *testing.T) {
+		result, err := svc.Diff(context.Background(), oldDir, newDir, files.DiffOptions{})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Type, "directory")
+		got := statuses(result)
+		testutil.AssertEqual(t, len(got), 3)
+		testutil.AssertEqual(t, got["assets"], files.DiffAdded)
+		testutil.AssertEqual(t, got["removed.txt"], files.DiffRemoved)
+		testutil.AssertEqual(t, got[filepath.Join("lib", "resized.txt")], files.DiffChanged)
+		testutil.AssertEqual(t, *result.Summary, files.TreeDiffSummary{Added: 1, Removed: 1, Changed: 1, Unchanged: 2})
+	})
+
+	for _, compare := range []string{files.CompareMtime, files.CompareHash} {
+		t.Run("by "+compare, func(t *testing.T) {
+			result, err := svc.Diff(context.Background(), oldDir, newDir, files.DiffOptions{Compare: compare})
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, statuses(result)[filepath.Join("lib", "edited.txt")], files.DiffChanged)
+			testutil.AssertEqual(t, result.Summary.Changed, 2)
+		})
+	}
+
+	t.Run("identical trees", func(t *testing.T) {
+		result, err := svc.Diff(context.Background(), oldDir, oldDir, files.DiffOptions{Compare: files.CompareHash})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Identical, true)
+		testutil.AssertEqual(t, len(result.Entries), 0)
+	})
+
+	t.Run("invalid compare", func(t *testing.T) {
+		_, err := svc.Diff(context.Background(), oldDir, newDir, files.DiffOptions{Compare: "bogus"})
+		if !errors.Is(err, files.ErrInvalidCompare) {
+			t.Errorf("err = %v, want ErrInvalidCompare", err)
+		}
+	})
+}
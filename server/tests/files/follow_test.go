@@ -0,0 +1,207 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+// nextFollowEvent waits for the next event of a follow
+func nextFollowEvent(t *testing.T, f *files.Follow) files.FollowEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-f.Events():
+		if !ok {
+			t.Fatalf("follow stopped: %v", f.Err())
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a follow event")
+	}
+	return files.FollowEvent{}
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	testutil.AssertNoError(t, err)
+	_, err = f.WriteString(data)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, f.Close())
+}
+
+func TestService_Follow(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+
+	t.Run("last lines then appended lines", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "app.log")
+		writeLines(t, path, 20, true)
+
+		f, err := svc.Follow(files.FollowRequest{Path: path, Lines: 2})
+		testutil.AssertNoError(t, err)
+		defer f.Close()
+
+		ev := nextFollowEvent(t, f)
+		testutil.AssertEqual(t, ev.Type, files.FollowLines)
+		testutil.AssertEqual(t, strings.Join(ev.Lines, ","), "line 19,line 20")
+
+		// A line is only sent once it ends
+		appendFile(t, path, "line 21\nline ")
+		ev = nextFollowEvent(t, f)
+		testutil.AssertEqual(t, strings.Join(ev.Lines, ","), "line 21")
+		appendFile(t, path, "22\r\n")
+		ev = nextFollowEvent(t, f)
+		testutil.AssertEqual(t, strings.Join(ev.Lines, ","), "line 22")
+
+		info, err := os.Stat(path)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, ev.Offset, info.Size())
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "filtered.log")
+		testutil.AssertNoError(t, os.WriteFile(path, []byte("INFO a\nERROR b\nINFO c\n"), 0644))
+
+		f, err := svc.Follow(files.FollowRequest{Path: path, Lines: 3, Filter: "^ERROR"})
+		testutil.AssertNoError(t, err)
+		defer f.Close()
+
+		ev := nextFollowEvent(t, f)
+		testutil.AssertEqual(t, strings.Join(ev.Lines, ","), "ERROR b")
+		appendFile(t, path, "INFO d\nERROR e\n")
+		ev = nextFollowEvent(t, f)
+		testutil.AssertEqual(t, strings.Join(ev.Lines, ","), "ERROR e")
+	})
+
+	t.Run("truncation", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "truncated.log")
+		writeLines(t, path, 5, true)
+
+		f, err := svc.Follow(files.FollowRequest{Path: path})
+		testutil.AssertNoError(t, err)
+		defer f.Close()
+
+		testutil.AssertNoError(t, os.Truncate(path, 0))
+		ev := nextFollowEvent(t, f)
+		testutil.AssertEqual(t, ev.Type, files.FollowTruncated)
+
+		appendFile(t, path, "fresh\n")
+		ev = nextFollowEvent(t, f)
+		testutil.AssertEqual(t, strings.Join(ev.Lines, ","), "fresh")
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "rotated.log")
+		writeLines(t, path, 1, true)
+
+		f, err := svc.Follow(files.FollowRequest{Path: path})
+		testutil.AssertNoError(t, err)
+		defer f.Close()
+
+		// Lines written just before the rename are still read
+		appendFile(t, path, "last old")
+		testutil.AssertNoError(t, os.Rename(path, path+".1"))
+		testutil.AssertNoError(t, os.WriteFile(path, []byte("first new\n"), 0644))
+
+		ev := nextFollowEvent(t, f)
+		testutil.AssertEqual(t, strings.Join(ev.Lines, ","), "last old")
+		ev = nextFollowEvent(t, f)
+		testutil.AssertEqual(t, ev.Type, files.FollowRotated)
+		ev = nextFollowEvent(t, f)
+		testutil.AssertEqual(t, strings.Join(ev.Lines, ","), "first new")
+	})
+
+	t.Run("rotation to a link out of the allowed paths", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "swapped.log")
+		writeLines(t, path, 1, true)
+		secret := filepath.Join(t.TempDir(), "secret")
+		testutil.AssertNoError(t, os.WriteFile(secret, []byte("secret\n"), 0644))
+
+		f, err := svc.Follow(files.FollowRequest{Path: path})
+		testutil.AssertNoError(t, err)
+		defer f.Close()
+
+		appendFile(t, path, "last\n")
+		testutil.AssertNoError(t, os.Remove(path))
+		testutil.AssertNoError(t, os.Symlink(secret, path))
+
+		// What was left in the old file is still sent, the new one never
+		var lines []string
+		timeout := time.After(5 * time.Second)
+	loop:
+		for {
+			select {
+			case ev, ok := <-f.Events():
+				if !ok {
+					break loop
+				}
+				lines = append(lines, ev.Lines...)
+			case <-timeout:
+				t.Fatal("follow did not stop")
+			}
+		}
+		testutil.AssertEqual(t, strings.Join(lines, ","), "last")
+		if !errors.Is(f.Err(), files.ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", f.Err())
+		}
+	})
+
+	t.Run("slow client skips ahead", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "busy.log")
+		testutil.AssertNoError(t, os.WriteFile(path, nil, 0644))
+
+		f, err := svc.Follow(files.FollowRequest{Path: path})
+		testutil.AssertNoError(t, err)
+		defer f.Close()
+
+		line := strings.Repeat("x", 99) + "\n"
+		appendFile(t, path, strings.Repeat(line, 2*files.MaxFollowLag/len(line)))
+
+		// A batch or two may have been read before the file grew past the lag
+		ev := nextFollowEvent(t, f)
+		for ev.Type == files.FollowLines {
+			ev = nextFollowEvent(t, f)
+		}
+		testutil.AssertEqual(t, ev.Type, files.FollowSkipped)
+		if ev.Skipped <= 0 {
+			t.Errorf("skipped = %d, want > 0", ev.Skipped)
+		}
+		ev = nextFollowEvent(t, f)
+		testutil.AssertEqual(t, ev.Type, files.FollowLines)
+		testutil.AssertEqual(t, ev.Lines[0], strings.TrimSuffix(line, "\n"))
+	})
+
+	t.Run("close stops events", func(t *testing.T) {
+		f, err := svc.Follow(files.FollowRequest{Path: filepath.Join(tmpDir, "test.txt")})
+		testutil.AssertNoError(t, err)
+		f.Close()
+		if _, ok := <-f.Events(); ok {
+			t.Error("events still open after Close")
+		}
+		testutil.AssertNoError(t, f.Err())
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "test.txt")
+		for name, req := range map[string]files.FollowRequest{
+			"bad filter":     {Path: path, Filter: "("},
+			"too many lines": {Path: path, Lines: files.MaxRangeLines + 1},
+		} {
+			if _, err := svc.Follow(req); !errors.Is(err, files.ErrInvalidFollow) {
+				t.Errorf("%s: err = %v, want ErrInvalidFollow", name, err)
+			}
+		}
+		if _, err := svc.Follow(files.FollowRequest{Path: filepath.Join(tmpDir, "dir1")}); !errors.Is(err, files.ErrIsDirectory) {
+			t.Errorf("directory: err = %v, want ErrIsDirectory", err)
+		}
+		if _, err := svc.Follow(files.FollowRequest{Path: "/etc/passwd"}); err == nil {
+			t.Error("path outside allowed paths: want error")
+		}
+	})
+}
@@ -0,0 +1,263 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+// newTestHistory returns a files service that snapshots into a temp history
+func newTestHistory(t *testing.T, limits config.HistoryConfig) (*files.Service, *files.History, string, string) {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	svc, tmpDir := newTestService(t)
+	historyDir := t.TempDir()
+	history := files.NewHistory(svc, db, historyDir, limits)
+	svc.SetHistory(history)
+	return svc, history, tmpDir, historyDir
+}
+
+func listVersions(t *testing.T, history *files.History, path string) []*files.FileVersion {
+	t.Helper()
+	versions, err := history.List(path)
+	testutil.AssertNoError(t, err)
+	return versions
+}
+
+func countObjects(t *testing.T, historyDir string) int {
+	t.Helper()
+	n := 0
+	filepath.WalkDir(filepath.Join(historyDir, "objects"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+func TestHistory_SnapshotOnWrite(t *testing.T) {
+	svc, history, tmpDir, historyDir := newTestHistory(t, config.HistoryConfig{MaxVersions: 10})
+	path := filepath.Join(tmpDir, "test.txt")
+
+	_, err := svc.Write(path, "first edit\n", "")
+	testutil.AssertNoError(t, err)
+	_, err = svc.Write(path, "second edit\n", "")
+	testutil.AssertNoError(t, err)
+
+	versions := listVersions(t, history, path)
+	testutil.AssertEqual(t, len(versions), 2)
+	testutil.AssertEqual(t, versions[0].Op, files.OpWrite)
+	testutil.AssertEqual(t, versions[0].Path, files.ToTildePath(path))
+
+	v, content, err := history.Read(versions[0].ID)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, content, "first edit\n")
+	testutil.AssertEqual(t, v.Size, int64(len("first edit\n")))
+
+	_, content, err = history.Read(versions[1].ID)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, content, "test content")
+
+	t.Run("new files have no history", func(t *testing.T) {
+		newPath := filepath.Join(tmpDir, "new.txt")
+		_, err := svc.Write(newPath, "new", "")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, len(listVersions(t, history, newPath)), 0)
+	})
+
+	t.Run("content is deduplicated", func(t *testing.T) {
+		other := filepath.Join(tmpDir, "dir1", "file1.txt")
+		testutil.AssertNoError(t, os.WriteFile(other, []byte("first edit\n"), 0644))
+		_, err := svc.Write(other, "changed", "")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, countObjects(t, historyDir), 2)
+	})
+
+	t.Run("conflicting writes take no snapshot", func(t *testing.T) {
+		_, err := svc.Write(path, "stale", "0000")
+		if !errors.Is(err, files.ErrVersionConflict) {
+			t.Fatalf("err = %v, want ErrVersionConflict", err)
+		}
+		testutil.AssertEqual(t, len(listVersions(t, history, path)), 2)
+	})
+}
+
+func TestHistory_SnapshotOnUpload(t *testing.T) {
+	svc, history, tmpDir, _ := newTestHistory(t, config.HistoryConfig{})
+
+	testutil.AssertNoError(t, svc.Upload(tmpDir, "test.txt", strings.NewReader("uploaded")))
+	testutil.AssertNoError(t, svc.Upload(tmpDir, "fresh.txt", strings.NewReader("fresh")))
+
+	versions := listVersions(t, history, filepath.Join(tmpDir, "test.txt"))
+	testutil.AssertEqual(t, len(versions), 1)
+	testutil.AssertEqual(t, versions[0].Op, files.OpUpload)
+	testutil.AssertEqual(t, len(listVersions(t, history, filepath.Join(tmpDir, "fresh.txt"))), 0)
+}
+
+func TestHistory_DiffAndRestore(t *testing.T) {
+	svc, history, tmpDir, _ := newTestHistory(t, config.HistoryConfig{})
+	path := filepath.Join(tmpDir, "test.txt")
+
+	_, err := svc.Write(path, "broken\n", "")
+	testutil.AssertNoError(t, err)
+	original := listVersions(t, history, path)[0]
+
+	diff, err := history.Diff(original.ID, files.DefaultDiffContext)
+	testutil.AssertNoError(t, err)
+	testutil.AssertContains(t, diff, "-test content\n\\ No newline at end of file\n+broken\n")
+
+	version, err := history.Restore(original.ID)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, readFile(t, path), "test content")
+	_, current, err := svc.Read(path)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, version, current)
+
+	// The restore kept what it replaced
+	versions := listVersions(t, history, path)
+	testutil.AssertEqual(t, len(versions), 2)
+	testutil.AssertEqual(t, versions[0].Op, files.OpRestore)
+
+	diff, err = history.Diff(original.ID, files.DefaultDiffContext)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, diff, "")
+
+	t.Run("restore a deleted file", func(t *testing.T) {
+		testutil.AssertNoError(t, os.Remove(path))
+		_, err := history.Restore(versions[0].ID)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, readFile(t, path), "broken\n")
+	})
+
+	t.Run("restore through a symlinked parent", func(t *testing.T) {
+		nested := filepath.Join(tmpDir, "dir1", "file1.txt")
+		_, err := svc.Write(nested, "changed", "")
+		testutil.AssertNoError(t, err)
+		v := listVersions(t, history, nested)[0]
+
+		// The parent is swapped for a link out of the allowed paths
+		outside := t.TempDir()
+		testutil.AssertNoError(t, os.RemoveAll(filepath.Join(tmpDir, "dir1")))
+		testutil.AssertNoError(t, os.Symlink(outside, filepath.Join(tmpDir, "dir1")))
+
+		if _, err := history.Restore(v.ID); !errors.Is(err, files.ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+		entries, err := os.ReadDir(outside)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, len(entries), 0)
+	})
+
+	t.Run("restore with missing parents under a link", func(t *testing.T) {
+		nested := filepath.Join(tmpDir, "nested", "sub", "f.txt")
+		testutil.AssertNoError(t, os.MkdirAll(filepath.Dir(nested), 0755))
+		testutil.AssertNoError(t, os.WriteFile(nested, []byte("one"), 0644))
+		_, err := svc.Write(nested, "two", "")
+		testutil.AssertNoError(t, err)
+		v := listVersions(t, history, nested)[0]
+
+		outside := t.TempDir()
+		testutil.AssertNoError(t, os.RemoveAll(filepath.Join(tmpDir, "nested")))
+		testutil.AssertNoError(t, os.Symlink(outside, filepath.Join(tmpDir, "nested")))
+
+		if _, err := history.Restore(v.ID); !errors.Is(err, files.ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+		if _, err := os.Lstat(filepath.Join(outside, "sub")); !os.IsNotExist(err) {
+			t.Error("directory created outside the allowed paths")
+		}
+	})
+
+	t.Run("diff against a file swapped for a link", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "swapped.txt")
+		_, err := svc.Write(path, "one", "")
+		testutil.AssertNoError(t, err)
+		_, err = svc.Write(path, "two", "")
+		testutil.AssertNoError(t, err)
+		v := listVersions(t, history, path)[0]
+
+		secret := filepath.Join(t.TempDir(), "secret")
+		testutil.AssertNoError(t, os.WriteFile(secret, []byte("secret\n"), 0644))
+		testutil.AssertNoError(t, os.Remove(path))
+		testutil.AssertNoError(t, os.Symlink(secret, path))
+
+		diff, err := history.Diff(v.ID, files.DefaultDiffContext)
+		if !errors.Is(err, files.ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+		if strings.Contains(diff, "secret") {
+			t.Error("diff shows a file outside the allowed paths")
+		}
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		_, err := history.Restore("missing")
+		if !errors.Is(err, files.ErrFileVersionNotFound) {
+			t.Errorf("err = %v, want ErrFileVersionNotFound", err)
+		}
+	})
+}
+
+func TestHistory_Retention(t *testing.T) {
+	t.Run("per file", func(t *testing.T) {
+		svc, history, tmpDir, historyDir := newTestHistory(t, config.HistoryConfig{MaxVersions: 2})
+		path := filepath.Join(tmpDir, "test.txt")
+		for _, content := range []string{"one", "two", "three", "four"} {
+			_, err := svc.Write(path, content, "")
+			testutil.AssertNoError(t, err)
+		}
+
+		versions := listVersions(t, history, path)
+		testutil.AssertEqual(t, len(versions), 2)
+		_, content, err := history.Read(versions[1].ID)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, content, "two")
+		testutil.AssertEqual(t, countObjects(t, historyDir), 2)
+	})
+
+	t.Run("total size", func(t *testing.T) {
+		svc, history, tmpDir, historyDir := newTestHistory(t, config.HistoryConfig{MaxTotalSize: 20})
+		first := filepath.Join(tmpDir, "dir1", "file1.txt")
+		second := filepath.Join(tmpDir, "dir1", "file2.txt")
+		_, err := svc.Write(first, "x", "")
+		testutil.AssertNoError(t, err)
+		_, err = svc.Write(second, "y", "")
+		testutil.AssertNoError(t, err)
+
+		// Each file held 13 bytes; only the newest version fits
+		testutil.AssertEqual(t, len(listVersions(t, history, first)), 0)
+		testutil.AssertEqual(t, len(listVersions(t, history, second)), 1)
+		testutil.AssertEqual(t, countObjects(t, historyDir), 1)
+	})
+
+	t.Run("large files are skipped", func(t *testing.T) {
+		svc, history, tmpDir, _ := newTestHistory(t, config.HistoryConfig{MaxFileSize: 4})
+		path := filepath.Join(tmpDir, "test.txt")
+		_, err := svc.Write(path, "x", "")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, len(listVersions(t, history, path)), 0)
+	})
+}
+
+func TestHistory_Delete(t *testing.T) {
+	svc, history, tmpDir, historyDir := newTestHistory(t, config.HistoryConfig{})
+	path := filepath.Join(tmpDir, "test.txt")
+	_, err := svc.Write(path, "edited", "")
+	testutil.AssertNoError(t, err)
+
+	versions := listVersions(t, history, path)
+	testutil.AssertNoError(t, history.Delete(versions[0].ID))
+	testutil.AssertEqual(t, len(listVersions(t, history, path)), 0)
+	testutil.AssertEqual(t, countObjects(t, historyDir), 0)
+}
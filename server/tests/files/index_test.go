@@ -0,0 +1,199 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+// newTestIndex returns a started search index of a temp dir, once its first
+// scan is done
+func newTestIndex(t *testing.T, watch bool) (*files.SearchIndex, string) {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	svc, tmpDir := newTestService(t)
+	notes := "Meeting notes\nThe quick brown fox jumps over the lazy dog.\nNothing else.\n"
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "dir1", "notes.md"), []byte(notes), 0644))
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "fox.txt"), []byte("hello"), 0644))
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "image.bin"), []byte("fox\x00\x01"), 0644))
+	testutil.AssertNoError(t, os.MkdirAll(filepath.Join(tmpDir, "node_modules", "fox"), 0755))
+
+	index := files.NewSearchIndex(svc, db, config.SearchIndexConfig{
+		Enabled:     true,
+		Roots:       []string{tmpDir},
+		Watch:       watch,
+		MaxFileSize: 1024,
+	})
+	index.Start()
+	t.Cleanup(index.Shutdown)
+	waitIndexed(t, index, time.Time{})
+	return index, tmpDir
+}
+
+// waitIndexed waits for a scan finishing after since
+func waitIndexed(t *testing.T, index *files.SearchIndex, since time.Time) files.IndexRootStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		root := index.Status().Roots[0]
+		if root.State == files.IndexReady && root.LastScanAt != nil && root.LastScanAt.After(since) {
+			return root
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("index not scanned: %+v", index.Status().Roots[0])
+	return files.IndexRootStatus{}
+}
+
+func searchIndex(t *testing.T, index *files.SearchIndex, path, query string, content bool) []files.SearchResult {
+	t.Helper()
+	results, err := index.Search(files.IndexQuery{Query: query, Path: path, Content: content, Limit: 10})
+	testutil.AssertNoError(t, err)
+	return results
+}
+
+func resultNames(results []files.SearchResult) []string {
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.Name
+	}
+	return names
+}
+
+func TestSearchIndex_Search(t *testing.T) {
+	index, tmpDir := newTestIndex(t, false)
+
+	status := index.Status()
+	testutil.AssertEqual(t, status.Roots[0].Root, tmpDir)
+	// The root, 3 directories and 7 files; node_modules is skipped
+	testutil.AssertEqual(t, status.Roots[0].Entries, 11)
+	testutil.AssertEqual(t, index.Covers(filepath.Join(tmpDir, "dir1")), true)
+
+	t.Run("names", func(t *testing.T) {
+		results := searchIndex(t, index, tmpDir, "fox", false)
+		testutil.AssertEqual(t, len(results), 1)
+		testutil.AssertEqual(t, results[0].Path, filepath.Join(tmpDir, "fox.txt"))
+		testutil.AssertEqual(t, results[0].NameHighlights[0], files.SearchHighlight{Start: 0, End: 3})
+	})
+
+	t.Run("content ranks names first", func(t *testing.T) {
+		results := searchIndex(t, index, tmpDir, "fox", true)
+		testutil.AssertEqual(t, len(results), 2)
+		testutil.AssertEqual(t, resultNames(results)[0], "fox.txt")
+		testutil.AssertEqual(t, results[1].Name, "notes.md")
+		if results[0].Score <= results[1].Score {
+			t.Errorf("scores = %v, %v, want the name match higher", results[0].Score, results[1].Score)
+		}
+		testutil.AssertContains(t, results[1].Snippet, "quick brown fox")
+		h := results[1].Highlights[0]
+		testutil.AssertEqual(t, string([]rune(results[1].Snippet)[h.Start:h.End]), "fox")
+	})
+
+	t.Run("syntax", func(t *testing.T) {
+		queries := map[string][]string{
+			`"brown fox"`:            {"notes.md"},
+			`"fox brown"`:            {},
+			`qui*`:                   {"notes.md"},
+			`lazy AND dog`:           {"notes.md"},
+			`file1 OR file2`:         {"file1.txt", "file2.txt"},
+			`fox NOT name:notes`:     {"fox.txt"},
+			`content:(hello OR cat)`: {"fox.txt"},
+			`nested/test`:            {},
+		}
+		for query, want := range queries {
+			got := resultNames(searchIndex(t, index, tmpDir, query, true))
+			if len(got) != len(want) {
+				t.Errorf("%s: got %v, want %v", query, got, want)
+				continue
+			}
+			for _, name := range want {
+				if !slices.Contains(got, name) {
+					t.Errorf("%s: got %v, want %v", query, got, want)
+				}
+			}
+		}
+	})
+
+	t.Run("below a path", func(t *testing.T) {
+		results := searchIndex(t, index, filepath.Join(tmpDir, "dir1"), "fox", true)
+		testutil.AssertEqual(t, len(results), 1)
+		testutil.AssertEqual(t, results[0].Name, "notes.md")
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		for _, query := range []string{"(fox", `"fox`, "fox AND", "***"} {
+			_, err := index.Search(files.IndexQuery{Query: query, Path: tmpDir, Content: true, Limit: 10})
+			if !errors.Is(err, files.ErrInvalidSearchQuery) {
+				t.Errorf("%s: err = %v, want ErrInvalidSearchQuery", query, err)
+			}
+		}
+	})
+
+	t.Run("not a root", func(t *testing.T) {
+		err := index.Rescan(filepath.Join(tmpDir, "dir1"))
+		if !errors.Is(err, files.ErrNotIndexed) {
+			t.Errorf("err = %v, want ErrNotIndexed", err)
+		}
+	})
+}
+
+func TestSearchIndex_Rescan(t *testing.T) {
+	index, tmpDir := newTestIndex(t, false)
+	before := index.Status().Roots[0].LastScanAt
+
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "fox.txt"), []byte("goodbye for now"), 0644))
+	testutil.AssertNoError(t, os.Remove(filepath.Join(tmpDir, "dir1", "notes.md")))
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "dir2", "todo.txt"), []byte("feed the fox"), 0644))
+
+	testutil.AssertNoError(t, index.Rescan(tmpDir))
+	root := waitIndexed(t, index, *before)
+	testutil.AssertEqual(t, root.Entries, 11)
+
+	testutil.AssertEqual(t, len(searchIndex(t, index, tmpDir, "hello", true)), 0)
+	testutil.AssertEqual(t, len(searchIndex(t, index, tmpDir, "goodbye", true)), 1)
+	names := resultNames(searchIndex(t, index, tmpDir, "fox", true))
+	if !slices.Equal(names, []string{"fox.txt", "todo.txt"}) {
+		t.Errorf("got %v, want fox.txt then todo.txt", names)
+	}
+}
+
+func TestSearchIndex_Watch(t *testing.T) {
+	index, tmpDir := newTestIndex(t, true)
+	testutil.AssertEqual(t, index.Status().Watching, true)
+
+	waitFor := func(query string, want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if len(searchIndex(t, index, tmpDir, query, true)) == want {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("%s: want %d results", query, want)
+	}
+
+	newDir := filepath.Join(tmpDir, "dir2", "reports")
+	testutil.AssertNoError(t, os.Mkdir(newDir, 0755))
+	waitFor("reports", 1)
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(newDir, "summary.txt"), []byte("quarterly badger count"), 0644))
+	waitFor("badger", 1)
+
+	testutil.AssertNoError(t, os.Rename(newDir, filepath.Join(tmpDir, "archived")))
+	waitFor("reports", 0)
+	waitFor("archived", 1)
+
+	testutil.AssertNoError(t, os.RemoveAll(filepath.Join(tmpDir, "archived")))
+	waitFor("badger", 0)
+}
@@ -0,0 +1,412 @@
+package files_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func newTestMedia(t *testing.T, limits config.MediaConfig) (*files.Media, string, string) {
+	t.Helper()
+	svc, tmpDir := newTestService(t)
+	cacheDir := t.TempDir()
+	return files.NewMedia(svc, cacheDir, limits), tmpDir, cacheDir
+}
+
+func defaultMediaLimits() config.MediaConfig {
+	return config.DefaultConfig().Media
+}
+
+// testImage returns a w×h image, transparent on the left half if alpha is set
+func testImage(w, h int, alpha bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255}
+			if alpha && x < w/2 {
+				c.A = 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	var buf bytes.Buffer
+	testutil.AssertNoError(t, png.Encode(&buf, img))
+	testutil.AssertNoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+// tiffEntry is an IFD entry of a test EXIF block; sub, if set, makes it a
+// pointer to the IFD with that index
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	data     []byte
+	sub      int
+}
+
+func asciiEntry(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag: tag, typ: 2, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+func shortEntry(tag, v uint16) tiffEntry {
+	return tiffEntry{tag: tag, typ: 3, count: 1, data: binary.LittleEndian.AppendUint16(nil, v)}
+}
+
+func rationalEntry(tag uint16, values ...[2]uint32) tiffEntry {
+	var data []byte
+	for _, v := range values {
+		data = binary.LittleEndian.AppendUint32(data, v[0])
+		data = binary.LittleEndian.AppendUint32(data, v[1])
+	}
+	return tiffEntry{tag: tag, typ: 5, count: uint32(len(values)), data: data}
+}
+
+// buildTIFF lays out little-endian IFDs one after another, each followed by
+// the values that don't fit in its entries
+func buildTIFF(ifds ...[]tiffEntry) []byte {
+	offsets := make([]uint32, len(ifds))
+	off := uint32(8)
+	for i, entries := range ifds {
+		offsets[i] = off
+		off += 2 + 12*uint32(len(entries)) + 4
+		for _, e := range entries {
+			if len(e.data) > 4 {
+				off += uint32(len(e.data))
+			}
+		}
+	}
+
+	le := binary.LittleEndian
+	out := []byte("II*\x00")
+	out = le.AppendUint32(out, 8)
+	for i, entries := range ifds {
+		dataOff := offsets[i] + 2 + 12*uint32(len(entries)) + 4
+		var extra []byte
+		out = le.AppendUint16(out, uint16(len(entries)))
+		for _, e := range entries {
+			out = le.AppendUint16(out, e.tag)
+			if e.sub > 0 {
+				out = le.AppendUint16(out, 4)
+				out = le.AppendUint32(out, 1)
+				out = le.AppendUint32(out, offsets[e.sub])
+				continue
+			}
+			out = le.AppendUint16(out, e.typ)
+			out = le.AppendUint32(out, e.count)
+			if len(e.data) > 4 {
+				out = le.AppendUint32(out, dataOff+uint32(len(extra)))
+				extra = append(extra, e.data...)
+			} else {
+				out = append(out, append(e.data, make([]byte, 4-len(e.data))...)...)
+			}
+		}
+		out = le.AppendUint32(out, 0)
+		out = append(out, extra...)
+	}
+	return out
+}
+
+// writeExifJPEG writes a w×h JPEG taken by a test camera, with the given
+// EXIF orientation
+func writeExifJPEG(t *testing.T, path string, w, h int, orientation uint16) {
+	t.Helper()
+	var buf bytes.Buffer
+	testutil.AssertNoError(t, jpeg.Encode(&buf, testImage(w, h, false), nil))
+
+	tiff := buildTIFF(
+		[]tiffEntry{
+			asciiEntry(0x010F, "Gloski"),
+			asciiEntry(0x0110, "Test Camera"),
+			shortEntry(0x0112, orientation),
+			{tag: 0x8769, sub: 1},
+			{tag: 0x8825, sub: 2},
+		},
+		[]tiffEntry{
+			rationalEntry(0x829A, [2]uint32{1, 250}),
+			rationalEntry(0x829D, [2]uint32{28, 10}),
+			shortEntry(0x8827, 200),
+			asciiEntry(0x9003, "2024:06:01 12:30:45"),
+		},
+		[]tiffEntry{
+			asciiEntry(1, "N"),
+			rationalEntry(2, [2]uint32{37, 1}, [2]uint32{30, 1}, [2]uint32{0, 1}),
+			asciiEntry(3, "W"),
+			rationalEntry(4, [2]uint32{122, 1}, [2]uint32{15, 1}, [2]uint32{0, 1}),
+			rationalEntry(6, [2]uint32{100, 1}),
+		},
+	)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+
+	data := append([]byte{0xFF, 0xD8}, app1...)
+	data = append(data, segment...)
+	data = append(data, buf.Bytes()[2:]...)
+	testutil.AssertNoError(t, os.WriteFile(path, data, 0644))
+}
+
+// decodeThumbnail decodes a generated thumbnail
+func decodeThumbnail(t *testing.T, thumb *files.Thumbnail) (image.Image, string) {
+	t.Helper()
+	f, err := os.Open(thumb.Path)
+	testutil.AssertNoError(t, err)
+	defer f.Close()
+	img, format, err := image.Decode(f)
+	testutil.AssertNoError(t, err)
+	return img, format
+}
+
+func TestMedia_Thumbnail(t *testing.T) {
+	media, tmpDir, cacheDir := newTestMedia(t, defaultMediaLimits())
+	ctx := context.Background()
+
+	t.Run("downscales keeping the aspect ratio", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "wide.png")
+		writePNG(t, path, testImage(400, 200, false))
+
+		thumb, err := media.Thumbnail(ctx, path, 100)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, thumb.ContentType, "image/jpeg")
+		if filepath.Dir(filepath.Dir(thumb.Path)) != cacheDir {
+			t.Errorf("thumbnail at %s, want it in %s", thumb.Path, cacheDir)
+		}
+		img, format := decodeThumbnail(t, thumb)
+		testutil.AssertEqual(t, format, "jpeg")
+		testutil.AssertEqual(t, img.Bounds().Dx(), 100)
+		testutil.AssertEqual(t, img.Bounds().Dy(), 50)
+	})
+
+	t.Run("cached until the image changes", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "cached.png")
+		writePNG(t, path, testImage(64, 64, false))
+		first, err := media.Thumbnail(ctx, path, 32)
+		testutil.AssertNoError(t, err)
+		second, err := media.Thumbnail(ctx, path, 32)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, second.Path, first.Path)
+		testutil.AssertEqual(t, second.ETag, first.ETag)
+
+		other, err := media.Thumbnail(ctx, path, 48)
+		testutil.AssertNoError(t, err)
+		if other.ETag == first.ETag {
+			t.Error("thumbnails of different sizes share an ETag")
+		}
+
+		later := time.Now().Add(time.Minute)
+		testutil.AssertNoError(t, os.Chtimes(path, later, later))
+		changed, err := media.Thumbnail(ctx, path, 32)
+		testutil.AssertNoError(t, err)
+		if changed.ETag == first.ETag {
+			t.Error("thumbnail of a modified image has the old ETag")
+		}
+	})
+
+	t.Run("transparent images give PNG", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "alpha.png")
+		writePNG(t, path, testImage(80, 80, true))
+		thumb, err := media.Thumbnail(ctx, path, 40)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, thumb.ContentType, "image/png")
+		img, _ := decodeThumbnail(t, thumb)
+		_, _, _, a := img.At(0, 20).RGBA()
+		testutil.AssertEqual(t, a, uint32(0))
+	})
+
+	t.Run("small images are not upscaled", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "small.png")
+		writePNG(t, path, testImage(20, 10, false))
+		thumb, err := media.Thumbnail(ctx, path, 0)
+		testutil.AssertNoError(t, err)
+		img, _ := decodeThumbnail(t, thumb)
+		testutil.AssertEqual(t, img.Bounds().Dx(), 20)
+		testutil.AssertEqual(t, img.Bounds().Dy(), 10)
+	})
+
+	t.Run("applies the EXIF orientation", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "rotated.jpg")
+		writeExifJPEG(t, path, 200, 100, 6)
+		thumb, err := media.Thumbnail(ctx, path, 100)
+		testutil.AssertNoError(t, err)
+		img, _ := decodeThumbnail(t, thumb)
+		testutil.AssertEqual(t, img.Bounds().Dx(), 50)
+		testutil.AssertEqual(t, img.Bounds().Dy(), 100)
+	})
+
+	t.Run("rejects what it can't decode", func(t *testing.T) {
+		_, err := media.Thumbnail(ctx, filepath.Join(tmpDir, "test.txt"), 0)
+		if !errors.Is(err, files.ErrUnsupportedImage) {
+			t.Errorf("text file: err = %v, want ErrUnsupportedImage", err)
+		}
+		_, err = media.Thumbnail(ctx, filepath.Join(tmpDir, "dir1"), 0)
+		if !errors.Is(err, files.ErrIsDirectory) {
+			t.Errorf("directory: err = %v, want ErrIsDirectory", err)
+		}
+		_, err = media.Thumbnail(ctx, filepath.Join(tmpDir, "wide.png"), 5000)
+		if !errors.Is(err, files.ErrInvalidThumbSize) {
+			t.Errorf("size 5000: err = %v, want ErrInvalidThumbSize", err)
+		}
+		_, err = media.Thumbnail(ctx, "/etc/passwd", 0)
+		if !errors.Is(err, files.ErrPathNotAllowed) {
+			t.Errorf("outside allowed paths: err = %v, want ErrPathNotAllowed", err)
+		}
+	})
+}
+
+func TestMedia_ThumbnailLimits(t *testing.T) {
+	limits := defaultMediaLimits()
+	limits.MaxPixels = 100 * 100
+	media, tmpDir, _ := newTestMedia(t, limits)
+
+	path := filepath.Join(tmpDir, "big.png")
+	writePNG(t, path, testImage(200, 100, false))
+	_, err := media.Thumbnail(context.Background(), path, 0)
+	if !errors.Is(err, files.ErrImageTooLarge) {
+		t.Errorf("err = %v, want ErrImageTooLarge", err)
+	}
+
+	limits.MaxFileSize = 10
+	media, tmpDir, _ = newTestMedia(t, limits)
+	_, err = media.Thumbnail(context.Background(), filepath.Join(tmpDir, "test.txt"), 0)
+	if !errors.Is(err, files.ErrImageTooLarge) {
+		t.Errorf("err = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestMedia_ThumbnailCachePruning(t *testing.T) {
+	limits := defaultMediaLimits()
+	limits.ThumbnailCache = 1 // Bytes; every new thumbnail prunes the rest
+	media, tmpDir, cacheDir := newTestMedia(t, limits)
+	ctx := context.Background()
+
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		path := filepath.Join(tmpDir, name)
+		writePNG(t, path, testImage(64, 64, false))
+		_, err := media.Thumbnail(ctx, path, 32)
+		testutil.AssertNoError(t, err)
+	}
+
+	var cached int
+	filepath.Walk(cacheDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			cached++
+		}
+		return nil
+	})
+	if cached > 1 {
+		t.Errorf("%d thumbnails cached, want the cache pruned", cached)
+	}
+}
+
+func TestMedia_Info(t *testing.T) {
+	media, tmpDir, _ := newTestMedia(t, defaultMediaLimits())
+
+	t.Run("image with EXIF", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "photo.jpg")
+		writeExifJPEG(t, path, 120, 80, 1)
+
+		info, err := media.Info(path)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, info.Kind, files.MediaImage)
+		testutil.AssertEqual(t, info.MimeType, "image/jpeg")
+		testutil.AssertEqual(t, info.Image.Format, "jpeg")
+		testutil.AssertEqual(t, info.Image.Width, 120)
+		testutil.AssertEqual(t, info.Image.Height, 80)
+
+		exif := info.Exif
+		if exif == nil {
+			t.Fatal("no EXIF data")
+		}
+		testutil.AssertEqual(t, exif.Make, "Gloski")
+		testutil.AssertEqual(t, exif.Model, "Test Camera")
+		testutil.AssertEqual(t, exif.DateTime, "2024-06-01T12:30:45")
+		testutil.AssertEqual(t, exif.Orientation, 1)
+		testutil.AssertEqual(t, exif.ExposureTime, "1/250")
+		testutil.AssertEqual(t, exif.FNumber, 2.8)
+		testutil.AssertEqual(t, exif.ISO, 200)
+		if exif.GPS == nil {
+			t.Fatal("no GPS position")
+		}
+		testutil.AssertEqual(t, exif.GPS.Latitude, 37.5)
+		testutil.AssertEqual(t, exif.GPS.Longitude, -122.25)
+		if exif.GPS.Altitude == nil || math.Abs(*exif.GPS.Altitude-100) > 1e-9 {
+			t.Errorf("altitude = %v, want 100", exif.GPS.Altitude)
+		}
+	})
+
+	t.Run("image without EXIF", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "plain.png")
+		writePNG(t, path, testImage(30, 40, false))
+		info, err := media.Info(path)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, info.Kind, files.MediaImage)
+		testutil.AssertEqual(t, info.Image.Width, 30)
+		testutil.AssertEqual(t, info.Image.Height, 40)
+		if info.Exif != nil {
+			t.Errorf("exif = %+v, want none", info.Exif)
+		}
+	})
+
+	t.Run("wav audio", func(t *testing.T) {
+		// One second of 16-bit stereo silence at 8 kHz
+		const rate, channels, bits = 8000, 2, 16
+		samples := make([]byte, rate*channels*bits/8)
+		le := binary.LittleEndian
+		wav := []byte("RIFF")
+		wav = le.AppendUint32(wav, uint32(36+len(samples)))
+		wav = append(wav, "WAVEfmt "...)
+		wav = le.AppendUint32(wav, 16)
+		wav = le.AppendUint16(wav, 1)
+		wav = le.AppendUint16(wav, channels)
+		wav = le.AppendUint32(wav, rate)
+		wav = le.AppendUint32(wav, rate*channels*bits/8)
+		wav = le.AppendUint16(wav, channels*bits/8)
+		wav = le.AppendUint16(wav, bits)
+		wav = append(wav, "data"...)
+		wav = le.AppendUint32(wav, uint32(len(samples)))
+		wav = append(wav, samples...)
+		path := filepath.Join(tmpDir, "tone.wav")
+		testutil.AssertNoError(t, os.WriteFile(path, wav, 0644))
+
+		info, err := media.Info(path)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, info.Kind, files.MediaAudio)
+		c := info.Container
+		if c == nil || len(c.Tracks) != 1 {
+			t.Fatalf("container = %+v, want one track", c)
+		}
+		testutil.AssertEqual(t, c.Format, "wav")
+		testutil.AssertEqual(t, c.Duration, 1.0)
+		testutil.AssertEqual(t, c.Tracks[0].Codec, "pcm")
+		testutil.AssertEqual(t, c.Tracks[0].SampleRate, rate)
+		testutil.AssertEqual(t, c.Tracks[0].Channels, channels)
+		testutil.AssertEqual(t, c.Tracks[0].BitsPerSample, bits)
+	})
+
+	t.Run("other files", func(t *testing.T) {
+		info, err := media.Info(filepath.Join(tmpDir, "test.txt"))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, info.Kind, files.MediaOther)
+		testutil.AssertEqual(t, info.MimeType, "text/plain")
+		if info.Image != nil || info.Container != nil {
+			t.Errorf("info = %+v, want no image or container data", info)
+		}
+	})
+}
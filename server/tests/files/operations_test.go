@@ -0,0 +1,203 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func newTestOperations(t *testing.T) (*files.Operations, string) {
+	t.Helper()
+	svc, tmpDir := newTestService(t)
+	ops := files.NewOperations(svc)
+	t.Cleanup(ops.Shutdown)
+	return ops, tmpDir
+}
+
+// waitForOperation polls until the operation is no longer running
+func waitForOperation(t *testing.T, ops *files.Operations, id string) *files.Operation {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		op, err := ops.Get(id)
+		testutil.AssertNoError(t, err)
+		if op.Status != files.OperationRunning {
+			return op
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for operation")
+	return nil
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	testutil.AssertNoError(t, err)
+	return string(data)
+}
+
+func TestOperations_Copy(t *testing.T) {
+	ops, tmpDir := newTestOperations(t)
+	dest := filepath.Join(tmpDir, "dest")
+	testutil.AssertNoError(t, os.Mkdir(dest, 0755))
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := filepath.Join(tmpDir, "dir1", "file1.txt")
+	testutil.AssertNoError(t, os.Chmod(src, 0640))
+	testutil.AssertNoError(t, os.Chtimes(src, mtime, mtime))
+
+	op, err := ops.Start(files.OperationRequest{
+		Type:        files.OperationCopy,
+		Sources:     []string{filepath.Join(tmpDir, "dir1"), filepath.Join(tmpDir, "test.txt")},
+		Destination: dest,
+	})
+	testutil.AssertNoError(t, err)
+
+	op = waitForOperation(t, ops, op.ID)
+	testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+	testutil.AssertEqual(t, op.TotalFiles, 3)
+	testutil.AssertEqual(t, op.FilesDone, 3)
+	testutil.AssertEqual(t, op.BytesDone, op.TotalBytes)
+
+	copied := filepath.Join(dest, "dir1", "file1.txt")
+	testutil.AssertEqual(t, readFile(t, copied), "file1 content")
+	info, err := os.Stat(copied)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, info.Mode().Perm(), os.FileMode(0640))
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), mtime)
+	}
+
+	// Sources are untouched
+	testutil.AssertEqual(t, readFile(t, src), "file1 content")
+}
+
+func TestOperations_Conflicts(t *testing.T) {
+	ops, tmpDir := newTestOperations(t)
+	dest := filepath.Join(tmpDir, "dest")
+	testutil.AssertNoError(t, os.MkdirAll(filepath.Join(dest, "dir1"), 0755))
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(dest, "dir1", "file1.txt"), []byte("old"), 0644))
+
+	start := func(conflict files.ConflictPolicy) (*files.Operation, error) {
+		op, err := ops.Start(files.OperationRequest{
+			Type:        files.OperationCopy,
+			Sources:     []string{filepath.Join(tmpDir, "dir1")},
+			Destination: dest,
+			Conflict:    conflict,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return waitForOperation(t, ops, op.ID), nil
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		_, err := start("")
+		if !errors.Is(err, files.ErrPathExists) {
+			t.Errorf("err = %v, want ErrPathExists", err)
+		}
+	})
+
+	t.Run("skip merges and keeps existing files", func(t *testing.T) {
+		op, err := start(files.ConflictSkip)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+		testutil.AssertEqual(t, op.FilesSkipped, 1)
+		testutil.AssertEqual(t, readFile(t, filepath.Join(dest, "dir1", "file1.txt")), "old")
+		testutil.AssertEqual(t, readFile(t, filepath.Join(dest, "dir1", "file2.txt")), "file2 content")
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		op, err := start(files.ConflictOverwrite)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+		testutil.AssertEqual(t, readFile(t, filepath.Join(dest, "dir1", "file1.txt")), "file1 content")
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		op, err := start(files.ConflictRename)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+		testutil.AssertEqual(t, readFile(t, filepath.Join(dest, "dir1 (1)", "file1.txt")), "file1 content")
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		_, err := start("merge")
+		testutil.AssertError(t, err)
+	})
+}
+
+func TestOperations_Move(t *testing.T) {
+	ops, tmpDir := newTestOperations(t)
+	dest := filepath.Join(tmpDir, "dest")
+	testutil.AssertNoError(t, os.Mkdir(dest, 0755))
+
+	op, err := ops.Start(files.OperationRequest{
+		Type:        files.OperationMove,
+		Sources:     []string{filepath.Join(tmpDir, "dir2")},
+		Destination: dest,
+	})
+	testutil.AssertNoError(t, err)
+
+	op = waitForOperation(t, ops, op.ID)
+	testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+	testutil.AssertEqual(t, op.FilesDone, 1)
+	testutil.AssertEqual(t, readFile(t, filepath.Join(dest, "dir2", "nested", "test.md")), "# Test\nmarkdown content")
+	if _, err := os.Stat(filepath.Join(tmpDir, "dir2")); !os.IsNotExist(err) {
+		t.Errorf("source still exists: %v", err)
+	}
+}
+
+func TestOperations_ListCancelDelete(t *testing.T) {
+	ops, tmpDir := newTestOperations(t)
+
+	op, err := ops.Start(files.OperationRequest{
+		Type:        files.OperationCopy,
+		Sources:     []string{filepath.Join(tmpDir, "test.txt")},
+		Destination: filepath.Join(tmpDir, "dir1"),
+	})
+	testutil.AssertNoError(t, err)
+	waitForOperation(t, ops, op.ID)
+
+	list, meta := ops.List(listing.Filter{Status: []string{"completed"}}, listing.Params{Sort: "created_at"})
+	testutil.AssertEqual(t, meta.Total, 1)
+	testutil.AssertEqual(t, list[0].ID, op.ID)
+
+	if err := ops.Cancel(op.ID); !errors.Is(err, files.ErrOperationFinished) {
+		t.Errorf("Cancel finished op: err = %v", err)
+	}
+	testutil.AssertNoError(t, ops.Delete(op.ID))
+	if _, err := ops.Get(op.ID); !errors.Is(err, files.ErrOperationNotFound) {
+		t.Errorf("Get deleted op: err = %v", err)
+	}
+}
+
+func TestOperations_Validation(t *testing.T) {
+	ops, tmpDir := newTestOperations(t)
+
+	tests := []struct {
+		name string
+		req  files.OperationRequest
+		want error
+	}{
+		{"destination not a directory", files.OperationRequest{Type: files.OperationCopy, Sources: []string{filepath.Join(tmpDir, "dir1")}, Destination: filepath.Join(tmpDir, "test.txt")}, files.ErrNotDirectory},
+		{"missing source", files.OperationRequest{Type: files.OperationCopy, Sources: []string{filepath.Join(tmpDir, "nope")}, Destination: tmpDir}, files.ErrPathNotFound},
+		{"into itself", files.OperationRequest{Type: files.OperationCopy, Sources: []string{filepath.Join(tmpDir, "dir2")}, Destination: filepath.Join(tmpDir, "dir2", "nested")}, files.ErrDangerousPath},
+		{"outside allowed paths", files.OperationRequest{Type: files.OperationCopy, Sources: []string{"/etc/hostname"}, Destination: tmpDir}, files.ErrPathNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ops.Start(tt.req)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
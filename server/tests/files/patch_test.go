@@ -0,0 +1,152 @@
+package files_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+var patchOptions = files.PatchOptions{Strip: -1, Fuzz: files.DefaultPatchFuzz}
+
+// numberedLines returns "line 1\n" ... "line n\n"
+func numberedLines(from, to int) string {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	return sb.String()
+}
+
+func TestService_ApplyPatch(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+
+	patch := `diff --git a/test.txt b/test.txt
+index 1234567..89abcde 100644
+--- a/test.txt
++++ b/test.txt
+@@ -1 +1,2 @@
+-test content
+\ No newline at end of file
++patched content
++second line
+diff --git a/dir1/new.txt b/dir1/new.txt
+new file mode 100644
+--- /dev/null
++++ b/dir1/new.txt
+@@ -0,0 +1 @@
++created
+--- a/dir1/file2.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-file2 content
+\ No newline at end of file
+`
+	result, err := svc.ApplyPatch(tmpDir, patch, patchOptions)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, result.Applied, true)
+	testutil.AssertEqual(t, len(result.Files), 3)
+	testutil.AssertEqual(t, result.Files[1].Op, files.PatchCreate)
+	testutil.AssertEqual(t, result.Files[2].Op, files.PatchDelete)
+
+	testutil.AssertEqual(t, readFile(t, filepath.Join(tmpDir, "test.txt")), "patched content\nsecond line\n")
+	testutil.AssertEqual(t, readFile(t, filepath.Join(tmpDir, "dir1", "new.txt")), "created\n")
+	if _, err := os.Stat(filepath.Join(tmpDir, "dir1", "file2.txt")); !os.IsNotExist(err) {
+		t.Error("deleted file still exists")
+	}
+}
+
+func TestService_ApplyPatchOffsetAndFuzz(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	path := filepath.Join(tmpDir, "config.txt")
+	original := numberedLines(1, 20)
+	lines := strings.SplitAfter(original, "\n")
+	edited := strings.Replace(original, lines[9], "changed\n", 1)
+	patch := files.UnifiedDiff("a/config.txt", "b/config.txt", original, edited, 3)
+
+	t.Run("offset", func(t *testing.T) {
+		// Five lines were added above the hunk since the diff was made
+		testutil.AssertNoError(t, os.WriteFile(path, []byte("new\nnew\nnew\nnew\nnew\n"+original), 0644))
+		result, err := svc.ApplyPatch(tmpDir, patch, patchOptions)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Files[0].Hunks[0].Offset, 5)
+		testutil.AssertEqual(t, result.Files[0].Hunks[0].Fuzz, 0)
+		testutil.AssertEqual(t, readFile(t, path), "new\nnew\nnew\nnew\nnew\n"+edited)
+	})
+
+	t.Run("fuzz", func(t *testing.T) {
+		// The first context line has changed since
+		drifted := strings.Replace(original, lines[6], "drifted\n", 1)
+		testutil.AssertNoError(t, os.WriteFile(path, []byte(drifted), 0644))
+
+		_, err := svc.ApplyPatch(tmpDir, patch, files.PatchOptions{Strip: -1})
+		if !errors.Is(err, files.ErrPatchFailed) {
+			t.Fatalf("err = %v, want ErrPatchFailed without fuzz", err)
+		}
+
+		result, err := svc.ApplyPatch(tmpDir, patch, patchOptions)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Files[0].Hunks[0].Fuzz, 1)
+		testutil.AssertEqual(t, readFile(t, path), strings.Replace(drifted, lines[9], "changed\n", 1))
+	})
+}
+
+func TestService_ApplyPatchAllOrNothing(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	patch := files.UnifiedDiff("test.txt", "test.txt", "test content", "good", 3) +
+		files.UnifiedDiff("dir1/file1.txt", "dir1/file1.txt", "something else", "bad", 3)
+
+	t.Run("dry run reports failing hunks", func(t *testing.T) {
+		result, err := svc.ApplyPatch(tmpDir, patch, files.PatchOptions{Strip: 0, DryRun: true})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Applied, false)
+		testutil.AssertEqual(t, result.Files[0].OK, true)
+		testutil.AssertEqual(t, result.Files[1].OK, false)
+		testutil.AssertEqual(t, result.Files[1].Hunks[0].Applied, false)
+		testutil.AssertEqual(t, readFile(t, filepath.Join(tmpDir, "test.txt")), "test content")
+	})
+
+	t.Run("nothing is written when a hunk fails", func(t *testing.T) {
+		_, err := svc.ApplyPatch(tmpDir, patch, files.PatchOptions{Strip: 0})
+		if !errors.Is(err, files.ErrPatchFailed) {
+			t.Fatalf("err = %v, want ErrPatchFailed", err)
+		}
+		e, _ := apperr.As(err)
+		testutil.AssertEqual(t, e.Details.(*files.PatchResult).Files[1].OK, false)
+		testutil.AssertEqual(t, readFile(t, filepath.Join(tmpDir, "test.txt")), "test content")
+	})
+}
+
+func TestService_ApplyPatchPaths(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	base := filepath.Join(tmpDir, "dir1")
+
+	t.Run("outside the base directory", func(t *testing.T) {
+		patch := files.UnifiedDiff("../test.txt", "../test.txt", "test content", "escaped", 3)
+		_, err := svc.ApplyPatch(base, patch, files.PatchOptions{Strip: 0})
+		if !errors.Is(err, files.ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+		testutil.AssertEqual(t, readFile(t, filepath.Join(tmpDir, "test.txt")), "test content")
+	})
+
+	t.Run("new file in a new directory", func(t *testing.T) {
+		patch := files.UnifiedDiff("/dev/null", "b/sub/dir/new.txt", "", "new\n", 3)
+		_, err := svc.ApplyPatch(base, patch, patchOptions)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, readFile(t, filepath.Join(base, "sub", "dir", "new.txt")), "new\n")
+	})
+
+	t.Run("malformed patch", func(t *testing.T) {
+		_, err := svc.ApplyPatch(base, "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-x\n", patchOptions)
+		if !errors.Is(err, files.ErrInvalidPatch) {
+			t.Errorf("err = %v, want ErrInvalidPatch", err)
+		}
+	})
+}
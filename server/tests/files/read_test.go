@@ -0,0 +1,218 @@
+package files_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+// writeLines writes a file of lines "line 1" to "line n"
+func writeLines(t *testing.T, path string, n int, trailingNewline bool) string {
+	t.Helper()
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d", i)
+		if i < n || trailingNewline {
+			b.WriteByte('\n')
+		}
+	}
+	testutil.AssertNoError(t, os.WriteFile(path, []byte(b.String()), 0644))
+	return b.String()
+}
+
+func TestService_ReadRange(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	ctx := context.Background()
+
+	t.Run("pages of multibyte text decode cleanly", func(t *testing.T) {
+		content := strings.Repeat("héllo wörld — ✓ ", 50)
+		path := filepath.Join(tmpDir, "utf8.txt")
+		testutil.AssertNoError(t, os.WriteFile(path, []byte(content), 0644))
+
+		var got strings.Builder
+		offset := int64(0)
+		for pages := 0; ; pages++ {
+			if pages > len(content) {
+				t.Fatal("paging did not reach the end")
+			}
+			result, err := svc.ReadRange(ctx, files.RangeRequest{Path: path, Offset: offset, Length: 7})
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqual(t, result.Encoding, files.EncodingUTF8)
+			testutil.AssertEqual(t, result.Format, files.RangeFormatText)
+			testutil.AssertEqual(t, result.Size, int64(len(content)))
+			if strings.Contains(result.Content, "�") {
+				t.Fatalf("page at %d has a broken character: %q", offset, result.Content)
+			}
+			got.WriteString(result.Content)
+			offset = result.Offset + result.Length
+			if result.EOF {
+				break
+			}
+		}
+		testutil.AssertEqual(t, got.String(), content)
+	})
+
+	t.Run("offset from the end", func(t *testing.T) {
+		result, err := svc.ReadRange(ctx, files.RangeRequest{Path: filepath.Join(tmpDir, "test.txt"), Offset: -7})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "content")
+		testutil.AssertEqual(t, result.Offset, int64(5))
+		testutil.AssertEqual(t, result.Encoding, files.EncodingASCII)
+		testutil.AssertEqual(t, result.EOF, true)
+	})
+
+	t.Run("line range", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "lines.txt")
+		writeLines(t, path, 10, true)
+
+		result, err := svc.ReadRange(ctx, files.RangeRequest{Path: path, Line: 3, Lines: 2})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "line 3\nline 4\n")
+		testutil.AssertEqual(t, result.StartLine, 3)
+		testutil.AssertEqual(t, result.LineCount, 2)
+		testutil.AssertEqual(t, result.Offset, int64(len("line 1\nline 2\n")))
+		testutil.AssertEqual(t, result.EOF, false)
+
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: path, Line: 9, Lines: 5})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "line 9\nline 10\n")
+		testutil.AssertEqual(t, result.LineCount, 2)
+		testutil.AssertEqual(t, result.EOF, true)
+
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: path, Line: 50})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "")
+		testutil.AssertEqual(t, result.EOF, true)
+
+		// Lines that don't fit the byte limit are left for the next page
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: path, Line: 1, Lines: 5, Length: 16})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "line 1\nline 2\n")
+		testutil.AssertEqual(t, result.Truncated, true)
+	})
+
+	t.Run("tail", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "tail.txt")
+		writeLines(t, path, 20, true)
+		result, err := svc.ReadRange(ctx, files.RangeRequest{Path: path, Tail: 3})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "line 18\nline 19\nline 20\n")
+		testutil.AssertEqual(t, result.LineCount, 3)
+		testutil.AssertEqual(t, result.EOF, true)
+
+		writeLines(t, path, 20, false)
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: path, Tail: 2})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "line 19\nline 20")
+
+		whole := writeLines(t, path, 4, true)
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: path, Tail: 10})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, whole)
+		testutil.AssertEqual(t, result.Offset, int64(0))
+
+		// Over the byte limit, the last whole lines that fit are returned
+		writeLines(t, path, 20, true)
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: path, Tail: 10, Length: 20})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "line 19\nline 20\n")
+		testutil.AssertEqual(t, result.Truncated, true)
+	})
+
+	t.Run("tail of a file over the read limit", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "big.log")
+		writeLines(t, path, 1500000, true)
+		info, err := os.Stat(path)
+		testutil.AssertNoError(t, err)
+		if info.Size() <= files.MaxFileSize {
+			t.Fatalf("test file is only %d bytes", info.Size())
+		}
+		_, _, err = svc.Read(path)
+		if !errors.Is(err, files.ErrFileTooLarge) {
+			t.Fatalf("Read: err = %v, want ErrFileTooLarge", err)
+		}
+
+		result, err := svc.ReadRange(ctx, files.RangeRequest{Path: path, Tail: 2})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "line 1499999\nline 1500000\n")
+		testutil.AssertEqual(t, result.Size, info.Size())
+	})
+
+	t.Run("binary content as hex", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "binary.bin")
+		data := append([]byte("\x7fELF\x02\x01\x01\x00"), make([]byte, 12)...)
+		testutil.AssertNoError(t, os.WriteFile(path, data, 0644))
+
+		result, err := svc.ReadRange(ctx, files.RangeRequest{Path: path})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Encoding, files.EncodingBinary)
+		testutil.AssertEqual(t, result.Format, files.RangeFormatHex)
+		testutil.AssertEqual(t, result.Content,
+			"00000000  7f 45 4c 46 02 01 01 00  00 00 00 00 00 00 00 00  |.ELF............|\n"+
+				"00000010  00 00 00 00                                       |....|\n")
+
+		// Offsets in the dump are those in the file
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: path, Offset: 1, Length: 3})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "00000001  45 4c 46                                          |ELF|\n")
+
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: filepath.Join(tmpDir, "test.txt"), Format: files.RangeFormatHex, Length: 4})
+		testutil.AssertNoError(t, err)
+		testutil.AssertContains(t, result.Content, "74 65 73 74")
+	})
+
+	t.Run("encodings", func(t *testing.T) {
+		utf16 := filepath.Join(tmpDir, "utf16.txt")
+		testutil.AssertNoError(t, os.WriteFile(utf16, []byte("\xff\xfeh\x00i\x00 \x00\x3a\xd8\x00\xdf"), 0644))
+		result, err := svc.ReadRange(ctx, files.RangeRequest{Path: utf16})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Encoding, files.EncodingUTF16LE)
+		testutil.AssertEqual(t, result.BOM, true)
+		testutil.AssertEqual(t, result.Content, "hi "+string(rune(0x1EB00)))
+
+		// A page never splits a surrogate pair
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: utf16, Length: 10})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Content, "hi ")
+		testutil.AssertEqual(t, result.Length, int64(8))
+
+		_, err = svc.ReadRange(ctx, files.RangeRequest{Path: utf16, Tail: 1})
+		if !errors.Is(err, files.ErrInvalidRange) {
+			t.Errorf("tail of UTF-16: err = %v, want ErrInvalidRange", err)
+		}
+
+		latin1 := filepath.Join(tmpDir, "latin1.txt")
+		testutil.AssertNoError(t, os.WriteFile(latin1, []byte("caf\xe9 cr\xe8me\n"), 0644))
+		result, err = svc.ReadRange(ctx, files.RangeRequest{Path: latin1})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, result.Encoding, files.EncodingLatin1)
+		testutil.AssertEqual(t, result.Content, "café crème\n")
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "test.txt")
+		for name, req := range map[string]files.RangeRequest{
+			"past the end":       {Path: path, Offset: 100},
+			"line and tail":      {Path: path, Line: 1, Tail: 1},
+			"offset and line":    {Path: path, Offset: 2, Line: 1},
+			"lines without line": {Path: path, Lines: 5},
+			"length over limit":  {Path: path, Length: files.MaxRangeLength + 1},
+			"unknown format":     {Path: path, Format: "base64"},
+		} {
+			if _, err := svc.ReadRange(ctx, req); !errors.Is(err, files.ErrInvalidRange) {
+				t.Errorf("%s: err = %v, want ErrInvalidRange", name, err)
+			}
+		}
+		_, err := svc.ReadRange(ctx, files.RangeRequest{Path: filepath.Join(tmpDir, "dir1")})
+		if !errors.Is(err, files.ErrIsDirectory) {
+			t.Errorf("directory: err = %v, want ErrIsDirectory", err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package files_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+// newTestSearchTree adds a project with ignore files and hidden directories
+// to a test service's temp dir
+func newTestSearchTree(t *testing.T) (*files.Service, string) {
+	t.Helper()
+	svc, tmpDir := newTestService(t)
+	project := map[string]string{
+		"project/.gitignore":          "*.log\nbuild/\n!keep.log\n",
+		"project/main.go":             "package main\n\nfunc main() {\n\tprintln(\"Hello, World\")\n}\n",
+		"project/util.go":             "package main\n\n// TODO: hello helpers\n",
+		"project/README.md":           "# Hello project\n",
+		"project/debug.log":           "hello from the log\n",
+		"project/keep.log":            "kept\n",
+		"project/build/out.go":        "package out\n",
+		"project/docs/.ignore":        "draft*\n",
+		"project/docs/draft-guide.md": "hello draft\n",
+		"project/docs/guide.md":       "Guide\n",
+		"project/.config/hello.toml":  "x = 1\n",
+		"project/node_modules/x.go":   "package x\n",
+	}
+	for name, content := range project {
+		path := filepath.Join(tmpDir, name)
+		testutil.AssertNoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		testutil.AssertNoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return svc, filepath.Join(tmpDir, "project")
+}
+
+func runSearch(t *testing.T, svc *files.Service, req files.SearchRequest) ([]files.SearchResult, files.SearchSummary) {
+	t.Helper()
+	if req.Filter.MinSize == 0 && req.Filter.MaxSize == 0 {
+		req.Filter = listing.Filter{MinSize: -1, MaxSize: -1}
+	}
+	var results []files.SearchResult
+	summary, err := svc.SearchStream(context.Background(), req, func(r files.SearchResult) error {
+		results = append(results, r)
+		return nil
+	})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, summary.Count, len(results))
+	return results, summary
+}
+
+// searchNames returns the sorted names of the results
+func searchNames(results []files.SearchResult) []string {
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.Name
+	}
+	slices.Sort(names)
+	return names
+}
+
+func assertNames(t *testing.T, results []files.SearchResult, want ...string) {
+	t.Helper()
+	slices.Sort(want)
+	if got := searchNames(results); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestService_SearchStream(t *testing.T) {
+	svc, project := newTestSearchTree(t)
+
+	t.Run("substring skips ignored and hidden", func(t *testing.T) {
+		// Hidden files are searched, hidden directories aren't
+		results, _ := runSearch(t, svc, files.SearchRequest{Path: project, Query: "G"})
+		assertNames(t, results, ".gitignore", ".ignore", "guide.md", "keep.log", "main.go", "util.go")
+		// *.log is ignored, but keep.log is re-included
+		results, _ = runSearch(t, svc, files.SearchRequest{Path: project, Query: ".log"})
+		assertNames(t, results, "keep.log")
+	})
+
+	t.Run("no ignore and hidden", func(t *testing.T) {
+		results, _ := runSearch(t, svc, files.SearchRequest{Path: project, Query: "go", NoIgnore: true})
+		assertNames(t, results, "main.go", "out.go", "util.go", "x.go")
+		results, _ = runSearch(t, svc, files.SearchRequest{Path: project, Query: "hello", Hidden: true})
+		assertNames(t, results, "hello.toml")
+	})
+
+	t.Run("case sensitive content", func(t *testing.T) {
+		results, _ := runSearch(t, svc, files.SearchRequest{Path: project, Query: "Hello", Content: true, CaseSensitive: true})
+		assertNames(t, results, "README.md", "main.go")
+		for _, r := range results {
+			if r.Name == "main.go" {
+				testutil.AssertEqual(t, r.LineNum, 4)
+				testutil.AssertEqual(t, r.Highlights[0], files.SearchHighlight{Start: 10, End: 15})
+			}
+		}
+	})
+
+	t.Run("regex", func(t *testing.T) {
+		results, _ := runSearch(t, svc, files.SearchRequest{Path: project, Query: `^(main|util)\.go$`, Mode: files.SearchRegex})
+		assertNames(t, results, "main.go", "util.go")
+		testutil.AssertEqual(t, results[0].NameHighlights[0].End, 7)
+
+		results, _ = runSearch(t, svc, files.SearchRequest{Path: project, Query: `TODO:\s+\w+`, Mode: files.SearchRegex, Content: true})
+		assertNames(t, results, "util.go")
+		testutil.AssertEqual(t, results[0].Match, "// TODO: hello helpers")
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		results, _ := runSearch(t, svc, files.SearchRequest{Path: project, Query: "*.md", Mode: files.SearchGlob})
+		assertNames(t, results, "README.md", "guide.md")
+		results, _ = runSearch(t, svc, files.SearchRequest{Path: project, Query: "docs/**", Mode: files.SearchGlob})
+		assertNames(t, results, ".ignore", "guide.md")
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		results, _ := runSearch(t, svc, files.SearchRequest{Path: project, Query: ".", Mode: files.SearchRegex, Extensions: []string{".MD", "go"}})
+		assertNames(t, results, "README.md", "guide.md", "main.go", "util.go")
+
+		filter, err := listing.ParseFilter(url.Values{"min_size": {"50"}, "type": {"file"}})
+		testutil.AssertNoError(t, err)
+		results, _ = runSearch(t, svc, files.SearchRequest{Path: project, Query: "i", Filter: filter})
+		assertNames(t, results, "main.go")
+
+		results, _ = runSearch(t, svc, files.SearchRequest{Path: project, Query: "i", Include: []string{"docs/*.md"}})
+		assertNames(t, results, "guide.md")
+		results, _ = runSearch(t, svc, files.SearchRequest{Path: project, Query: "i", Exclude: []string{"docs", "*.go", ".*"}})
+		assertNames(t, results)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		results, summary := runSearch(t, svc, files.SearchRequest{Path: project, Query: "e", Limit: 2})
+		testutil.AssertEqual(t, len(results), 2)
+		testutil.AssertEqual(t, summary.Truncated, true)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		requests := []files.SearchRequest{
+			{Path: project, Query: "(", Mode: files.SearchRegex},
+			{Path: project, Query: "*.go", Mode: files.SearchGlob, Content: true},
+			{Path: project, Query: "x", Mode: "fuzzy"},
+			{Path: project, Query: "x", Include: []string{"[a-"}},
+		}
+		for _, req := range requests {
+			_, err := svc.SearchStream(context.Background(), req, func(files.SearchResult) error { return nil })
+			if !errors.Is(err, files.ErrInvalidSearch) {
+				t.Errorf("%+v: err = %v, want ErrInvalidSearch", req, err)
+			}
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package files_test
 
 import (
+	"errors"
 	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -101,3 +103,76 @@ func TestToTildePath(t *testing.T) {
 		})
 	}
 }
+
+func newTestService(t *testing.T) (*files.Service, string) {
+	t.Helper()
+	cfg := testutil.TestConfig(t)
+	tmpDir := testutil.TestTempDir(t)
+	cfg.AllowedPaths = []string{tmpDir}
+	return files.NewService(cfg), tmpDir
+}
+
+func TestService_Copy(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+
+	t.Run("copy directory tree", func(t *testing.T) {
+		src := filepath.Join(tmpDir, "dir1")
+		testutil.AssertNoError(t, os.Chmod(filepath.Join(src, "file1.txt"), 0600))
+		testutil.AssertNoError(t, os.Symlink("file2.txt", filepath.Join(src, "link")))
+
+		dst := filepath.Join(tmpDir, "dir1-copy")
+		testutil.AssertNoError(t, svc.Copy(src, dst))
+
+		data, err := os.ReadFile(filepath.Join(dst, "file1.txt"))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, string(data), "file1 content")
+
+		info, err := os.Stat(filepath.Join(dst, "file1.txt"))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, info.Mode().Perm(), os.FileMode(0600))
+
+		target, err := os.Readlink(filepath.Join(dst, "link"))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, target, "file2.txt")
+	})
+
+	t.Run("existing destination", func(t *testing.T) {
+		err := svc.Copy(filepath.Join(tmpDir, "test.txt"), filepath.Join(tmpDir, "dir1", "file2.txt"))
+		if !errors.Is(err, files.ErrPathExists) {
+			t.Errorf("err = %v, want ErrPathExists", err)
+		}
+	})
+
+	t.Run("into itself", func(t *testing.T) {
+		err := svc.Copy(filepath.Join(tmpDir, "dir2"), filepath.Join(tmpDir, "dir2", "nested", "again"))
+		if !errors.Is(err, files.ErrDangerousPath) {
+			t.Errorf("err = %v, want ErrDangerousPath", err)
+		}
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		err := svc.Copy(filepath.Join(tmpDir, "nope"), filepath.Join(tmpDir, "nope2"))
+		if !errors.Is(err, files.ErrPathNotFound) {
+			t.Errorf("err = %v, want ErrPathNotFound", err)
+		}
+	})
+}
+
+func TestService_Chmod(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	path := filepath.Join(tmpDir, "test.txt")
+
+	mode, err := files.ParseMode("0640")
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, svc.Chmod(path, mode))
+
+	info, err := os.Stat(path)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, info.Mode().Perm(), os.FileMode(0640))
+
+	for _, bad := range []string{"", "rwx", "1777", "999"} {
+		if _, err := files.ParseMode(bad); err == nil {
+			t.Errorf("ParseMode(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+// newTestTrash returns a trash whose home trash lives in a temp directory
+func newTestTrash(t *testing.T) (*files.Trash, string, string) {
+	t.Helper()
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	svc, tmpDir := newTestService(t)
+	return files.NewTrash(svc, db, 0), tmpDir, filepath.Join(dataHome, "Trash")
+}
+
+func listTrash(t *testing.T, trash *files.Trash) []*files.TrashItem {
+	t.Helper()
+	items, _, err := trash.List(listing.Filter{MinSize: -1, MaxSize: -1}, listing.Params{Sort: "deleted_at", Order: listing.OrderDesc})
+	testutil.AssertNoError(t, err)
+	return items
+}
+
+func TestTrash_PutAndRestore(t *testing.T) {
+	trash, tmpDir, trashDir := newTestTrash(t)
+	src := filepath.Join(tmpDir, "dir1")
+
+	item, err := trash.Put(src)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, item.Name, "dir1")
+	testutil.AssertEqual(t, item.Type, "directory")
+	testutil.AssertEqual(t, item.Size, int64(len("file1 content")+len("file2 content")))
+
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Fatal("source still exists after trashing")
+	}
+	testutil.AssertEqual(t, readFile(t, filepath.Join(trashDir, "files", "dir1", "file1.txt")), "file1 content")
+
+	info := readFile(t, filepath.Join(trashDir, "info", "dir1.trashinfo"))
+	if !strings.HasPrefix(info, "[Trash Info]\nPath="+src+"\nDeletionDate=") {
+		t.Errorf("unexpected trashinfo:\n%s", info)
+	}
+
+	items := listTrash(t, trash)
+	testutil.AssertEqual(t, len(items), 1)
+	testutil.AssertEqual(t, items[0].ID, item.ID)
+
+	t.Run("restore refuses to replace", func(t *testing.T) {
+		testutil.AssertNoError(t, os.Mkdir(src, 0755))
+		_, err := trash.Restore(item.ID, "")
+		if !errors.Is(err, files.ErrPathExists) {
+			t.Errorf("err = %v, want ErrPathExists", err)
+		}
+		testutil.AssertNoError(t, os.Remove(src))
+	})
+
+	t.Run("restore to original path", func(t *testing.T) {
+		_, err := trash.Restore(item.ID, "")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, readFile(t, filepath.Join(src, "file2.txt")), "file2 content")
+		testutil.AssertEqual(t, len(listTrash(t, trash)), 0)
+		if _, err := os.Stat(filepath.Join(trashDir, "info", "dir1.trashinfo")); !os.IsNotExist(err) {
+			t.Error("trashinfo left behind")
+		}
+	})
+
+	t.Run("restore unknown item", func(t *testing.T) {
+		_, err := trash.Restore("missing", "")
+		if !errors.Is(err, files.ErrTrashItemNotFound) {
+			t.Errorf("err = %v, want ErrTrashItemNotFound", err)
+		}
+	})
+}
+
+func TestTrash_RestoreThroughSymlinkedParent(t *testing.T) {
+	trash, tmpDir, _ := newTestTrash(t)
+	item, err := trash.Put(filepath.Join(tmpDir, "dir1", "file1.txt"))
+	testutil.AssertNoError(t, err)
+
+	// The parent is swapped for a link out of the allowed paths
+	outside := t.TempDir()
+	testutil.AssertNoError(t, os.RemoveAll(filepath.Join(tmpDir, "dir1")))
+	testutil.AssertNoError(t, os.Symlink(outside, filepath.Join(tmpDir, "dir1")))
+
+	if _, err := trash.Restore(item.ID, ""); !errors.Is(err, files.ErrPathNotAllowed) {
+		t.Errorf("err = %v, want ErrPathNotAllowed", err)
+	}
+	entries, err := os.ReadDir(outside)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, len(entries), 0)
+	testutil.AssertEqual(t, len(listTrash(t, trash)), 1)
+
+	t.Run("missing parents under the link", func(t *testing.T) {
+		testutil.AssertNoError(t, os.Remove(filepath.Join(tmpDir, "dir1")))
+		testutil.AssertNoError(t, os.MkdirAll(filepath.Join(tmpDir, "dir1", "sub"), 0755))
+		testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "dir1", "sub", "f"), []byte("f"), 0644))
+		nested, err := trash.Put(filepath.Join(tmpDir, "dir1", "sub", "f"))
+		testutil.AssertNoError(t, err)
+
+		testutil.AssertNoError(t, os.RemoveAll(filepath.Join(tmpDir, "dir1")))
+		testutil.AssertNoError(t, os.Symlink(outside, filepath.Join(tmpDir, "dir1")))
+
+		if _, err := trash.Restore(nested.ID, ""); !errors.Is(err, files.ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+		if _, err := os.Lstat(filepath.Join(outside, "sub")); !os.IsNotExist(err) {
+			t.Error("directory created outside the allowed paths")
+		}
+	})
+}
+
+func TestTrash_RestoreToDestination(t *testing.T) {
+	trash, tmpDir, _ := newTestTrash(t)
+	item, err := trash.Put(filepath.Join(tmpDir, "test.txt"))
+	testutil.AssertNoError(t, err)
+
+	dst := filepath.Join(tmpDir, "dir2", "restored.txt")
+	restored, err := trash.Restore(item.ID, dst)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, restored.OriginalPath, files.ToTildePath(dst))
+	testutil.AssertEqual(t, readFile(t, dst), "test content")
+}
+
+func TestTrash_NameCollision(t *testing.T) {
+	trash, tmpDir, trashDir := newTestTrash(t)
+	path := filepath.Join(tmpDir, "test.txt")
+
+	_, err := trash.Put(path)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, os.WriteFile(path, []byte("second"), 0644))
+	_, err = trash.Put(path)
+	testutil.AssertNoError(t, err)
+
+	testutil.AssertEqual(t, readFile(t, filepath.Join(trashDir, "files", "test.txt")), "test content")
+	testutil.AssertEqual(t, readFile(t, filepath.Join(trashDir, "files", "test.2.txt")), "second")
+	testutil.AssertEqual(t, len(listTrash(t, trash)), 2)
+}
+
+func TestTrash_PurgeAndEmpty(t *testing.T) {
+	trash, tmpDir, trashDir := newTestTrash(t)
+
+	first, err := trash.Put(filepath.Join(tmpDir, "test.txt"))
+	testutil.AssertNoError(t, err)
+	_, err = trash.Put(filepath.Join(tmpDir, "dir1"))
+	testutil.AssertNoError(t, err)
+	_, err = trash.Put(filepath.Join(tmpDir, "dir2"))
+	testutil.AssertNoError(t, err)
+
+	t.Run("purge one", func(t *testing.T) {
+		testutil.AssertNoError(t, trash.Purge(first.ID))
+		if _, err := os.Lstat(filepath.Join(trashDir, "files", "test.txt")); !os.IsNotExist(err) {
+			t.Error("purged file still in trash")
+		}
+		testutil.AssertEqual(t, len(listTrash(t, trash)), 2)
+	})
+
+	t.Run("empty respects age", func(t *testing.T) {
+		n, err := trash.Empty(time.Now().Add(-time.Hour))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, n, 0)
+
+		n, err = trash.Empty(time.Time{})
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, n, 2)
+		testutil.AssertEqual(t, len(listTrash(t, trash)), 0)
+	})
+}
+
+func TestTrash_ForgetsItemsRemovedElsewhere(t *testing.T) {
+	trash, tmpDir, trashDir := newTestTrash(t)
+	_, err := trash.Put(filepath.Join(tmpDir, "test.txt"))
+	testutil.AssertNoError(t, err)
+
+	// As a desktop file manager emptying the trash would
+	testutil.AssertNoError(t, os.RemoveAll(filepath.Join(trashDir, "files")))
+	testutil.AssertEqual(t, len(listTrash(t, trash)), 0)
+}
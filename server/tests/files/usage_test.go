@@ -0,0 +1,115 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func newTestDiskUsage(t *testing.T) (*files.DiskUsage, *files.Operations, string) {
+	t.Helper()
+	ops, tmpDir := newTestOperations(t)
+	return files.NewDiskUsage(ops), ops, tmpDir
+}
+
+// scanUsage scans path and waits for the scan to complete
+func scanUsage(t *testing.T, usage *files.DiskUsage, ops *files.Operations, path string) *files.UsageEntry {
+	t.Helper()
+	op, err := usage.Scan(files.UsageScanRequest{Path: path, OneFileSystem: true})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, op.Type, files.OperationUsage)
+	op = waitForOperation(t, ops, op.ID)
+	testutil.AssertEqual(t, op.Status, files.OperationCompleted)
+	return op.Result.(*files.UsageEntry)
+}
+
+func TestDiskUsage_Scan(t *testing.T) {
+	usage, ops, tmpDir := newTestDiskUsage(t)
+	big := filepath.Join(tmpDir, "dir2", "nested", "big.bin")
+	testutil.AssertNoError(t, os.WriteFile(big, make([]byte, 100000), 0644))
+	// Hard links are counted once, where they're found first
+	testutil.AssertNoError(t, os.Link(big, filepath.Join(tmpDir, "dir2", "zz-link.bin")))
+
+	summary := scanUsage(t, usage, ops, tmpDir)
+	testutil.AssertEqual(t, summary.Files, 5)
+	testutil.AssertEqual(t, summary.Dirs, 3)
+	if summary.Size < 100000 || summary.Size > 200000 {
+		t.Errorf("size = %d, want the big file counted once", summary.Size)
+	}
+	if summary.Allocated < 100000 {
+		t.Errorf("allocated = %d, want at least the big file's blocks", summary.Allocated)
+	}
+
+	t.Run("tree", func(t *testing.T) {
+		tree, err := usage.Tree(tmpDir, 1, 10, "")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, tree.Root, tmpDir)
+		testutil.AssertEqual(t, tree.Size, summary.Size)
+		testutil.AssertEqual(t, len(tree.Children), 3)
+		testutil.AssertEqual(t, tree.Children[0].Path, filepath.Join(tmpDir, "dir2"))
+		testutil.AssertEqual(t, len(tree.Children[0].Children), 0)
+
+		tree, err = usage.Tree(filepath.Join(tmpDir, "dir2"), 2, 10, files.UsageSortAllocated)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, tree.Root, tmpDir)
+		testutil.AssertEqual(t, tree.Children[0].Children[0].Path, big)
+	})
+
+	t.Run("top files", func(t *testing.T) {
+		top, err := usage.Top(tmpDir, "file", 2, "")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, len(top), 2)
+		testutil.AssertEqual(t, top[0].Path, big)
+		testutil.AssertEqual(t, top[0].Size, int64(100000))
+	})
+
+	t.Run("top directories", func(t *testing.T) {
+		top, err := usage.Top(tmpDir, "directory", 0, "")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, len(top), 3)
+		testutil.AssertEqual(t, top[0].Path, filepath.Join(tmpDir, "dir2"))
+		testutil.AssertEqual(t, top[1].Path, filepath.Join(tmpDir, "dir2", "nested"))
+	})
+
+	t.Run("not scanned", func(t *testing.T) {
+		later := filepath.Join(tmpDir, "later")
+		testutil.AssertNoError(t, os.Mkdir(later, 0755))
+		_, err := usage.Tree(later, 1, 10, "")
+		if !errors.Is(err, files.ErrUsageNotScanned) {
+			t.Errorf("err = %v, want ErrUsageNotScanned", err)
+		}
+	})
+}
+
+func TestDiskUsage_Refresh(t *testing.T) {
+	usage, ops, tmpDir := newTestDiskUsage(t)
+	before := scanUsage(t, usage, ops, tmpDir)
+
+	dir1 := filepath.Join(tmpDir, "dir1")
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(dir1, "added.txt"), []byte(strings.Repeat("x", 5000)), 0644))
+	testutil.AssertNoError(t, os.Remove(filepath.Join(dir1, "file1.txt")))
+
+	refreshed := scanUsage(t, usage, ops, dir1)
+	testutil.AssertEqual(t, refreshed.Files, 2)
+
+	tree, err := usage.Tree(tmpDir, 0, 0, "")
+	testutil.AssertNoError(t, err)
+	if tree.RefreshedAt == nil {
+		t.Error("refreshed_at not set")
+	}
+	testutil.AssertEqual(t, tree.Files, before.Files)
+
+	// The refreshed tree matches a full rescan
+	full := scanUsage(t, files.NewDiskUsage(ops), ops, tmpDir)
+	testutil.AssertEqual(t, tree.Size, full.Size)
+	testutil.AssertEqual(t, tree.Allocated, full.Allocated)
+	testutil.AssertEqual(t, len(usage.Cached()), 1)
+
+	testutil.AssertNoError(t, usage.Forget(tmpDir))
+	testutil.AssertEqual(t, len(usage.Cached()), 0)
+}
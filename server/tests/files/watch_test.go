@@ -0,0 +1,110 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+// nextWatchBatch waits for the next batch of events from a watch
+func nextWatchBatch(t *testing.T, w *files.Watch) []files.WatchEvent {
+	t.Helper()
+	select {
+	case batch, ok := <-w.Events():
+		if !ok {
+			t.Fatal("watch closed")
+		}
+		return batch
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for watch events")
+	}
+	return nil
+}
+
+func TestWatch_Events(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	w, err := svc.NewWatch(0)
+	testutil.AssertNoError(t, err)
+	defer w.Close()
+
+	_, err = w.Add(tmpDir)
+	testutil.AssertNoError(t, err)
+	path := func(name string) string { return files.ToTildePath(filepath.Join(tmpDir, name)) }
+
+	t.Run("writes are coalesced into a create", func(t *testing.T) {
+		f, err := os.Create(filepath.Join(tmpDir, "new.txt"))
+		testutil.AssertNoError(t, err)
+		f.WriteString("one")
+		f.WriteString("two")
+		f.Close()
+
+		batch := nextWatchBatch(t, w)
+		testutil.AssertEqual(t, len(batch), 1)
+		testutil.AssertEqual(t, batch[0].Op, files.WatchCreate)
+		testutil.AssertEqual(t, batch[0].Path, path("new.txt"))
+		testutil.AssertEqual(t, batch[0].Dir, files.ToTildePath(tmpDir))
+	})
+
+	t.Run("modify", func(t *testing.T) {
+		testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "test.txt"), []byte("changed"), 0644))
+		batch := nextWatchBatch(t, w)
+		testutil.AssertEqual(t, len(batch), 1)
+		testutil.AssertEqual(t, batch[0].Op, files.WatchModify)
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		testutil.AssertNoError(t, os.Rename(filepath.Join(tmpDir, "new.txt"), filepath.Join(tmpDir, "renamed.txt")))
+		batch := nextWatchBatch(t, w)
+		testutil.AssertEqual(t, len(batch), 1)
+		testutil.AssertEqual(t, batch[0].Op, files.WatchRename)
+		testutil.AssertEqual(t, batch[0].OldPath, path("new.txt"))
+		testutil.AssertEqual(t, batch[0].Path, path("renamed.txt"))
+	})
+
+	t.Run("delete directory", func(t *testing.T) {
+		testutil.AssertNoError(t, os.RemoveAll(filepath.Join(tmpDir, "dir2")))
+		batch := nextWatchBatch(t, w)
+		last := batch[len(batch)-1]
+		testutil.AssertEqual(t, last.Op, files.WatchDelete)
+		testutil.AssertEqual(t, last.Path, path("dir2"))
+		testutil.AssertEqual(t, last.IsDir, true)
+	})
+
+	t.Run("unwatched directories are silent", func(t *testing.T) {
+		testutil.AssertNoError(t, w.Remove(tmpDir))
+		testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "quiet.txt"), nil, 0644))
+		select {
+		case batch := <-w.Events():
+			t.Errorf("unexpected events after unwatch: %+v", batch)
+		case <-time.After(500 * time.Millisecond):
+		}
+	})
+}
+
+func TestWatch_Limits(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	w, err := svc.NewWatch(1)
+	testutil.AssertNoError(t, err)
+
+	_, err = w.Add(filepath.Join(tmpDir, "dir1"))
+	testutil.AssertNoError(t, err)
+	if _, err := w.Add(filepath.Join(tmpDir, "dir2")); !errors.Is(err, files.ErrTooManyWatches) {
+		t.Errorf("err = %v, want ErrTooManyWatches", err)
+	}
+	if _, err := w.Add("/etc"); !errors.Is(err, files.ErrPathNotAllowed) {
+		t.Errorf("err = %v, want ErrPathNotAllowed", err)
+	}
+	if _, err := w.Add(filepath.Join(tmpDir, "test.txt")); !errors.Is(err, files.ErrNotDirectory) {
+		t.Errorf("err = %v, want ErrNotDirectory", err)
+	}
+
+	testutil.AssertNoError(t, w.Close())
+	if _, ok := <-w.Events(); ok {
+		t.Error("events channel still open after close")
+	}
+}
@@ -0,0 +1,100 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func TestService_WriteVersions(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	path := filepath.Join(tmpDir, "test.txt")
+
+	content, version, err := svc.Read(path)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, content, "test content")
+
+	t.Run("matching version", func(t *testing.T) {
+		newVersion, err := svc.Write(path, "first edit\n", version)
+		testutil.AssertNoError(t, err)
+		if newVersion == version {
+			t.Error("version did not change")
+		}
+		_, readVersion, err := svc.Read(path)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, readVersion, newVersion)
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		_, err := svc.Write(path, "second edit\n", version)
+		if !errors.Is(err, files.ErrVersionConflict) {
+			t.Fatalf("err = %v, want ErrVersionConflict", err)
+		}
+		e, _ := apperr.As(err)
+		details := e.Details.(map[string]string)
+		testutil.AssertContains(t, details["diff"], "-first edit\n+second edit\n")
+		testutil.AssertEqual(t, readFile(t, path), "first edit\n")
+	})
+
+	t.Run("any version of a missing file", func(t *testing.T) {
+		_, err := svc.Write(filepath.Join(tmpDir, "missing.txt"), "x", "*")
+		if !errors.Is(err, files.ErrVersionConflict) {
+			t.Errorf("err = %v, want ErrVersionConflict", err)
+		}
+	})
+
+	t.Run("quoted etag", func(t *testing.T) {
+		testutil.AssertEqual(t, files.ParseIfMatch(`W/"abc"`), "abc")
+	})
+}
+
+func TestService_WriteAtomic(t *testing.T) {
+	svc, tmpDir := newTestService(t)
+	path := filepath.Join(tmpDir, "test.txt")
+	testutil.AssertNoError(t, os.Chmod(path, 0600))
+
+	_, err := svc.Write(path, "replaced", "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, readFile(t, path), "replaced")
+
+	info, err := os.Stat(path)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, info.Mode().Perm(), os.FileMode(0600))
+
+	entries, err := os.ReadDir(tmpDir)
+	testutil.AssertNoError(t, err)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("temporary file left behind: %s", e.Name())
+		}
+	}
+
+	t.Run("through a symlink", func(t *testing.T) {
+		link := filepath.Join(tmpDir, "link.txt")
+		testutil.AssertNoError(t, os.Symlink("test.txt", link))
+		_, err := svc.Write(link, "via link", "")
+		testutil.AssertNoError(t, err)
+
+		info, err := os.Lstat(link)
+		testutil.AssertNoError(t, err)
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Error("symlink was replaced by a file")
+		}
+		testutil.AssertEqual(t, readFile(t, path), "via link")
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		newPath := filepath.Join(tmpDir, "new.txt")
+		_, err := svc.Write(newPath, "new", "")
+		testutil.AssertNoError(t, err)
+		info, err := os.Stat(newPath)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, info.Mode().Perm(), os.FileMode(0644))
+	})
+}
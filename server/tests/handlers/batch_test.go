@@ -0,0 +1,93 @@
+package handlers_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/api/handlers"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func TestFilesHandler_Batch(t *testing.T) {
+	handler, tmpDir := setupFilesHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/files/batch", handler.Batch)
+
+	batch := func(t *testing.T, body interface{}) (*handlers.BatchResponse, int) {
+		t.Helper()
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodPost,
+			Path:   "/api/files/batch",
+			Body:   body,
+		})
+		var resp handlers.BatchResponse
+		if w.Code == http.StatusOK {
+			testutil.DecodeJSON(t, w.Body, &resp)
+		}
+		return &resp, w.Code
+	}
+
+	t.Run("copy with per item results", func(t *testing.T) {
+		resp, status := batch(t, map[string]interface{}{
+			"op": "copy",
+			"items": []map[string]string{
+				{"path": filepath.Join(tmpDir, "test.txt"), "destination": filepath.Join(tmpDir, "copy.txt")},
+				{"path": filepath.Join(tmpDir, "missing.txt"), "destination": filepath.Join(tmpDir, "copy2.txt")},
+				{"path": filepath.Join(tmpDir, "dir1"), "destination": filepath.Join(tmpDir, "dir1")},
+				{"path": filepath.Join(tmpDir, "test.txt")},
+			},
+		})
+
+		testutil.AssertStatus(t, status, http.StatusOK)
+		testutil.AssertEqual(t, resp.Succeeded, 1)
+		testutil.AssertEqual(t, resp.Failed, 3)
+		testutil.AssertEqual(t, len(resp.Results), 4)
+
+		if !resp.Results[0].OK {
+			t.Errorf("item 0 failed: %+v", resp.Results[0].Error)
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, "copy.txt")); err != nil {
+			t.Errorf("copy.txt not created: %v", err)
+		}
+
+		testutil.AssertEqual(t, resp.Results[1].Status, http.StatusNotFound)
+		testutil.AssertEqual(t, string(resp.Results[1].Error.Code), "path_not_found")
+		testutil.AssertEqual(t, string(resp.Results[2].Error.Code), "path_exists")
+		testutil.AssertEqual(t, resp.Results[3].Status, http.StatusBadRequest)
+		testutil.AssertEqual(t, resp.Results[3].Index, 3)
+	})
+
+	t.Run("chmod", func(t *testing.T) {
+		resp, status := batch(t, map[string]interface{}{
+			"op": "chmod",
+			"items": []map[string]string{
+				{"path": filepath.Join(tmpDir, "dir1", "file1.txt"), "mode": "600"},
+				{"path": filepath.Join(tmpDir, "dir1", "file2.txt"), "mode": "abc"},
+			},
+			"concurrency": 2,
+		})
+
+		testutil.AssertStatus(t, status, http.StatusOK)
+		testutil.AssertEqual(t, resp.Succeeded, 1)
+		testutil.AssertEqual(t, string(resp.Results[1].Error.Code), "invalid_parameter")
+
+		info, err := os.Stat(filepath.Join(tmpDir, "dir1", "file1.txt"))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, info.Mode().Perm(), os.FileMode(0600))
+	})
+
+	t.Run("unsupported op", func(t *testing.T) {
+		_, status := batch(t, map[string]interface{}{
+			"op":    "shred",
+			"items": []map[string]string{{"path": filepath.Join(tmpDir, "test.txt")}},
+		})
+		testutil.AssertStatus(t, status, http.StatusBadRequest)
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		_, status := batch(t, map[string]interface{}{"op": "delete"})
+		testutil.AssertStatus(t, status, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/api/handlers"
+	"github.com/ss497254/gloski/internal/api/middleware"
+	"github.com/ss497254/gloski/internal/apperr"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/jobs"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+type errorBody struct {
+	Success   bool                   `json:"success"`
+	Error     string                 `json:"error"`
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details"`
+	RequestID string                 `json:"request_id"`
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	failWith := func(err error) http.Handler {
+		return middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.Fail(w, err, "operation failed")
+		}))
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   apperr.Code
+	}{
+		{"typed sentinel", files.ErrPathNotAllowed, http.StatusForbidden, apperr.CodePathNotAllowed},
+		{"wrapped sentinel", fmt.Errorf("stop: %w", jobs.ErrJobNotFound), http.StatusNotFound, apperr.CodeJobNotFound},
+		{"permission error", &os.PathError{Op: "open", Path: "/x", Err: os.ErrPermission}, http.StatusForbidden, apperr.CodePermissionDenied},
+		{"untyped error", fmt.Errorf("boom"), http.StatusInternalServerError, apperr.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.MakeRequest(t, failWith(tt.err), testutil.HTTPRequest{
+				Method: http.MethodGet,
+				Path:   "/test",
+			})
+
+			testutil.AssertStatus(t, w.Code, tt.wantStatus)
+
+			var body errorBody
+			testutil.DecodeJSON(t, w.Body, &body)
+			if body.Success {
+				t.Error("success should be false")
+			}
+			testutil.AssertEqual(t, body.Code, string(tt.wantCode))
+			testutil.AssertEqual(t, body.Error, body.Message)
+			testutil.AssertEqual(t, body.RequestID, w.Header().Get("X-Request-ID"))
+			if body.RequestID == "" {
+				t.Error("request_id should be set")
+			}
+		})
+	}
+
+	t.Run("details are included", func(t *testing.T) {
+		err := files.ErrUploadIncomplete.WithDetails(map[string]int{"chunk_index": 3})
+		w := testutil.MakeRequest(t, failWith(err), testutil.HTTPRequest{
+			Method: http.MethodPost,
+			Path:   "/test",
+		})
+
+		var body errorBody
+		testutil.DecodeJSON(t, w.Body, &body)
+		testutil.AssertEqual(t, body.Code, string(apperr.CodeUploadIncomplete))
+		testutil.AssertEqual(t, body.Details["chunk_index"], float64(3))
+	})
+
+	t.Run("incoming request ID is propagated", func(t *testing.T) {
+		w := testutil.MakeRequest(t, failWith(files.ErrBinaryFile), testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/test",
+			Headers: map[string]string{"X-Request-ID": "trace-abc.123"},
+		})
+
+		var body errorBody
+		testutil.DecodeJSON(t, w.Body, &body)
+		testutil.AssertEqual(t, body.RequestID, "trace-abc.123")
+	})
+
+	t.Run("malformed request ID is replaced", func(t *testing.T) {
+		w := testutil.MakeRequest(t, failWith(files.ErrBinaryFile), testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/test",
+			Headers: map[string]string{"X-Request-ID": "bad id\twith spaces"},
+		})
+
+		if got := w.Header().Get("X-Request-ID"); got == "bad id\twith spaces" || got == "" {
+			t.Errorf("request id = %q, want a generated id", got)
+		}
+	})
+}
+
+func TestErrorSentinels(t *testing.T) {
+	// Copies match their sentinel
+	err := fmt.Errorf("follow: %w", files.ErrInvalidFollow.WithMessage("bad filter").WithDetails("x"))
+	if !errors.Is(err, files.ErrInvalidFollow) {
+		t.Error("copy of ErrInvalidFollow should match it")
+	}
+
+	// Sentinels sharing a code do not match each other
+	testutil.AssertEqual(t, files.ErrInvalidRange.Code, files.ErrInvalidFollow.Code)
+	if errors.Is(files.ErrInvalidRange, files.ErrInvalidFollow) {
+		t.Error("ErrInvalidRange should not match ErrInvalidFollow")
+	}
+	if errors.Is(files.ErrInvalidRange.WithMessage("x"), files.ErrInvalidFollow) {
+		t.Error("copy of ErrInvalidRange should not match ErrInvalidFollow")
+	}
+	testutil.AssertEqual(t, apperr.CodeOf(err), apperr.CodeInvalidParameter)
+}
@@ -0,0 +1,71 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/api/handlers"
+	"github.com/ss497254/gloski/internal/auth"
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func TestEventsHandler_Stream(t *testing.T) {
+	authService, err := auth.NewService(testutil.TestConfig(t))
+	testutil.AssertNoError(t, err)
+
+	bus := events.NewBus(16)
+	server := httptest.NewServer(http.HandlerFunc(handlers.NewEventsHandler(bus, authService).Stream))
+	defer server.Close()
+
+	t.Run("requires authentication", func(t *testing.T) {
+		resp, err := http.Get(server.URL)
+		testutil.AssertNoError(t, err)
+		resp.Body.Close()
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusUnauthorized)
+	})
+
+	t.Run("resumes after last event id", func(t *testing.T) {
+		bus.Publish(events.TopicJobStarted, "before")
+		lastID := bus.LastID()
+		bus.Publish(events.TopicFileChanged, "skipped")
+		bus.Publish(events.TopicJobFinished, "after")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?topics=jobs&api_key=test-api-key", nil)
+		req.Header.Set("Last-Event-ID", fmt.Sprint(lastID))
+		resp, err := http.DefaultClient.Do(req)
+		testutil.AssertNoError(t, err)
+		defer resp.Body.Close()
+
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusOK)
+		testutil.AssertContains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+		// Live events follow the replay on the same stream
+		go bus.Publish(events.TopicJobFailed, "live")
+
+		var topics []string
+		scanner := bufio.NewScanner(resp.Body)
+		for len(topics) < 2 && scanner.Scan() {
+			if topic, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				topics = append(topics, topic)
+			}
+		}
+		testutil.AssertEqual(t, strings.Join(topics, ","), events.TopicJobFinished+","+events.TopicJobFailed)
+	})
+
+	t.Run("rejects malformed last event id", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "?api_key=test-api-key&last_event_id=abc")
+		testutil.AssertNoError(t, err)
+		resp.Body.Close()
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusBadRequest)
+	})
+}
@@ -1,16 +1,23 @@
 package handlers_test
 
 import (
+	"archive/zip"
 	"bytes"
+	"fmt"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/ss497254/gloski/internal/api/handlers"
 	"testing"
 
+	"github.com/ss497254/gloski/internal/api/routes"
+	"github.com/ss497254/gloski/internal/auth"
+	"github.com/ss497254/gloski/internal/config"
 	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/internal/listing"
 	"github.com/ss497254/gloski/tests/testutil"
 )
 
@@ -74,6 +81,76 @@ func TestFilesHandler_List(t *testing.T) {
 
 		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
 	})
+
+	t.Run("paginate with cursor", func(t *testing.T) {
+		var names []string
+		path := "/api/files?limit=2&path=" + tmpDir
+		for i := 0; i < 3 && path != ""; i++ {
+			w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+				Method: http.MethodGet,
+				Path:   path,
+			})
+			testutil.AssertStatus(t, w.Code, http.StatusOK)
+
+			var data files.ListResponse
+			testutil.DecodeJSON(t, w.Body, &data)
+			testutil.AssertEqual(t, data.Meta.Total, 3)
+			for _, e := range data.Entries {
+				names = append(names, e.Name)
+			}
+
+			path = ""
+			if data.Meta.NextCursor != "" {
+				path = "/api/files?limit=2&cursor=" + data.Meta.NextCursor + "&path=" + tmpDir
+			}
+		}
+
+		testutil.AssertEqual(t, len(names), 3)
+		testutil.AssertEqual(t, names[0], "dir1")
+		testutil.AssertEqual(t, names[2], "test.txt")
+	})
+
+	t.Run("filter by type and sort descending", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files?type=directory&sort=-name&path=" + tmpDir,
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+
+		var data files.ListResponse
+		testutil.DecodeJSON(t, w.Body, &data)
+		testutil.AssertEqual(t, len(data.Entries), 2)
+		testutil.AssertEqual(t, data.Entries[0].Name, "dir2")
+	})
+
+	t.Run("whole directory without a limit", func(t *testing.T) {
+		big := filepath.Join(tmpDir, "big")
+		testutil.AssertNoError(t, os.Mkdir(big, 0755))
+		for i := range listing.MaxLimit + 5 {
+			testutil.AssertNoError(t, os.WriteFile(filepath.Join(big, fmt.Sprintf("f%04d", i)), nil, 0644))
+		}
+		defer os.RemoveAll(big)
+
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files?path=" + big,
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+
+		var data files.ListResponse
+		testutil.DecodeJSON(t, w.Body, &data)
+		testutil.AssertEqual(t, len(data.Entries), listing.MaxLimit+5)
+		testutil.AssertEqual(t, data.Meta.NextCursor, "")
+	})
+
+	t.Run("invalid sort field", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files?sort=owner&path=" + tmpDir,
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
+	})
 }
 
 func TestFilesHandler_Read(t *testing.T) {
@@ -124,6 +201,53 @@ func TestFilesHandler_Read(t *testing.T) {
 	})
 }
 
+func TestFilesHandler_ReadRange(t *testing.T) {
+	handler, tmpDir := setupFilesHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/files/read/range", handler.ReadRange)
+
+	testFile := filepath.Join(tmpDir, "test.txt")
+
+	t.Run("byte range", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/read/range?offset=-7&path=" + testFile,
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+
+		var result files.RangeResult
+		testutil.DecodeJSON(t, w.Body, &result)
+		testutil.AssertEqual(t, result.Content, "content")
+		testutil.AssertEqual(t, result.Size, int64(12))
+		testutil.AssertEqual(t, result.Encoding, files.EncodingASCII)
+		testutil.AssertEqual(t, result.EOF, true)
+	})
+
+	t.Run("hex", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/read/range?format=hex&length=4&path=" + testFile,
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertContains(t, w.Body.String(), "74 65 73 74")
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/read/range?line=1&tail=2&path=" + testFile,
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
+		testutil.AssertContains(t, w.Body.String(), "invalid_parameter")
+
+		w = testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/read/range?offset=abc&path=" + testFile,
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
+	})
+}
+
 func TestFilesHandler_Write(t *testing.T) {
 	handler, tmpDir := setupFilesHandler(t)
 	mux := http.NewServeMux()
@@ -176,6 +300,37 @@ func TestFilesHandler_Write(t *testing.T) {
 
 		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
 	})
+
+	t.Run("write with stale If-Match", func(t *testing.T) {
+		testPath := filepath.Join(tmpDir, "test.txt")
+		body := map[string]string{"path": testPath, "content": "mine"}
+
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method:  http.MethodPost,
+			Path:    "/api/files/write",
+			Body:    body,
+			Headers: map[string]string{"If-Match": `"stale"`},
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusConflict)
+		testutil.AssertContains(t, w.Body.String(), `"version_conflict"`)
+		testutil.AssertContains(t, w.Body.String(), `-test content`)
+
+		// Saving again with the version from the conflict succeeds
+		var resp struct {
+			Details map[string]string `json:"details"`
+		}
+		testutil.DecodeJSON(t, w.Body, &resp)
+		w = testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method:  http.MethodPost,
+			Path:    "/api/files/write",
+			Body:    body,
+			Headers: map[string]string{"If-Match": `"` + resp.Details["current_version"] + `"`},
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		if w.Header().Get("ETag") == "" {
+			t.Error("ETag header not set")
+		}
+	})
 }
 
 func TestFilesHandler_Mkdir(t *testing.T) {
@@ -391,6 +546,43 @@ func TestFilesHandler_Search(t *testing.T) {
 
 		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
 	})
+
+	t.Run("stream ndjson", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/search?path=" + tmpDir + "&q=%5Efile%5Cd&mode=regex&ext=txt",
+			Headers: map[string]string{"Accept": "application/x-ndjson"},
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertEqual(t, w.Header().Get("Content-Type"), "application/x-ndjson")
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		testutil.AssertEqual(t, len(lines), 3)
+		testutil.AssertContains(t, lines[0], `"type":"result"`)
+		testutil.AssertContains(t, lines[2], `"type":"done"`)
+		testutil.AssertContains(t, lines[2], `"count":2`)
+	})
+
+	t.Run("stream sse", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/search?path=" + tmpDir + "&q=test.txt&stream=sse",
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertEqual(t, w.Header().Get("Content-Type"), "text/event-stream")
+		testutil.AssertContains(t, w.Body.String(), "event: result\ndata: {")
+		testutil.AssertContains(t, w.Body.String(), "event: done\n")
+	})
+
+	t.Run("invalid regex", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/search?path=" + tmpDir + "&q=%28&mode=regex&stream=ndjson",
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
+	})
 }
 
 func TestFilesHandler_Download(t *testing.T) {
@@ -414,12 +606,72 @@ func TestFilesHandler_Download(t *testing.T) {
 			t.Error("Content-Disposition header not set")
 		}
 
-		contentType := w.Header().Get("Content-Type")
-		if contentType != "application/octet-stream" {
-			t.Errorf("Content-Type = %s, want application/octet-stream", contentType)
+		testutil.AssertEqual(t, contentDisposition, `attachment; filename="test.txt"`)
+		testutil.AssertEqual(t, w.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+		testutil.AssertEqual(t, w.Header().Get("Accept-Ranges"), "bytes")
+		testutil.AssertEqual(t, w.Body.String(), "test content")
+		if w.Header().Get("ETag") == "" || w.Header().Get("Last-Modified") == "" {
+			t.Error("ETag or Last-Modified header not set")
 		}
 	})
 
+	t.Run("inline disposition", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/download?disposition=inline&path=" + filepath.Join(tmpDir, "test.txt"),
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertEqual(t, w.Header().Get("Content-Disposition"), `inline; filename="test.txt"`)
+		testutil.AssertEqual(t, w.Header().Get("Content-Security-Policy"), "sandbox")
+	})
+
+	t.Run("range request", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/files/download?path=" + filepath.Join(tmpDir, "test.txt"),
+			Headers: map[string]string{"Range": "bytes=5-"},
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusPartialContent)
+		testutil.AssertEqual(t, w.Header().Get("Content-Range"), "bytes 5-11/12")
+		testutil.AssertEqual(t, w.Body.String(), "content")
+	})
+
+	t.Run("multi-range request", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/files/download?path=" + filepath.Join(tmpDir, "test.txt"),
+			Headers: map[string]string{"Range": "bytes=0-3,5-11"},
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusPartialContent)
+		testutil.AssertContains(t, w.Header().Get("Content-Type"), "multipart/byteranges")
+		testutil.AssertContains(t, w.Body.String(), "test")
+		testutil.AssertContains(t, w.Body.String(), "content")
+	})
+
+	t.Run("conditional requests", func(t *testing.T) {
+		path := "/api/files/download?path=" + filepath.Join(tmpDir, "test.txt")
+		etag := testutil.MakeRequest(t, mux, testutil.HTTPRequest{Method: http.MethodGet, Path: path}).Header().Get("ETag")
+
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    path,
+			Headers: map[string]string{"If-None-Match": etag},
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusNotModified)
+
+		// A stale If-Range validator gets the whole file
+		w = testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    path,
+			Headers: map[string]string{"Range": "bytes=5-", "If-Range": `"stale"`},
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertEqual(t, w.Body.String(), "test content")
+	})
+
 	t.Run("download directory fails", func(t *testing.T) {
 		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
 			Method: http.MethodGet,
@@ -429,3 +681,88 @@ func TestFilesHandler_Download(t *testing.T) {
 		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
 	})
 }
+
+func TestFilesHandler_DownloadNotCompressed(t *testing.T) {
+	cfg := testutil.TestConfig(t)
+	tmpDir := testutil.TestTempDir(t)
+	cfg.AllowedPaths = []string{tmpDir}
+	cfg.Compression = config.CompressionConfig{Enabled: true, Level: 5, MinSize: 16}
+	authService, err := auth.NewService(cfg)
+	testutil.AssertNoError(t, err)
+	router, _ := routes.Setup(routes.Config{
+		Cfg:         cfg,
+		AuthService: authService,
+		FileService: files.NewService(cfg),
+	})
+
+	text := strings.Repeat("compressible text\n", 200)
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "big.txt"), []byte(text), 0644))
+
+	w := testutil.MakeRequest(t, router, testutil.HTTPRequest{
+		Method: http.MethodGet,
+		Path:   "/api/files/download?path=" + filepath.Join(tmpDir, "big.txt"),
+		Headers: map[string]string{
+			"X-API-Key":       cfg.APIKey,
+			"Accept-Encoding": "gzip, zstd",
+		},
+	})
+	testutil.AssertStatus(t, w.Code, http.StatusOK)
+	testutil.AssertEqual(t, w.Header().Get("Content-Encoding"), "")
+	testutil.AssertEqual(t, w.Header().Get("Accept-Ranges"), "bytes")
+	testutil.AssertEqual(t, w.Body.String(), text)
+
+	// JSON responses on the same chain are still compressed
+	w = testutil.MakeRequest(t, router, testutil.HTTPRequest{
+		Method: http.MethodGet,
+		Path:   "/api/files/read?path=" + filepath.Join(tmpDir, "big.txt"),
+		Headers: map[string]string{
+			"X-API-Key":       cfg.APIKey,
+			"Accept-Encoding": "gzip",
+		},
+	})
+	testutil.AssertStatus(t, w.Code, http.StatusOK)
+	testutil.AssertEqual(t, w.Header().Get("Content-Encoding"), "gzip")
+}
+
+func TestFilesHandler_Archive(t *testing.T) {
+	handler, tmpDir := setupFilesHandler(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/files/archive", handler.Archive)
+
+	t.Run("zip directory", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/archive?path=" + filepath.Join(tmpDir, "dir1"),
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertEqual(t, w.Header().Get("Content-Type"), "application/zip")
+		testutil.AssertEqual(t, w.Header().Get("Content-Disposition"), `attachment; filename="dir1.zip"`)
+
+		zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		testutil.AssertNoError(t, err)
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		testutil.AssertEqual(t, strings.Join(names, ","), "dir1/,dir1/file1.txt,dir1/file2.txt")
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/archive?format=rar&path=" + tmpDir,
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/archive?path=" + filepath.Join(tmpDir, "missing"),
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,113 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ss497254/gloski/internal/api/handlers"
+	"github.com/ss497254/gloski/internal/auth"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func TestFollowHandler_Stream(t *testing.T) {
+	cfg := testutil.TestConfig(t)
+	tmpDir := testutil.TestTempDir(t)
+	cfg.AllowedPaths = []string{tmpDir}
+	authService, err := auth.NewService(cfg)
+	testutil.AssertNoError(t, err)
+
+	handler := handlers.NewFollowHandler(files.NewService(cfg), authService)
+	server := httptest.NewServer(http.HandlerFunc(handler.Stream))
+	defer server.Close()
+
+	logPath := filepath.Join(tmpDir, "app.log")
+	testutil.AssertNoError(t, os.WriteFile(logPath, []byte("one\ntwo\nthree\n"), 0644))
+	appendLog := func(data string) {
+		f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+		testutil.AssertNoError(t, err)
+		_, err = f.WriteString(data)
+		testutil.AssertNoError(t, err)
+		f.Close()
+	}
+
+	t.Run("requires authentication", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "?path=" + url.QueryEscape(logPath))
+		testutil.AssertNoError(t, err)
+		resp.Body.Close()
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusUnauthorized)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		path := "&path=" + url.QueryEscape(logPath)
+		for query, status := range map[string]int{
+			"":                    http.StatusBadRequest,
+			path + "&filter=%28":  http.StatusBadRequest,
+			path + "&lines=-1":    http.StatusBadRequest,
+			path + "&lines=10001": http.StatusBadRequest,
+			"&path=" + url.QueryEscape(filepath.Join(tmpDir, "missing.log")): http.StatusNotFound,
+		} {
+			resp, err := http.Get(server.URL + "?api_key=test-api-key" + query)
+			testutil.AssertNoError(t, err)
+			resp.Body.Close()
+			if resp.StatusCode != status {
+				t.Errorf("%q: status = %d, want %d", query, resp.StatusCode, status)
+			}
+		}
+	})
+
+	t.Run("server-sent events", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet,
+			server.URL+"?api_key=test-api-key&lines=2&path="+url.QueryEscape(logPath), nil)
+		resp, err := http.DefaultClient.Do(req)
+		testutil.AssertNoError(t, err)
+		defer resp.Body.Close()
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusOK)
+		testutil.AssertContains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+		scanner := bufio.NewScanner(resp.Body)
+		next := func() string {
+			for scanner.Scan() {
+				if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+					return data
+				}
+			}
+			t.Fatalf("stream ended: %v", scanner.Err())
+			return ""
+		}
+		testutil.AssertContains(t, next(), `"lines":["two","three"]`)
+		appendLog("four\n")
+		testutil.AssertContains(t, next(), `"lines":["four"]`)
+	})
+
+	t.Run("websocket with filter", func(t *testing.T) {
+		wsURL := "ws" + strings.TrimPrefix(server.URL, "http") +
+			"?api_key=test-api-key&lines=0&filter=%5Eerr&path=" + url.QueryEscape(logPath)
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		testutil.AssertNoError(t, err)
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		appendLog("info: fine\nerr: broken\n")
+		var ev files.FollowEvent
+		testutil.AssertNoError(t, conn.ReadJSON(&ev))
+		testutil.AssertEqual(t, ev.Type, files.FollowLines)
+		testutil.AssertEqual(t, strings.Join(ev.Lines, ","), "err: broken")
+
+		testutil.AssertNoError(t, os.Truncate(logPath, 0))
+		testutil.AssertNoError(t, conn.ReadJSON(&ev))
+		testutil.AssertEqual(t, ev.Type, files.FollowTruncated)
+	})
+}
@@ -0,0 +1,180 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/api/handlers"
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/downloads"
+	"github.com/ss497254/gloski/internal/jobs"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/internal/packages"
+	"github.com/ss497254/gloski/internal/system"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+// listPage is one page of a list endpoint's response
+type listPage struct {
+	Items []json.RawMessage
+	Meta  listing.Meta
+}
+
+// getPage requests one page of a list endpoint whose items are under key
+func getPage(t *testing.T, handler http.HandlerFunc, path, key string) listPage {
+	t.Helper()
+	w := testutil.MakeRequest(t, handler, testutil.HTTPRequest{Method: http.MethodGet, Path: path})
+	testutil.AssertStatus(t, w.Code, http.StatusOK)
+
+	var data map[string]json.RawMessage
+	testutil.DecodeJSON(t, w.Body, &data)
+	var page listPage
+	testutil.AssertNoError(t, json.Unmarshal(data[key], &page.Items))
+	testutil.AssertNoError(t, json.Unmarshal(data["meta"], &page.Meta))
+	return page
+}
+
+// pageThrough follows next cursors from path to the last page, calling
+// between before each page after the first, and returns all items
+func pageThrough[T any](t *testing.T, handler http.HandlerFunc, path, key string, between func()) []T {
+	t.Helper()
+	var items []T
+	for range 1000 {
+		page := getPage(t, handler, path, key)
+		testutil.AssertEqual(t, page.Meta.Count, len(page.Items))
+		for _, raw := range page.Items {
+			var item T
+			testutil.AssertNoError(t, json.Unmarshal(raw, &item))
+			items = append(items, item)
+		}
+		if page.Meta.NextCursor == "" {
+			return items
+		}
+		u, _ := url.Parse(path)
+		q := u.Query()
+		q.Set("cursor", page.Meta.NextCursor)
+		path = u.Path + "?" + q.Encode()
+		if between != nil {
+			between()
+		}
+	}
+	t.Fatal("paging did not end")
+	return nil
+}
+
+func openTestDB(t *testing.T) *database.Database {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestJobsHandler_ListPaging(t *testing.T) {
+	svc, err := jobs.NewService(openTestDB(t), jobs.Config{LogsDir: t.TempDir()})
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { svc.Shutdown() })
+	handler := handlers.NewJobsHandler(svc).List
+
+	start := func(id string) {
+		_, err := svc.Start(id, "true", t.TempDir())
+		testutil.AssertNoError(t, err)
+		time.Sleep(5 * time.Millisecond) // Distinct creation times
+	}
+	for i := range 5 {
+		start(fmt.Sprintf("job-%d", i))
+	}
+
+	// Newest first; jobs started between pages come before the cursor
+	added := 0
+	list := pageThrough[jobs.Job](t, handler, "/api/jobs?limit=2", "jobs", func() {
+		added++
+		start(fmt.Sprintf("new-%d", added))
+	})
+	var ids []string
+	for _, job := range list {
+		ids = append(ids, job.ID)
+	}
+	testutil.AssertEqual(t, fmt.Sprint(ids), "[job-4 job-3 job-2 job-1 job-0]")
+
+	t.Run("sort by a column that may be unset", func(t *testing.T) {
+		list := pageThrough[jobs.Job](t, handler, "/api/jobs?limit=3&sort=finished_at", "jobs", nil)
+		testutil.AssertEqual(t, len(list), 5+added)
+	})
+}
+
+func TestDownloadsHandler_ListPaging(t *testing.T) {
+	db := openTestDB(t)
+	store := downloads.NewStore(db)
+	created := time.Now().Add(-time.Hour)
+	for i := range 5 {
+		testutil.AssertNoError(t, store.Insert(&downloads.Download{
+			ID:        fmt.Sprintf("d%d", i),
+			URL:       "https://example.com/file",
+			Filename:  fmt.Sprintf("File-%d.bin", 4-i),
+			Status:    downloads.StatusCompleted,
+			Total:     int64(i % 2),
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	svc, err := downloads.NewService(db, downloads.Config{})
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { svc.Shutdown(time.Second) })
+	handler := handlers.NewDownloadsHandler(svc).List
+
+	for path, want := range map[string]string{
+		"/api/downloads?limit=2":                      "[d4 d3 d2 d1 d0]",
+		"/api/downloads?limit=2&sort=filename":        "[d0 d1 d2 d3 d4]",
+		"/api/downloads?limit=2&sort=total&order=asc": "[d0 d2 d4 d1 d3]",
+		"/api/downloads?limit=3&sort=-progress":       "[d4 d3 d2 d1 d0]",
+	} {
+		var ids []string
+		for _, d := range pageThrough[downloads.Download](t, handler, path, "downloads", nil) {
+			ids = append(ids, d.ID)
+		}
+		testutil.AssertEqual(t, fmt.Sprint(ids), want)
+	}
+}
+
+func TestSystemHandler_ProcessPaging(t *testing.T) {
+	handler := handlers.NewSystemHandler(system.NewService(nil, nil), nil).GetProcesses
+
+	procs := pageThrough[system.Process](t, handler, "/api/system/processes?limit=5&sort=pid&order=asc", "processes", nil)
+	if len(procs) == 0 {
+		t.Fatal("no processes listed")
+	}
+	for i := 1; i < len(procs); i++ {
+		if procs[i].PID <= procs[i-1].PID {
+			t.Fatalf("pid %d listed after %d", procs[i].PID, procs[i-1].PID)
+		}
+	}
+}
+
+func TestPackagesHandler_ListPaging(t *testing.T) {
+	svc, err := packages.NewService()
+	if err != nil {
+		t.Skip("no package manager")
+	}
+	handler := handlers.NewPackagesHandler(svc).ListInstalled
+
+	all := getPage(t, handler, "/api/packages?limit=1000", "packages")
+	pkgs := pageThrough[packages.Package](t, handler, "/api/packages?limit=7", "packages", nil)
+	testutil.AssertEqual(t, len(pkgs), all.Meta.Total)
+
+	seen := map[string]bool{}
+	for i, pkg := range pkgs {
+		id := pkg.Name + ":" + pkg.Architecture
+		if seen[id] {
+			t.Fatalf("%s listed twice", id)
+		}
+		seen[id] = true
+		if i > 0 && pkg.Name < pkgs[i-1].Name {
+			t.Fatalf("%s listed after %s", pkg.Name, pkgs[i-1].Name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package handlers_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/api/handlers"
+	"github.com/ss497254/gloski/internal/config"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func TestMediaHandler(t *testing.T) {
+	cfg := testutil.TestConfig(t)
+	tmpDir := testutil.TestTempDir(t)
+	cfg.AllowedPaths = []string{tmpDir}
+	cfg.Media = config.DefaultConfig().Media
+	media := files.NewMedia(files.NewService(cfg), t.TempDir(), cfg.Media)
+	handler := handlers.NewMediaHandler(media)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/files/thumbnail", handler.Thumbnail)
+	mux.HandleFunc("/api/files/media", handler.Info)
+
+	var buf bytes.Buffer
+	testutil.AssertNoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 300, 150))))
+	imagePath := filepath.Join(tmpDir, "image.png")
+	testutil.AssertNoError(t, os.WriteFile(imagePath, buf.Bytes(), 0644))
+
+	t.Run("thumbnail", func(t *testing.T) {
+		path := "/api/files/thumbnail?size=64&path=" + imagePath
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{Method: http.MethodGet, Path: path})
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertEqual(t, w.Header().Get("Content-Type"), "image/jpeg")
+		img, _, err := image.Decode(w.Body)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, img.Bounds().Dx(), 64)
+		testutil.AssertEqual(t, img.Bounds().Dy(), 32)
+
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("ETag header not set")
+		}
+		w = testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    path,
+			Headers: map[string]string{"If-None-Match": etag},
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusNotModified)
+	})
+
+	t.Run("thumbnail of a text file", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/thumbnail?path=" + filepath.Join(tmpDir, "test.txt"),
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusUnsupportedMediaType)
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/media?path=" + imagePath,
+		})
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		var info files.MediaInfo
+		testutil.DecodeJSON(t, w.Body, &info)
+		testutil.AssertEqual(t, info.Kind, files.MediaImage)
+		testutil.AssertEqual(t, info.Image.Width, 300)
+		testutil.AssertEqual(t, info.Image.Height, 150)
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		w := testutil.MakeRequest(t, mux, testutil.HTTPRequest{Method: http.MethodGet, Path: "/api/files/media"})
+		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,72 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ss497254/gloski/internal/api/handlers"
+	"github.com/ss497254/gloski/internal/auth"
+	"github.com/ss497254/gloski/internal/files"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+type watchMessage struct {
+	Type  string `json:"type"`
+	Path  string `json:"path"`
+	Error *struct {
+		Code string `json:"code"`
+	} `json:"error"`
+	Events []files.WatchEvent `json:"events"`
+}
+
+func TestWatchHandler(t *testing.T) {
+	cfg := testutil.TestConfig(t)
+	tmpDir := testutil.TestTempDir(t)
+	cfg.AllowedPaths = []string{tmpDir}
+	authService, err := auth.NewService(cfg)
+	testutil.AssertNoError(t, err)
+
+	handler := handlers.NewWatchHandler(files.NewService(cfg), authService)
+	server := httptest.NewServer(http.HandlerFunc(handler.Handle))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	t.Run("requires authentication", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err == nil {
+			t.Fatal("expected dial to fail")
+		}
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusUnauthorized)
+	})
+
+	t.Run("streams changes", func(t *testing.T) {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?api_key=test-api-key&path="+tmpDir, nil)
+		testutil.AssertNoError(t, err)
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		var msg watchMessage
+		testutil.AssertNoError(t, conn.ReadJSON(&msg))
+		testutil.AssertEqual(t, msg.Type, "watch")
+		testutil.AssertEqual(t, msg.Path, files.ToTildePath(tmpDir))
+
+		testutil.AssertNoError(t, conn.WriteJSON(map[string]interface{}{"action": "watch", "paths": []string{"/etc"}}))
+		testutil.AssertNoError(t, conn.ReadJSON(&msg))
+		testutil.AssertEqual(t, msg.Type, "error")
+		testutil.AssertEqual(t, msg.Error.Code, "path_not_allowed")
+
+		testutil.AssertNoError(t, os.WriteFile(filepath.Join(tmpDir, "watched.txt"), []byte("x"), 0644))
+		msg = watchMessage{}
+		testutil.AssertNoError(t, conn.ReadJSON(&msg))
+		testutil.AssertEqual(t, msg.Type, "events")
+		testutil.AssertEqual(t, len(msg.Events), 1)
+		testutil.AssertEqual(t, msg.Events[0].Op, files.WatchCreate)
+		testutil.AssertEqual(t, msg.Events[0].Path, files.ToTildePath(filepath.Join(tmpDir, "watched.txt")))
+	})
+}
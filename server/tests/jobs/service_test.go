@@ -0,0 +1,56 @@
+package jobs_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/jobs"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func newService(t *testing.T) *jobs.Service {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	svc, err := jobs.NewService(db, jobs.Config{LogsDir: t.TempDir()})
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { svc.Shutdown() })
+	return svc
+}
+
+// waitForJob polls until a job is no longer running
+func waitForJob(t *testing.T, svc *jobs.Service, id string) *jobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := svc.Get(id)
+		testutil.AssertNoError(t, err)
+		if job.Status != jobs.StatusRunning {
+			return job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for job")
+	return nil
+}
+
+func TestService_Stop(t *testing.T) {
+	svc := newService(t)
+
+	if err := svc.Stop("missing"); !errors.Is(err, jobs.ErrJobNotFound) {
+		t.Errorf("unknown job: err = %v, want ErrJobNotFound", err)
+	}
+
+	_, err := svc.Start("done", "true", t.TempDir())
+	testutil.AssertNoError(t, err)
+	waitForJob(t, svc, "done")
+	if err := svc.Stop("done"); !errors.Is(err, jobs.ErrJobNotRunning) {
+		t.Errorf("finished job: err = %v, want ErrJobNotRunning", err)
+	}
+}
@@ -0,0 +1,192 @@
+package listing_test
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+var spec = listing.Spec{
+	SortFields:   []string{"name", "size"},
+	DefaultSort:  "name",
+	DefaultOrder: listing.OrderAsc,
+	DefaultLimit: 2,
+}
+
+func TestParseParams(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		p, err := listing.ParseParams(url.Values{}, spec)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, p.Limit, 2)
+		testutil.AssertEqual(t, p.Sort, "name")
+		testutil.AssertEqual(t, p.Order, listing.OrderAsc)
+	})
+
+	t.Run("descending shorthand", func(t *testing.T) {
+		p, err := listing.ParseParams(url.Values{"sort": {"-size"}}, spec)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, p.Sort, "size")
+		testutil.AssertEqual(t, p.Order, listing.OrderDesc)
+	})
+
+	t.Run("limit is capped", func(t *testing.T) {
+		p, err := listing.ParseParams(url.Values{"limit": {"999999"}}, spec)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, p.Limit, listing.MaxLimit)
+	})
+
+	t.Run("unknown sort field", func(t *testing.T) {
+		_, err := listing.ParseParams(url.Values{"sort": {"owner"}}, spec)
+		if !errors.Is(err, listing.ErrInvalidParam) {
+			t.Errorf("err = %v, want ErrInvalidParam", err)
+		}
+	})
+
+	t.Run("garbage cursor", func(t *testing.T) {
+		_, err := listing.ParseParams(url.Values{"cursor": {"not-a-cursor"}}, spec)
+		if !errors.Is(err, listing.ErrInvalidCursor) {
+			t.Errorf("err = %v, want ErrInvalidCursor", err)
+		}
+	})
+}
+
+type item struct {
+	ID   string
+	Size int
+}
+
+var fields = map[string]listing.Key[item]{
+	"name": func(it item) any { return listing.Fold(it.ID) },
+	"size": func(it item) any { return it.Size },
+}
+
+func itemID(it item) string { return it.ID }
+
+// pageAll follows cursors to the end, calling between before each next page
+func pageAll(t *testing.T, q url.Values, items func() []item, between func()) []string {
+	t.Helper()
+	var seen []string
+	for range 20 {
+		p, err := listing.ParseParams(q, spec)
+		testutil.AssertNoError(t, err)
+
+		page, meta := listing.Page(items(), p, fields, itemID)
+		for _, it := range page {
+			seen = append(seen, it.ID)
+		}
+		testutil.AssertEqual(t, meta.Count, len(page))
+		if meta.NextCursor == "" {
+			return seen
+		}
+		q = url.Values{"cursor": {meta.NextCursor}}
+		if between != nil {
+			between()
+		}
+	}
+	t.Fatal("paging did not end")
+	return nil
+}
+
+func TestCursorPagination(t *testing.T) {
+	items := []item{{"e", 1}, {"C", 3}, {"a", 2}, {"d", 3}, {"b", 1}}
+	list := func() []item { return append([]item(nil), items...) }
+
+	seen := pageAll(t, url.Values{"order": {"desc"}}, list, nil)
+	testutil.AssertEqual(t, strings.Join(seen, ","), "e,d,C,b,a")
+
+	t.Run("equal keys", func(t *testing.T) {
+		seen := pageAll(t, url.Values{"sort": {"-size"}}, list, nil)
+		testutil.AssertEqual(t, strings.Join(seen, ","), "d,C,a,e,b")
+	})
+
+	t.Run("items change between pages", func(t *testing.T) {
+		// Removing items already seen and adding ones before the cursor
+		// neither skips nor repeats the rest
+		current := []item{{"a", 0}, {"b", 0}, {"c", 0}, {"d", 0}, {"e", 0}, {"f", 0}}
+		seen := pageAll(t, url.Values{}, func() []item { return append([]item(nil), current...) }, func() {
+			current = append(current[1:], item{"0", 0})
+		})
+		testutil.AssertEqual(t, strings.Join(seen, ","), "a,b,c,d,e,f")
+	})
+
+	t.Run("unsorted order resumes after the last item", func(t *testing.T) {
+		unsorted := listing.Spec{SortFields: []string{"name"}, DefaultLimit: 2}
+		current := []item{{"x", 0}, {"y", 0}, {"z", 0}, {"w", 0}}
+		var seen []string
+		q := url.Values{}
+		for i := 0; i < 3; i++ {
+			p, err := listing.ParseParams(q, unsorted)
+			testutil.AssertNoError(t, err)
+			page, meta := listing.Page(append([]item(nil), current...), p, fields, itemID)
+			for _, it := range page {
+				seen = append(seen, it.ID)
+			}
+			if meta.NextCursor == "" {
+				break
+			}
+			q = url.Values{"cursor": {meta.NextCursor}}
+			current = append([]item{{"v", 0}}, current...)
+		}
+		testutil.AssertEqual(t, strings.Join(seen, ","), "x,y,z,w")
+	})
+
+	t.Run("cursor keeps the page size", func(t *testing.T) {
+		p, _ := listing.ParseParams(url.Values{"limit": {"3"}}, spec)
+		_, meta := listing.Page(list(), p, fields, itemID)
+		p, err := listing.ParseParams(url.Values{"cursor": {meta.NextCursor}}, spec)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, p.Limit, 3)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		all := spec
+		all.DefaultLimit = listing.NoLimit
+		p, err := listing.ParseParams(url.Values{}, all)
+		testutil.AssertNoError(t, err)
+		page, meta := listing.Page(list(), p, fields, itemID)
+		testutil.AssertEqual(t, len(page), len(items))
+		testutil.AssertEqual(t, meta.NextCursor, "")
+	})
+
+	t.Run("cursor rejects a different sort", func(t *testing.T) {
+		p, _ := listing.ParseParams(url.Values{}, spec)
+		_, meta := listing.Page(list(), p, fields, itemID)
+		_, err := listing.ParseParams(url.Values{"cursor": {meta.NextCursor}, "sort": {"size"}}, spec)
+		if !errors.Is(err, listing.ErrInvalidCursor) {
+			t.Errorf("err = %v, want ErrInvalidCursor", err)
+		}
+	})
+}
+
+func TestFilter(t *testing.T) {
+	f, err := listing.ParseFilter(url.Values{
+		"status":        {"running,failed"},
+		"name":          {"*backup*"},
+		"min_size":      {"10"},
+		"created_after": {"2026-01-01"},
+	})
+	testutil.AssertNoError(t, err)
+
+	testutil.AssertEqual(t, f.MatchStatus("failed"), true)
+	testutil.AssertEqual(t, f.MatchStatus("finished"), false)
+	testutil.AssertEqual(t, f.MatchName("/usr/local/bin/backup.sh"), true)
+	testutil.AssertEqual(t, f.MatchName("restore.sh"), false)
+	testutil.AssertEqual(t, f.MatchSize(9), false)
+	testutil.AssertEqual(t, f.MatchSize(10), true)
+
+	t.Run("invalid values", func(t *testing.T) {
+		for _, q := range []url.Values{
+			{"name": {"[abc"}},
+			{"min_size": {"-1"}},
+			{"created_after": {"yesterday"}},
+		} {
+			if _, err := listing.ParseFilter(q); !errors.Is(err, listing.ErrInvalidParam) {
+				t.Errorf("ParseFilter(%v) err = %v, want ErrInvalidParam", q, err)
+			}
+		}
+	})
+}
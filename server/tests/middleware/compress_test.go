@@ -0,0 +1,165 @@
+package middleware_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ss497254/gloski/internal/api/middleware"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func TestCompressMiddleware(t *testing.T) {
+	largeJSON := `{"data":"` + strings.Repeat("a", 4096) + `"}`
+
+	jsonHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(largeJSON))
+	})
+
+	compress := middleware.Compress(middleware.CompressionConfig{Level: 5, MinSize: 1024})
+
+	t.Run("gzip when accepted", func(t *testing.T) {
+		w := testutil.MakeRequest(t, compress(jsonHandler), testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/files",
+			Headers: map[string]string{"Accept-Encoding": "gzip"},
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertEqual(t, w.Header().Get("Content-Encoding"), "gzip")
+
+		zr, err := gzip.NewReader(w.Body)
+		testutil.AssertNoError(t, err)
+		body, err := io.ReadAll(zr)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, string(body), largeJSON)
+	})
+
+	t.Run("zstd preferred over gzip", func(t *testing.T) {
+		w := testutil.MakeRequest(t, compress(jsonHandler), testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/files",
+			Headers: map[string]string{"Accept-Encoding": "gzip, deflate, zstd"},
+		})
+
+		testutil.AssertEqual(t, w.Header().Get("Content-Encoding"), "zstd")
+
+		zr, err := zstd.NewReader(w.Body)
+		testutil.AssertNoError(t, err)
+		defer zr.Close()
+		body, err := io.ReadAll(zr)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqual(t, string(body), largeJSON)
+	})
+
+	t.Run("small responses are not compressed", func(t *testing.T) {
+		small := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status":"ok"}`))
+		})
+
+		w := testutil.MakeRequest(t, compress(small), testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/health",
+			Headers: map[string]string{"Accept-Encoding": "gzip"},
+		})
+
+		testutil.AssertEqual(t, w.Header().Get("Content-Encoding"), "")
+		testutil.AssertEqual(t, w.Body.String(), `{"status":"ok"}`)
+	})
+
+	t.Run("already compressed content is skipped", func(t *testing.T) {
+		binary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/zip")
+			w.Write([]byte(strings.Repeat("z", 4096)))
+		})
+
+		w := testutil.MakeRequest(t, compress(binary), testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/files/download",
+			Headers: map[string]string{"Accept-Encoding": "gzip"},
+		})
+
+		testutil.AssertEqual(t, w.Header().Get("Content-Encoding"), "")
+		testutil.AssertEqual(t, w.Body.Len(), 4096)
+	})
+
+	t.Run("range requests are skipped", func(t *testing.T) {
+		w := testutil.MakeRequest(t, compress(jsonHandler), testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files/download",
+			Headers: map[string]string{
+				"Accept-Encoding": "gzip",
+				"Range":           "bytes=0-10",
+			},
+		})
+
+		testutil.AssertEqual(t, w.Header().Get("Content-Encoding"), "")
+	})
+
+	t.Run("no encoding without Accept-Encoding", func(t *testing.T) {
+		w := testutil.MakeRequest(t, compress(jsonHandler), testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/files",
+		})
+
+		testutil.AssertEqual(t, w.Header().Get("Content-Encoding"), "")
+		testutil.AssertEqual(t, w.Body.String(), largeJSON)
+	})
+
+	t.Run("gzip explicitly refused", func(t *testing.T) {
+		w := testutil.MakeRequest(t, compress(jsonHandler), testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/files",
+			Headers: map[string]string{"Accept-Encoding": "gzip;q=0"},
+		})
+
+		testutil.AssertEqual(t, w.Header().Get("Content-Encoding"), "")
+	})
+}
+
+// hijackRecorder is a ResponseRecorder that supports hijacking
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestCompressMiddlewarePreservesHijack(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("response writer does not implement http.Hijacker")
+		}
+		hijacker.Hijack()
+	})
+
+	compress := middleware.Compress(middleware.DefaultCompressionConfig())
+
+	for _, upgrade := range []string{"websocket", ""} {
+		rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		r := httptest.NewRequest(http.MethodGet, "/api/terminal", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		if upgrade != "" {
+			r.Header.Set("Connection", "Upgrade")
+			r.Header.Set("Upgrade", upgrade)
+		}
+
+		compress(handler).ServeHTTP(rec, r)
+
+		if !rec.hijacked {
+			t.Errorf("connection was not hijacked (upgrade=%q)", upgrade)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/api/middleware"
+	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/idempotency"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func newIdempotencyMiddleware(t *testing.T) middleware.Middleware {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return middleware.Idempotency(middleware.IdempotencyConfig{
+		Store: idempotency.NewStore(db.DB()),
+		TTL:   time.Hour,
+	})
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	handler := newIdempotencyMiddleware(t)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == `{"fail":true}` {
+			response.InternalError(w, "boom", "")
+			return
+		}
+		response.Success(w, map[string]interface{}{"call": n, "body": string(body)})
+	}))
+
+	post := func(key string, body interface{}) *http.Response {
+		headers := map[string]string{}
+		if key != "" {
+			headers[middleware.IdempotencyKeyHeader] = key
+		}
+		return testutil.MakeRequest(t, handler, testutil.HTTPRequest{
+			Method:  http.MethodPost,
+			Path:    "/api/jobs",
+			Body:    body,
+			Headers: headers,
+		}).Result()
+	}
+
+	decode := func(resp *http.Response) map[string]interface{} {
+		var data map[string]interface{}
+		testutil.AssertNoError(t, json.NewDecoder(resp.Body).Decode(&data))
+		return data
+	}
+
+	t.Run("repeat replays first response", func(t *testing.T) {
+		calls.Store(0)
+		first := post("key-1", map[string]string{"command": "ls"})
+		testutil.AssertStatus(t, first.StatusCode, http.StatusOK)
+		testutil.AssertEqual(t, first.Header.Get(middleware.IdempotentReplayedHeader), "")
+
+		second := post("key-1", map[string]string{"command": "ls"})
+		testutil.AssertStatus(t, second.StatusCode, http.StatusOK)
+		testutil.AssertEqual(t, second.Header.Get(middleware.IdempotentReplayedHeader), "true")
+		testutil.AssertEqual(t, second.Header.Get("Content-Type"), "application/json")
+		testutil.AssertEqual(t, decode(second)["call"], float64(1))
+		testutil.AssertEqual(t, calls.Load(), int32(1))
+	})
+
+	t.Run("reused key with different body is rejected", func(t *testing.T) {
+		calls.Store(0)
+		post("key-2", map[string]string{"command": "ls"})
+
+		resp := post("key-2", map[string]string{"command": "rm -rf /tmp/x"})
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusUnprocessableEntity)
+		testutil.AssertEqual(t, decode(resp)["code"], "idempotency_key_reused")
+		testutil.AssertEqual(t, calls.Load(), int32(1))
+	})
+
+	t.Run("requests without key always run", func(t *testing.T) {
+		calls.Store(0)
+		post("", map[string]string{"command": "ls"})
+		post("", map[string]string{"command": "ls"})
+		testutil.AssertEqual(t, calls.Load(), int32(2))
+	})
+
+	t.Run("server errors are not stored", func(t *testing.T) {
+		calls.Store(0)
+		resp := post("key-3", map[string]bool{"fail": true})
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusInternalServerError)
+
+		resp = post("key-3", map[string]bool{"fail": true})
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusInternalServerError)
+		testutil.AssertEqual(t, resp.Header.Get(middleware.IdempotentReplayedHeader), "")
+		testutil.AssertEqual(t, calls.Load(), int32(2))
+	})
+
+	t.Run("key too long", func(t *testing.T) {
+		long := make([]byte, 256)
+		for i := range long {
+			long[i] = 'k'
+		}
+		resp := post(string(long), map[string]string{"command": "ls"})
+		testutil.AssertStatus(t, resp.StatusCode, http.StatusBadRequest)
+	})
+}
+
+func TestIdempotencyMiddleware_InProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := newIdempotencyMiddleware(t)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		response.Success(w, map[string]string{"status": "ok"})
+	}))
+
+	req := testutil.HTTPRequest{
+		Method:  http.MethodPost,
+		Path:    "/api/downloads",
+		Body:    map[string]string{"url": "https://example.com/file"},
+		Headers: map[string]string{middleware.IdempotencyKeyHeader: "slow"},
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- testutil.MakeRequest(t, handler, req).Code
+	}()
+	<-started
+
+	w := testutil.MakeRequest(t, handler, req)
+	testutil.AssertStatus(t, w.Code, http.StatusConflict)
+
+	close(release)
+	testutil.AssertStatus(t, <-done, http.StatusOK)
+
+	w = testutil.MakeRequest(t, handler, req)
+	testutil.AssertStatus(t, w.Code, http.StatusOK)
+	testutil.AssertEqual(t, w.Header().Get(middleware.IdempotentReplayedHeader), "true")
+}
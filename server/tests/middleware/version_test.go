@@ -0,0 +1,92 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/api/middleware"
+	"github.com/ss497254/gloski/internal/api/response"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func TestAPIVersionMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/things", func(w http.ResponseWriter, r *http.Request) {
+		response.Success(w, map[string]string{"version": middleware.APIVersionFromContext(r.Context())})
+	})
+
+	sunset := time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+	handler := middleware.APIVersion(middleware.VersionConfig{
+		Deprecation: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+		Sunset:      sunset,
+	})(mux)
+
+	t.Run("versioned path uses envelope", func(t *testing.T) {
+		w := testutil.MakeRequest(t, handler, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/v1/things",
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertEqual(t, w.Header().Get(middleware.APIVersionHeader), "1")
+		testutil.AssertEqual(t, w.Header().Get("Deprecation"), "")
+
+		var body struct {
+			Success bool              `json:"success"`
+			Data    map[string]string `json:"data"`
+		}
+		testutil.DecodeJSON(t, w.Body, &body)
+		if !body.Success {
+			t.Error("success should be true")
+		}
+		testutil.AssertEqual(t, body.Data["version"], "1")
+	})
+
+	t.Run("legacy path keeps bare shape and is deprecated", func(t *testing.T) {
+		w := testutil.MakeRequest(t, handler, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/things",
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertContains(t, w.Header().Get("Deprecation"), "@")
+		testutil.AssertEqual(t, w.Header().Get("Sunset"), sunset.Format(http.TimeFormat))
+		testutil.AssertEqual(t, w.Header().Get("Link"), `</api/v1/things>; rel="successor-version"`)
+
+		var body map[string]string
+		testutil.DecodeJSON(t, w.Body, &body)
+		testutil.AssertEqual(t, body["version"], "")
+	})
+
+	t.Run("version negotiated by header", func(t *testing.T) {
+		w := testutil.MakeRequest(t, handler, testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/things",
+			Headers: map[string]string{middleware.APIVersionHeader: "1"},
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusOK)
+		testutil.AssertEqual(t, w.Header().Get("Deprecation"), "")
+		testutil.AssertEqual(t, w.Header().Get(middleware.APIVersionHeader), "1")
+	})
+
+	t.Run("version negotiated by Accept media type", func(t *testing.T) {
+		w := testutil.MakeRequest(t, handler, testutil.HTTPRequest{
+			Method:  http.MethodGet,
+			Path:    "/api/things",
+			Headers: map[string]string{"Accept": "application/vnd.gloski.v1+json"},
+		})
+
+		testutil.AssertEqual(t, w.Header().Get(middleware.APIVersionHeader), "1")
+	})
+
+	t.Run("unsupported version is rejected", func(t *testing.T) {
+		w := testutil.MakeRequest(t, handler, testutil.HTTPRequest{
+			Method: http.MethodGet,
+			Path:   "/api/v9/things",
+		})
+
+		testutil.AssertStatus(t, w.Code, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,182 @@
+package webhooks_test
+
+import (
+	"crypto/hmac"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ss497254/gloski/internal/database"
+	"github.com/ss497254/gloski/internal/events"
+	"github.com/ss497254/gloski/internal/listing"
+	"github.com/ss497254/gloski/internal/webhooks"
+	"github.com/ss497254/gloski/tests/testutil"
+)
+
+func newService(t *testing.T) (*webhooks.Service, *events.Bus) {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	testutil.AssertNoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	bus := events.NewBus(16)
+	svc, err := webhooks.NewService(db, webhooks.Config{MaxAttempts: 3, Timeout: time.Second, Events: bus})
+	testutil.AssertNoError(t, err)
+	svc.Start()
+	t.Cleanup(svc.Shutdown)
+
+	return svc, bus
+}
+
+// waitForDelivery polls until the webhook's latest delivery satisfies done
+func waitForDelivery(t *testing.T, svc *webhooks.Service, id string, done func(*webhooks.Delivery) bool) *webhooks.Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		list, _, err := svc.Deliveries(id, listing.Filter{}, listing.Params{Limit: 10, Sort: "created_at", Order: listing.OrderDesc})
+		testutil.AssertNoError(t, err)
+		if len(list) > 0 && done(list[0]) {
+			return list[0]
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for delivery")
+	return nil
+}
+
+func TestService_Deliver(t *testing.T) {
+	svc, bus := newService(t)
+
+	received := make(chan *http.Request, 4)
+	bodies := make(chan []byte, 4)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer target.Close()
+
+	hook, err := svc.Create(webhooks.CreateWebhookRequest{URL: target.URL, Events: []string{"jobs.failed"}})
+	testutil.AssertNoError(t, err)
+	if hook.Secret == "" {
+		t.Fatal("expected a generated secret")
+	}
+
+	bus.Publish(events.TopicJobFinished, "ignored")
+	bus.Publish(events.TopicJobFailed, map[string]string{"id": "job-1"})
+
+	var req *http.Request
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+	body := <-bodies
+
+	testutil.AssertEqual(t, req.Header.Get(webhooks.HeaderEvent), events.TopicJobFailed)
+	testutil.AssertContains(t, string(body), `"job-1"`)
+
+	ts, err := strconv.ParseInt(req.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+	testutil.AssertNoError(t, err)
+	want := webhooks.Sign(hook.Secret, ts, body)
+	if !hmac.Equal([]byte(req.Header.Get(webhooks.HeaderSignature)), []byte(want)) {
+		t.Errorf("signature = %q, want %q", req.Header.Get(webhooks.HeaderSignature), want)
+	}
+
+	d := waitForDelivery(t, svc, hook.ID, func(d *webhooks.Delivery) bool { return d.Status == webhooks.DeliverySucceeded })
+	testutil.AssertEqual(t, d.Attempts, 1)
+	testutil.AssertEqual(t, d.StatusCode, http.StatusOK)
+
+	// The filter excluded jobs.finished
+	_, meta, err := svc.Deliveries(hook.ID, listing.Filter{}, listing.Params{Limit: 10})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, meta.Total, 1)
+}
+
+func TestService_ProgressTopics(t *testing.T) {
+	svc, bus := newService(t)
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	all, err := svc.Create(webhooks.CreateWebhookRequest{URL: target.URL, Events: []string{"downloads"}})
+	testutil.AssertNoError(t, err)
+	wildcard, err := svc.Create(webhooks.CreateWebhookRequest{URL: target.URL, Events: []string{"*"}})
+	testutil.AssertNoError(t, err)
+	progress, err := svc.Create(webhooks.CreateWebhookRequest{URL: target.URL, Events: []string{events.TopicDownloadProgress}})
+	testutil.AssertNoError(t, err)
+
+	bus.Publish(events.TopicDownloadProgress, map[string]int{"percent": 10})
+	bus.Publish(events.TopicDownloadCompleted, map[string]string{"id": "dl-1"})
+
+	// Deliveries are recorded in event order, so once the completion is
+	// there the progress event has been handled too
+	for _, hook := range []*webhooks.Webhook{all, wildcard} {
+		d := waitForDelivery(t, svc, hook.ID, func(d *webhooks.Delivery) bool { return d.Topic == events.TopicDownloadCompleted })
+		_, meta, err := svc.Deliveries(hook.ID, listing.Filter{}, listing.Params{Limit: 10})
+		testutil.AssertNoError(t, err)
+		if meta.Total != 1 {
+			t.Errorf("%v: %d deliveries, want only %s", hook.Events, meta.Total, d.Topic)
+		}
+	}
+	waitForDelivery(t, svc, progress.ID, func(d *webhooks.Delivery) bool { return d.Topic == events.TopicDownloadProgress })
+}
+
+func TestService_RetryAndRedeliver(t *testing.T) {
+	svc, bus := newService(t)
+
+	var calls atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}))
+	defer target.Close()
+
+	hook, err := svc.Create(webhooks.CreateWebhookRequest{URL: target.URL, Events: []string{"downloads"}})
+	testutil.AssertNoError(t, err)
+
+	bus.Publish(events.TopicDownloadCompleted, map[string]string{"id": "dl-1"})
+
+	failed := waitForDelivery(t, svc, hook.ID, func(d *webhooks.Delivery) bool { return d.Attempts == 1 })
+	testutil.AssertEqual(t, failed.Status, webhooks.DeliveryPending)
+	testutil.AssertEqual(t, failed.StatusCode, http.StatusServiceUnavailable)
+	testutil.AssertContains(t, failed.Response, "busy")
+	if failed.NextAttemptAt == nil || time.Until(*failed.NextAttemptAt) < 5*time.Second {
+		t.Errorf("next attempt = %v, want backoff of several seconds", failed.NextAttemptAt)
+	}
+
+	redelivery, err := svc.Redeliver(hook.ID, failed.ID)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqual(t, redelivery.RedeliveryOf, failed.ID)
+	testutil.AssertEqual(t, string(redelivery.Payload), string(failed.Payload))
+
+	waitForDelivery(t, svc, hook.ID, func(d *webhooks.Delivery) bool {
+		return d.ID == redelivery.ID && d.Status == webhooks.DeliverySucceeded
+	})
+
+	if _, err := svc.Redeliver(hook.ID, "missing"); !errors.Is(err, webhooks.ErrDeliveryNotFound) {
+		t.Errorf("err = %v, want ErrDeliveryNotFound", err)
+	}
+}
+
+func TestService_Validation(t *testing.T) {
+	svc, _ := newService(t)
+
+	if _, err := svc.Create(webhooks.CreateWebhookRequest{URL: "ftp://example.com", Events: []string{"jobs"}}); !errors.Is(err, webhooks.ErrInvalidURL) {
+		t.Errorf("err = %v, want ErrInvalidURL", err)
+	}
+	if _, err := svc.Create(webhooks.CreateWebhookRequest{URL: "https://example.com/hook"}); !errors.Is(err, webhooks.ErrNoEvents) {
+		t.Errorf("err = %v, want ErrNoEvents", err)
+	}
+	if _, err := svc.Get("missing"); !errors.Is(err, webhooks.ErrWebhookNotFound) {
+		t.Errorf("err = %v, want ErrWebhookNotFound", err)
+	}
+}